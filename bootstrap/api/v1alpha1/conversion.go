@@ -22,6 +22,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/conversion"
 
 	apiconversion "k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/utils/ptr"
 
 	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
 	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
@@ -51,6 +52,9 @@ func (src *RKE2Config) ConvertTo(dstRaw conversion.Hub) error {
 		dst.Spec.AgentConfig.PodSecurityAdmissionConfigFile = restored.Spec.AgentConfig.PodSecurityAdmissionConfigFile
 	}
 
+	dst.Spec.TemplateFormat = restored.Spec.TemplateFormat
+	RestoreFileSources(dst.Spec.Files, restored.Spec.Files)
+
 	return nil
 }
 
@@ -110,6 +114,9 @@ func (src *RKE2ConfigTemplate) ConvertTo(dstRaw conversion.Hub) error {
 		dst.Spec.Template.Spec.AgentConfig.PodSecurityAdmissionConfigFile = restored.Spec.Template.Spec.AgentConfig.PodSecurityAdmissionConfigFile
 	}
 
+	dst.Spec.Template.Spec.TemplateFormat = restored.Spec.Template.Spec.TemplateFormat
+	RestoreFileSources(dst.Spec.Template.Spec.Files, restored.Spec.Template.Spec.Files)
+
 	return nil
 }
 
@@ -154,3 +161,63 @@ func Convert_v1beta1_RKE2AgentConfig_To_v1alpha1_RKE2AgentConfig(in *bootstrapv1
 	// We have to invoke conversion manually because of the added AirGappedChecksum field.
 	return autoConvert_v1beta1_RKE2AgentConfig_To_v1alpha1_RKE2AgentConfig(in, out, s)
 }
+
+func Convert_v1beta1_RKE2ConfigSpec_To_v1alpha1_RKE2ConfigSpec(in *bootstrapv1.RKE2ConfigSpec, out *RKE2ConfigSpec, s apiconversion.Scope) error {
+	// We have to invoke conversion manually because of the added TemplateFormat field.
+	return autoConvert_v1beta1_RKE2ConfigSpec_To_v1alpha1_RKE2ConfigSpec(in, out, s)
+}
+
+func Convert_v1alpha1_FileSource_To_v1beta1_FileSource(in *FileSource, out *bootstrapv1.FileSource, s apiconversion.Scope) error {
+	// We have to invoke conversion manually because the Secret field became optional.
+	if err := autoConvert_v1alpha1_FileSource_To_v1beta1_FileSource(in, out, s); err != nil {
+		return err
+	}
+
+	if in.Secret != (SecretFileSource{}) {
+		out.Secret = &bootstrapv1.SecretFileSource{}
+		if err := Convert_v1alpha1_SecretFileSource_To_v1beta1_SecretFileSource(&in.Secret, out.Secret, s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func Convert_v1beta1_FileSource_To_v1alpha1_FileSource(in *bootstrapv1.FileSource, out *FileSource, s apiconversion.Scope) error {
+	// We have to invoke conversion manually because of the added ConfigMap field, and because the Secret field
+	// became optional.
+	if err := autoConvert_v1beta1_FileSource_To_v1alpha1_FileSource(in, out, s); err != nil {
+		return err
+	}
+
+	if in.Secret != nil {
+		return Convert_v1beta1_SecretFileSource_To_v1alpha1_SecretFileSource(in.Secret, &out.Secret, s)
+	}
+
+	return nil
+}
+
+func Convert_v1beta1_SecretFileSource_To_v1alpha1_SecretFileSource(in *bootstrapv1.SecretFileSource, out *SecretFileSource, s apiconversion.Scope) error {
+	// We have to invoke conversion manually because of the added Namespace field.
+	return autoConvert_v1beta1_SecretFileSource_To_v1alpha1_SecretFileSource(in, out, s)
+}
+
+// RestoreFileSources restores the config map and namespace file sources added in v1beta1, which are lost on
+// down-conversion, on the files which were not changed since.
+func RestoreFileSources(dst, restored []bootstrapv1.File) {
+	for i := range dst {
+		if i >= len(restored) || dst[i].Path != restored[i].Path || dst[i].ContentFrom == nil || restored[i].ContentFrom == nil {
+			continue
+		}
+
+		// A file with no secret name nor key has no secret once converted back from v1alpha1.
+		dstSecret, restoredSecret := ptr.Deref(dst[i].ContentFrom.Secret, bootstrapv1.SecretFileSource{}),
+			ptr.Deref(restored[i].ContentFrom.Secret, bootstrapv1.SecretFileSource{})
+		if dstSecret.Name != restoredSecret.Name || dstSecret.Key != restoredSecret.Key {
+			continue
+		}
+
+		dst[i].ContentFrom.Secret = restored[i].ContentFrom.Secret
+		dst[i].ContentFrom.ConfigMap = restored[i].ContentFrom.ConfigMap
+	}
+}
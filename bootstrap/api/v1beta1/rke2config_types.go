@@ -23,6 +23,16 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
+const (
+	// RKE2ConfigAgentTokenFinalizer allows the controller to revoke the agent token of a worker machine before its
+	// RKE2Config is removed.
+	RKE2ConfigAgentTokenFinalizer = "rke2config.bootstrap.cluster.x-k8s.io/agent-token"
+
+	// AgentTokenIDAnnotation is set on the RKE2Config of a worker machine joining the cluster with its own agent token,
+	// to the ID of the bootstrap token created for it in the workload cluster.
+	AgentTokenIDAnnotation = "bootstrap.cluster.x-k8s.io/agent-token-id"
+)
+
 // Format specifies the output format of the bootstrap data
 // +kubebuilder:validation:Enum=cloud-config;ignition
 type Format string
@@ -35,6 +45,15 @@ const (
 	Ignition Format = "ignition"
 )
 
+// TemplateFormat specifies the format of the templates in the files and commands of a RKE2Config.
+// +kubebuilder:validation:Enum=go-template
+type TemplateFormat string
+
+const (
+	// GoTemplate makes the files and commands to be rendered as Go templates.
+	GoTemplate TemplateFormat = "go-template"
+)
+
 // RKE2ConfigSpec defines the desired state of RKE2Config.
 type RKE2ConfigSpec struct {
 	// Files specifies extra files to be passed to user_data upon creation.
@@ -49,6 +68,11 @@ type RKE2ConfigSpec struct {
 	//+optional
 	PostRKE2Commands []string `json:"postRKE2Commands,omitempty"`
 
+	// TemplateFormat enables the rendering of the content of the Files, and of the PreRKE2Commands and PostRKE2Commands,
+	// as templates of the Machine they bootstrap, e.g. "{{ .Machine.Name }}". When not set, they are copied verbatim.
+	//+optional
+	TemplateFormat TemplateFormat `json:"templateFormat,omitempty"`
+
 	// AgentConfig specifies configuration for the agent nodes.
 	//+optional
 	AgentConfig RKE2AgentConfig `json:"agentConfig,omitempty"`
@@ -319,7 +343,12 @@ type File struct {
 // sources of data for target systems should add them here.
 type FileSource struct {
 	// SecretFileSource represents a secret that should populate this file.
-	Secret SecretFileSource `json:"secret"`
+	//+optional
+	Secret *SecretFileSource `json:"secret,omitempty"`
+
+	// ConfigMap represents a config map that should populate this file.
+	//+optional
+	ConfigMap *ConfigMapFileSource `json:"configMap,omitempty"`
 }
 
 // SecretFileSource adapts a Secret into a FileSource.
@@ -332,6 +361,25 @@ type SecretFileSource struct {
 
 	// Key is the key in the secret's data map for this value.
 	Key string `json:"key"`
+
+	// Namespace of the secret, defaults to the RKE2BootstrapConfig's namespace. Other namespaces must be
+	// allowed with the --allowed-file-source-namespaces flag of the bootstrap provider.
+	//+optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ConfigMapFileSource adapts a ConfigMap into a FileSource.
+type ConfigMapFileSource struct {
+	// Name of the config map to use.
+	Name string `json:"name"`
+
+	// Key is the key in the config map's data or binaryData map for this value.
+	Key string `json:"key"`
+
+	// Namespace of the config map, defaults to the RKE2BootstrapConfig's namespace. Other namespaces must be
+	// allowed with the --allowed-file-source-namespaces flag of the bootstrap provider.
+	//+optional
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // Registry is registry settings including mirrors, TLS, and credentials.
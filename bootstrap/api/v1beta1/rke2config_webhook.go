@@ -115,6 +115,29 @@ func (s *RKE2ConfigSpec) validate(pathPrefix *field.Path) field.ErrorList {
 
 	allErrs = append(allErrs, s.validateIgnition(pathPrefix)...)
 	allErrs = append(allErrs, s.validateRegistries(pathPrefix)...)
+	allErrs = append(allErrs, s.validateFileSources(pathPrefix)...)
+
+	return allErrs
+}
+
+func (s *RKE2ConfigSpec) validateFileSources(pathPrefix *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, file := range s.Files {
+		if file.ContentFrom == nil {
+			continue
+		}
+
+		if (file.ContentFrom.Secret != nil) == (file.ContentFrom.ConfigMap != nil) {
+			allErrs = append(
+				allErrs,
+				field.Invalid(
+					pathPrefix.Child("files").Index(i).Child("contentFrom"),
+					file.ContentFrom,
+					"exactly one of secret or configMap must be set"),
+			)
+		}
+	}
 
 	return allErrs
 }
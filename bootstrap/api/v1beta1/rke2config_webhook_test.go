@@ -66,6 +66,36 @@ func TestRKE2Config_ValidateCreate(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		{
+			name: "file content from config map",
+			spec: &RKE2ConfigSpec{
+				Files: []File{{
+					Path:        "/etc/sysctl.d/90-rke2.conf",
+					ContentFrom: &FileSource{ConfigMap: &ConfigMapFileSource{Name: "sysctl", Key: "90-rke2.conf"}},
+				}},
+			},
+			expectErr: false,
+		},
+		{
+			name: "file content from secret and config map",
+			spec: &RKE2ConfigSpec{
+				Files: []File{{
+					Path: "/etc/sysctl.d/90-rke2.conf",
+					ContentFrom: &FileSource{
+						Secret:    &SecretFileSource{Name: "sysctl", Key: "90-rke2.conf"},
+						ConfigMap: &ConfigMapFileSource{Name: "sysctl", Key: "90-rke2.conf"},
+					},
+				}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "file content from no source",
+			spec: &RKE2ConfigSpec{
+				Files: []File{{Path: "/etc/sysctl.d/90-rke2.conf", ContentFrom: &FileSource{}}},
+			},
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,282 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+	bootstraputil "k8s.io/cluster-bootstrap/token/util"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"sigs.k8s.io/cluster-api/controllers/clustercache"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/patch"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+)
+
+// agentTokenExtraGroups are the groups of the agent tokens, which are the ones of the tokens created by
+// `rke2 token create`.
+const agentTokenExtraGroups = "system:bootstrappers:k3s:default-node-token"
+
+// agentTokensEnabled returns true when the worker machines join the cluster with their own agent token.
+func (r *RKE2ConfigReconciler) agentTokensEnabled() bool {
+	return r.AgentTokenTTL > 0
+}
+
+// agentTokenRequeueAfter returns when the agent token of a worker Machine not yet joined has to be refreshed, or zero
+// when the Machine has no agent token to refresh.
+func (r *RKE2ConfigReconciler) agentTokenRequeueAfter(scope *Scope) time.Duration {
+	if _, found := scope.Config.Annotations[bootstrapv1.AgentTokenIDAnnotation]; !found ||
+		!r.agentTokensEnabled() || scope.Machine.Status.NodeRef != nil {
+		return 0
+	}
+
+	// The token is checked three times within its TTL, so that it can be refreshed in time despite a failure.
+	return r.AgentTokenTTL / 3 //nolint:mnd
+}
+
+// getAgentToken returns the agent token of a worker Machine. The expiration of the existing bootstrap token of the
+// Machine is extended, and a new one is created in the workload cluster when there is none, or it expired.
+func (r *RKE2ConfigReconciler) getAgentToken(ctx context.Context, scope *Scope) (string, error) {
+	remoteClient, err := r.ClusterCache.GetClient(ctx, util.ObjectKey(scope.Cluster))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get workload cluster client")
+	}
+
+	if tokenID, found := scope.Config.Annotations[bootstrapv1.AgentTokenIDAnnotation]; found {
+		secret, err := r.refreshAgentToken(ctx, remoteClient, scope.Logger, tokenID)
+		if err != nil {
+			return "", err
+		}
+
+		if secret != nil {
+			return bootstraputil.TokenFromIDAndSecret(tokenID, string(secret.Data[bootstrapapi.BootstrapTokenSecretKey])), nil
+		}
+	}
+
+	token, err := bootstraputil.GenerateBootstrapToken()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate agent token")
+	}
+
+	substrs := bootstraputil.BootstrapTokenRegexp.FindStringSubmatch(token)
+	if len(substrs) != 3 { //nolint:mnd
+		return "", errors.Errorf("the agent token is not of the form %q", bootstrapapi.BootstrapTokenPattern)
+	}
+
+	tokenID, tokenSecret := substrs[1], substrs[2]
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      bootstraputil.BootstrapTokenSecretName(tokenID),
+			Namespace: metav1.NamespaceSystem,
+		},
+		Type: bootstrapapi.SecretTypeBootstrapToken,
+		Data: map[string][]byte{
+			bootstrapapi.BootstrapTokenIDKey:               []byte(tokenID),
+			bootstrapapi.BootstrapTokenSecretKey:           []byte(tokenSecret),
+			bootstrapapi.BootstrapTokenExpirationKey:       []byte(time.Now().UTC().Add(r.AgentTokenTTL).Format(time.RFC3339)),
+			bootstrapapi.BootstrapTokenUsageSigningKey:     []byte("true"),
+			bootstrapapi.BootstrapTokenUsageAuthentication: []byte("true"),
+			bootstrapapi.BootstrapTokenExtraGroupsKey:      []byte(agentTokenExtraGroups),
+			bootstrapapi.BootstrapTokenDescriptionKey: []byte(fmt.Sprintf(
+				"agent token generated by cluster-api-provider-rke2 for machine %s/%s", scope.Machine.Namespace, scope.Machine.Name)),
+		},
+	}
+
+	// The finalizer is added first, so that the token is revoked even if the bootstrap data fails to be generated.
+	controllerutil.AddFinalizer(scope.Config, bootstrapv1.RKE2ConfigAgentTokenFinalizer)
+
+	if err := remoteClient.Create(ctx, secret); err != nil {
+		return "", errors.Wrap(err, "failed to create agent token")
+	}
+
+	if scope.Config.Annotations == nil {
+		scope.Config.Annotations = map[string]string{}
+	}
+
+	scope.Config.Annotations[bootstrapv1.AgentTokenIDAnnotation] = tokenID
+
+	scope.Logger.Info("Created agent token", "token-id", tokenID)
+
+	return token, nil
+}
+
+// refreshAgentToken extends the expiration of the bootstrap token of the given ID, unless it expires in more than
+// two thirds of its TTL. It returns nil when the token no longer exists, as it expired or was revoked.
+func (r *RKE2ConfigReconciler) refreshAgentToken(
+	ctx context.Context,
+	remoteClient client.Client,
+	logger logr.Logger,
+	tokenID string,
+) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: metav1.NamespaceSystem, Name: bootstraputil.BootstrapTokenSecretName(tokenID)}
+
+	if err := remoteClient.Get(ctx, key, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(err, "failed to get agent token %s", tokenID)
+	}
+
+	now := time.Now().UTC()
+
+	expiration, err := time.Parse(time.RFC3339, string(secret.Data[bootstrapapi.BootstrapTokenExpirationKey]))
+	if err == nil && expiration.Before(now) {
+		// An expired token can't be used, even if it was not removed yet.
+		return nil, nil
+	}
+
+	if err == nil && expiration.After(now.Add(r.AgentTokenTTL*2/3)) { //nolint:mnd
+		return secret, nil
+	}
+
+	newExpiration := now.Add(r.AgentTokenTTL).Format(time.RFC3339)
+	secret.Data[bootstrapapi.BootstrapTokenExpirationKey] = []byte(newExpiration)
+
+	if err := remoteClient.Update(ctx, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to refresh agent token %s", tokenID)
+	}
+
+	logger.V(3).Info("Refreshed agent token until the Machine joins the cluster", "token-id", tokenID, "expiration", newExpiration)
+
+	return secret, nil
+}
+
+// agentTokenLost refreshes the agent token of a worker Machine not yet joined, and returns true when the token
+// expired or was revoked while the Machine is not yet provisioned, in which case its bootstrap data has to be
+// regenerated with a new token.
+func (r *RKE2ConfigReconciler) agentTokenLost(ctx context.Context, scope *Scope) (bool, error) {
+	if r.agentTokenRequeueAfter(scope) == 0 {
+		return false, nil
+	}
+
+	remoteClient, err := r.ClusterCache.GetClient(ctx, util.ObjectKey(scope.Cluster))
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get workload cluster client")
+	}
+
+	tokenID := scope.Config.Annotations[bootstrapv1.AgentTokenIDAnnotation]
+
+	secret, err := r.refreshAgentToken(ctx, remoteClient, scope.Logger, tokenID)
+	if err != nil || secret != nil {
+		return false, err
+	}
+
+	dataSecret, err := r.getPendingBootstrapData(ctx, scope)
+	if err != nil {
+		return false, err
+	}
+
+	if dataSecret == nil {
+		scope.Logger.Info("Agent token of a Machine not yet joined no longer exists", "token-id", tokenID)
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// reconcileDelete revokes the agent token of a worker Machine before its RKE2Config is removed.
+func (r *RKE2ConfigReconciler) reconcileDelete(ctx context.Context, config *bootstrapv1.RKE2Config) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(config, bootstrapv1.RKE2ConfigAgentTokenFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.revokeAgentToken(ctx, config); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	patchHelper, err := patch.NewHelper(config, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	controllerutil.RemoveFinalizer(config, bootstrapv1.RKE2ConfigAgentTokenFinalizer)
+
+	return ctrl.Result{}, patchHelper.Patch(ctx, config)
+}
+
+// revokeAgentToken deletes the bootstrap token of the agent token of a worker Machine from the workload cluster.
+func (r *RKE2ConfigReconciler) revokeAgentToken(ctx context.Context, config *bootstrapv1.RKE2Config) error {
+	logger := ctrl.LoggerFrom(ctx)
+
+	tokenID, found := config.Annotations[bootstrapv1.AgentTokenIDAnnotation]
+	if !found {
+		return nil
+	}
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.Client, config.ObjectMeta)
+	if err != nil {
+		if errors.Is(err, util.ErrNoCluster) || apierrors.IsNotFound(errors.Cause(err)) {
+			return nil
+		}
+
+		return err
+	}
+
+	// The token is removed along with the workload cluster.
+	if !cluster.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	if r.ClusterCache == nil {
+		logger.Info("Agent tokens are disabled, the agent token expires without being revoked", "token-id", tokenID)
+
+		return nil
+	}
+
+	remoteClient, err := r.ClusterCache.GetClient(ctx, util.ObjectKey(cluster))
+	if err != nil {
+		if errors.Is(err, clustercache.ErrClusterNotConnected) {
+			// The deletion of the Machine is not blocked, as the token is no longer refreshed and expires.
+			logger.Info("Workload cluster not reachable, the agent token expires without being revoked", "token-id", tokenID)
+
+			return nil
+		}
+
+		return errors.Wrap(err, "failed to get workload cluster client")
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      bootstraputil.BootstrapTokenSecretName(tokenID),
+			Namespace: metav1.NamespaceSystem,
+		},
+	}
+
+	if err := remoteClient.Delete(ctx, secret); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to revoke agent token %s", tokenID)
+	}
+
+	logger.Info("Revoked agent token", "token-id", tokenID)
+
+	return nil
+}
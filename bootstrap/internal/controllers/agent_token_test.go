@@ -0,0 +1,142 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	bootstrapapi "k8s.io/cluster-bootstrap/token/api"
+	bootstraputil "k8s.io/cluster-bootstrap/token/util"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/clustercache"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+)
+
+func TestAgentToken(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(bootstrapv1.AddToScheme(scheme)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"}}
+	dataSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "default"}}
+	config := &bootstrapv1.RKE2Config{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: "test"},
+		},
+		Status: bootstrapv1.RKE2ConfigStatus{Ready: true, DataSecretName: ptr.To("config")},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, dataSecret, config).Build()
+	workloadClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	r := &RKE2ConfigReconciler{
+		Client:        fakeClient,
+		ClusterCache:  clustercache.NewFakeClusterCache(workloadClient, client.ObjectKeyFromObject(cluster)),
+		AgentTokenTTL: 15 * time.Minute,
+	}
+	scope := &Scope{Logger: logr.Discard(), Config: config, Machine: machine, Cluster: cluster}
+
+	getTokenSecret := func(tokenID string) (*corev1.Secret, error) {
+		secret := &corev1.Secret{}
+		key := client.ObjectKey{Namespace: metav1.NamespaceSystem, Name: bootstraputil.BootstrapTokenSecretName(tokenID)}
+
+		return secret, workloadClient.Get(ctx, key, secret)
+	}
+
+	// A bootstrap token is created in the workload cluster for the Machine.
+	token, err := r.getAgentToken(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(bootstraputil.IsValidBootstrapToken(token)).To(BeTrue())
+	g.Expect(controllerutil.ContainsFinalizer(config, bootstrapv1.RKE2ConfigAgentTokenFinalizer)).To(BeTrue())
+
+	tokenID := config.Annotations[bootstrapv1.AgentTokenIDAnnotation]
+	g.Expect(token).To(HavePrefix(tokenID + "."))
+
+	secret, err := getTokenSecret(tokenID)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(secret.Type).To(Equal(bootstrapapi.SecretTypeBootstrapToken))
+	g.Expect(secret.Data).To(HaveKeyWithValue(bootstrapapi.BootstrapTokenExtraGroupsKey, []byte(agentTokenExtraGroups)))
+	g.Expect(r.agentTokenRequeueAfter(scope)).To(Equal(5 * time.Minute))
+
+	// The token is reused, and refreshed while the Machine has not joined.
+	secret.Data[bootstrapapi.BootstrapTokenExpirationKey] = []byte(time.Now().UTC().Add(time.Minute).Format(time.RFC3339))
+	g.Expect(workloadClient.Update(ctx, secret)).To(Succeed())
+
+	lost, err := r.agentTokenLost(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lost).To(BeFalse())
+
+	secret, err = getTokenSecret(tokenID)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	expiration, err := time.Parse(time.RFC3339, string(secret.Data[bootstrapapi.BootstrapTokenExpirationKey]))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(expiration).To(BeTemporally(">", time.Now().Add(10*time.Minute)))
+
+	g.Expect(r.getAgentToken(ctx, scope)).To(Equal(token))
+
+	// The bootstrap data of a Machine not yet provisioned is regenerated once the token expired.
+	secret.Data[bootstrapapi.BootstrapTokenExpirationKey] = []byte(time.Now().UTC().Add(-time.Minute).Format(time.RFC3339))
+	g.Expect(workloadClient.Update(ctx, secret)).To(Succeed())
+
+	lost, err = r.agentTokenLost(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(lost).To(BeTrue())
+
+	newToken, err := r.getAgentToken(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(newToken).ToNot(Equal(token))
+
+	// The token is no longer refreshed once the Machine joined.
+	machine.Status.NodeRef = &corev1.ObjectReference{Name: "worker"}
+	g.Expect(r.agentTokenRequeueAfter(scope)).To(BeZero())
+
+	// The token is revoked when the RKE2Config is deleted.
+	g.Expect(fakeClient.Update(ctx, config)).To(Succeed())
+	g.Expect(fakeClient.Delete(ctx, config)).To(Succeed())
+	g.Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(config), config)).To(Succeed())
+
+	_, err = r.reconcileDelete(ctx, config)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	_, err = getTokenSecret(config.Annotations[bootstrapv1.AgentTokenIDAnnotation])
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	err = fakeClient.Get(ctx, client.ObjectKeyFromObject(config), config)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
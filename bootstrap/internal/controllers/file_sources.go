@@ -0,0 +1,236 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+)
+
+const (
+	// fileSourcesChecksumAnnotation is set on the bootstrap data secret to the checksum of the content of the file
+	// sources it was generated from, so that it is regenerated when they change.
+	fileSourcesChecksumAnnotation = "bootstrap.cluster.x-k8s.io/file-sources-checksum"
+
+	// fileSourcesIndex indexes the RKE2Configs by the secrets and config maps their files are populated from.
+	fileSourcesIndex = "spec.files.contentFrom"
+
+	secretFileSourceKind    = "Secret"
+	configMapFileSourceKind = "ConfigMap"
+)
+
+// fileSourceKey returns the key of a file source in the fileSourcesIndex.
+func fileSourceKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// fileSourceReference returns the kind, namespace and name of the object the given file source references,
+// defaulting the namespace to the one of the RKE2Config. The file source must reference a secret or a config map.
+func fileSourceReference(source *bootstrapv1.FileSource, configNamespace string) (string, types.NamespacedName) {
+	if source.ConfigMap != nil {
+		namespace := source.ConfigMap.Namespace
+		if namespace == "" {
+			namespace = configNamespace
+		}
+
+		return configMapFileSourceKind, types.NamespacedName{Namespace: namespace, Name: source.ConfigMap.Name}
+	}
+
+	namespace := source.Secret.Namespace
+	if namespace == "" {
+		namespace = configNamespace
+	}
+
+	return secretFileSourceKind, types.NamespacedName{Namespace: namespace, Name: source.Secret.Name}
+}
+
+// indexFileSources is the indexer function of the fileSourcesIndex.
+func indexFileSources(o client.Object) []string {
+	config, ok := o.(*bootstrapv1.RKE2Config)
+	if !ok {
+		return nil
+	}
+
+	keys := []string{}
+
+	for _, file := range config.Spec.Files {
+		if file.ContentFrom == nil || (file.ContentFrom.Secret == nil && file.ContentFrom.ConfigMap == nil) {
+			continue
+		}
+
+		kind, key := fileSourceReference(file.ContentFrom, config.Namespace)
+		keys = append(keys, fileSourceKey(kind, key.Namespace, key.Name))
+	}
+
+	return keys
+}
+
+// fileSourceToRKE2Configs returns a handler.MapFunc enqueuing the RKE2Configs with files populated from the
+// secret or config map of the given kind.
+func (r *RKE2ConfigReconciler) fileSourceToRKE2Configs(kind string) handler.MapFunc {
+	return func(ctx context.Context, o client.Object) []ctrl.Request {
+		configs := &bootstrapv1.RKE2ConfigList{}
+		if err := r.Client.List(ctx, configs,
+			client.MatchingFields{fileSourcesIndex: fileSourceKey(kind, o.GetNamespace(), o.GetName())}); err != nil {
+			return nil
+		}
+
+		result := []ctrl.Request{}
+		for _, config := range configs.Items {
+			result = append(result, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&config)})
+		}
+
+		return result
+	}
+}
+
+// resolveFileSources returns the files of the RKE2Config with the content of their sources, and the checksum of the
+// content of the sources, empty when there is none.
+func (r *RKE2ConfigReconciler) resolveFileSources(ctx context.Context, scope *Scope) ([]bootstrapv1.File, string, error) {
+	files := []bootstrapv1.File{}
+	hash := sha256.New()
+	hasSources := false
+
+	for _, file := range scope.Config.Spec.Files {
+		if file.ContentFrom != nil {
+			scope.Logger.V(5).Info("File content is coming from a Secret or ConfigMap, getting the content...")
+
+			content, err := r.getFileSourceContent(ctx, file.ContentFrom, scope.Config.Namespace)
+			if err != nil {
+				return nil, "", err
+			}
+
+			file.Content = string(content)
+			file.ContentFrom = nil
+
+			fmt.Fprintf(hash, "%s\x00%d\x00", file.Path, len(content))
+			hash.Write(content)
+
+			hasSources = true
+		}
+
+		files = append(files, file)
+	}
+
+	if !hasSources {
+		return files, "", nil
+	}
+
+	return files, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// getFileSourceContent returns the content referenced by the given file source.
+func (r *RKE2ConfigReconciler) getFileSourceContent(
+	ctx context.Context,
+	source *bootstrapv1.FileSource,
+	configNamespace string,
+) ([]byte, error) {
+	if source.Secret == nil && source.ConfigMap == nil {
+		return nil, errors.New("file source must reference a secret or a config map")
+	}
+
+	kind, key := fileSourceReference(source, configNamespace)
+
+	if key.Namespace != configNamespace && !slices.Contains(r.AllowedFileSourceNamespaces, key.Namespace) {
+		return nil, fmt.Errorf("%s %s is in namespace %s, which is not allowed for file sources", kind, key, key.Namespace)
+	}
+
+	if kind == configMapFileSourceKind {
+		configMap := &corev1.ConfigMap{}
+		if err := r.Client.Get(ctx, key, configMap); err != nil {
+			return nil, fmt.Errorf("unable to get config map %s: %w", key, err)
+		}
+
+		if content, found := configMap.Data[source.ConfigMap.Key]; found {
+			return []byte(content), nil
+		}
+
+		if content, found := configMap.BinaryData[source.ConfigMap.Key]; found {
+			return content, nil
+		}
+
+		return nil, fmt.Errorf("file content is empty for config map %s, config map key %s", key, source.ConfigMap.Key)
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, key, secret); err != nil {
+		return nil, fmt.Errorf("unable to get secret %s: %w", key, err)
+	}
+
+	content := secret.Data[source.Secret.Key]
+	if content == nil {
+		return nil, fmt.Errorf("file content is empty for secret %s, secret key %s", key, source.Secret.Key)
+	}
+
+	return content, nil
+}
+
+// fileSourcesChanged returns true when the bootstrap data of a Machine not yet provisioned was generated from file
+// sources whose content changed since, in which case it has to be regenerated.
+func (r *RKE2ConfigReconciler) fileSourcesChanged(ctx context.Context, scope *Scope) (bool, error) {
+	dataSecret, err := r.getPendingBootstrapData(ctx, scope)
+	if err != nil || dataSecret == nil {
+		return false, err
+	}
+
+	_, checksum, err := r.resolveFileSources(ctx, scope)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to resolve file sources")
+	}
+
+	return dataSecret.Annotations[fileSourcesChecksumAnnotation] != checksum, nil
+}
+
+// getPendingBootstrapData returns the bootstrap data secret generated by the RKE2Config, as long as the Machine is not
+// provisioned, or nil.
+func (r *RKE2ConfigReconciler) getPendingBootstrapData(ctx context.Context, scope *Scope) (*corev1.Secret, error) {
+	if scope.Machine.Status.InfrastructureReady || scope.Machine.Status.NodeRef != nil {
+		return nil, nil
+	}
+
+	// The bootstrap data was not generated by the RKE2Config.
+	if scope.Config.Status.DataSecretName == nil || *scope.Config.Status.DataSecretName != scope.Config.Name {
+		return nil, nil
+	}
+
+	dataSecret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{
+		Namespace: scope.Config.Namespace,
+		Name:      *scope.Config.Status.DataSecretName,
+	}, dataSecret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to get bootstrap data secret")
+	}
+
+	return dataSecret, nil
+}
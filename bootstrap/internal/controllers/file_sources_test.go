@@ -0,0 +1,128 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+)
+
+func TestFileSources(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(bootstrapv1.AddToScheme(scheme)).To(Succeed())
+
+	sysctl := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "sysctl", Namespace: "shared"},
+		Data:       map[string]string{"90-rke2.conf": "vm.max_map_count=262144"},
+	}
+	token := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("secret")},
+	}
+	dataSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "default"}}
+
+	config := &bootstrapv1.RKE2Config{
+		ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "default"},
+		Spec: bootstrapv1.RKE2ConfigSpec{
+			Files: []bootstrapv1.File{
+				{
+					Path: "/etc/sysctl.d/90-rke2.conf",
+					ContentFrom: &bootstrapv1.FileSource{
+						ConfigMap: &bootstrapv1.ConfigMapFileSource{Name: "sysctl", Namespace: "shared", Key: "90-rke2.conf"},
+					},
+				},
+				{
+					Path:        "/etc/token",
+					ContentFrom: &bootstrapv1.FileSource{Secret: &bootstrapv1.SecretFileSource{Name: "token", Key: "token"}},
+				},
+				{Path: "/etc/motd", Content: "hello"},
+			},
+		},
+		Status: bootstrapv1.RKE2ConfigStatus{Ready: true, DataSecretName: ptr.To("config")},
+	}
+
+	g.Expect(indexFileSources(config)).To(ConsistOf("ConfigMap/shared/sysctl", "Secret/default/token"))
+
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(sysctl, token, dataSecret, config).
+		WithIndex(&bootstrapv1.RKE2Config{}, fileSourcesIndex, indexFileSources).
+		Build()
+
+	r := &RKE2ConfigReconciler{Client: fakeClient}
+	scope := &Scope{Logger: logr.Discard(), Config: config, Machine: &clusterv1.Machine{}}
+
+	// Config maps can only be referenced from other namespaces when they are allowed.
+	_, _, err := r.resolveFileSources(ctx, scope)
+	g.Expect(err).To(MatchError(ContainSubstring("not allowed")))
+
+	r.AllowedFileSourceNamespaces = []string{"shared"}
+
+	files, checksum, err := r.resolveFileSources(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(checksum).ToNot(BeEmpty())
+	g.Expect(files).To(HaveLen(3))
+	g.Expect(files[0].Content).To(Equal("vm.max_map_count=262144"))
+	g.Expect(files[0].ContentFrom).To(BeNil())
+	g.Expect(files[1].Content).To(Equal("secret"))
+	g.Expect(files[2].Content).To(Equal("hello"))
+
+	g.Expect(r.fileSourceToRKE2Configs(configMapFileSourceKind)(ctx, sysctl)).To(HaveLen(1))
+	g.Expect(r.fileSourceToRKE2Configs(configMapFileSourceKind)(ctx, token)).To(BeEmpty())
+
+	// The bootstrap data is regenerated when it was generated from other file sources content.
+	changed, err := r.fileSourcesChanged(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changed).To(BeTrue())
+
+	dataSecret.Annotations = map[string]string{fileSourcesChecksumAnnotation: checksum}
+	g.Expect(fakeClient.Update(ctx, dataSecret)).To(Succeed())
+
+	changed, err = r.fileSourcesChanged(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changed).To(BeFalse())
+
+	sysctl.Data["90-rke2.conf"] = "vm.max_map_count=524288"
+	g.Expect(fakeClient.Update(ctx, sysctl)).To(Succeed())
+
+	changed, err = r.fileSourcesChanged(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changed).To(BeTrue())
+
+	// Once the Machine is provisioned, its bootstrap data is left untouched.
+	scope.Machine.Status.InfrastructureReady = true
+
+	changed, err = r.fileSourcesChanged(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changed).To(BeFalse())
+}
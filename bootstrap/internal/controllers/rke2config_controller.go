@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -39,6 +41,7 @@ import (
 	kubeyaml "sigs.k8s.io/yaml"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/clustercache"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/conditions"
@@ -47,6 +50,7 @@ import (
 	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
 	"github.com/rancher/cluster-api-provider-rke2/bootstrap/internal/cloudinit"
 	"github.com/rancher/cluster-api-provider-rke2/bootstrap/internal/ignition"
+	"github.com/rancher/cluster-api-provider-rke2/bootstrap/internal/templating"
 	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
 	"github.com/rancher/cluster-api-provider-rke2/pkg/consts"
 	"github.com/rancher/cluster-api-provider-rke2/pkg/locking"
@@ -59,7 +63,6 @@ const (
 	filePermissions  string = "0640"
 	registrationPort int    = 9345
 	serverURLFormat  string = "https://%v:%v"
-	tokenPrefix      string = "-token"
 )
 
 // RKE2ConfigReconciler reconciles a Rke2Config object.
@@ -67,6 +70,21 @@ type RKE2ConfigReconciler struct {
 	RKE2InitLock RKE2InitLock
 	client.Client
 	Scheme *runtime.Scheme
+
+	// AllowedFileSourceNamespaces are the namespaces, other than the one of a RKE2Config, its files can be
+	// populated from.
+	AllowedFileSourceNamespaces []string
+
+	// ClusterCache provides access to the workload clusters, where the agent tokens are created.
+	ClusterCache clustercache.ClusterCache
+
+	// AgentTokenTTL is the time the agent token of a worker machine is valid for once it joined the cluster. Worker
+	// machines join the cluster with the server token when it is zero.
+	AgentTokenTTL time.Duration
+
+	// InitLockTimeout is the time the control plane machine initializing the cluster holds the init lock for without
+	// getting a node, before it can be taken over by another machine. The default timeout is used when it is zero.
+	InitLockTimeout time.Duration
 }
 
 const (
@@ -82,6 +100,7 @@ const (
 //+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=rke2controlplanes;rke2controlplanes/status,verbs=get;list;watch
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status;machinesets;machines;machines/status;machinepools;machinepools/status,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=secrets;events;configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;create;update;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -89,7 +108,25 @@ func (r *RKE2ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	logger := log.FromContext(ctx)
 	logger.Info("Reconcile RKE2Config")
 
-	scope, res, err := r.prepareScope(ctx, logger, req)
+	config := &bootstrapv1.RKE2Config{}
+
+	if err := r.Get(ctx, req.NamespacedName, config, &client.GetOptions{}); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("rke2Config not found", "rke2-config-name", req.NamespacedName)
+
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "", "rke2-config-namespaced-name", req.NamespacedName)
+
+		return ctrl.Result{}, err
+	}
+
+	if !config.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, config)
+	}
+
+	scope, res, err := r.prepareScope(ctx, logger, config)
 	if err != nil {
 		if errors.Is(errors.Cause(err), util.ErrNoCluster) {
 			logger.Info(fmt.Sprintf("%s does not belong to a cluster yet, waiting until it's part of a cluster", scope.Machine.Kind))
@@ -157,8 +194,38 @@ func (r *RKE2ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 	// Status is ready means a config has been generated.
 	if scope.Config.Status.Ready {
-		// In any other case just return as the config is already generated and need not be generated again.
-		return ctrl.Result{}, nil
+		filesChanged, err := r.fileSourcesChanged(ctx, scope)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		tokenChanged, err := r.tokenChanged(ctx, scope)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		agentTokenLost, err := r.agentTokenLost(ctx, scope)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		switch {
+		case filesChanged:
+			logger.Info("File sources changed, regenerating bootstrap data for the Machine not yet provisioned")
+		case tokenChanged:
+			logger.Info("Token rotated, regenerating bootstrap data for the Machine not yet provisioned")
+		case agentTokenLost:
+			logger.Info("Agent token expired, regenerating bootstrap data for the Machine not yet provisioned")
+		default:
+			// The Machine initializing the control plane renews the init lock until the control plane is initialized.
+			if r.renewInitLock(ctx, scope) {
+				return ctrl.Result{RequeueAfter: locking.InitLockRenewInterval}, nil
+			}
+
+			// In any other case just return as the config is already generated and need not be generated again,
+			// once the agent token of a worker Machine not yet joined is refreshed.
+			return ctrl.Result{RequeueAfter: r.agentTokenRequeueAfter(scope)}, nil
+		}
 	}
 
 	// Note: can't use IsFalse here because we need to handle the absence of the condition as well as false.
@@ -196,22 +263,8 @@ func (r *RKE2ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 func (r *RKE2ConfigReconciler) prepareScope(
 	ctx context.Context,
 	logger logr.Logger,
-	req ctrl.Request,
+	config *bootstrapv1.RKE2Config,
 ) (*Scope, ctrl.Result, error) {
-	config := &bootstrapv1.RKE2Config{}
-
-	if err := r.Get(ctx, req.NamespacedName, config, &client.GetOptions{}); err != nil {
-		if apierrors.IsNotFound(err) {
-			logger.Error(err, "rke2Config not found", "rke2-config-name", req.NamespacedName)
-
-			return nil, ctrl.Result{}, err
-		}
-
-		logger.Error(err, "", "rke2-config-namespaced-name", req.NamespacedName)
-
-		return nil, ctrl.Result{}, err
-	}
-
 	machine, err := util.GetOwnerMachine(ctx, r.Client, config.ObjectMeta)
 	if err != nil {
 		logger.Error(err, "Failed to retrieve owner Machine from the API Server", "RKE2Config", config.Namespace+"/"+config.Name)
@@ -275,16 +328,74 @@ type Scope struct {
 	Cluster              *clusterv1.Cluster
 	HasControlPlaneOwner bool
 	ControlPlane         *controlplanev1.RKE2ControlPlane
+
+	fileSourcesChecksum string
+	tokenChecksum       string
 }
 
 func (s *Scope) getDesiredVersion() string {
 	return *s.Machine.Spec.Version
 }
 
+// renderFiles renders the content of the given files as templates of the Machine, when enabled by the TemplateFormat.
+func (s *Scope) renderFiles(files []bootstrapv1.File) ([]bootstrapv1.File, error) {
+	if s.Config.Spec.TemplateFormat != bootstrapv1.GoTemplate {
+		return files, nil
+	}
+
+	return templating.RenderFiles(files, templating.NewData(s.Machine, s.Cluster))
+}
+
+// renderCommands returns the PreRKE2Commands and PostRKE2Commands, rendered as templates of the Machine
+// when enabled by the TemplateFormat.
+func (s *Scope) renderCommands() ([]string, []string, error) {
+	if s.Config.Spec.TemplateFormat != bootstrapv1.GoTemplate {
+		return s.Config.Spec.PreRKE2Commands, s.Config.Spec.PostRKE2Commands, nil
+	}
+
+	data := templating.NewData(s.Machine, s.Cluster)
+
+	preRKE2Commands, err := templating.RenderCommands("preRKE2Commands", s.Config.Spec.PreRKE2Commands, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	postRKE2Commands, err := templating.RenderCommands("postRKE2Commands", s.Config.Spec.PostRKE2Commands, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return preRKE2Commands, postRKE2Commands, nil
+}
+
+// getServerRole returns the server role of a control plane machine of a server pool,
+// or an empty role if the machine runs all the server roles.
+func (s *Scope) getServerRole() controlplanev1.ServerRole {
+	return controlplanev1.ServerRole(s.Machine.Labels[controlplanev1.ServerRoleLabel])
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *RKE2ConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if r.RKE2InitLock == nil {
-		r.RKE2InitLock = locking.NewControlPlaneInitMutex(mgr.GetClient())
+		r.RKE2InitLock = locking.NewControlPlaneInitMutex(mgr.GetClient(), r.InitLockTimeout)
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.Background(),
+		&bootstrapv1.RKE2Config{},
+		fileSourcesIndex,
+		indexFileSources,
+	); err != nil {
+		return errors.Wrap(err, "failed to index file sources")
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(
+		context.Background(),
+		&controlplanev1.RKE2ControlPlane{},
+		tokenSecretRefIndex,
+		indexTokenSecretRef,
+	); err != nil {
+		return errors.Wrap(err, "failed to index token secret references")
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
@@ -297,6 +408,20 @@ func (r *RKE2ConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			&clusterv1.Cluster{},
 			handler.EnqueueRequestsFromMapFunc(r.ClusterToRKE2Configs),
 		).
+		// Only the metadata of the file sources and token secrets is cached, as the client reads the secrets and config
+		// maps directly.
+		WatchesMetadata(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.fileSourceToRKE2Configs(secretFileSourceKind)),
+		).
+		WatchesMetadata(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.tokenSecretToRKE2Configs),
+		).
+		WatchesMetadata(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.fileSourceToRKE2Configs(configMapFileSourceKind)),
+		).
 		Complete(r)
 }
 
@@ -356,6 +481,13 @@ func (r *RKE2ConfigReconciler) handleClusterNotInitialized(ctx context.Context,
 		return ctrl.Result{RequeueAfter: DefaultRequeueAfter}, nil
 	}
 
+	// The cluster is initialized by a machine running all the server roles.
+	if scope.getServerRole() != "" {
+		scope.Logger.Info("Requeuing because this machine of a server pool can only join an initialized control plane")
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueAfter}, nil
+	}
+
 	if !r.RKE2InitLock.Lock(ctx, scope.Cluster, scope.Machine) {
 		scope.Logger.Info("A control plane is already being initialized, requeuing until control plane is ready")
 
@@ -393,27 +525,9 @@ func (r *RKE2ConfigReconciler) handleClusterNotInitialized(ctx context.Context,
 
 	conditions.MarkTrue(scope.Config, bootstrapv1.CertificatesAvailableCondition)
 
-	// RKE2 server token must only be generated once, so all nodes join the cluster with the same registration token.
-	var token string
-
-	tokenName := bsutil.TokenName(scope.Cluster.Name)
-	token, err := r.generateAndStoreToken(ctx, scope, tokenName)
-
+	token, err := r.getOrGenerateServerToken(ctx, scope)
 	if err != nil {
-		if !apierrors.IsAlreadyExists(err) {
-			scope.Logger.Error(err, "unable to generate and store an RKE2 server token")
-
-			return ctrl.Result{}, err
-		}
-
-		token, err = r.getRegistrationTokenFromSecretValue(ctx, tokenName, scope.Cluster.Namespace)
-		if err != nil {
-			scope.Logger.Error(err, "unable to retrieve an RKE2 server token from existing secret")
-
-			return ctrl.Result{}, err
-		}
-	} else {
-		scope.Logger.Info("RKE2 server token generated and stored in Secret!")
+		return ctrl.Result{}, err
 	}
 
 	configStruct, configFiles, err := rke2.GenerateInitControlPlaneConfig(
@@ -423,6 +537,7 @@ func (r *RKE2ConfigReconciler) handleClusterNotInitialized(ctx context.Context,
 			Token:                token,
 			ServerURL:            fmt.Sprintf(serverURLFormat, scope.Cluster.Spec.ControlPlaneEndpoint.Host, registrationPort),
 			ServerConfig:         scope.ControlPlane.Spec.ServerConfig,
+			ServerRole:           scope.getServerRole(),
 			AgentConfig:          scope.Config.Spec.AgentConfig,
 			Ctx:                  ctx,
 			Client:               r.Client,
@@ -457,7 +572,7 @@ func (r *RKE2ConfigReconciler) handleClusterNotInitialized(ctx context.Context,
 		return ctrl.Result{}, err
 	}
 
-	manifestFiles, err := generateFilesFromManifestConfig(ctx, r.Client, scope.ControlPlane.Spec.ManifestsConfigMapReference)
+	manifestFiles, err := generateFilesFromManifestConfig(ctx, r.Client, scope.ControlPlane)
 	if err != nil {
 		manifestCm := scope.ControlPlane.Spec.ManifestsConfigMapReference.Name
 		ns := scope.ControlPlane.Spec.ManifestsConfigMapReference.Namespace
@@ -480,13 +595,18 @@ func (r *RKE2ConfigReconciler) handleClusterNotInitialized(ctx context.Context,
 		ntpServers = scope.Config.Spec.AgentConfig.NTP.Servers
 	}
 
+	preRKE2Commands, postRKE2Commands, err := scope.renderCommands()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	cpinput := &cloudinit.ControlPlaneInput{
 		BaseUserData: cloudinit.BaseUserData{
 			AirGapped:               scope.Config.Spec.AgentConfig.AirGapped,
 			AirGappedChecksum:       scope.Config.Spec.AgentConfig.AirGappedChecksum,
 			CISEnabled:              scope.Config.Spec.AgentConfig.CISProfile != "",
-			PreRKE2Commands:         scope.Config.Spec.PreRKE2Commands,
-			PostRKE2Commands:        scope.Config.Spec.PostRKE2Commands,
+			PreRKE2Commands:         preRKE2Commands,
+			PostRKE2Commands:        postRKE2Commands,
 			ConfigFile:              initConfigFile,
 			RKE2Version:             scope.getDesiredVersion(),
 			WriteFiles:              files,
@@ -517,7 +637,8 @@ func (r *RKE2ConfigReconciler) handleClusterNotInitialized(ctx context.Context,
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	// Renew the init lock until the control plane is initialized.
+	return ctrl.Result{RequeueAfter: locking.InitLockRenewInterval}, nil
 }
 
 // generateFileListIncludingRegistries generates a list of files to be written to disk on the node
@@ -556,32 +677,16 @@ func (r *RKE2ConfigReconciler) generateFileListIncludingRegistries(
 		Permissions: filePermissions,
 	}
 
-	additionalFiles := []bootstrapv1.File{}
-
-	for _, file := range scope.Config.Spec.Files {
-		if file.ContentFrom != nil {
-			scope.Logger.V(5).Info("File content is coming from a Secret, getting the content...")
-
-			fileContentSecret := &corev1.Secret{}
-
-			if err := r.Client.Get(ctx, types.NamespacedName{
-				Name:      file.ContentFrom.Secret.Name,
-				Namespace: scope.Config.Namespace,
-			}, fileContentSecret); err != nil {
-				return nil, fmt.Errorf("unable to get secret %s/%s: %w", scope.Config.Namespace, file.ContentFrom.Secret.Name, err)
-			}
-
-			fileContent := fileContentSecret.Data[file.ContentFrom.Secret.Key]
-			if fileContent == nil {
-				return nil, fmt.Errorf("file content is empty for secret %s/%s, secret key %s",
-					scope.Config.Namespace, file.ContentFrom.Secret.Name, file.ContentFrom.Secret.Key)
-			}
+	additionalFiles, checksum, err := r.resolveFileSources(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
 
-			file.Content = string(fileContent)
-			file.ContentFrom = nil
-		}
+	scope.fileSourcesChecksum = checksum
 
-		additionalFiles = append(additionalFiles, file)
+	additionalFiles, err = scope.renderFiles(additionalFiles)
+	if err != nil {
+		return nil, err
 	}
 
 	files := configFiles
@@ -597,29 +702,29 @@ func (r *RKE2ConfigReconciler) generateFileListIncludingRegistries(
 type RKE2InitLock interface {
 	Unlock(ctx context.Context, cluster *clusterv1.Cluster) bool
 	Lock(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) bool
+	Renew(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) bool
+}
+
+// renewInitLock renews the init lock held by the control plane Machine initializing the cluster, and returns false if
+// the control plane is initialized, or the Machine does not hold the lock.
+func (r *RKE2ConfigReconciler) renewInitLock(ctx context.Context, scope *Scope) bool {
+	if !scope.HasControlPlaneOwner || conditions.IsTrue(scope.Cluster, clusterv1.ControlPlaneInitializedCondition) {
+		return false
+	}
+
+	return r.RKE2InitLock.Renew(ctx, scope.Cluster, scope.Machine)
 }
 
 // joinControlPlane implements the part of the Reconciler which bootstraps a secondary
 // Control Plane machine joining a cluster that is already initialized.
 func (r *RKE2ConfigReconciler) joinControlplane(ctx context.Context, scope *Scope) (res ctrl.Result, rerr error) {
-	tokenSecret := &corev1.Secret{}
-
-	secretKey := types.NamespacedName{
-		Namespace: scope.Cluster.Namespace,
-		Name:      scope.Cluster.Name + tokenPrefix,
-	}
-	if err := r.Client.Get(ctx, secretKey, tokenSecret); err != nil {
-		scope.Logger.Error(
-			err,
-			"Token for already initialized RKE2 Cluster not found", "token-namespace",
-			scope.Cluster.Namespace, "token-name",
-			scope.Cluster.Name+tokenPrefix)
+	token, err := r.getServerToken(ctx, scope)
+	if err != nil {
+		scope.Logger.Error(err, "Token for already initialized RKE2 Cluster not found")
 
 		return ctrl.Result{}, err
 	}
 
-	token := string(tokenSecret.Data["value"])
-
 	scope.Logger.Info("RKE2 server token found in Secret!")
 
 	if len(scope.ControlPlane.Status.AvailableServerIPs) == 0 {
@@ -635,6 +740,7 @@ func (r *RKE2ConfigReconciler) joinControlplane(ctx context.Context, scope *Scop
 			ControlPlaneEndpoint: scope.Cluster.Spec.ControlPlaneEndpoint.Host,
 			ServerURL:            fmt.Sprintf(serverURLFormat, scope.ControlPlane.Status.AvailableServerIPs[0], registrationPort),
 			ServerConfig:         scope.ControlPlane.Spec.ServerConfig,
+			ServerRole:           scope.getServerRole(),
 			AgentConfig:          scope.Config.Spec.AgentConfig,
 			Ctx:                  ctx,
 			Client:               r.Client,
@@ -676,7 +782,7 @@ func (r *RKE2ConfigReconciler) joinControlplane(ctx context.Context, scope *Scop
 		return ctrl.Result{}, err
 	}
 
-	manifestFiles, err := generateFilesFromManifestConfig(ctx, r.Client, scope.ControlPlane.Spec.ManifestsConfigMapReference)
+	manifestFiles, err := generateFilesFromManifestConfig(ctx, r.Client, scope.ControlPlane)
 	if err != nil {
 		manifestCm := scope.ControlPlane.Spec.ManifestsConfigMapReference.Name
 		ns := scope.ControlPlane.Spec.ManifestsConfigMapReference.Namespace
@@ -699,13 +805,18 @@ func (r *RKE2ConfigReconciler) joinControlplane(ctx context.Context, scope *Scop
 		ntpServers = scope.Config.Spec.AgentConfig.NTP.Servers
 	}
 
+	preRKE2Commands, postRKE2Commands, err := scope.renderCommands()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	cpinput := &cloudinit.ControlPlaneInput{
 		BaseUserData: cloudinit.BaseUserData{
 			AirGapped:           scope.Config.Spec.AgentConfig.AirGapped,
 			AirGappedChecksum:   scope.Config.Spec.AgentConfig.AirGappedChecksum,
 			CISEnabled:          scope.Config.Spec.AgentConfig.CISProfile != "",
-			PreRKE2Commands:     scope.Config.Spec.PreRKE2Commands,
-			PostRKE2Commands:    scope.Config.Spec.PostRKE2Commands,
+			PreRKE2Commands:     preRKE2Commands,
+			PostRKE2Commands:    postRKE2Commands,
 			ConfigFile:          initConfigFile,
 			RKE2Version:         scope.getDesiredVersion(),
 			WriteFiles:          files,
@@ -740,33 +851,17 @@ func (r *RKE2ConfigReconciler) joinControlplane(ctx context.Context, scope *Scop
 // joinWorker implements the part of the Reconciler which bootstraps a worker node
 // after the cluster has been initialized.
 func (r *RKE2ConfigReconciler) joinWorker(ctx context.Context, scope *Scope) (res ctrl.Result, rerr error) {
-	tokenSecret := &corev1.Secret{}
-
-	secretKey := types.NamespacedName{
-		Namespace: scope.Cluster.Namespace,
-		Name:      scope.Cluster.Name + tokenPrefix,
-	}
-	if err := r.Client.Get(ctx, secretKey, tokenSecret); err != nil {
-		scope.Logger.Info(
-			"Token for already initialized RKE2 Cluster not found",
-			"token-namespace",
-			scope.Cluster.Namespace,
-			"token-name",
-			scope.Cluster.Name+tokenPrefix)
-
-		return ctrl.Result{}, err
-	}
-
-	token := string(tokenSecret.Data["value"])
-
-	scope.Logger.Info("RKE2 server token found in Secret!")
-
 	if len(scope.ControlPlane.Status.AvailableServerIPs) == 0 {
 		scope.Logger.V(1).Info("No ControlPlane IP Address found for node registration")
 
 		return ctrl.Result{RequeueAfter: DefaultRequeueAfter}, nil
 	}
 
+	token, err := r.getWorkerToken(ctx, scope)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	configStruct, configFiles, err := rke2.GenerateWorkerConfig(
 		rke2.AgentConfigOpts{
 			ServerURL:              fmt.Sprintf(serverURLFormat, scope.ControlPlane.Status.AvailableServerIPs[0], registrationPort),
@@ -812,12 +907,17 @@ func (r *RKE2ConfigReconciler) joinWorker(ctx context.Context, scope *Scope) (re
 		ntpServers = scope.Config.Spec.AgentConfig.NTP.Servers
 	}
 
+	preRKE2Commands, postRKE2Commands, err := scope.renderCommands()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	wkInput := &cloudinit.BaseUserData{
-		PreRKE2Commands:         scope.Config.Spec.PreRKE2Commands,
+		PreRKE2Commands:         preRKE2Commands,
 		AirGapped:               scope.Config.Spec.AgentConfig.AirGapped,
 		AirGappedChecksum:       scope.Config.Spec.AgentConfig.AirGappedChecksum,
 		CISEnabled:              scope.Config.Spec.AgentConfig.CISProfile != "",
-		PostRKE2Commands:        scope.Config.Spec.PostRKE2Commands,
+		PostRKE2Commands:        postRKE2Commands,
 		ConfigFile:              wkJoinConfigFile,
 		RKE2Version:             scope.getDesiredVersion(),
 		WriteFiles:              files,
@@ -846,7 +946,26 @@ func (r *RKE2ConfigReconciler) joinWorker(ctx context.Context, scope *Scope) (re
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: r.agentTokenRequeueAfter(scope)}, nil
+}
+
+// getWorkerToken returns the token a worker Machine joins the cluster with: its own agent token when agent tokens are
+// enabled, the server token otherwise.
+func (r *RKE2ConfigReconciler) getWorkerToken(ctx context.Context, scope *Scope) (string, error) {
+	if r.agentTokensEnabled() {
+		return r.getAgentToken(ctx, scope)
+	}
+
+	token, err := r.getServerToken(ctx, scope)
+	if err != nil {
+		scope.Logger.Info("Token for already initialized RKE2 Cluster not found", "error", err.Error())
+
+		return "", err
+	}
+
+	scope.Logger.Info("RKE2 server token found in Secret!")
+
+	return token, nil
 }
 
 // getRegistrationTokenFromSecretValue retrieves the registration token from an existing secret's value.
@@ -931,6 +1050,16 @@ func (r *RKE2ConfigReconciler) storeBootstrapData(ctx context.Context, scope *Sc
 		Type: clusterv1.ClusterSecretType,
 	}
 
+	secret.Annotations = map[string]string{}
+
+	if scope.fileSourcesChecksum != "" {
+		secret.Annotations[fileSourcesChecksumAnnotation] = scope.fileSourcesChecksum
+	}
+
+	if scope.tokenChecksum != "" {
+		secret.Annotations[tokenChecksumAnnotation] = scope.tokenChecksum
+	}
+
 	if err := r.createOrUpdateSecretFromObject(ctx, *secret, scope.Logger, "bootstrap data", *scope.Config); err != nil {
 		return err
 	}
@@ -993,32 +1122,47 @@ func (r *RKE2ConfigReconciler) createOrUpdateSecretFromObject(
 	return
 }
 
+// generateFilesFromManifestConfig returns the files of the manifests and of the addons of the control plane, written
+// in the manifests directory of the servers, unless they are synchronized by the control plane controller.
 func generateFilesFromManifestConfig(
 	ctx context.Context,
 	cl client.Client,
-	manifestConfigMap corev1.ObjectReference,
-) (files []bootstrapv1.File, err error) {
-	if (manifestConfigMap == corev1.ObjectReference{}) {
+	controlPlane *controlplanev1.RKE2ControlPlane,
+) ([]bootstrapv1.File, error) {
+	if controlPlane.Spec.ManifestsStrategy == controlplanev1.ManifestsStrategySync {
 		return []bootstrapv1.File{}, nil
 	}
 
-	manifestSec := &corev1.ConfigMap{}
+	manifests := map[string][]byte{}
 
-	err = cl.Get(ctx, types.NamespacedName{
-		Namespace: manifestConfigMap.Namespace,
-		Name:      manifestConfigMap.Name,
-	}, manifestSec)
+	userManifests, err := rke2.GetManifests(ctx, cl, controlPlane)
+	if err != nil {
+		return nil, err
+	}
+
+	maps.Copy(manifests, userManifests)
 
+	addonManifests, err := rke2.GetAddonManifests(ctx, cl, controlPlane)
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	maps.Copy(manifests, addonManifests)
+
+	names := make([]string, 0, len(manifests))
+	for name := range manifests {
+		names = append(names, name)
 	}
 
-	for filename, content := range manifestSec.Data {
+	slices.Sort(names)
+
+	files := []bootstrapv1.File{}
+	for _, name := range names {
 		files = append(files, bootstrapv1.File{
-			Path:    DefaultManifestDirectory + "/" + filename,
-			Content: content,
+			Path:    DefaultManifestDirectory + "/" + name,
+			Content: string(manifests[name]),
 		})
 	}
 
-	return
+	return files, nil
 }
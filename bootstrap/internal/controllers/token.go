@@ -0,0 +1,241 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	bsutil "github.com/rancher/cluster-api-provider-rke2/pkg/util"
+)
+
+const (
+	// tokenChecksumAnnotation is set on the bootstrap data secret to the checksum of the token it was generated with,
+	// so that it is regenerated when the token is rotated.
+	tokenChecksumAnnotation = "bootstrap.cluster.x-k8s.io/token-checksum"
+
+	// tokenSecretRefIndex indexes the RKE2ControlPlanes by the name of the token secret they reference.
+	tokenSecretRefIndex = "spec.tokenSecretRef.name"
+)
+
+// indexTokenSecretRef is the indexer function of the tokenSecretRefIndex.
+func indexTokenSecretRef(o client.Object) []string {
+	rcp, ok := o.(*controlplanev1.RKE2ControlPlane)
+	if !ok || rcp.Spec.TokenSecretRef == nil {
+		return nil
+	}
+
+	return []string{rcp.Spec.TokenSecretRef.Name}
+}
+
+// tokenChecksum returns the checksum of the given token.
+func tokenChecksum(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// getServerToken returns the server token of the cluster, stored in the secret referenced by the TokenSecretRef of
+// the control plane, or in the secret generated by the bootstrap provider otherwise.
+func (r *RKE2ConfigReconciler) getServerToken(ctx context.Context, scope *Scope) (string, error) {
+	controlPlane, err := r.getClusterControlPlane(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+
+	key := bsutil.TokenSecretKey(controlPlane, scope.Cluster.Name)
+
+	tokenFailed := func(err error) (string, error) {
+		conditions.MarkFalse(scope.Config,
+			bootstrapv1.DataSecretAvailableCondition,
+			bootstrapv1.DataSecretGenerationFailedReason,
+			clusterv1.ConditionSeverityWarning,
+			err.Error())
+
+		return "", err
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, key, secret); err != nil {
+		return tokenFailed(errors.Wrapf(err, "failed to get token secret %s", key))
+	}
+
+	token, err := bsutil.TokenFromSecret(secret)
+	if err != nil {
+		return tokenFailed(err)
+	}
+
+	scope.tokenChecksum = tokenChecksum(token)
+
+	return token, nil
+}
+
+// getOrGenerateServerToken returns the server token the cluster is initialized with. Unless the control plane
+// references a token secret, the token is generated once, so all nodes join the cluster with the same registration
+// token.
+func (r *RKE2ConfigReconciler) getOrGenerateServerToken(ctx context.Context, scope *Scope) (string, error) {
+	if scope.ControlPlane.Spec.TokenSecretRef != nil {
+		token, err := r.getServerToken(ctx, scope)
+		if err != nil {
+			scope.Logger.Error(err, "unable to retrieve the RKE2 server token from the referenced secret")
+
+			return "", err
+		}
+
+		return token, nil
+	}
+
+	tokenName := bsutil.TokenName(scope.Cluster.Name)
+
+	token, err := r.generateAndStoreToken(ctx, scope, tokenName)
+	if err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			scope.Logger.Error(err, "unable to generate and store an RKE2 server token")
+
+			return "", err
+		}
+
+		token, err = r.getServerToken(ctx, scope)
+		if err != nil {
+			scope.Logger.Error(err, "unable to retrieve an RKE2 server token from existing secret")
+
+			return "", err
+		}
+
+		return token, nil
+	}
+
+	scope.Logger.Info("RKE2 server token generated and stored in Secret!")
+
+	scope.tokenChecksum = tokenChecksum(token)
+
+	return token, nil
+}
+
+// getClusterControlPlane returns the RKE2ControlPlane of the cluster, which is only part of the scope of control plane
+// machines.
+func (r *RKE2ConfigReconciler) getClusterControlPlane(ctx context.Context, scope *Scope) (*controlplanev1.RKE2ControlPlane, error) {
+	if scope.ControlPlane != nil {
+		return scope.ControlPlane, nil
+	}
+
+	ref := scope.Cluster.Spec.ControlPlaneRef
+	if ref == nil {
+		return nil, errors.Errorf("cluster %s has no control plane", scope.Cluster.Name)
+	}
+
+	controlPlane := &controlplanev1.RKE2ControlPlane{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, controlPlane); err != nil {
+		return nil, errors.Wrapf(err, "failed to get control plane %s", ref.Name)
+	}
+
+	return controlPlane, nil
+}
+
+// tokenChanged returns true when the bootstrap data of a Machine not yet provisioned was generated with a token which
+// was rotated since, in which case it has to be regenerated.
+func (r *RKE2ConfigReconciler) tokenChanged(ctx context.Context, scope *Scope) (bool, error) {
+	dataSecret, err := r.getPendingBootstrapData(ctx, scope)
+	if err != nil || dataSecret == nil {
+		return false, err
+	}
+
+	// The bootstrap data was generated before the checksum of the token was recorded.
+	checksum, found := dataSecret.Annotations[tokenChecksumAnnotation]
+	if !found {
+		return false, nil
+	}
+
+	controlPlane, err := r.getClusterControlPlane(ctx, scope)
+	if err != nil {
+		if apierrors.IsNotFound(errors.Cause(err)) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	key := bsutil.TokenSecretKey(controlPlane, scope.Cluster.Name)
+
+	token, err := r.getRegistrationTokenFromSecretValue(ctx, key.Name, key.Namespace)
+	if err != nil {
+		if apierrors.IsNotFound(errors.Cause(err)) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return checksum != tokenChecksum(token), nil
+}
+
+// tokenSecretToRKE2Configs is a handler.MapFunc enqueuing the RKE2Configs of the cluster of a token secret, so that
+// their bootstrap data is regenerated when the token is rotated.
+func (r *RKE2ConfigReconciler) tokenSecretToRKE2Configs(ctx context.Context, o client.Object) []ctrl.Request {
+	clusterNames := []string{}
+
+	if clusterName, found := o.GetLabels()[clusterv1.ClusterNameLabel]; found && o.GetName() == bsutil.TokenName(clusterName) {
+		clusterNames = append(clusterNames, clusterName)
+	}
+
+	// The token secrets referenced by the control planes.
+	rcps := &controlplanev1.RKE2ControlPlaneList{}
+	if err := r.Client.List(ctx, rcps,
+		client.InNamespace(o.GetNamespace()), client.MatchingFields{tokenSecretRefIndex: o.GetName()}); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to list control planes referencing token secret", "secret", o.GetName())
+	}
+
+	for _, rcp := range rcps.Items {
+		cluster, err := util.GetOwnerCluster(ctx, r.Client, rcp.ObjectMeta)
+		if err != nil || cluster == nil {
+			continue
+		}
+
+		clusterNames = append(clusterNames, cluster.Name)
+	}
+
+	result := []ctrl.Request{}
+
+	for _, clusterName := range clusterNames {
+		configs := &bootstrapv1.RKE2ConfigList{}
+		if err := r.Client.List(ctx, configs,
+			client.InNamespace(o.GetNamespace()), client.MatchingLabels{clusterv1.ClusterNameLabel: clusterName}); err != nil {
+			return nil
+		}
+
+		for _, config := range configs.Items {
+			if config.Status.Ready {
+				result = append(result, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&config)})
+			}
+		}
+	}
+
+	return result
+}
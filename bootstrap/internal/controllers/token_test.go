@@ -0,0 +1,186 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+func TestTokenChanged(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(bootstrapv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(controlplanev1.AddToScheme(scheme)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	controlPlane := &controlplanev1.RKE2ControlPlane{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	token := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-token",
+			Namespace: "default",
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: "test"},
+		},
+		Data: map[string][]byte{"value": []byte("previous")},
+	}
+	dataSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "config",
+			Namespace:   "default",
+			Annotations: map[string]string{tokenChecksumAnnotation: tokenChecksum("previous")},
+		},
+	}
+	config := &bootstrapv1.RKE2Config{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: "test"},
+		},
+		Status: bootstrapv1.RKE2ConfigStatus{Ready: true, DataSecretName: ptr.To("config")},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(token, dataSecret, config).
+		WithIndex(&controlplanev1.RKE2ControlPlane{}, tokenSecretRefIndex, indexTokenSecretRef).
+		Build()
+
+	r := &RKE2ConfigReconciler{Client: fakeClient}
+	scope := &Scope{
+		Logger:       logr.Discard(),
+		Config:       config,
+		Machine:      &clusterv1.Machine{},
+		Cluster:      cluster,
+		ControlPlane: controlPlane,
+	}
+
+	changed, err := r.tokenChanged(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changed).To(BeFalse())
+
+	g.Expect(r.tokenSecretToRKE2Configs(ctx, token)).To(HaveLen(1))
+	g.Expect(r.tokenSecretToRKE2Configs(ctx, dataSecret)).To(BeEmpty())
+
+	// The bootstrap data is regenerated once the token is rotated.
+	token.Data["value"] = []byte("next")
+	g.Expect(fakeClient.Update(ctx, token)).To(Succeed())
+
+	changed, err = r.tokenChanged(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changed).To(BeTrue())
+
+	// Once the Machine is provisioned, its bootstrap data is left untouched.
+	scope.Machine.Status.InfrastructureReady = true
+
+	changed, err = r.tokenChanged(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(changed).To(BeFalse())
+}
+
+func TestTokenSecretRef(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(bootstrapv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(controlplanev1.AddToScheme(scheme)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{
+		TypeMeta:   metav1.TypeMeta{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: clusterv1.ClusterSpec{
+			ControlPlaneRef: &corev1.ObjectReference{Name: "test", Namespace: "default"},
+		},
+	}
+	controlPlane := &controlplanev1.RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: "test"},
+			},
+		},
+		Spec: controlplanev1.RKE2ControlPlaneSpec{
+			TokenSecretRef: &corev1.LocalObjectReference{Name: "existing-token"},
+		},
+	}
+	token := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "existing-token", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("K10abc::server:existing")},
+	}
+	config := &bootstrapv1.RKE2Config{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: "test"},
+		},
+		Status: bootstrapv1.RKE2ConfigStatus{Ready: true, DataSecretName: ptr.To("config")},
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, controlPlane, token, config).
+		WithIndex(&controlplanev1.RKE2ControlPlane{}, tokenSecretRefIndex, indexTokenSecretRef).
+		Build()
+
+	r := &RKE2ConfigReconciler{Client: fakeClient}
+
+	// The worker machines read the token of the secret referenced by the control plane of the cluster.
+	scope := &Scope{Logger: logr.Discard(), Config: config, Machine: &clusterv1.Machine{}, Cluster: cluster}
+
+	value, err := r.getServerToken(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(value).To(Equal("K10abc::server:existing"))
+	g.Expect(scope.tokenChecksum).To(Equal(tokenChecksum(value)))
+
+	// The cluster is initialized with the referenced token, instead of a generated one.
+	scope.ControlPlane = controlPlane
+
+	value, err = r.getOrGenerateServerToken(ctx, scope)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(value).To(Equal("K10abc::server:existing"))
+
+	err = fakeClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: "test-token"}, &corev1.Secret{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	g.Expect(r.tokenSecretToRKE2Configs(ctx, token)).To(HaveLen(1))
+
+	// Secrets not holding a valid token are rejected.
+	token.Data = map[string][]byte{"token": []byte("existing")}
+	g.Expect(fakeClient.Update(ctx, token)).To(Succeed())
+
+	_, err = r.getServerToken(ctx, scope)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(conditions.GetReason(config, bootstrapv1.DataSecretAvailableCondition)).To(Equal(bootstrapv1.DataSecretGenerationFailedReason))
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package templating renders the files and commands of a RKE2Config as templates of the Machine they bootstrap.
+package templating
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"k8s.io/utils/ptr"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+)
+
+// Data is the data exposed to the templates.
+type Data struct {
+	Machine Machine
+	Cluster Cluster
+}
+
+// Machine exposes the metadata of the Machine bootstrapped.
+type Machine struct {
+	Name          string
+	Namespace     string
+	FailureDomain string
+	Labels        map[string]string
+}
+
+// Cluster exposes the metadata of the Cluster the Machine belongs to.
+type Cluster struct {
+	Name                 string
+	ControlPlaneEndpoint clusterv1.APIEndpoint
+}
+
+// NewData returns the data exposing the given Machine and Cluster to the templates.
+func NewData(machine *clusterv1.Machine, cluster *clusterv1.Cluster) *Data {
+	labels := make(map[string]string, len(machine.Labels))
+	for key, value := range machine.Labels {
+		labels[key] = value
+	}
+
+	return &Data{
+		Machine: Machine{
+			Name:          machine.Name,
+			Namespace:     machine.Namespace,
+			FailureDomain: ptr.Deref(machine.Spec.FailureDomain, ""),
+			Labels:        labels,
+		},
+		Cluster: Cluster{
+			Name:                 cluster.Name,
+			ControlPlaneEndpoint: cluster.Spec.ControlPlaneEndpoint,
+		},
+	}
+}
+
+// Render renders the given text as a Go template of the data. No function is made available besides the builtin
+// ones of text/template, and referencing a missing label is an error, so that a typo can't silently render an
+// empty value on the node.
+func Render(name, text string, data *Data) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse template %s", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "failed to render template %s", name)
+	}
+
+	return buf.String(), nil
+}
+
+// RenderFiles renders the content of the given files. The files with an encoding are copied verbatim, as their
+// content can't be a template.
+func RenderFiles(files []bootstrapv1.File, data *Data) ([]bootstrapv1.File, error) {
+	rendered := make([]bootstrapv1.File, 0, len(files))
+
+	for _, file := range files {
+		if file.Encoding == "" {
+			content, err := Render(file.Path, file.Content, data)
+			if err != nil {
+				return nil, err
+			}
+
+			file.Content = content
+		}
+
+		rendered = append(rendered, file)
+	}
+
+	return rendered, nil
+}
+
+// RenderCommands renders the given commands.
+func RenderCommands(name string, commands []string, data *Data) ([]string, error) {
+	if commands == nil {
+		return nil, nil
+	}
+
+	rendered := make([]string, 0, len(commands))
+
+	for i, command := range commands {
+		command, err := Render(fmt.Sprintf("%s[%d]", name, i), command, data)
+		if err != nil {
+			return nil, err
+		}
+
+		rendered = append(rendered, command)
+	}
+
+	return rendered, nil
+}
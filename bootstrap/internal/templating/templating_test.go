@@ -0,0 +1,96 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+)
+
+func TestTemplating(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Templating Suite")
+}
+
+var _ = Describe("Render", func() {
+	var data *Data
+
+	BeforeEach(func() {
+		machine := &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "machine-0",
+				Namespace: "default",
+				Labels:    map[string]string{"topology.kubernetes.io/zone": "zone-a"},
+			},
+			Spec: clusterv1.MachineSpec{FailureDomain: ptr.To("fd-a")},
+		}
+		cluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+			Spec: clusterv1.ClusterSpec{
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "example.com", Port: 6443},
+			},
+		}
+
+		data = NewData(machine, cluster)
+	})
+
+	It("should render the metadata of the machine", func() {
+		rendered, err := Render("test", `{{ .Machine.Namespace }}/{{ .Machine.Name }} {{ .Machine.FailureDomain }} `+
+			`{{ index .Machine.Labels "topology.kubernetes.io/zone" }} {{ .Cluster.Name }} `+
+			`{{ .Cluster.ControlPlaneEndpoint.Host }}:{{ .Cluster.ControlPlaneEndpoint.Port }}`, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered).To(Equal("default/machine-0 fd-a zone-a cluster example.com:6443"))
+	})
+
+	It("should fail on invalid or missing fields", func() {
+		_, err := Render("test", "{{ .Machine.Name", data)
+		Expect(err).To(HaveOccurred())
+
+		_, err = Render("test", "{{ .Machine.Hostname }}", data)
+		Expect(err).To(HaveOccurred())
+
+		_, err = Render("test", "{{ .Machine.Labels.missing }}", data)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should render the files without encoding", func() {
+		files, err := RenderFiles([]bootstrapv1.File{
+			{Path: "/etc/hostname", Content: "{{ .Machine.Name }}"},
+			{Path: "/etc/encoded", Content: "e3sgLk1hY2hpbmUuTmFtZSB9fQ==", Encoding: bootstrapv1.Base64},
+		}, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files[0].Content).To(Equal("machine-0"))
+		Expect(files[1].Content).To(Equal("e3sgLk1hY2hpbmUuTmFtZSB9fQ=="))
+	})
+
+	It("should render the commands", func() {
+		commands, err := RenderCommands("preRKE2Commands", []string{"hostnamectl set-hostname {{ .Machine.Name }}"}, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(commands).To(Equal([]string{"hostnamectl set-hostname machine-0"}))
+
+		_, err = RenderCommands("preRKE2Commands", []string{"{{ .Missing }}"}, data)
+		Expect(err).To(MatchError(ContainSubstring("preRKE2Commands[0]")))
+	})
+})
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -27,6 +28,7 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"github.com/spf13/pflag"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -35,9 +37,12 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/clustercache"
+	"sigs.k8s.io/cluster-api/controllers/remote"
 	"sigs.k8s.io/cluster-api/util/flags"
 
 	bootstrapv1alpha1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1alpha1"
@@ -46,6 +51,7 @@ import (
 	controlplanev1alpha1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1alpha1"
 	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
 	"github.com/rancher/cluster-api-provider-rke2/pkg/consts"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/locking"
 	"github.com/rancher/cluster-api-provider-rke2/version"
 )
 
@@ -66,6 +72,11 @@ var (
 	webhookPort                 int
 	webhookCertDir              string
 	healthAddr                  string
+	allowedFileSourceNamespaces []string
+	agentTokenTTL               time.Duration
+	initLockTimeout             time.Duration
+	clusterCacheClientQPS       float32
+	clusterCacheClientBurst     int
 	managerOptions              = flags.ManagerOptions{}
 )
 
@@ -118,6 +129,21 @@ func InitFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&healthAddr, "health-addr", ":9440",
 		"The address the health endpoint binds to.")
 
+	fs.StringSliceVar(&allowedFileSourceNamespaces, "allowed-file-source-namespaces", nil,
+		"Namespaces, other than the one of a RKE2Config, the secrets and config maps populating its files can be referenced from.")
+
+	fs.DurationVar(&agentTokenTTL, "agent-token-ttl", 0,
+		"The time the agent token of a worker machine is valid for once it joined the cluster. If unspecified, worker machines join the cluster with the server token.") //nolint:lll
+
+	fs.DurationVar(&initLockTimeout, "init-lock-timeout", locking.DefaultInitTimeout,
+		"The time the control plane machine initializing a cluster holds the init lock for without getting a node, before it can be taken over by another machine.") //nolint:lll
+
+	fs.Float32Var(&clusterCacheClientQPS, "clustercache-client-qps", 20,
+		"Maximum queries per second from the cluster cache clients to the Kubernetes API server of workload clusters.")
+
+	fs.IntVar(&clusterCacheClientBurst, "clustercache-client-burst", 30,
+		"Maximum number of queries that should be allowed in one burst from the cluster cache clients to the Kubernetes API server of workload clusters.") //nolint:lll
+
 	flags.AddManagerOptions(fs, &managerOptions)
 }
 
@@ -168,6 +194,7 @@ func main() {
 				DisableFor: []client.Object{
 					&corev1.ConfigMap{},
 					&corev1.Secret{},
+					&coordinationv1.Lease{},
 				},
 			},
 		},
@@ -190,7 +217,7 @@ func main() {
 	ctx := ctrl.SetupSignalHandler()
 
 	setupChecks(mgr)
-	setupReconcilers(mgr)
+	setupReconcilers(ctx, mgr)
 	setupWebhooks(mgr)
 
 	setupLog.Info("Starting manager", "version", version.Get().String())
@@ -213,10 +240,42 @@ func setupChecks(mgr ctrl.Manager) {
 	}
 }
 
-func setupReconcilers(mgr ctrl.Manager) {
+func setupReconcilers(ctx context.Context, mgr ctrl.Manager) {
+	var clusterCache clustercache.ClusterCache
+
+	// The workload clusters are only accessed to manage the agent tokens.
+	if agentTokenTTL > 0 {
+		var err error
+
+		clusterCache, err = clustercache.SetupWithManager(ctx, mgr, clustercache.Options{
+			// The secrets are not cached by the client of the manager.
+			SecretClient: mgr.GetClient(),
+			Client: clustercache.ClientOptions{
+				QPS:       clusterCacheClientQPS,
+				Burst:     clusterCacheClientBurst,
+				UserAgent: remote.DefaultClusterAPIUserAgent("rke2-bootstrap-controller"),
+				Cache: clustercache.ClientCacheOptions{
+					DisableFor: []client.Object{
+						// Don't cache ConfigMaps & Secrets.
+						&corev1.ConfigMap{},
+						&corev1.Secret{},
+					},
+				},
+			},
+		}, controller.Options{})
+		if err != nil {
+			setupLog.Error(err, "unable to create cluster cache")
+			os.Exit(1)
+		}
+	}
+
 	if err := (&controllers.RKE2ConfigReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                      mgr.GetClient(),
+		Scheme:                      mgr.GetScheme(),
+		AllowedFileSourceNamespaces: allowedFileSourceNamespaces,
+		ClusterCache:                clusterCache,
+		AgentTokenTTL:               agentTokenTTL,
+		InitLockTimeout:             initLockTimeout,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Rke2Config")
 		os.Exit(1)
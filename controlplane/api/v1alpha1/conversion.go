@@ -57,7 +57,29 @@ func (src *RKE2ControlPlane) ConvertTo(dstRaw conversion.Hub) error {
 		dst.Spec.AgentConfig.PodSecurityAdmissionConfigFile = restored.Spec.AgentConfig.PodSecurityAdmissionConfigFile
 	}
 
+	dst.Spec.TemplateFormat = restored.Spec.TemplateFormat
+	bootstrapv1alpha1.RestoreFileSources(dst.Spec.Files, restored.Spec.Files)
+
 	dst.Spec.MachineTemplate = restored.Spec.MachineTemplate
+	dst.Spec.RemediationStrategy = restored.Spec.RemediationStrategy
+	dst.Spec.RolloutBefore = restored.Spec.RolloutBefore
+	dst.Spec.RolloutAfter = restored.Spec.RolloutAfter
+	dst.Spec.EtcdDefragmentation = restored.Spec.EtcdDefragmentation
+	dst.Spec.ServerPools = restored.Spec.ServerPools
+	dst.Spec.ManifestsStrategy = restored.Spec.ManifestsStrategy
+	dst.Spec.ServerConfig.Addons = restored.Spec.ServerConfig.Addons
+	dst.Spec.TokenSecretRef = restored.Spec.TokenSecretRef
+	dst.Spec.DeletionPolicy = restored.Spec.DeletionPolicy
+	dst.Spec.WorkloadCleanup = restored.Spec.WorkloadCleanup
+	dst.Spec.HostCommandImage = restored.Spec.HostCommandImage
+
+	if restored.Spec.RolloutStrategy != nil && restored.Spec.RolloutStrategy.InPlaceUpgrade != nil {
+		if dst.Spec.RolloutStrategy == nil {
+			dst.Spec.RolloutStrategy = &controlplanev1.RolloutStrategy{}
+		}
+
+		dst.Spec.RolloutStrategy.InPlaceUpgrade = restored.Spec.RolloutStrategy.InPlaceUpgrade
+	}
 	dst.Status = restored.Status
 
 	return nil
@@ -186,9 +208,24 @@ func (dst *RKE2ControlPlaneTemplateList) ConvertFrom(srcRaw conversion.Hub) erro
 func Convert_v1beta1_RKE2ControlPlaneSpec_To_v1alpha1_RKE2ControlPlaneSpec(in *controlplanev1.RKE2ControlPlaneSpec, out *RKE2ControlPlaneSpec, s apiconversion.Scope) error {
 	// Version was added in v1beta1.
 	// MachineTemplate was added in v1beta1.
+	// ManifestsStrategy was added in v1beta1.
+	// TokenSecretRef was added in v1beta1.
+	// DeletionPolicy was added in v1beta1.
+	// WorkloadCleanup was added in v1beta1.
+	// HostCommandImage was added in v1beta1.
 	return autoConvert_v1beta1_RKE2ControlPlaneSpec_To_v1alpha1_RKE2ControlPlaneSpec(in, out, s)
 }
 
+func Convert_v1beta1_RKE2ServerConfig_To_v1alpha1_RKE2ServerConfig(in *controlplanev1.RKE2ServerConfig, out *RKE2ServerConfig, s apiconversion.Scope) error {
+	// Addons was added in v1beta1.
+	return autoConvert_v1beta1_RKE2ServerConfig_To_v1alpha1_RKE2ServerConfig(in, out, s)
+}
+
+func Convert_v1beta1_RolloutStrategy_To_v1alpha1_RolloutStrategy(in *controlplanev1.RolloutStrategy, out *RolloutStrategy, s apiconversion.Scope) error {
+	// InPlaceUpgrade was added in v1beta1.
+	return autoConvert_v1beta1_RolloutStrategy_To_v1alpha1_RolloutStrategy(in, out, s)
+}
+
 func Convert_v1beta1_RKE2ControlPlaneStatus_To_v1alpha1_RKE2ControlPlaneStatus(in *controlplanev1.RKE2ControlPlaneStatus, out *RKE2ControlPlaneStatus, s apiconversion.Scope) error {
 	return autoConvert_v1beta1_RKE2ControlPlaneStatus_To_v1alpha1_RKE2ControlPlaneStatus(in, out, s)
 }
@@ -77,6 +77,25 @@ const (
 	// RollingUpdateInProgressReason (Severity=Warning) documents a RKE2ControlPlane object executing a
 	// rolling upgrade for aligning the machines spec to the desired state.
 	RollingUpdateInProgressReason = "RollingUpdateInProgress"
+
+	// RolloutBlockedReason (Severity=Warning) documents a RKE2ControlPlane object that can't proceed with
+	// a rollout because the configured rollout strategy can't be executed safely.
+	RolloutBlockedReason = "RolloutBlocked"
+
+	// InPlaceUpgradeInProgressReason (Severity=Warning) documents a RKE2ControlPlane object executing an
+	// in place upgrade of the RKE2 version of its machines.
+	InPlaceUpgradeInProgressReason = "InPlaceUpgradeInProgress"
+
+	// RolloutPlanPendingApprovalReason (Severity=Info) documents a RKE2ControlPlane in dry-run mode waiting for the plan
+	// of a rollout to be approved.
+	RolloutPlanPendingApprovalReason = "RolloutPlanPendingApproval"
+
+	// MachineSpecUpToDateCondition documents that the spec of a machine controlled by the RKE2ControlPlane is up to date.
+	// When this condition is false, its message lists the reasons why the machine is rolled out or upgraded in place.
+	MachineSpecUpToDateCondition clusterv1.ConditionType = "MachineSpecUpToDate"
+
+	// MachineSpecOutdatedReason (Severity=Info) documents a machine whose spec differs from the one of the RKE2ControlPlane.
+	MachineSpecOutdatedReason = "MachineSpecOutdated"
 )
 
 const (
@@ -93,6 +112,17 @@ const (
 	// EtcdMemberInspectionFailedReason documents a failure in inspecting the etcd member status.
 	EtcdMemberInspectionFailedReason = "MemberInspectionFailed"
 
+	// EtcdMemberUnhealthyReason (Severity=Error) documents a machine's etcd member being unhealthy, e.g. reporting alarms.
+	EtcdMemberUnhealthyReason = "EtcdMemberUnhealthy"
+
+	// EtcdMemberLaggingReason (Severity=Warning) documents a machine's etcd member lagging behind the leader
+	// in applied raft entries.
+	EtcdMemberLaggingReason = "EtcdMemberLagging"
+
+	// EtcdMemberLearnerReason (Severity=Info) documents a machine's etcd member which is a raft learner,
+	// not yet promoted to a voting member.
+	EtcdMemberLearnerReason = "EtcdMemberLearner"
+
 	// ResizedCondition documents a RKE2ControlPlane that is resizing the set of controlled machines.
 	ResizedCondition clusterv1.ConditionType = "Resized"
 
@@ -110,3 +140,172 @@ const (
 	// CertificatesGenerationFailedReason documents a failure in generating the certificates.
 	CertificatesGenerationFailedReason string = "CertificateGenerationFailed"
 )
+
+const (
+	// ManifestsSyncedCondition documents that the manifests of the ManifestsConfigMapReference are applied to the
+	// workload cluster. It is only set when the ManifestsStrategy is Sync.
+	ManifestsSyncedCondition clusterv1.ConditionType = "ManifestsSynced"
+
+	// ManifestsSyncFailedReason (Severity=Warning) documents a failure in reading, applying or pruning the manifests;
+	// the synchronization is retried.
+	ManifestsSyncFailedReason = "ManifestsSyncFailed"
+)
+
+const (
+	// AddonsSyncedCondition documents that the HelmChartConfigs and HelmCharts of the addons are applied to the
+	// workload cluster. It is only set when addons are defined and the ManifestsStrategy is Sync.
+	AddonsSyncedCondition clusterv1.ConditionType = "AddonsSynced"
+
+	// AddonsSyncFailedReason (Severity=Warning) documents a failure in rendering, applying or pruning the addons;
+	// the synchronization is retried.
+	AddonsSyncFailedReason = "AddonsSyncFailed"
+)
+
+const (
+	// CertificateAuthoritiesRotatedCondition documents the progress of the rotation of the certificate authorities
+	// of the RKE2ControlPlane. It is only set once a rotation is requested.
+	CertificateAuthoritiesRotatedCondition clusterv1.ConditionType = "CertificateAuthoritiesRotated"
+
+	// DistributingCertificateAuthoritiesReason (Severity=Info) documents the certificate authorities of the current
+	// rotation phase being distributed to the control plane.
+	DistributingCertificateAuthoritiesReason = "DistributingCertificateAuthorities"
+
+	// CertificateAuthoritiesDistributionFailedReason (Severity=Warning) documents a failure in distributing the
+	// certificate authorities of the current rotation phase to the control plane; the distribution is retried.
+	CertificateAuthoritiesDistributionFailedReason = "CertificateAuthoritiesDistributionFailed"
+
+	// RollingOutMachinesReason (Severity=Info) documents machines being rolled out to pick up the certificate
+	// authorities of the current rotation phase.
+	RollingOutMachinesReason = "RollingOutMachines"
+
+	// WaitingForWorkerMachinesReason (Severity=Warning) documents worker machines which have to be rolled out
+	// before the rotation can proceed.
+	WaitingForWorkerMachinesReason = "WaitingForWorkerMachines"
+)
+
+const (
+	// ServerTokenRotatedCondition documents the progress of the rotation of the server token of the RKE2ControlPlane.
+	// It is only set once a rotation is requested.
+	ServerTokenRotatedCondition clusterv1.ConditionType = "ServerTokenRotated"
+
+	// RotatingServerTokenReason (Severity=Info) documents the bootstrap data stored in the datastore of the control
+	// plane being encrypted with the new token.
+	RotatingServerTokenReason = "RotatingServerToken"
+
+	// ServerTokenRotationFailedReason (Severity=Warning) documents a failure in encrypting the bootstrap data stored in
+	// the datastore of the control plane with the new token; the rotation is retried.
+	ServerTokenRotationFailedReason = "ServerTokenRotationFailed"
+
+	// ServerTokenRotationNotSupportedReason (Severity=Warning) documents a rotation requested for a server token read
+	// from the Secret referenced by the TokenSecretRef, which is not rotated.
+	ServerTokenRotationNotSupportedReason = "ServerTokenRotationNotSupported"
+
+	// UpdatingNodesTokenReason (Severity=Info) documents the token being updated in the configuration of the nodes.
+	UpdatingNodesTokenReason = "UpdatingNodesToken"
+
+	// NodeTokenUpdateFailedReason (Severity=Warning) documents a failure in updating the token in the configuration
+	// of a node; the update is retried.
+	NodeTokenUpdateFailedReason = "NodeTokenUpdateFailed"
+)
+
+const (
+	// InitLockReleasedCondition documents the init lock held by the control plane machine initializing the cluster,
+	// which is released once the control plane is initialized.
+	InitLockReleasedCondition clusterv1.ConditionType = "InitLockReleased"
+
+	// WaitingForInitLockReason (Severity=Info) documents no control plane machine holding the init lock yet.
+	WaitingForInitLockReason = "WaitingForInitLock"
+
+	// InitLockHeldReason (Severity=Info) documents a control plane machine holding the init lock while it initializes
+	// the cluster.
+	InitLockHeldReason = "InitLockHeld"
+
+	// InitLockStaleReason (Severity=Warning) documents the control plane machine holding the init lock showing no
+	// progress, having no node once the init timeout is reached; the machine is deleted, and the lock is taken over by
+	// the machine replacing it.
+	InitLockStaleReason = "InitLockStale"
+)
+
+const (
+	// FinalEtcdSnapshotCreatedCondition documents the final etcd snapshot taken before the machines of the
+	// RKE2ControlPlane are deleted. It is only set while the RKE2ControlPlane is deleted with the Snapshot
+	// DeletionPolicy.
+	FinalEtcdSnapshotCreatedCondition clusterv1.ConditionType = "FinalEtcdSnapshotCreated"
+
+	// TakingFinalEtcdSnapshotReason (Severity=Info) documents the final etcd snapshot being taken.
+	TakingFinalEtcdSnapshotReason = "TakingFinalEtcdSnapshot"
+
+	// FinalEtcdSnapshotFailedReason (Severity=Error) documents a failure in taking the final etcd snapshot; the
+	// machines are not deleted until the failed RKE2EtcdSnapshot is deleted to retry, or the DeletionPolicy is
+	// changed to Delete.
+	FinalEtcdSnapshotFailedReason = "FinalEtcdSnapshotFailed"
+)
+
+const (
+	// WorkloadCleanedUpCondition documents the deletion of the resources of the workload cluster backed by cloud
+	// resources before the machines of the RKE2ControlPlane are deleted. It is only set while the RKE2ControlPlane
+	// is deleted with a WorkloadCleanup.
+	WorkloadCleanedUpCondition clusterv1.ConditionType = "WorkloadCleanedUp"
+
+	// CleaningUpWorkloadReason (Severity=Info) documents resources of the workload cluster being deleted, and waited
+	// for until their cloud resources are released.
+	CleaningUpWorkloadReason = "CleaningUpWorkload"
+
+	// WorkloadCleanupTimedOutReason (Severity=Warning) documents resources of the workload cluster which were not
+	// deleted within the timeout of the WorkloadCleanup; the machines are deleted anyway.
+	WorkloadCleanupTimedOutReason = "WorkloadCleanupTimedOut"
+)
+
+// Conditions and condition Reasons for the RKE2EtcdRestore object.
+
+const (
+	// ControlPlaneScaledDownCondition documents that all the control plane machines but the one the snapshot
+	// is restored on have been deleted.
+	ControlPlaneScaledDownCondition clusterv1.ConditionType = "ControlPlaneScaledDown"
+
+	// WaitingForControlPlaneReason (Severity=Info) documents a RKE2EtcdRestore waiting for the RKE2ControlPlane
+	// to be initialized, or for another restore of the same RKE2ControlPlane to complete.
+	WaitingForControlPlaneReason = "WaitingForControlPlane"
+
+	// MachineNotFoundReason (Severity=Error) documents a RKE2EtcdRestore that can't find the machine to restore the snapshot on.
+	MachineNotFoundReason = "MachineNotFound"
+
+	// EtcdRestoredCondition documents that the etcd snapshot has been restored.
+	EtcdRestoredCondition clusterv1.ConditionType = "EtcdRestored"
+
+	// EtcdRestoreInProgressReason (Severity=Info) documents a RKE2EtcdRestore running the restore on the control plane node.
+	EtcdRestoreInProgressReason = "EtcdRestoreInProgress"
+
+	// EtcdRestoreFailedReason (Severity=Error) documents a failure in restoring the etcd snapshot.
+	EtcdRestoreFailedReason = "EtcdRestoreFailed"
+
+	// MachinesRejoinedCondition documents that the RKE2ControlPlane has been scaled back to the desired replicas
+	// after the snapshot was restored.
+	MachinesRejoinedCondition clusterv1.ConditionType = "MachinesRejoined"
+
+	// WaitingForMachinesReason (Severity=Info) documents a RKE2EtcdRestore waiting for the control plane machines.
+	WaitingForMachinesReason = "WaitingForMachines"
+)
+
+// Conditions and condition Reasons for the RKE2EtcdSnapshot object.
+
+const (
+	// EtcdSnapshotCreatedCondition documents that the on-demand etcd snapshot has been taken.
+	EtcdSnapshotCreatedCondition clusterv1.ConditionType = "EtcdSnapshotCreated"
+
+	// EtcdSnapshotInProgressReason (Severity=Info) documents a RKE2EtcdSnapshot running the snapshot on the control plane node.
+	EtcdSnapshotInProgressReason = "EtcdSnapshotInProgress"
+
+	// EtcdSnapshotFailedReason (Severity=Error) documents a failure in taking the etcd snapshot.
+	EtcdSnapshotFailedReason = "EtcdSnapshotFailed"
+)
+
+const (
+	// TokenSecretAvailableCondition documents that the Secret referenced by the TokenSecretRef holds a valid server
+	// token. It is only set when a TokenSecretRef is defined.
+	TokenSecretAvailableCondition clusterv1.ConditionType = "TokenSecretAvailable"
+
+	// TokenSecretInvalidReason (Severity=Warning) documents a Secret referenced by the TokenSecretRef which is missing,
+	// or doesn't hold a valid server token; no machine is created or rolled out until it is fixed.
+	TokenSecretInvalidReason = "TokenSecretInvalid"
+)
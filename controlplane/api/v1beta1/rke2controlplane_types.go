@@ -17,7 +17,10 @@ limitations under the License.
 package v1beta1
 
 import (
+	"time"
+
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
@@ -40,6 +43,51 @@ const (
 	// LegacyRKE2ControlPlane is a controlplane annotation that marks the CP as legacy. This CP will not provide
 	// etcd certificate management or etcd membership management.
 	LegacyRKE2ControlPlane = "controlplane.cluster.x-k8s.io/legacy"
+
+	// RemediationInProgressAnnotation is used to keep track that a RCP remediation is in progress, and more
+	// specifically it tracks that the system is in between having deleted an unhealthy machine and recreating its replacement.
+	// NOTE: if something external to CAPI removes this annotation the system cannot detect the above situation; this can lead to
+	// failures in updating remediation retry or remediation count (both counters restart from zero).
+	RemediationInProgressAnnotation = "controlplane.cluster.x-k8s.io/remediation-in-progress"
+
+	// RemediationForAnnotation is used to link a new machine to the unhealthy machine it is replacing;
+	// please note that in case of retry, when also the remediating machine fails, the system keeps track of
+	// the first machine of the sequence only.
+	// NOTE: if something external to CAPI removes this annotation the system this can lead to
+	// failures in updating remediation retry (the counter restarts from zero).
+	RemediationForAnnotation = "controlplane.cluster.x-k8s.io/remediation-for"
+
+	// RotateCertificateAuthoritiesAnnotation triggers the rotation of the certificate authorities of a RKE2ControlPlane.
+	// A new rotation is started each time the value of the annotation changes, once the previous rotation is completed.
+	RotateCertificateAuthoritiesAnnotation = "controlplane.cluster.x-k8s.io/rotate-certificate-authorities"
+
+	// RotateServerTokenAnnotation triggers the rotation of the server token of a RKE2ControlPlane. A new rotation is
+	// started each time the value of the annotation changes, once the previous rotation is completed. It is ignored
+	// when the server token is read from the Secret referenced by the TokenSecretRef.
+	RotateServerTokenAnnotation = "controlplane.cluster.x-k8s.io/rotate-server-token"
+
+	// SkipVersionSkewValidationAnnotation disables the validation rejecting downgrades and updates skipping a Kubernetes
+	// minor version when the version of a RKE2ControlPlane changes. It is meant for deliberate exceptions only, as the
+	// skew between the versions of the control plane components is not supported by Kubernetes.
+	SkipVersionSkewValidationAnnotation = "controlplane.cluster.x-k8s.io/skip-version-skew-validation"
+
+	// RolloutDryRunAnnotation enables the dry-run mode of a RKE2ControlPlane: before its machines are rolled out or
+	// upgraded in place, the plan of the rollout is reported in status.rolloutPlan, and the rollout waits for the plan
+	// to be approved with the ApproveRolloutPlanAnnotation.
+	RolloutDryRunAnnotation = "controlplane.cluster.x-k8s.io/rollout-dry-run"
+
+	// ApproveRolloutPlanAnnotation approves the rollout plan of a RKE2ControlPlane in dry-run mode. Its value is the
+	// identifier of the approved plan, as reported in status.rolloutPlan.id, so that a plan rolling out other machines,
+	// or rolling them out for other reasons, requires a new approval.
+	ApproveRolloutPlanAnnotation = "controlplane.cluster.x-k8s.io/approve-rollout-plan"
+
+	// ServerRoleLabel is the label of the control plane machines of a server pool, set to the ServerRole they run.
+	// Control plane machines without this label run all the server roles.
+	ServerRoleLabel = "controlplane.cluster.x-k8s.io/server-role"
+
+	// DefaultMinHealthyPeriod defines the default minimum period before we consider a remediation on a
+	// machine unrelated from the previous remediation.
+	DefaultMinHealthyPeriod = 1 * time.Hour
 )
 
 // RKE2ControlPlaneSpec defines the desired state of RKE2ControlPlane.
@@ -67,9 +115,18 @@ type RKE2ControlPlaneSpec struct {
 
 	// ManifestsConfigMapReference references a ConfigMap which contains Kubernetes manifests to be deployed automatically on the cluster
 	// Each data entry in the ConfigMap will be will be copied to a folder on the control plane nodes that RKE2 scans and uses to deploy manifests.
+	// A Secret can be referenced instead, by setting the kind of the reference to Secret. The reference must be in the
+	// namespace of the RKE2ControlPlane.
 	//+optional
 	ManifestsConfigMapReference corev1.ObjectReference `json:"manifestsConfigMapReference,omitempty"`
 
+	// ManifestsStrategy is the strategy used to deploy the manifests of the ManifestsConfigMapReference and of the addons.
+	// Defaults to Bootstrap, which copies them on the control plane nodes when they are bootstrapped.
+	// Sync applies them continuously to the workload cluster instead, and prunes the resources removed from them.
+	// +kubebuilder:validation:Enum=Bootstrap;Sync
+	//+optional
+	ManifestsStrategy ManifestsStrategyType `json:"manifestsStrategy,omitempty"`
+
 	// InfrastructureRef is a required reference to a custom resource
 	// offered by an infrastructure provider.
 	// This field is deprecated. Use `.machineTemplate.infrastructureRef` instead.
@@ -95,8 +152,59 @@ type RKE2ControlPlaneSpec struct {
 	// +optional
 	RegistrationAddress string `json:"registrationAddress,omitempty"`
 
+	// TokenSecretRef references a Secret, in the namespace of the RKE2ControlPlane, holding the server token in its
+	// value key. The nodes join the cluster with this token instead of the one generated by the bootstrap provider in
+	// the <cluster>-token Secret, which allows adopting an existing RKE2 cluster, or keeping its token when the control
+	// plane is moved to another management cluster. It is immutable.
+	// +optional
+	TokenSecretRef *corev1.LocalObjectReference `json:"tokenSecretRef,omitempty"`
+
 	// The RolloutStrategy to use to replace control plane machines with new ones.
 	RolloutStrategy *RolloutStrategy `json:"rolloutStrategy"`
+
+	// RolloutBefore is a field to indicate a rollout should be performed
+	// if the specified criteria is met.
+	// +optional
+	RolloutBefore *RolloutBefore `json:"rolloutBefore,omitempty"`
+
+	// RolloutAfter is a field to indicate a rollout should be performed
+	// after the specified time even if no changes have been made to the
+	// RKE2ControlPlane. Machines created before this time are replaced.
+	// +optional
+	RolloutAfter *metav1.Time `json:"rolloutAfter,omitempty"`
+
+	// EtcdDefragmentation enables the online defragmentation of the etcd members, one at a time and the leader last,
+	// when their database is fragmented.
+	// +optional
+	EtcdDefragmentation *EtcdDefragmentation `json:"etcdDefragmentation,omitempty"`
+
+	// ServerPools defines dedicated groups of control plane machines running a subset of the server roles,
+	// managed in addition to the Replicas machines which run all the server roles.
+	// +listType=map
+	// +listMapKey=role
+	// +optional
+	ServerPools []ServerPool `json:"serverPools,omitempty"`
+
+	// RemediationStrategy is the RemediationStrategy that controls how control plane machine remediation happens.
+	// +optional
+	RemediationStrategy *RemediationStrategy `json:"remediationStrategy,omitempty"`
+
+	// DeletionPolicy defines what happens to the etcd data of the control plane when the RKE2ControlPlane is deleted.
+	// Defaults to Delete, which deletes the machines right away. Snapshot takes a final etcd snapshot, uploaded to the
+	// S3 target of the etcd backup configuration, and waits for it to complete before deleting the machines.
+	// +kubebuilder:validation:Enum=Delete;Snapshot
+	// +optional
+	DeletionPolicy DeletionPolicyType `json:"deletionPolicy,omitempty"`
+
+	// WorkloadCleanup deletes the resources of the workload cluster backed by cloud resources, which would leak
+	// otherwise, before the machines are deleted when the RKE2ControlPlane is deleted.
+	// +optional
+	WorkloadCleanup *WorkloadCleanup `json:"workloadCleanup,omitempty"`
+
+	// HostCommandImage is the image of the privileged pods running commands on the control plane nodes, e.g. to rotate
+	// the certificate authorities. It must provide a shell and nsenter. Defaults to docker.io/library/busybox.
+	// +optional
+	HostCommandImage string `json:"hostCommandImage,omitempty"`
 }
 
 // RKE2ControlPlaneMachineTemplate defines the template for Machines
@@ -194,6 +302,87 @@ type RKE2ServerConfig struct {
 	// The config map must contain a key named cloud-config.
 	//+optional
 	CloudProviderConfigMap *corev1.ObjectReference `json:"cloudProviderConfigMap,omitempty"`
+
+	// Addons customizes the charts packaged with RKE2 and deploys additional charts. Their manifests are deployed
+	// with the ManifestsStrategy of the RKE2ControlPlane, like the manifests of the ManifestsConfigMapReference.
+	//+optional
+	Addons *Addons `json:"addons,omitempty"`
+}
+
+// Addons describes the HelmChartConfigs and HelmCharts deployed in the workload cluster.
+type Addons struct {
+	// Charts customizes the values of the charts packaged with RKE2, e.g. rke2-coredns or rke2-canal,
+	// with HelmChartConfigs.
+	//+optional
+	//+listType=map
+	//+listMapKey=name
+	Charts []ChartConfig `json:"charts,omitempty"`
+
+	// HelmCharts lists the additional charts to deploy with HelmCharts.
+	//+optional
+	//+listType=map
+	//+listMapKey=name
+	HelmCharts []HelmChart `json:"helmCharts,omitempty"`
+}
+
+// ChartConfig describes the values of a chart packaged with RKE2.
+type ChartConfig struct {
+	// Name of the chart packaged with RKE2, e.g. rke2-coredns.
+	Name string `json:"name"`
+
+	// ValuesContent is the YAML content of the values merged with the default values of the chart.
+	//+optional
+	ValuesContent string `json:"valuesContent,omitempty"`
+
+	// ValuesFrom references the YAML content of the values, instead of ValuesContent.
+	//+optional
+	ValuesFrom *AddonValuesSource `json:"valuesFrom,omitempty"`
+}
+
+// HelmChart describes an additional chart deployed by the helm controller of RKE2.
+type HelmChart struct {
+	// Name of the HelmChart, created in the kube-system namespace.
+	// The rke2- prefix is reserved to the charts packaged with RKE2.
+	Name string `json:"name"`
+
+	// Chart is the name of the chart in the repository, or the URL of the chart archive.
+	Chart string `json:"chart"`
+
+	// Repo is the URL of the repository of the chart.
+	//+optional
+	Repo string `json:"repo,omitempty"`
+
+	// Version of the chart.
+	//+optional
+	Version string `json:"version,omitempty"`
+
+	// TargetNamespace is the namespace the chart is installed in (default: default).
+	//+optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// CreateNamespace creates the target namespace when it does not exist.
+	//+optional
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+
+	// ValuesContent is the YAML content of the values of the chart.
+	//+optional
+	ValuesContent string `json:"valuesContent,omitempty"`
+
+	// ValuesFrom references the YAML content of the values, instead of ValuesContent.
+	//+optional
+	ValuesFrom *AddonValuesSource `json:"valuesFrom,omitempty"`
+}
+
+// AddonValuesSource references the values of a chart in a key of a Secret or a ConfigMap in the namespace of the
+// RKE2ControlPlane. Exactly one of them must be set.
+type AddonValuesSource struct {
+	// SecretKeyRef selects a key of a Secret.
+	//+optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+
+	// ConfigMapKeyRef selects a key of a ConfigMap.
+	//+optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
 }
 
 // RKE2ControlPlaneStatus defines the observed state of RKE2ControlPlane.
@@ -244,6 +433,357 @@ type RKE2ControlPlaneStatus struct {
 	// AvailableServerIPs is a list of the Control Plane IP adds that can be used to register further nodes.
 	// +optional
 	AvailableServerIPs []string `json:"availableServerIPs,omitempty"`
+
+	// LastRemediation stores info about last remediation performed.
+	// +optional
+	LastRemediation *LastRemediationStatus `json:"lastRemediation,omitempty"`
+
+	// EtcdSnapshots lists the etcd snapshots available for the control plane, newest first,
+	// as reported by the ETCDSnapshotFile objects of the workload cluster.
+	// +optional
+	EtcdSnapshots []EtcdSnapshotInfo `json:"etcdSnapshots,omitempty"`
+
+	// CertificateAuthorityRotation reports the progress of the last rotation of the certificate authorities.
+	// +optional
+	CertificateAuthorityRotation *CertificateAuthorityRotationStatus `json:"certificateAuthorityRotation,omitempty"`
+
+	// ServerTokenRotation reports the progress of the last rotation of the server token.
+	// +optional
+	ServerTokenRotation *ServerTokenRotationStatus `json:"serverTokenRotation,omitempty"`
+
+	// RolloutAfterCompletedTime is the time when the rollout requested by RolloutAfter was completed.
+	// +optional
+	RolloutAfterCompletedTime *metav1.Time `json:"rolloutAfterCompletedTime,omitempty"`
+
+	// EtcdMembers reports the status of the etcd members of the control plane, as seen during the last reconciliation.
+	// +optional
+	EtcdMembers []EtcdMemberStatus `json:"etcdMembers,omitempty"`
+
+	// ServerPools reports the machines of the server pools. The machines of the server pools are not counted
+	// in Replicas, ReadyReplicas, UpdatedReplicas and UnavailableReplicas.
+	// +optional
+	ServerPools []ServerPoolStatus `json:"serverPools,omitempty"`
+
+	// RolloutPlan reports the plan of the pending or ongoing rollout of the machines, when the RolloutDryRunAnnotation
+	// is set.
+	// +optional
+	RolloutPlan *RolloutPlan `json:"rolloutPlan,omitempty"`
+
+	// AppliedManifests lists the resources applied to the workload cluster from the manifests of the
+	// ManifestsConfigMapReference, when the ManifestsStrategy is Sync.
+	// +optional
+	AppliedManifests []ManifestReference `json:"appliedManifests,omitempty"`
+
+	// AppliedAddons lists the HelmChartConfigs and HelmCharts of the addons applied to the workload cluster, when the
+	// ManifestsStrategy is Sync.
+	// +optional
+	AppliedAddons []ManifestReference `json:"appliedAddons,omitempty"`
+}
+
+// ManifestsStrategyType is the strategy used to deploy the manifests of a RKE2ControlPlane.
+type ManifestsStrategyType string
+
+const (
+	// ManifestsStrategyBootstrap copies the manifests in the manifests directory of the control plane nodes
+	// when they are bootstrapped, from which they are deployed by RKE2.
+	ManifestsStrategyBootstrap ManifestsStrategyType = "Bootstrap"
+
+	// ManifestsStrategySync applies the manifests to the workload cluster on every reconciliation, and prunes
+	// the resources which were removed from them.
+	ManifestsStrategySync ManifestsStrategyType = "Sync"
+)
+
+// DeletionPolicyType defines what happens to the etcd data of a RKE2ControlPlane when it is deleted.
+type DeletionPolicyType string
+
+const (
+	// DeletionPolicyDelete deletes the control plane machines, along with the etcd data, without a final snapshot.
+	DeletionPolicyDelete DeletionPolicyType = "Delete"
+
+	// DeletionPolicySnapshot takes a final etcd snapshot to S3 before deleting the control plane machines.
+	DeletionPolicySnapshot DeletionPolicyType = "Snapshot"
+)
+
+// WorkloadCleanupResource is a kind of resource of the workload cluster deleted before the machines of a
+// RKE2ControlPlane are deleted.
+// +kubebuilder:validation:Enum=LoadBalancerServices;PersistentVolumeClaims
+type WorkloadCleanupResource string
+
+const (
+	// WorkloadCleanupLoadBalancerServices deletes the Services of type LoadBalancer, so that the cloud controller
+	// manager releases their load balancers.
+	WorkloadCleanupLoadBalancerServices WorkloadCleanupResource = "LoadBalancerServices"
+
+	// WorkloadCleanupPersistentVolumeClaims deletes the PersistentVolumeClaims, so that the dynamically provisioned
+	// PersistentVolumes with the Delete reclaim policy are released by their provisioner.
+	WorkloadCleanupPersistentVolumeClaims WorkloadCleanupResource = "PersistentVolumeClaims"
+)
+
+// WorkloadCleanup defines the resources of the workload cluster deleted before the machines of a RKE2ControlPlane are
+// deleted.
+type WorkloadCleanup struct {
+	// Resources are the kinds of resources to delete. Defaults to LoadBalancerServices and PersistentVolumeClaims.
+	// +listType=set
+	// +optional
+	Resources []WorkloadCleanupResource `json:"resources,omitempty"`
+
+	// Namespaces restricts the cleanup to the resources of the given namespaces. Defaults to all the namespaces.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Selector restricts the cleanup to the resources matching the label selector. Defaults to all the resources.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Timeout is how long to wait for the resources to be released before deleting the machines anyway.
+	// Defaults to 10 minutes.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// ManifestReference references a resource applied to the workload cluster from the manifests of a RKE2ControlPlane.
+type ManifestReference struct {
+	// APIVersion is the API version of the resource.
+	APIVersion string `json:"apiVersion"`
+
+	// Kind is the kind of the resource.
+	Kind string `json:"kind"`
+
+	// Namespace is the namespace of the resource, empty for cluster-scoped resources.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name is the name of the resource.
+	Name string `json:"name"`
+}
+
+// RolloutPlan is the plan of a rollout of the machines of a RKE2ControlPlane, computed in dry-run mode.
+type RolloutPlan struct {
+	// ID identifies the machines rolled out by the plan, and the reasons they are rolled out for. The rollout starts
+	// once the ApproveRolloutPlanAnnotation is set to this ID.
+	ID string `json:"id"`
+
+	// Generation is the generation of the RKE2ControlPlane the plan was computed for.
+	Generation int64 `json:"generation"`
+
+	// Approved indicates if the plan has been approved.
+	// +optional
+	Approved bool `json:"approved,omitempty"`
+
+	// Steps lists the machines to roll out, in the order they are expected to be rolled out. The steps are computed
+	// again at each reconciliation, so that they only list the machines remaining to be rolled out: an approved plan
+	// keeps its ID and remains approved as long as its remaining steps were part of it.
+	// +optional
+	Steps []RolloutPlanStep `json:"steps,omitempty"`
+}
+
+// RolloutPlanAction is the action taken on a machine by a step of a rollout plan.
+type RolloutPlanAction string
+
+const (
+	// RolloutPlanActionReplace replaces the machine with a new machine.
+	RolloutPlanActionReplace RolloutPlanAction = "Replace"
+
+	// RolloutPlanActionUpgradeInPlace upgrades the RKE2 version of the machine in place.
+	RolloutPlanActionUpgradeInPlace RolloutPlanAction = "UpgradeInPlace"
+)
+
+// RolloutPlanStep is a step of a rollout plan.
+type RolloutPlanStep struct {
+	// Machine is the name of the machine rolled out.
+	Machine string `json:"machine"`
+
+	// Action is the action taken on the machine.
+	Action RolloutPlanAction `json:"action"`
+
+	// Role is the server role of the machine, empty for the machines running all the server roles.
+	// +optional
+	Role ServerRole `json:"role,omitempty"`
+
+	// FailureDomain is the failure domain of the machine.
+	// +optional
+	FailureDomain string `json:"failureDomain,omitempty"`
+
+	// ReplacementFailureDomain is the failure domain the machine replacing it is expected to be created in.
+	// +optional
+	ReplacementFailureDomain string `json:"replacementFailureDomain,omitempty"`
+
+	// Reasons lists the reasons why the machine is rolled out.
+	// +optional
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// ServerPoolStatus reports the machines of a server pool.
+type ServerPoolStatus struct {
+	// Role is the server role run by the machines of the pool.
+	Role ServerRole `json:"role"`
+
+	// Replicas is the number of machines of the pool.
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// ReadyReplicas is the number of ready machines of the pool.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// UpdatedReplicas is the number of machines of the pool which are up to date with the desired configuration.
+	// +optional
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+}
+
+// EtcdMemberStatus reports the status of an etcd member of the control plane.
+type EtcdMemberStatus struct {
+	// Name is the name of the etcd member.
+	Name string `json:"name"`
+
+	// MachineName is the name of the machine hosting the etcd member.
+	// +optional
+	MachineName string `json:"machineName,omitempty"`
+
+	// DBSize is the size of the database of the etcd member, including free space.
+	// +optional
+	DBSize *resource.Quantity `json:"dbSize,omitempty"`
+
+	// DBSizeInUse is the size of the database of the etcd member actually in use.
+	// +optional
+	DBSizeInUse *resource.Quantity `json:"dbSizeInUse,omitempty"`
+
+	// Alarms lists the alarms raised by the etcd member, e.g. NOSPACE.
+	// +optional
+	Alarms []string `json:"alarms,omitempty"`
+
+	// Leader indicates if the etcd member is the leader of the cluster.
+	// +optional
+	Leader bool `json:"leader,omitempty"`
+
+	// Learner indicates if the etcd member is a raft learner, not yet promoted to a voting member.
+	// +optional
+	Learner bool `json:"learner,omitempty"`
+
+	// RaftTerm is the current raft term of the etcd member.
+	// +optional
+	RaftTerm int64 `json:"raftTerm,omitempty"`
+
+	// RaftAppliedIndex is the index of the last raft entry applied by the etcd member.
+	// +optional
+	RaftAppliedIndex int64 `json:"raftAppliedIndex,omitempty"`
+
+	// AppliedIndexLag is the number of raft entries applied by the leader and not yet applied by the etcd member.
+	// It is not set when the status of the leader is not known.
+	// +optional
+	AppliedIndexLag *int64 `json:"appliedIndexLag,omitempty"`
+}
+
+// CertificateAuthorityRotationPhase is a phase of the rotation of the certificate authorities.
+type CertificateAuthorityRotationPhase string
+
+const (
+	// CertificateAuthorityRotationPhaseTrustingNewCA is the phase where the nodes are made to trust both the old and new
+	// certificate authorities, while certificates are still signed by the old ones.
+	CertificateAuthorityRotationPhaseTrustingNewCA CertificateAuthorityRotationPhase = "TrustingNewCA"
+
+	// CertificateAuthorityRotationPhaseSigningWithNewCA is the phase where certificates are signed by the new certificate
+	// authorities, while nodes still trust the old ones.
+	CertificateAuthorityRotationPhaseSigningWithNewCA CertificateAuthorityRotationPhase = "SigningWithNewCA"
+
+	// CertificateAuthorityRotationPhaseDroppingOldCA is the phase where the old certificate authorities are removed.
+	CertificateAuthorityRotationPhaseDroppingOldCA CertificateAuthorityRotationPhase = "DroppingOldCA"
+
+	// CertificateAuthorityRotationPhaseCompleted is the phase of a completed rotation.
+	CertificateAuthorityRotationPhaseCompleted CertificateAuthorityRotationPhase = "Completed"
+)
+
+// CertificateAuthorityRotationStatus reports the progress of a rotation of the certificate authorities.
+type CertificateAuthorityRotationStatus struct {
+	// ID is the value of the rotate-certificate-authorities annotation which triggered the rotation.
+	ID string `json:"id"`
+
+	// Phase is the current phase of the rotation.
+	Phase CertificateAuthorityRotationPhase `json:"phase"`
+
+	// DistributedTime is the time the certificate authorities of the current phase were distributed to the
+	// control plane. Machines created before this time are rolled out.
+	// +optional
+	DistributedTime *metav1.Time `json:"distributedTime,omitempty"`
+}
+
+// ServerTokenRotationPhase is a phase of the rotation of the server token.
+type ServerTokenRotationPhase string
+
+const (
+	// ServerTokenRotationPhaseRotatingToken is the phase where the bootstrap data stored in the datastore of the control
+	// plane is encrypted with the new token.
+	ServerTokenRotationPhaseRotatingToken ServerTokenRotationPhase = "RotatingToken"
+
+	// ServerTokenRotationPhaseUpdatingServers is the phase where the token is updated in the configuration of the
+	// control plane nodes, which are restarted one at a time.
+	ServerTokenRotationPhaseUpdatingServers ServerTokenRotationPhase = "UpdatingServers"
+
+	// ServerTokenRotationPhaseUpdatingAgents is the phase where the token is updated in the configuration of the
+	// worker nodes, used when they restart.
+	ServerTokenRotationPhaseUpdatingAgents ServerTokenRotationPhase = "UpdatingAgents"
+
+	// ServerTokenRotationPhaseCompleted is the phase of a completed rotation.
+	ServerTokenRotationPhaseCompleted ServerTokenRotationPhase = "Completed"
+)
+
+// ServerTokenRotationStatus reports the progress of a rotation of the server token.
+type ServerTokenRotationStatus struct {
+	// ID is the value of the rotate-server-token annotation which triggered the rotation.
+	ID string `json:"id"`
+
+	// Phase is the current phase of the rotation.
+	Phase ServerTokenRotationPhase `json:"phase"`
+
+	// RotatedTime is the time the token was rotated in the datastore of the control plane. Machines created after this
+	// time are bootstrapped with the new token.
+	// +optional
+	RotatedTime *metav1.Time `json:"rotatedTime,omitempty"`
+
+	// UpdatedMachines lists the machines whose node was updated to the new token in the current phase.
+	// +optional
+	UpdatedMachines []string `json:"updatedMachines,omitempty"`
+}
+
+// EtcdSnapshotInfo describes an etcd snapshot available for the control plane.
+type EtcdSnapshotInfo struct {
+	// Name is the name of the snapshot, which can be used to restore it with a RKE2EtcdRestore.
+	Name string `json:"name"`
+
+	// NodeName is the name of the node which took the snapshot.
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// Source is where the snapshot is stored.
+	// +optional
+	Source EtcdSnapshotSource `json:"source,omitempty"`
+
+	// Location is the URI of the snapshot, i.e. the file path on the node, or the S3 object.
+	// +optional
+	Location string `json:"location,omitempty"`
+
+	// Size is the size of the snapshot.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// CreationTime is the time the snapshot was taken.
+	// +optional
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+}
+
+// LastRemediationStatus stores info about last remediation performed.
+// NOTE: if for any reason information about last remediation are lost, RetryCount is going to restart from 0 and thus
+// more remediations than expected might happen.
+type LastRemediationStatus struct {
+	// Machine is the machine name of the latest machine being remediated.
+	Machine string `json:"machine"`
+
+	// Timestamp is when last remediation happened. It is represented in RFC3339 form and is in UTC.
+	Timestamp metav1.Time `json:"timestamp"`
+
+	// RetryCount used to keep track of remediation retry for the last remediated machine.
+	// A retry happens when a machine that was created as a replacement for an unhealthy machine also fails.
+	RetryCount int32 `json:"retryCount"`
 }
 
 // +kubebuilder:object:root=true
@@ -398,14 +938,40 @@ const (
 // RolloutStrategy describes how to replace existing machines
 // with new ones.
 type RolloutStrategy struct {
-	// Type of rollout. Currently the only supported strategy is "RollingUpdate".
+	// Type of rollout. Supported strategies are "RollingUpdate", "ScaleDownFirst" and "InPlaceUpgrade".
 	// Default is RollingUpdate.
+	// +kubebuilder:validation:Enum=RollingUpdate;ScaleDownFirst;InPlaceUpgrade
 	// +optional
 	Type RolloutStrategyType `json:"type,omitempty"`
 
-	// Rolling update config params. Present only if RolloutStrategyType = RollingUpdate.
+	// Rolling update config params. Present only if RolloutStrategyType = RollingUpdate or InPlaceUpgrade.
 	// +optional
 	RollingUpdate *RollingUpdate `json:"rollingUpdate,omitempty"`
+
+	// InPlaceUpgrade config params. Used only if RolloutStrategyType = InPlaceUpgrade.
+	// +optional
+	InPlaceUpgrade *InPlaceUpgrade `json:"inPlaceUpgrade,omitempty"`
+}
+
+// InPlaceUpgrade is used to control how RKE2 version upgrades are performed in place by the
+// system-upgrade-controller running in the workload cluster.
+type InPlaceUpgrade struct {
+	// Image is the image used by the system-upgrade-controller to upgrade RKE2 on the nodes.
+	// Defaults to rancher/rke2-upgrade.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Namespace is the namespace in the workload cluster where the system-upgrade-controller is running
+	// and where the upgrade Plan is created.
+	// Defaults to system-upgrade.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// ServiceAccountName is the name of the service account used by the system-upgrade-controller
+	// to run upgrade jobs.
+	// Defaults to system-upgrade.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
 }
 
 // RollingUpdate is used to control the desired behavior of rolling update.
@@ -420,6 +986,96 @@ type RollingUpdate struct {
 	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
 }
 
+// RolloutBefore describes when a rollout should be performed on the control plane machines.
+type RolloutBefore struct {
+	// CertificatesExpiryDays indicates a rollout needs to be performed if the
+	// certificates of the control plane will expire within the specified days.
+	// +kubebuilder:validation:Minimum=7
+	// +optional
+	CertificatesExpiryDays *int32 `json:"certificatesExpiryDays,omitempty"`
+}
+
+// EtcdDefragmentation configures the online defragmentation of the etcd members.
+type EtcdDefragmentation struct {
+	// FragmentationThreshold is the percentage of the database size of an etcd member which must be free space
+	// for the member to be defragmented. Defaults to 50.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	// +optional
+	FragmentationThreshold *int32 `json:"fragmentationThreshold,omitempty"`
+}
+
+// ServerRole is a subset of the RKE2 server roles run by the machines of a server pool.
+// +kubebuilder:validation:Enum=etcd;control-plane
+type ServerRole string
+
+const (
+	// ServerRoleEtcd runs etcd only, without the kube-apiserver, kube-controller-manager and kube-scheduler.
+	ServerRoleEtcd ServerRole = "etcd"
+
+	// ServerRoleControlPlane runs the kube-apiserver, kube-controller-manager and kube-scheduler, without etcd.
+	ServerRoleControlPlane ServerRole = "control-plane"
+)
+
+// ServerPool defines a group of control plane machines running the same server role.
+type ServerPool struct {
+	// Role is the server role run by the machines of the pool.
+	Role ServerRole `json:"role"`
+
+	// Replicas is the number of machines of the pool.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// InfrastructureRef is a reference to the infrastructure template used to create the machines of the pool.
+	InfrastructureRef corev1.ObjectReference `json:"infrastructureRef"`
+}
+
+// RemediationStrategy allows to define how control plane machine remediation happens.
+type RemediationStrategy struct {
+	// MaxRetry is the Max number of retries while attempting to remediate an unhealthy machine.
+	// A retry happens when a machine that was created as a replacement for an unhealthy machine also fails.
+	// For example, given a control plane with three machines M1, M2, M3:
+	//
+	//	M1 become unhealthy; remediation happens, and M1-1 is created as a replacement.
+	//	If M1-1 (replacement of M1) has problems while bootstrapping it will become unhealthy, and then be
+	//	remediated; such operation is considered a retry, remediation-retry #1.
+	//	If M1-2 (replacement of M1-1) becomes unhealthy, remediation-retry #2 will happen, etc.
+	//
+	// A retry could happen only after RetryPeriod from the previous retry.
+	// If a machine is marked as unhealthy after MinHealthyPeriod from the previous remediation expired,
+	// this is not considered a retry anymore because the new issue is assumed unrelated from the previous one.
+	//
+	// If not set, the remedation will be retried infinitely.
+	// +optional
+	MaxRetry *int32 `json:"maxRetry,omitempty"`
+
+	// RetryPeriod is the duration that RCP should wait before remediating a machine being created as a replacement
+	// for an unhealthy machine (a retry).
+	//
+	// If not set, a retry will happen immediately.
+	// +optional
+	RetryPeriod metav1.Duration `json:"retryPeriod,omitempty"`
+
+	// MinHealthyPeriod defines the duration after which RCP will consider any failure to a machine unrelated
+	// from the previous one. In this case the remediation is not considered a retry anymore, and thus the retry
+	// counter restarts from 0. For example, assuming MinHealthyPeriod is set to 1h (default)
+	//
+	//	M1 become unhealthy; remediation happens, and M1-1 is created as a replacement.
+	//	If M1-1 (replacement of M1) has problems within the 1hr after the creation, also
+	//	this machine will be remediated and this operation is considered a retry - a problem related
+	//	to the original issue happened to M1 -.
+	//
+	//	If instead the problem on M1-1 is happening after MinHealthyPeriod expired, e.g. four days after
+	//	m1-1 has been created as a remediation of M1, the problem on M1-1 is considered unrelated to
+	//	the original issue happened to M1.
+	//
+	// If not set, this value is defaulted to 1h.
+	// +optional
+	MinHealthyPeriod *metav1.Duration `json:"minHealthyPeriod,omitempty"`
+}
+
 // RolloutStrategyType defines the rollout strategies for a RKE2ControlPlane.
 type RolloutStrategyType string
 
@@ -428,6 +1084,17 @@ const (
 	// i.e. gradually scale up or down the old control planes and scale up or down the new one.
 	RollingUpdateStrategyType RolloutStrategyType = "RollingUpdate"
 
+	// ScaleDownFirstStrategyType replaces the old control planes by new one without creating any additional machine
+	// i.e. an outdated control plane machine is deleted first, and then its replacement is created.
+	// This strategy is meant for infrastructures without spare capacity, and it is refused for control planes
+	// with less than 3 replicas, as removing a member would leave etcd without quorum.
+	ScaleDownFirstStrategyType RolloutStrategyType = "ScaleDownFirst"
+
+	// InPlaceUpgradeStrategyType upgrades the RKE2 version of the existing control planes in place, using
+	// system-upgrade-controller Plans in the workload cluster, instead of replacing the machines.
+	// Any other configuration change is rolled out like RollingUpdate.
+	InPlaceUpgradeStrategyType RolloutStrategyType = "InPlaceUpgrade"
+
 	// PreTerminateHookCleanupAnnotation is the annotation RKE2 sets on Machines to ensure it can later remove the
 	// etcd member right before Machine termination (i.e. before InfraMachine deletion).
 	// For RKE2 we need wait for all other pre-terminate hooks to finish to
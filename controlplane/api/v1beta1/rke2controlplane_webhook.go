@@ -18,8 +18,13 @@ package v1beta1
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/klog/v2"
@@ -27,8 +32,10 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
 
 	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2version"
 )
 
 // log is for logging in this package.
@@ -74,6 +81,13 @@ func (r *RKE2ControlPlane) ValidateCreate() (admission.Warnings, error) {
 	allErrs = append(allErrs, r.validateCNI()...)
 	allErrs = append(allErrs, r.validateRegistrationMethod()...)
 	allErrs = append(allErrs, r.validateMachineTemplate()...)
+	allErrs = append(allErrs, r.validateServerPools()...)
+	allErrs = append(allErrs, r.validateManifests()...)
+	allErrs = append(allErrs, r.validateAddons()...)
+	allErrs = append(allErrs, r.validateTokenSecretRef()...)
+	allErrs = append(allErrs, r.validateDeletionPolicy()...)
+	allErrs = append(allErrs, r.validateWorkloadCleanup()...)
+	allErrs = append(allErrs, r.validateVersion(nil)...)
 
 	if len(allErrs) == 0 {
 		return nil, nil
@@ -96,6 +110,12 @@ func (r *RKE2ControlPlane) ValidateUpdate(old runtime.Object) (admission.Warning
 	allErrs = append(allErrs, bootstrapv1.ValidateRKE2ConfigSpec(r.Name, &r.Spec.RKE2ConfigSpec)...)
 	allErrs = append(allErrs, r.validateCNI()...)
 	allErrs = append(allErrs, r.validateMachineTemplate()...)
+	allErrs = append(allErrs, r.validateServerPools()...)
+	allErrs = append(allErrs, r.validateManifests()...)
+	allErrs = append(allErrs, r.validateAddons()...)
+	allErrs = append(allErrs, r.validateDeletionPolicy()...)
+	allErrs = append(allErrs, r.validateWorkloadCleanup()...)
+	allErrs = append(allErrs, r.validateVersion(oldControlplane)...)
 
 	oldSet := oldControlplane.Spec.RegistrationMethod != ""
 	if oldSet && r.Spec.RegistrationMethod != oldControlplane.Spec.RegistrationMethod {
@@ -104,6 +124,13 @@ func (r *RKE2ControlPlane) ValidateUpdate(old runtime.Object) (admission.Warning
 		)
 	}
 
+	// The nodes of the cluster can't join it with another token.
+	if !reflect.DeepEqual(r.Spec.TokenSecretRef, oldControlplane.Spec.TokenSecretRef) {
+		allErrs = append(allErrs,
+			field.Forbidden(field.NewPath("spec", "tokenSecretRef"), "field value is immutable"),
+		)
+	}
+
 	if len(allErrs) == 0 {
 		return nil, nil
 	}
@@ -118,6 +145,40 @@ func (r *RKE2ControlPlane) ValidateDelete() (admission.Warnings, error) {
 	return nil, nil
 }
 
+// validateTokenSecretRef validates that the token secret reference has a name.
+func (r *RKE2ControlPlane) validateTokenSecretRef() field.ErrorList {
+	if r.Spec.TokenSecretRef != nil && r.Spec.TokenSecretRef.Name == "" {
+		return field.ErrorList{field.Required(field.NewPath("spec", "tokenSecretRef", "name"), "must be specified")}
+	}
+
+	return nil
+}
+
+// validateDeletionPolicy validates that the final etcd snapshot of the Snapshot deletion policy can be uploaded to S3,
+// as the snapshots stored on the control plane nodes are deleted along with them.
+func (r *RKE2ControlPlane) validateDeletionPolicy() field.ErrorList {
+	if r.Spec.DeletionPolicy == DeletionPolicySnapshot && r.Spec.ServerConfig.Etcd.BackupConfig.S3 == nil {
+		return field.ErrorList{field.Required(field.NewPath("spec", "serverConfig", "etcd", "backupConfig", "s3"),
+			"must be specified with the Snapshot deletionPolicy")}
+	}
+
+	return nil
+}
+
+// validateWorkloadCleanup validates the label selector of the workload cleanup.
+func (r *RKE2ControlPlane) validateWorkloadCleanup() field.ErrorList {
+	if r.Spec.WorkloadCleanup == nil || r.Spec.WorkloadCleanup.Selector == nil {
+		return nil
+	}
+
+	if _, err := metav1.LabelSelectorAsSelector(r.Spec.WorkloadCleanup.Selector); err != nil {
+		return field.ErrorList{field.Invalid(field.NewPath("spec", "workloadCleanup", "selector"),
+			r.Spec.WorkloadCleanup.Selector, err.Error())}
+	}
+
+	return nil
+}
+
 func (r *RKE2ControlPlane) validateCNI() field.ErrorList {
 	var allErrs field.ErrorList
 
@@ -155,3 +216,223 @@ func (r *RKE2ControlPlane) validateMachineTemplate() field.ErrorList {
 
 	return allErrs
 }
+
+// validateServerPools validates that each server pool has an infrastructure template, and that the control plane
+// keeps machines running all the server roles, the first of them initializing the cluster.
+func (r *RKE2ControlPlane) validateServerPools() field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(r.Spec.ServerPools) > 0 && r.Spec.Replicas != nil && *r.Spec.Replicas < 1 {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "replicas"), *r.Spec.Replicas,
+				"at least one replica running all the server roles is required when serverPools are defined"))
+	}
+
+	for i, pool := range r.Spec.ServerPools {
+		if pool.InfrastructureRef.Name == "" {
+			allErrs = append(allErrs,
+				field.Required(field.NewPath("spec", "serverPools").Index(i).Child("infrastructureRef", "name"),
+					"infrastructureRef is required"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateManifests validates that the manifests are read from a ConfigMap or a Secret in the namespace of the
+// RKE2ControlPlane, as they are written in the user data of the nodes or applied to the workload cluster.
+func (r *RKE2ControlPlane) validateManifests() field.ErrorList {
+	var allErrs field.ErrorList
+
+	ref := r.Spec.ManifestsConfigMapReference
+
+	switch ref.Kind {
+	case "", "ConfigMap", "Secret":
+	default:
+		allErrs = append(allErrs,
+			field.NotSupported(field.NewPath("spec", "manifestsConfigMapReference", "kind"),
+				ref.Kind, []string{"ConfigMap", "Secret"}))
+	}
+
+	if ref.Namespace != "" && ref.Namespace != r.Namespace {
+		allErrs = append(allErrs,
+			field.Forbidden(field.NewPath("spec", "manifestsConfigMapReference", "namespace"),
+				"must be the namespace of the RKE2ControlPlane"))
+	}
+
+	return allErrs
+}
+
+// cniCharts are the charts packaged with RKE2 deploying the CNI plugins.
+var cniCharts = []string{"rke2-calico", "rke2-canal", "rke2-cilium", "rke2-multus"}
+
+// packagedCharts are the charts packaged with RKE2, which can be customized with HelmChartConfigs.
+var packagedCharts = append([]string{
+	"rke2-calico-crd",
+	"rke2-coredns",
+	"rke2-flannel",
+	"rke2-ingress-nginx",
+	"rke2-metrics-server",
+	"rke2-runtimeclasses",
+	"rke2-snapshot-controller",
+	"rke2-snapshot-controller-crd",
+	"rke2-snapshot-validation-webhook",
+	"rke2-traefik",
+	"rke2-traefik-crd",
+	"harvester-cloud-provider",
+	"harvester-csi-driver",
+	"rancher-vsphere-cpi",
+	"rancher-vsphere-csi",
+}, cniCharts...)
+
+// validateAddons validates that the addons only customize the charts deployed by RKE2, that their names are unique
+// and that their values are valid YAML, read from a single source.
+func (r *RKE2ControlPlane) validateAddons() field.ErrorList {
+	var allErrs field.ErrorList
+
+	addons := r.Spec.ServerConfig.Addons
+	if addons == nil {
+		return allErrs
+	}
+
+	addonsPath := field.NewPath("spec", "serverConfig", "addons")
+
+	// The CNI plugins deployed, canal by default.
+	cnis := []string{}
+
+	switch r.Spec.ServerConfig.CNI {
+	case "":
+		cnis = append(cnis, "rke2-"+string(Canal))
+	case None:
+	default:
+		cnis = append(cnis, "rke2-"+string(r.Spec.ServerConfig.CNI))
+	}
+
+	if r.Spec.ServerConfig.CNIMultusEnable {
+		cnis = append(cnis, "rke2-multus")
+	}
+
+	names := map[string]bool{}
+
+	for i, chart := range addons.Charts {
+		chartPath := addonsPath.Child("charts").Index(i)
+
+		switch {
+		case names[chart.Name]:
+			allErrs = append(allErrs, field.Duplicate(chartPath.Child("name"), chart.Name))
+		case !slices.Contains(packagedCharts, chart.Name):
+			allErrs = append(allErrs, field.NotSupported(chartPath.Child("name"), chart.Name, packagedCharts))
+		case slices.Contains(r.Spec.ServerConfig.DisableComponents.PluginComponents, DisabledPluginComponent(chart.Name)):
+			allErrs = append(allErrs, field.Invalid(chartPath.Child("name"), chart.Name,
+				"chart is disabled in serverConfig.disableComponents.pluginComponents"))
+		case slices.Contains(cniCharts, chart.Name) && !slices.Contains(cnis, chart.Name):
+			allErrs = append(allErrs, field.Invalid(chartPath.Child("name"), chart.Name,
+				"chart is not deployed with the CNI plugins of serverConfig.cni and serverConfig.cniMultusEnable"))
+		}
+
+		names[chart.Name] = true
+
+		allErrs = append(allErrs, validateAddonValues(chartPath, chart.ValuesContent, chart.ValuesFrom)...)
+	}
+
+	names = map[string]bool{}
+
+	for i, chart := range addons.HelmCharts {
+		chartPath := addonsPath.Child("helmCharts").Index(i)
+
+		switch {
+		case names[chart.Name]:
+			allErrs = append(allErrs, field.Duplicate(chartPath.Child("name"), chart.Name))
+		case strings.HasPrefix(chart.Name, "rke2-"):
+			allErrs = append(allErrs, field.Invalid(chartPath.Child("name"), chart.Name,
+				"the rke2- prefix is reserved to the charts packaged with RKE2"))
+		}
+
+		names[chart.Name] = true
+
+		if chart.Chart == "" {
+			allErrs = append(allErrs, field.Required(chartPath.Child("chart"), "chart is required"))
+		}
+
+		allErrs = append(allErrs, validateAddonValues(chartPath, chart.ValuesContent, chart.ValuesFrom)...)
+	}
+
+	return allErrs
+}
+
+// validateAddonValues validates that the values of an addon are valid YAML, or are read from a single Secret or
+// ConfigMap.
+func validateAddonValues(addonPath *field.Path, content string, source *AddonValuesSource) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if source == nil {
+		values := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(content), &values); err != nil {
+			allErrs = append(allErrs, field.Invalid(addonPath.Child("valuesContent"), content,
+				fmt.Sprintf("values are not valid YAML: %v", err)))
+		}
+
+		return allErrs
+	}
+
+	if content != "" {
+		allErrs = append(allErrs, field.Forbidden(addonPath.Child("valuesFrom"),
+			"valuesFrom can't be set with valuesContent"))
+	}
+
+	if (source.SecretKeyRef == nil) == (source.ConfigMapKeyRef == nil) {
+		allErrs = append(allErrs, field.Invalid(addonPath.Child("valuesFrom"), source,
+			"exactly one of secretKeyRef or configMapKeyRef must be set"))
+	}
+
+	return allErrs
+}
+
+// validateVersion validates the version of the control plane, and, on update, that it is not downgraded and does
+// not skip a Kubernetes minor version, unless the SkipVersionSkewValidationAnnotation is set.
+func (r *RKE2ControlPlane) validateVersion(old *RKE2ControlPlane) field.ErrorList {
+	var allErrs field.ErrorList
+
+	versionPath := field.NewPath("spec", "version")
+
+	// The version is only validated when it changes, so that existing objects can still be updated.
+	if r.Spec.Version == "" || (old != nil && r.Spec.Version == old.Spec.Version) {
+		return nil
+	}
+
+	newVersion, err := rke2version.ParseRKE2Version(r.Spec.Version)
+	if err != nil {
+		return append(allErrs, field.Invalid(versionPath, r.Spec.Version, err.Error()))
+	}
+
+	if old == nil {
+		return nil
+	}
+
+	if _, skip := r.Annotations[SkipVersionSkewValidationAnnotation]; skip {
+		rke2controlplanelog.Info("Skipping version skew validation", "control-plane", klog.KObj(r),
+			"oldVersion", old.Spec.Version, "newVersion", r.Spec.Version)
+
+		return nil
+	}
+
+	oldVersion, err := rke2version.ParseRKE2Version(old.Spec.Version)
+	if err != nil {
+		// The previous version can't be compared, e.g. it was set before the version was validated.
+		return nil
+	}
+
+	switch {
+	case newVersion.Compare(oldVersion) < 0:
+		allErrs = append(allErrs, field.Forbidden(versionPath,
+			fmt.Sprintf("cannot downgrade from %s to %s, set the %s annotation to force it",
+				old.Spec.Version, r.Spec.Version, SkipVersionSkewValidationAnnotation)))
+	case newVersion.Kubernetes.Major != oldVersion.Kubernetes.Major ||
+		newVersion.Kubernetes.Minor > oldVersion.Kubernetes.Minor+1:
+		allErrs = append(allErrs, field.Forbidden(versionPath,
+			fmt.Sprintf("cannot skip Kubernetes minor versions when upgrading from %s to %s, set the %s annotation to force it",
+				old.Spec.Version, r.Spec.Version, SkipVersionSkewValidationAnnotation)))
+	}
+
+	return allErrs
+}
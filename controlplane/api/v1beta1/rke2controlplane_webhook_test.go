@@ -0,0 +1,375 @@
+/*
+Copyright 2024 SUSE.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRKE2ControlPlaneValidateVersion(t *testing.T) {
+	newControlPlane := func(version string, annotations map[string]string) *RKE2ControlPlane {
+		return &RKE2ControlPlane{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Annotations: annotations},
+			Spec: RKE2ControlPlaneSpec{
+				Version:           version,
+				InfrastructureRef: corev1.ObjectReference{Name: "infra"},
+			},
+		}
+	}
+
+	skipValidation := map[string]string{SkipVersionSkewValidationAnnotation: ""}
+
+	tests := []struct {
+		name        string
+		oldVersion  string
+		newVersion  string
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{
+			name:       "allow upgrading the RKE2 release",
+			oldVersion: "v1.30.2+rke2r1",
+			newVersion: "v1.30.2+rke2r2",
+		},
+		{
+			name:       "allow upgrading to the next Kubernetes minor version",
+			oldVersion: "v1.30.12+rke2r1",
+			newVersion: "v1.31.1+rke2r1",
+		},
+		{
+			name:       "allow updates keeping a malformed version",
+			oldVersion: "v1.30.2",
+			newVersion: "v1.30.2",
+		},
+		{
+			name:       "allow updating from a malformed version",
+			oldVersion: "v1.30.2",
+			newVersion: "v1.31.1+rke2r1",
+		},
+		{
+			name:       "reject malformed versions",
+			oldVersion: "v1.30.2+rke2r1",
+			newVersion: "v1.31.1",
+			wantErr:    true,
+		},
+		{
+			name:        "reject malformed versions with the skip annotation",
+			oldVersion:  "v1.30.2+rke2r1",
+			newVersion:  "1.31.1+rke2r1",
+			annotations: skipValidation,
+			wantErr:     true,
+		},
+		{
+			name:       "reject downgrading the RKE2 release",
+			oldVersion: "v1.30.2+rke2r2",
+			newVersion: "v1.30.2+rke2r1",
+			wantErr:    true,
+		},
+		{
+			name:       "reject downgrading the Kubernetes version",
+			oldVersion: "v1.31.1+rke2r1",
+			newVersion: "v1.30.12+rke2r1",
+			wantErr:    true,
+		},
+		{
+			name:       "reject skipping a Kubernetes minor version",
+			oldVersion: "v1.29.6+rke2r1",
+			newVersion: "v1.31.1+rke2r1",
+			wantErr:    true,
+		},
+		{
+			name:        "allow downgrading with the skip annotation",
+			oldVersion:  "v1.31.1+rke2r1",
+			newVersion:  "v1.30.12+rke2r1",
+			annotations: skipValidation,
+		},
+		{
+			name:        "allow skipping a Kubernetes minor version with the skip annotation",
+			oldVersion:  "v1.29.6+rke2r1",
+			newVersion:  "v1.31.1+rke2r1",
+			annotations: skipValidation,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			warn, err := newControlPlane(tt.newVersion, tt.annotations).ValidateUpdate(newControlPlane(tt.oldVersion, nil))
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(warn).To(BeNil())
+		})
+	}
+}
+
+func TestRKE2ControlPlaneValidateCreateVersion(t *testing.T) {
+	g := NewWithT(t)
+
+	rcp := &RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: RKE2ControlPlaneSpec{
+			Version:           "v1.30.2+rke2r1",
+			InfrastructureRef: corev1.ObjectReference{Name: "infra"},
+		},
+	}
+
+	_, err := rcp.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	rcp.Spec.Version = "v1.30.2-rke2r1"
+	_, err = rcp.ValidateCreate()
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestRKE2ControlPlaneValidateServerPools(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int32
+		pools    []ServerPool
+		wantErr  bool
+	}{
+		{
+			name:     "allow server pools with an infrastructure template",
+			replicas: 1,
+			pools: []ServerPool{
+				{Role: ServerRoleEtcd, InfrastructureRef: corev1.ObjectReference{Name: "etcd"}},
+				{Role: ServerRoleControlPlane, InfrastructureRef: corev1.ObjectReference{Name: "control-plane"}},
+			},
+		},
+		{
+			name:     "reject server pools without an infrastructure template",
+			replicas: 1,
+			pools:    []ServerPool{{Role: ServerRoleEtcd}},
+			wantErr:  true,
+		},
+		{
+			name:     "reject server pools without replicas running all the server roles",
+			replicas: 0,
+			pools:    []ServerPool{{Role: ServerRoleEtcd, InfrastructureRef: corev1.ObjectReference{Name: "etcd"}}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			rcp := &RKE2ControlPlane{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+				Spec: RKE2ControlPlaneSpec{
+					Replicas:          &tt.replicas,
+					Version:           "v1.30.2+rke2r1",
+					InfrastructureRef: corev1.ObjectReference{Name: "infra"},
+					ServerPools:       tt.pools,
+				},
+			}
+
+			_, err := rcp.ValidateCreate()
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestRKE2ControlPlaneValidateManifests(t *testing.T) {
+	g := NewWithT(t)
+
+	rcp := &RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: RKE2ControlPlaneSpec{
+			Version:                     "v1.30.2+rke2r1",
+			InfrastructureRef:           corev1.ObjectReference{Name: "infra"},
+			ManifestsConfigMapReference: corev1.ObjectReference{Kind: "Secret", Name: "manifests"},
+		},
+	}
+
+	_, err := rcp.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	rcp.Spec.ManifestsConfigMapReference.Namespace = "other"
+
+	_, err = rcp.ValidateCreate()
+	g.Expect(err).To(HaveOccurred())
+
+	rcp.Spec.ManifestsConfigMapReference.Namespace = ""
+	rcp.Spec.ManifestsConfigMapReference.Kind = "Deployment"
+
+	_, err = rcp.ValidateCreate()
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestRKE2ControlPlaneValidateTokenSecretRef(t *testing.T) {
+	g := NewWithT(t)
+
+	rcp := &RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: RKE2ControlPlaneSpec{
+			Version:           "v1.30.2+rke2r1",
+			InfrastructureRef: corev1.ObjectReference{Name: "infra"},
+			TokenSecretRef:    &corev1.LocalObjectReference{Name: "token"},
+		},
+	}
+
+	_, err := rcp.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = rcp.ValidateUpdate(rcp.DeepCopy())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The token secret reference is immutable.
+	updated := rcp.DeepCopy()
+	updated.Spec.TokenSecretRef.Name = "other-token"
+
+	_, err = updated.ValidateUpdate(rcp)
+	g.Expect(err).To(HaveOccurred())
+
+	updated.Spec.TokenSecretRef = nil
+
+	_, err = updated.ValidateUpdate(rcp)
+	g.Expect(err).To(HaveOccurred())
+
+	rcp.Spec.TokenSecretRef.Name = ""
+
+	_, err = rcp.ValidateCreate()
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestRKE2ControlPlaneValidateDeletionPolicy(t *testing.T) {
+	g := NewWithT(t)
+
+	rcp := &RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: RKE2ControlPlaneSpec{
+			Version:           "v1.30.2+rke2r1",
+			InfrastructureRef: corev1.ObjectReference{Name: "infra"},
+			DeletionPolicy:    DeletionPolicySnapshot,
+		},
+	}
+
+	// The final snapshot can't be stored on the nodes being deleted.
+	_, err := rcp.ValidateCreate()
+	g.Expect(err).To(MatchError(ContainSubstring("spec.serverConfig.etcd.backupConfig.s3")))
+
+	rcp.Spec.ServerConfig.Etcd.BackupConfig.S3 = &EtcdS3{Endpoint: "s3.example.com", Bucket: "snapshots"}
+
+	_, err = rcp.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	updated := rcp.DeepCopy()
+	updated.Spec.ServerConfig.Etcd.BackupConfig.S3 = nil
+
+	_, err = updated.ValidateUpdate(rcp)
+	g.Expect(err).To(HaveOccurred())
+
+	updated.Spec.DeletionPolicy = DeletionPolicyDelete
+
+	_, err = updated.ValidateUpdate(rcp)
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestRKE2ControlPlaneValidateWorkloadCleanup(t *testing.T) {
+	g := NewWithT(t)
+
+	rcp := &RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: RKE2ControlPlaneSpec{
+			Version:           "v1.30.2+rke2r1",
+			InfrastructureRef: corev1.ObjectReference{Name: "infra"},
+			WorkloadCleanup: &WorkloadCleanup{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"cleanup": "true"}},
+			},
+		},
+	}
+
+	_, err := rcp.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	rcp.Spec.WorkloadCleanup.Selector.MatchExpressions = []metav1.LabelSelectorRequirement{
+		{Key: "cleanup", Operator: "Unknown"},
+	}
+
+	_, err = rcp.ValidateCreate()
+	g.Expect(err).To(MatchError(ContainSubstring("spec.workloadCleanup.selector")))
+}
+
+func TestRKE2ControlPlaneValidateAddons(t *testing.T) {
+	g := NewWithT(t)
+
+	rcp := &RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: RKE2ControlPlaneSpec{
+			Version:           "v1.30.2+rke2r1",
+			InfrastructureRef: corev1.ObjectReference{Name: "infra"},
+			ServerConfig: RKE2ServerConfig{
+				CNI: Cilium,
+				Addons: &Addons{
+					Charts: []ChartConfig{
+						{Name: "rke2-coredns", ValuesContent: "autoscaler:\n  enabled: false\n"},
+						{Name: "rke2-cilium", ValuesFrom: &AddonValuesSource{
+							SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "cilium"},
+								Key:                  "values.yaml",
+							},
+						}},
+					},
+					HelmCharts: []HelmChart{{Name: "cert-manager", Chart: "cert-manager", Repo: "https://charts.jetstack.io"}},
+				},
+			},
+		},
+	}
+
+	_, err := rcp.ValidateCreate()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// Values of a disabled chart are rejected.
+	disabled := rcp.DeepCopy()
+	disabled.Spec.ServerConfig.DisableComponents.PluginComponents = []DisabledPluginComponent{CoreDNS}
+
+	_, err = disabled.ValidateCreate()
+	g.Expect(err).To(MatchError(ContainSubstring("spec.serverConfig.addons.charts[0].name")))
+
+	// Values of a CNI chart which is not deployed are rejected.
+	disabled = rcp.DeepCopy()
+	disabled.Spec.ServerConfig.CNI = Canal
+
+	_, err = disabled.ValidateCreate()
+	g.Expect(err).To(MatchError(ContainSubstring("spec.serverConfig.addons.charts[1].name")))
+
+	// Values of a chart which is not packaged with RKE2 are rejected.
+	unknown := rcp.DeepCopy()
+	unknown.Spec.ServerConfig.Addons.Charts[0].Name = "cert-manager"
+
+	_, err = unknown.ValidateCreate()
+	g.Expect(err).To(MatchError(ContainSubstring("spec.serverConfig.addons.charts[0].name")))
+
+	invalid := rcp.DeepCopy()
+	invalid.Spec.ServerConfig.Addons.Charts[0].ValuesContent = "autoscaler: ["
+	invalid.Spec.ServerConfig.Addons.Charts[1].ValuesFrom.ConfigMapKeyRef = &corev1.ConfigMapKeySelector{Key: "values"}
+	invalid.Spec.ServerConfig.Addons.HelmCharts = append(invalid.Spec.ServerConfig.Addons.HelmCharts,
+		HelmChart{Name: "rke2-extra", Chart: "extra"})
+
+	_, err = invalid.ValidateCreate()
+	g.Expect(err).To(MatchError(ContainSubstring("spec.serverConfig.addons.charts[0].valuesContent")))
+	g.Expect(err).To(MatchError(ContainSubstring("spec.serverConfig.addons.charts[1].valuesFrom")))
+	g.Expect(err).To(MatchError(ContainSubstring("spec.serverConfig.addons.helmCharts[1].name")))
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// RKE2EtcdRestoreFinalizer allows the controller to release the RKE2ControlPlane before an RKE2EtcdRestore is removed.
+	RKE2EtcdRestoreFinalizer = "rke2etcdrestore.controlplane.cluster.x-k8s.io"
+
+	// EtcdRestoreInProgressAnnotation is set on a RKE2ControlPlane while an etcd snapshot is being restored.
+	// The value is the name of the RKE2EtcdRestore. While the annotation is present the RKE2ControlPlane controller
+	// does not scale, roll out or remediate machines.
+	EtcdRestoreInProgressAnnotation = "controlplane.cluster.x-k8s.io/etcd-restore-in-progress"
+)
+
+// EtcdSnapshotSource defines where an etcd snapshot is stored.
+type EtcdSnapshotSource string
+
+const (
+	// EtcdSnapshotSourceLocal is a snapshot stored in the snapshot directory of the control plane node.
+	EtcdSnapshotSourceLocal EtcdSnapshotSource = "Local"
+
+	// EtcdSnapshotSourceS3 is a snapshot stored in the S3 bucket configured in the RKE2ControlPlane etcd backup configuration.
+	EtcdSnapshotSourceS3 EtcdSnapshotSource = "S3"
+)
+
+// RKE2EtcdRestorePhase is the phase of an etcd snapshot restore.
+type RKE2EtcdRestorePhase string
+
+const (
+	// RKE2EtcdRestorePhasePending is the phase before the restore starts.
+	RKE2EtcdRestorePhasePending RKE2EtcdRestorePhase = "Pending"
+
+	// RKE2EtcdRestorePhaseScalingDown is the phase where all the control plane machines but one are deleted.
+	RKE2EtcdRestorePhaseScalingDown RKE2EtcdRestorePhase = "ScalingDown"
+
+	// RKE2EtcdRestorePhaseRestoring is the phase where the snapshot is restored on the remaining machine.
+	RKE2EtcdRestorePhaseRestoring RKE2EtcdRestorePhase = "Restoring"
+
+	// RKE2EtcdRestorePhaseRejoining is the phase where the control plane is scaled back to the desired replicas.
+	RKE2EtcdRestorePhaseRejoining RKE2EtcdRestorePhase = "Rejoining"
+
+	// RKE2EtcdRestorePhaseCompleted is the phase of a successful restore.
+	RKE2EtcdRestorePhaseCompleted RKE2EtcdRestorePhase = "Completed"
+
+	// RKE2EtcdRestorePhaseFailed is the phase of a failed restore.
+	RKE2EtcdRestorePhaseFailed RKE2EtcdRestorePhase = "Failed"
+)
+
+// RKE2EtcdRestoreSpec defines the desired state of RKE2EtcdRestore.
+// +kubebuilder:validation:XValidation:rule="self == oldSelf", message="spec is immutable"
+type RKE2EtcdRestoreSpec struct {
+	// ControlPlaneName is the name of the RKE2ControlPlane to restore, in the same namespace as the RKE2EtcdRestore.
+	// +kubebuilder:validation:MinLength=1
+	ControlPlaneName string `json:"controlPlaneName"`
+
+	// Snapshot is the etcd snapshot to restore.
+	Snapshot EtcdRestoreSnapshot `json:"snapshot"`
+
+	// MachineName is the name of the control plane machine the snapshot is restored on.
+	// It is required to restore a local snapshot stored on a specific node. If empty, the oldest
+	// control plane machine is used.
+	// +optional
+	MachineName string `json:"machineName,omitempty"`
+
+	// Image is the image used to run the restore on the control plane node. It must provide a shell and nsenter.
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// EtcdRestoreSnapshot references an etcd snapshot.
+type EtcdRestoreSnapshot struct {
+	// Name is the name of the snapshot, i.e. the snapshot file name in the snapshot directory of the node
+	// for local snapshots, or the object name in the bucket for S3 snapshots.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Source is where the snapshot is stored. S3 snapshots are fetched using the `etcd.backupConfig.s3`
+	// configuration of the RKE2ControlPlane.
+	// +kubebuilder:validation:Enum=Local;S3
+	// +kubebuilder:default=Local
+	// +optional
+	Source EtcdSnapshotSource `json:"source,omitempty"`
+}
+
+// RKE2EtcdRestoreStatus defines the observed state of RKE2EtcdRestore.
+type RKE2EtcdRestoreStatus struct {
+	// Phase is the current phase of the restore.
+	// +optional
+	Phase RKE2EtcdRestorePhase `json:"phase,omitempty"`
+
+	// MachineName is the name of the control plane machine the snapshot is restored on.
+	// +optional
+	MachineName string `json:"machineName,omitempty"`
+
+	// Conditions defines current service state of the RKE2EtcdRestore.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:resource:path=rke2etcdrestores,scope=Namespaced,categories=cluster-api
+// +kubebuilder:printcolumn:name="ControlPlane",type="string",JSONPath=".spec.controlPlaneName",description="RKE2ControlPlane being restored"
+// +kubebuilder:printcolumn:name="Snapshot",type="string",JSONPath=".spec.snapshot.name",description="Snapshot being restored"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Restore phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// RKE2EtcdRestore is the Schema for the rke2etcdrestores API.
+type RKE2EtcdRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RKE2EtcdRestoreSpec   `json:"spec,omitempty"`
+	Status RKE2EtcdRestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RKE2EtcdRestoreList contains a list of RKE2EtcdRestore.
+type RKE2EtcdRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RKE2EtcdRestore `json:"items"`
+}
+
+// GetConditions returns the list of conditions for a RKE2EtcdRestore object.
+func (r *RKE2EtcdRestore) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the list of conditions for a RKE2EtcdRestore object.
+func (r *RKE2EtcdRestore) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+func init() { //nolint:gochecknoinits
+	objectTypes = append(objectTypes, &RKE2EtcdRestore{}, &RKE2EtcdRestoreList{})
+}
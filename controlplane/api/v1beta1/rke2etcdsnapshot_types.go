@@ -0,0 +1,123 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// RKE2EtcdSnapshotPhase is the phase of an on-demand etcd snapshot.
+type RKE2EtcdSnapshotPhase string
+
+const (
+	// RKE2EtcdSnapshotPhasePending is the phase before the snapshot is started.
+	RKE2EtcdSnapshotPhasePending RKE2EtcdSnapshotPhase = "Pending"
+
+	// RKE2EtcdSnapshotPhaseRunning is the phase where the snapshot is taken on the control plane node.
+	RKE2EtcdSnapshotPhaseRunning RKE2EtcdSnapshotPhase = "Running"
+
+	// RKE2EtcdSnapshotPhaseCompleted is the phase of a successful snapshot.
+	RKE2EtcdSnapshotPhaseCompleted RKE2EtcdSnapshotPhase = "Completed"
+
+	// RKE2EtcdSnapshotPhaseFailed is the phase of a failed snapshot.
+	RKE2EtcdSnapshotPhaseFailed RKE2EtcdSnapshotPhase = "Failed"
+)
+
+// RKE2EtcdSnapshotSpec defines the desired state of RKE2EtcdSnapshot.
+// +kubebuilder:validation:XValidation:rule="self == oldSelf", message="spec is immutable"
+type RKE2EtcdSnapshotSpec struct {
+	// ControlPlaneName is the name of the RKE2ControlPlane to snapshot, in the same namespace as the RKE2EtcdSnapshot.
+	// +kubebuilder:validation:MinLength=1
+	ControlPlaneName string `json:"controlPlaneName"`
+
+	// SnapshotName is the base name of the snapshot. RKE2 appends the node name and a timestamp to it.
+	// If empty, the name of the RKE2EtcdSnapshot is used.
+	// +optional
+	SnapshotName string `json:"snapshotName,omitempty"`
+
+	// MachineName is the name of the control plane machine the snapshot is taken on.
+	// If empty, the oldest control plane machine is used.
+	// +optional
+	MachineName string `json:"machineName,omitempty"`
+
+	// Image is the image used to run the snapshot on the control plane node. It must provide nsenter.
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// RKE2EtcdSnapshotStatus defines the observed state of RKE2EtcdSnapshot.
+type RKE2EtcdSnapshotStatus struct {
+	// Phase is the current phase of the snapshot.
+	// +optional
+	Phase RKE2EtcdSnapshotPhase `json:"phase,omitempty"`
+
+	// MachineName is the name of the control plane machine the snapshot is taken on.
+	// +optional
+	MachineName string `json:"machineName,omitempty"`
+
+	// Location is the location of the snapshot once it is taken, e.g. its S3 URL when it was uploaded to S3, as
+	// reported by RKE2.
+	// +optional
+	Location string `json:"location,omitempty"`
+
+	// Conditions defines current service state of the RKE2EtcdSnapshot.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:resource:path=rke2etcdsnapshots,scope=Namespaced,categories=cluster-api
+// +kubebuilder:printcolumn:name="ControlPlane",type="string",JSONPath=".spec.controlPlaneName",description="RKE2ControlPlane being snapshotted"
+// +kubebuilder:printcolumn:name="Machine",type="string",JSONPath=".status.machineName",description="Machine taking the snapshot"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Snapshot phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// RKE2EtcdSnapshot is the Schema for the rke2etcdsnapshots API.
+type RKE2EtcdSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RKE2EtcdSnapshotSpec   `json:"spec,omitempty"`
+	Status RKE2EtcdSnapshotStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RKE2EtcdSnapshotList contains a list of RKE2EtcdSnapshot.
+type RKE2EtcdSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RKE2EtcdSnapshot `json:"items"`
+}
+
+// GetConditions returns the list of conditions for a RKE2EtcdSnapshot object.
+func (r *RKE2EtcdSnapshot) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the list of conditions for a RKE2EtcdSnapshot object.
+func (r *RKE2EtcdSnapshot) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+func init() { //nolint:gochecknoinits
+	objectTypes = append(objectTypes, &RKE2EtcdSnapshot{}, &RKE2EtcdSnapshotList{})
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+// addonValuesSourceKeys returns the keys in the manifestsSourceIndex of the Secrets and ConfigMaps the values of the
+// addons are read from, when the addons are synchronized.
+func addonValuesSourceKeys(rcp *controlplanev1.RKE2ControlPlane) []string {
+	addons := rcp.Spec.ServerConfig.Addons
+	if addons == nil || rcp.Spec.ManifestsStrategy != controlplanev1.ManifestsStrategySync {
+		return nil
+	}
+
+	sources := []*controlplanev1.AddonValuesSource{}
+	for _, chart := range addons.Charts {
+		sources = append(sources, chart.ValuesFrom)
+	}
+
+	for _, chart := range addons.HelmCharts {
+		sources = append(sources, chart.ValuesFrom)
+	}
+
+	keys := []string{}
+
+	for _, source := range sources {
+		switch {
+		case source == nil:
+		case source.SecretKeyRef != nil:
+			keys = append(keys, manifestsSourceKey("Secret", rcp.Namespace, source.SecretKeyRef.Name))
+		case source.ConfigMapKeyRef != nil:
+			keys = append(keys, manifestsSourceKey("ConfigMap", rcp.Namespace, source.ConfigMapKeyRef.Name))
+		}
+	}
+
+	return keys
+}
+
+// reconcileAddons applies the HelmChartConfigs and HelmCharts of the addons to the workload cluster, and prunes the
+// ones removed from them, when the ManifestsStrategy is Sync. Otherwise, the addons are only written in the manifests
+// directory of the servers when they are bootstrapped, like the manifests of the ManifestsConfigMapReference.
+// Values which cannot be read are reported in the AddonsSynced condition, and read again when the Secrets and
+// ConfigMaps they are read from change, without holding back the reconciliation of the machines.
+func (r *RKE2ControlPlaneReconciler) reconcileAddons(ctx context.Context, controlPlane *rke2.ControlPlane) {
+	logger := controlPlane.Logger()
+	rcp := controlPlane.RCP
+
+	if rcp.Spec.ManifestsStrategy != controlplanev1.ManifestsStrategySync {
+		// The resources applied are left in the workload cluster, and managed by RKE2 from the manifests directory.
+		rcp.Status.AppliedAddons = nil
+		conditions.Delete(rcp, controlplanev1.AddonsSyncedCondition)
+
+		return
+	}
+
+	if rcp.Spec.ServerConfig.Addons == nil && len(rcp.Status.AppliedAddons) == 0 {
+		conditions.Delete(rcp, controlplanev1.AddonsSyncedCondition)
+
+		return
+	}
+
+	if !rcp.Status.Initialized {
+		return
+	}
+
+	syncFailed := func(err error) {
+		logger.Error(err, "Failed to synchronize addons")
+		r.recorder.Eventf(rcp, corev1.EventTypeWarning, "AddonsSyncFailed", "Failed to synchronize addons: %v", err)
+		conditions.MarkFalse(rcp,
+			controlplanev1.AddonsSyncedCondition,
+			controlplanev1.AddonsSyncFailedReason,
+			clusterv1.ConditionSeverityWarning,
+			err.Error())
+	}
+
+	manifests, err := rke2.GetAddonManifests(ctx, r.Client, rcp)
+	if err != nil {
+		syncFailed(err)
+
+		return
+	}
+
+	objects, err := rke2.ParseManifests(manifests)
+	if err != nil {
+		syncFailed(err)
+
+		return
+	}
+
+	workloadCluster, err := r.GetWorkloadCluster(ctx, controlPlane)
+	if err != nil {
+		syncFailed(err)
+
+		return
+	}
+
+	applied, err := workloadCluster.SyncManifests(ctx, objects, rcp.Status.AppliedAddons)
+	rcp.Status.AppliedAddons = applied
+
+	if err != nil {
+		syncFailed(err)
+
+		return
+	}
+
+	if rcp.Spec.ServerConfig.Addons == nil {
+		// The addons were removed, and their resources pruned.
+		conditions.Delete(rcp, controlplanev1.AddonsSyncedCondition)
+
+		return
+	}
+
+	conditions.MarkTrue(rcp, controlplanev1.AddonsSyncedCondition)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestReconcileAddons(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	values := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns-values", Namespace: metav1.NamespaceDefault},
+		Data:       map[string]string{"values.yaml": "autoscaler:\n  enabled: false\n"},
+	}
+
+	cluster, rcp, machines := newFakeControlPlane()
+	rcp.Status.Initialized = true
+	rcp.Spec.ManifestsStrategy = controlplanev1.ManifestsStrategySync
+	rcp.Spec.ServerConfig.Addons = &controlplanev1.Addons{
+		Charts: []controlplanev1.ChartConfig{{
+			Name: "rke2-coredns",
+			ValuesFrom: &controlplanev1.AddonValuesSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: "coredns-values"},
+				Key:                  "values.yaml",
+			}},
+		}},
+		HelmCharts: []controlplanev1.HelmChart{{Name: "cert-manager", Chart: "cert-manager", TargetNamespace: "cert-manager"}},
+	}
+
+	g.Expect(indexManifestsSource(rcp)).To(ConsistOf("ConfigMap/default/coredns-values"))
+
+	workloadCluster := &fakeWorkloadCluster{}
+	r := &RKE2ControlPlaneReconciler{
+		Client:            newFakeClient(values),
+		recorder:          record.NewFakeRecorder(32),
+		managementCluster: &fakeManagementCluster{Machines: machines, Workload: workloadCluster},
+	}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	r.reconcileAddons(ctx, controlPlane)
+	g.Expect(workloadCluster.SyncedManifests).To(HaveLen(2))
+	g.Expect(rcp.Status.AppliedAddons).To(ConsistOf(
+		controlplanev1.ManifestReference{APIVersion: "helm.cattle.io/v1", Kind: "HelmChartConfig", Namespace: "kube-system", Name: "rke2-coredns"},
+		controlplanev1.ManifestReference{APIVersion: "helm.cattle.io/v1", Kind: "HelmChart", Namespace: "kube-system", Name: "cert-manager"},
+	))
+	g.Expect(conditions.IsTrue(rcp, controlplanev1.AddonsSyncedCondition)).To(BeTrue())
+
+	// Missing values are reported, without changing the applied resources.
+	g.Expect(r.Client.Delete(ctx, values)).To(Succeed())
+
+	r.reconcileAddons(ctx, controlPlane)
+	g.Expect(conditions.GetReason(rcp, controlplanev1.AddonsSyncedCondition)).To(Equal(controlplanev1.AddonsSyncFailedReason))
+	g.Expect(rcp.Status.AppliedAddons).To(HaveLen(2))
+
+	// Once the addons are removed, their resources are pruned.
+	rcp.Spec.ServerConfig.Addons = nil
+
+	r.reconcileAddons(ctx, controlPlane)
+	g.Expect(workloadCluster.SyncedManifests).To(BeEmpty())
+	g.Expect(rcp.Status.AppliedAddons).To(BeEmpty())
+	g.Expect(conditions.Has(rcp, controlplanev1.AddonsSyncedCondition)).To(BeFalse())
+
+	// With the Bootstrap strategy, the addons are only written in the manifests directory of the servers.
+	rcp.Spec.ManifestsStrategy = controlplanev1.ManifestsStrategyBootstrap
+	rcp.Spec.ServerConfig.Addons = &controlplanev1.Addons{Charts: []controlplanev1.ChartConfig{{Name: "rke2-coredns"}}}
+	g.Expect(indexManifestsSource(rcp)).To(BeEmpty())
+
+	r.reconcileAddons(ctx, controlPlane)
+	g.Expect(workloadCluster.SyncedManifests).To(BeEmpty())
+	g.Expect(rcp.Status.AppliedAddons).To(BeEmpty())
+	g.Expect(conditions.Has(rcp, controlplanev1.AddonsSyncedCondition)).To(BeFalse())
+}
@@ -0,0 +1,358 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/blang/semver/v4"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/patch"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+// adoptMachines takes the ownership of the control plane machines of the cluster which have no controller, e.g.
+// the machines of a control plane created before it was managed by an RKE2ControlPlane, once they are validated.
+// A machine which can't be adopted is reported with an AdoptionFailed event, and the RKE2ControlPlane keeps refusing
+// to operate in mixed management mode until it is fixed or removed.
+//
+// The server configuration of the adopted machines is read from their bootstrap data, and the machines running a CNI,
+// cluster DNS or cluster domain a rollout can't change are rejected. The adopted machines are then rolled out like the
+// other machines when their version, server configuration, RKE2Config or infrastructure template don't match the
+// RKE2ControlPlane. The server configuration of a machine whose bootstrap data can't be read is left unknown, and
+// doesn't trigger its rollout.
+func (r *RKE2ControlPlaneReconciler) adoptMachines(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	rcp *controlplanev1.RKE2ControlPlane,
+	machines collections.Machines,
+) (ctrl.Result, error) {
+	logger := ctrl.LoggerFrom(ctx)
+
+	// The RKE2ControlPlane is read again from the API server, so that machines orphaned by the garbage collector while
+	// it is being deleted are not adopted, even if the cache doesn't have its deletion yet.
+	rcpCopy := &controlplanev1.RKE2ControlPlane{}
+	if err := r.APIReader.Get(ctx, client.ObjectKeyFromObject(rcp), rcpCopy); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to check whether the RKE2ControlPlane is being deleted")
+	}
+
+	if !rcpCopy.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	adoptable := true
+	serverConfigs := map[string]*controlplanev1.RKE2ServerConfig{}
+
+	for _, machine := range machines.SortedByCreationTimestamp() {
+		err := validateAdoption(rcp, machine)
+		if err == nil {
+			serverConfigs[machine.Name], err = r.adoptedServerConfig(ctx, rcp, machine)
+		}
+
+		if err != nil {
+			logger.Info("Cannot adopt control plane machine", "machine", machine.Name, "reason", err.Error())
+			r.recorder.Eventf(rcp, corev1.EventTypeWarning, "AdoptionFailed", "Could not adopt machine %s: %v", machine.Name, err)
+
+			adoptable = false
+		}
+	}
+
+	// The machines are adopted at once, so that the control plane is never managed with a part of its machines.
+	if !adoptable {
+		return ctrl.Result{}, nil
+	}
+
+	for _, machine := range machines.SortedByCreationTimestamp() {
+		if err := r.adoptRKE2Config(ctx, rcp, machine); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		patchHelper, err := patch.NewHelper(machine, r.Client)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to create patch helper for machine %s", machine.Name)
+		}
+
+		if err := controllerutil.SetControllerReference(rcp, machine, r.Client.Scheme()); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to set owner reference of machine %s", machine.Name)
+		}
+
+		if machine.Annotations == nil {
+			machine.Annotations = map[string]string{}
+		}
+
+		_, found := machine.Annotations[controlplanev1.RKE2ServerConfigurationAnnotation]
+		if serverConfig := serverConfigs[machine.Name]; !found && serverConfig != nil {
+			serverConfigJSON, err := json.Marshal(serverConfig)
+			if err != nil {
+				return ctrl.Result{}, errors.Wrapf(err, "failed to marshal server configuration of machine %s", machine.Name)
+			}
+
+			machine.Annotations[controlplanev1.RKE2ServerConfigurationAnnotation] = string(serverConfigJSON)
+		}
+
+		if _, found := machine.Annotations[controlplanev1.PreTerminateHookCleanupAnnotation]; !found {
+			machine.Annotations[controlplanev1.PreTerminateHookCleanupAnnotation] = ""
+		}
+
+		if err := patchHelper.Patch(ctx, machine); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to adopt machine %s", machine.Name)
+		}
+
+		logger.Info("Adopted control plane machine", "machine", machine.Name, "cluster", cluster.Name)
+		r.recorder.Eventf(rcp, corev1.EventTypeNormal, "AdoptedMachine", "Adopted machine %s", machine.Name)
+	}
+
+	// The other operations wait for the ownership of the machines to be updated in the cache.
+	return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+}
+
+// validateAdoption returns why a control plane machine can't be adopted by the RKE2ControlPlane, or nil if it can.
+func validateAdoption(rcp *controlplanev1.RKE2ControlPlane, machine *clusterv1.Machine) error {
+	if machine.Spec.Version == nil {
+		return errors.New("machine has no version")
+	}
+
+	machineVersion, err := semver.ParseTolerant(*machine.Spec.Version)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse version %s of the machine", *machine.Spec.Version)
+	}
+
+	desiredVersion, err := semver.ParseTolerant(rcp.GetDesiredVersion())
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse version %s of the RKE2ControlPlane", rcp.GetDesiredVersion())
+	}
+
+	if !util.IsSupportedVersionSkew(desiredVersion, machineVersion) {
+		return errors.Errorf("version %s of the machine is more than one minor version away from version %s",
+			*machine.Spec.Version, rcp.GetDesiredVersion())
+	}
+
+	ref := machine.Spec.Bootstrap.ConfigRef
+	if ref == nil || ref.Kind != "RKE2Config" {
+		return errors.New("machine is not bootstrapped with an RKE2Config")
+	}
+
+	if ref.Namespace != "" && ref.Namespace != machine.Namespace {
+		return errors.Errorf("RKE2Config %s/%s is not in the namespace of the machine", ref.Namespace, ref.Name)
+	}
+
+	// The machines of a server pool missing from the RKE2ControlPlane would be scaled down right after their adoption.
+	if role := rke2.ServerRoleOf(machine); role != "" && !hasServerPool(rcp, role) {
+		return errors.Errorf("RKE2ControlPlane has no server pool for the role %s of the machine", role)
+	}
+
+	return nil
+}
+
+// adoptedServerConfig returns the server configuration of a control plane machine, read from its bootstrap data, or
+// nil if it can't be read. An error is returned if the machine can't be adopted with this server configuration.
+func (r *RKE2ControlPlaneReconciler) adoptedServerConfig(
+	ctx context.Context,
+	rcp *controlplanev1.RKE2ControlPlane,
+	machine *clusterv1.Machine,
+) (*controlplanev1.RKE2ServerConfig, error) {
+	if machine.Spec.Bootstrap.DataSecretName == nil {
+		return nil, nil //nolint:nilnil
+	}
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: machine.Namespace, Name: *machine.Spec.Bootstrap.DataSecretName}
+
+	if err := r.Client.Get(ctx, key, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil //nolint:nilnil
+		}
+
+		return nil, errors.Wrapf(err, "failed to get bootstrap data secret %s", key)
+	}
+
+	machineConfig, err := rke2.ServerConfigFromBootstrapData(secret.Data["value"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the server configuration of the machine from secret %s", key)
+	}
+
+	if machineConfig == nil {
+		return nil, nil //nolint:nilnil
+	}
+
+	return serverConfigFromMachineConfig(rcp, machine, machineConfig)
+}
+
+// serverConfigFromMachineConfig returns the server configuration of the RKE2ControlPlane a machine running the given
+// RKE2 configuration was created with. The settings missing from the RKE2 configuration, or rendered from other
+// resources, are assumed to be the ones of the RKE2ControlPlane.
+func serverConfigFromMachineConfig(
+	rcp *controlplanev1.RKE2ControlPlane,
+	machine *clusterv1.Machine,
+	machineConfig *rke2.ServerConfig,
+) (*controlplanev1.RKE2ServerConfig, error) {
+	serverConfig := rcp.Spec.ServerConfig.DeepCopy()
+
+	cni, multus := controlplanev1.CNI(""), false
+
+	for _, plugin := range machineConfig.CNI {
+		if plugin == "multus" {
+			multus = true
+		} else {
+			cni = controlplanev1.CNI(plugin)
+		}
+	}
+
+	// The CNI, the cluster DNS and the cluster domain of a cluster can't be changed by rolling out its machines.
+	if defaultCNI(cni) != defaultCNI(serverConfig.CNI) {
+		return nil, errors.Errorf("machine runs the CNI %s instead of %s", defaultCNI(cni), defaultCNI(serverConfig.CNI))
+	}
+
+	if machineConfig.ClusterDNS != serverConfig.ClusterDNS {
+		return nil, errors.Errorf("machine uses the cluster DNS %q instead of %q", machineConfig.ClusterDNS, serverConfig.ClusterDNS)
+	}
+
+	if machineConfig.ClusterDomain != serverConfig.ClusterDomain {
+		return nil, errors.Errorf("machine uses the cluster domain %q instead of %q",
+			machineConfig.ClusterDomain, serverConfig.ClusterDomain)
+	}
+
+	role := rke2.ServerRoleOf(machine)
+
+	switch {
+	case machineConfig.DisableEtcd && machineConfig.DisableAPIserver:
+		return nil, errors.New("machine runs neither etcd nor the kube-apiserver")
+	case machineConfig.DisableAPIserver && role != controlplanev1.ServerRoleEtcd,
+		machineConfig.DisableEtcd && role != controlplanev1.ServerRoleControlPlane,
+		!machineConfig.DisableAPIserver && !machineConfig.DisableEtcd && role != "":
+		return nil, errors.Errorf("components run by the machine don't match its server role %q", role)
+	}
+
+	serverConfig.CNI = cni
+	serverConfig.CNIMultusEnable = multus
+	serverConfig.BindAddress = machineConfig.BindAddress
+	serverConfig.AdvertiseAddress = machineConfig.AdvertiseAddress
+	serverConfig.ServiceNodePortRange = machineConfig.ServiceNodePortRange
+	serverConfig.CloudProviderName = machineConfig.CloudProviderName
+
+	pluginComponents := []controlplanev1.DisabledPluginComponent{}
+	for _, plugin := range machineConfig.DisableComponents {
+		pluginComponents = append(pluginComponents, controlplanev1.DisabledPluginComponent(plugin))
+	}
+
+	kubernetesComponents := []controlplanev1.DisabledKubernetesComponent{}
+	if machineConfig.DisableKubeProxy {
+		kubernetesComponents = append(kubernetesComponents, controlplanev1.KubeProxy)
+	}
+
+	// The scheduler is disabled on the etcd machines whatever the server configuration.
+	if machineConfig.DisableScheduler && role != controlplanev1.ServerRoleEtcd {
+		kubernetesComponents = append(kubernetesComponents, controlplanev1.Scheduler)
+	}
+
+	if machineConfig.DisableCloudController {
+		kubernetesComponents = append(kubernetesComponents, controlplanev1.CloudController)
+	}
+
+	// The components keep the order of the RKE2ControlPlane, so that they are only reported as changed when they are.
+	if !sameElements(pluginComponents, serverConfig.DisableComponents.PluginComponents) {
+		serverConfig.DisableComponents.PluginComponents = pluginComponents
+	}
+
+	if !sameElements(kubernetesComponents, serverConfig.DisableComponents.KubernetesComponents) {
+		serverConfig.DisableComponents.KubernetesComponents = kubernetesComponents
+	}
+
+	return serverConfig, nil
+}
+
+// defaultCNI returns the CNI deployed by RKE2 for the given CNI setting.
+func defaultCNI(cni controlplanev1.CNI) controlplanev1.CNI {
+	if cni == "" {
+		return controlplanev1.Canal
+	}
+
+	return cni
+}
+
+// sameElements returns true if the given slices have the same elements, whatever their order.
+func sameElements[T cmp.Ordered](a, b []T) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+
+	return slices.Equal(a, b)
+}
+
+// hasServerPool returns true if the RKE2ControlPlane has a server pool running the given role.
+func hasServerPool(rcp *controlplanev1.RKE2ControlPlane, role controlplanev1.ServerRole) bool {
+	for _, pool := range rcp.Spec.ServerPools {
+		if pool.Role == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// adoptRKE2Config adds the RKE2ControlPlane to the owners of the RKE2Config of an adopted machine, like the
+// RKE2Configs it generates, the machine remaining its controller.
+func (r *RKE2ControlPlaneReconciler) adoptRKE2Config(
+	ctx context.Context,
+	rcp *controlplanev1.RKE2ControlPlane,
+	machine *clusterv1.Machine,
+) error {
+	config := &bootstrapv1.RKE2Config{}
+	key := client.ObjectKey{Namespace: machine.Namespace, Name: machine.Spec.Bootstrap.ConfigRef.Name}
+
+	if err := r.Client.Get(ctx, key, config); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return errors.Wrapf(err, "failed to get RKE2Config %s", key)
+	}
+
+	patchHelper, err := patch.NewHelper(config, r.Client)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create patch helper for RKE2Config %s", key)
+	}
+
+	config.SetOwnerReferences(util.EnsureOwnerRef(config.GetOwnerReferences(), metav1.OwnerReference{
+		APIVersion: controlplanev1.GroupVersion.String(),
+		Kind:       "RKE2ControlPlane",
+		Name:       rcp.Name,
+		UID:        rcp.UID,
+	}))
+
+	if err := patchHelper.Patch(ctx, config); err != nil {
+		return errors.Wrapf(err, "failed to adopt RKE2Config %s", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,346 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestAdoptMachines(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cluster, rcp, machines := newFakeControlPlane()
+	rcp.Spec.Version = "v1.31.3+rke2r1"
+	rcp.Spec.ServerConfig.CNI = controlplanev1.Calico
+
+	objects := []client.Object{rcp}
+
+	for _, machine := range machines {
+		machine.Spec.Version = ptr.To("v1.30.6+rke2r1")
+		machine.Spec.Bootstrap.ConfigRef = &corev1.ObjectReference{
+			APIVersion: bootstrapv1.GroupVersion.String(),
+			Kind:       "RKE2Config",
+			Name:       machine.Name,
+		}
+
+		objects = append(objects, machine, &bootstrapv1.RKE2Config{
+			ObjectMeta: metav1.ObjectMeta{Name: machine.Name, Namespace: metav1.NamespaceDefault},
+		})
+	}
+
+	// The server configuration of m0 is read from its bootstrap data, the one of the other machines is unknown.
+	machines["m0"].Spec.Bootstrap.DataSecretName = ptr.To("m0-bootstrap")
+	bootstrapData := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "m0-bootstrap", Namespace: metav1.NamespaceDefault},
+		Data: map[string][]byte{"value": []byte(`#cloud-config
+write_files:
+-   path: /etc/rancher/rke2/config.yaml
+    content: |
+      cni:
+      - calico
+      disable:
+      - rke2-ingress-nginx
+`)},
+	}
+	objects = append(objects, bootstrapData)
+
+	recorder := record.NewFakeRecorder(32)
+	fakeClient := newFakeClient(objects...)
+	r := &RKE2ControlPlaneReconciler{
+		Client:    fakeClient,
+		APIReader: fakeClient,
+		recorder:  recorder,
+	}
+
+	getMachine := func(name string) *clusterv1.Machine {
+		machine := &clusterv1.Machine{}
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: metav1.NamespaceDefault, Name: name}, machine)).To(Succeed())
+
+		return machine
+	}
+
+	// No machine is adopted while one of them can't be.
+	machines["m1"].Spec.Version = ptr.To("v1.29.10+rke2r1")
+
+	result, err := r.adoptMachines(ctx, cluster, rcp, machines)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("AdoptionFailed")))
+
+	for name := range machines {
+		g.Expect(metav1.GetControllerOf(getMachine(name))).To(BeNil())
+	}
+
+	// Nor while one of them runs another CNI.
+	machines["m1"].Spec.Version = ptr.To("v1.31.3+rke2r1")
+	bootstrapData.Data["value"] = []byte(strings.ReplaceAll(string(bootstrapData.Data["value"]), "calico", "cilium"))
+	g.Expect(r.Client.Update(ctx, bootstrapData)).To(Succeed())
+
+	result, err = r.adoptMachines(ctx, cluster, rcp, machines)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("runs the CNI cilium instead of calico")))
+
+	for name := range machines {
+		g.Expect(metav1.GetControllerOf(getMachine(name))).To(BeNil())
+	}
+
+	// The machines are adopted once they are all compatible.
+	bootstrapData.Data["value"] = []byte(strings.ReplaceAll(string(bootstrapData.Data["value"]), "cilium", "calico"))
+	g.Expect(r.Client.Update(ctx, bootstrapData)).To(Succeed())
+
+	result, err = r.adoptMachines(ctx, cluster, rcp, machines)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(DefaultRequeueTime))
+
+	for name := range machines {
+		machine := getMachine(name)
+		g.Expect(metav1.GetControllerOf(machine)).To(And(
+			HaveField("Kind", "RKE2ControlPlane"),
+			HaveField("Name", rcp.Name),
+		))
+		g.Expect(machine.Annotations).To(HaveKey(controlplanev1.PreTerminateHookCleanupAnnotation))
+
+		if name == "m0" {
+			g.Expect(machine.Annotations).To(HaveKeyWithValue(controlplanev1.RKE2ServerConfigurationAnnotation,
+				ContainSubstring(`"pluginComponents":["rke2-ingress-nginx"]`)))
+		} else {
+			g.Expect(machine.Annotations).ToNot(HaveKey(controlplanev1.RKE2ServerConfigurationAnnotation))
+		}
+
+		config := &bootstrapv1.RKE2Config{}
+		g.Expect(r.Client.Get(ctx, client.ObjectKey{Namespace: metav1.NamespaceDefault, Name: name}, config)).To(Succeed())
+		g.Expect(config.OwnerReferences).To(ContainElement(HaveField("Kind", "RKE2ControlPlane")))
+	}
+}
+
+func TestAdoptMachinesWhileDeleting(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cluster, rcp, machines := newFakeControlPlane()
+	rcp.Spec.Version = "v1.31.3+rke2r1"
+
+	objects := []client.Object{rcp}
+
+	for _, machine := range machines {
+		machine.Spec.Version = ptr.To("v1.31.3+rke2r1")
+		machine.Spec.Bootstrap.ConfigRef = &corev1.ObjectReference{
+			APIVersion: bootstrapv1.GroupVersion.String(),
+			Kind:       "RKE2Config",
+			Name:       machine.Name,
+		}
+
+		objects = append(objects, machine, &bootstrapv1.RKE2Config{
+			ObjectMeta: metav1.ObjectMeta{Name: machine.Name, Namespace: metav1.NamespaceDefault},
+		})
+	}
+
+	// The cache doesn't have the deletion of the RKE2ControlPlane yet, but the API server does.
+	deleting := rcp.DeepCopy()
+	deleting.Finalizers = []string{controlplanev1.RKE2ControlPlaneFinalizer}
+	deleting.DeletionTimestamp = ptr.To(metav1.Now())
+
+	recorder := record.NewFakeRecorder(32)
+	fakeClient := newFakeClient(objects...)
+	r := &RKE2ControlPlaneReconciler{
+		Client:    fakeClient,
+		APIReader: newFakeClient(deleting),
+		recorder:  recorder,
+	}
+
+	result, err := r.adoptMachines(ctx, cluster, rcp, machines)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(recorder.Events).To(BeEmpty())
+
+	for name := range machines {
+		machine := &clusterv1.Machine{}
+		g.Expect(fakeClient.Get(ctx, client.ObjectKey{Namespace: metav1.NamespaceDefault, Name: name}, machine)).To(Succeed())
+		g.Expect(metav1.GetControllerOf(machine)).To(BeNil())
+	}
+}
+
+func TestValidateAdoption(t *testing.T) {
+	_, rcp, machines := newFakeControlPlane()
+	rcp.Spec.Version = "v1.31.3+rke2r1"
+
+	tests := []struct {
+		name    string
+		mutate  func(machine *clusterv1.Machine)
+		wantErr string
+	}{
+		{
+			name:   "compatible machine",
+			mutate: func(_ *clusterv1.Machine) {},
+		},
+		{
+			name:    "no version",
+			mutate:  func(machine *clusterv1.Machine) { machine.Spec.Version = nil },
+			wantErr: "machine has no version",
+		},
+		{
+			name:    "unsupported version skew",
+			mutate:  func(machine *clusterv1.Machine) { machine.Spec.Version = ptr.To("v1.29.10+rke2r1") },
+			wantErr: "more than one minor version away",
+		},
+		{
+			name:    "not an RKE2Config",
+			mutate:  func(machine *clusterv1.Machine) { machine.Spec.Bootstrap.ConfigRef.Kind = "KubeadmConfig" },
+			wantErr: "not bootstrapped with an RKE2Config",
+		},
+		{
+			name:    "RKE2Config in another namespace",
+			mutate:  func(machine *clusterv1.Machine) { machine.Spec.Bootstrap.ConfigRef.Namespace = "other" },
+			wantErr: "not in the namespace of the machine",
+		},
+		{
+			name: "missing server pool",
+			mutate: func(machine *clusterv1.Machine) {
+				machine.Labels[controlplanev1.ServerRoleLabel] = string(controlplanev1.ServerRoleEtcd)
+			},
+			wantErr: "no server pool for the role etcd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := machines["m0"].DeepCopy()
+			machine.Spec.Version = ptr.To("v1.30.6+rke2r1")
+			machine.Spec.Bootstrap.ConfigRef = &corev1.ObjectReference{Kind: "RKE2Config", Name: "m0"}
+			tt.mutate(machine)
+
+			err := validateAdoption(rcp, machine)
+			if tt.wantErr == "" {
+				g.Expect(err).ToNot(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+			}
+		})
+	}
+}
+
+func TestServerConfigFromMachineConfig(t *testing.T) {
+	_, rcp, machines := newFakeControlPlane()
+	rcp.Spec.ServerConfig.CNI = controlplanev1.Calico
+	rcp.Spec.ServerConfig.DisableComponents.KubernetesComponents = []controlplanev1.DisabledKubernetesComponent{
+		controlplanev1.CloudController,
+		controlplanev1.KubeProxy,
+	}
+
+	tests := []struct {
+		name          string
+		role          controlplanev1.ServerRole
+		machineConfig rke2.ServerConfig
+		want          func(serverConfig *controlplanev1.RKE2ServerConfig)
+		wantErr       string
+	}{
+		{
+			name: "same server configuration",
+			machineConfig: rke2.ServerConfig{
+				CNI:                    []string{"calico"},
+				DisableCloudController: true,
+				DisableKubeProxy:       true,
+			},
+			want: func(_ *controlplanev1.RKE2ServerConfig) {},
+		},
+		{
+			name: "different disabled components",
+			machineConfig: rke2.ServerConfig{
+				CNI:               []string{"multus", "calico"},
+				DisableComponents: []string{"rke2-metrics-server"},
+				DisableKubeProxy:  true,
+			},
+			want: func(serverConfig *controlplanev1.RKE2ServerConfig) {
+				serverConfig.CNIMultusEnable = true
+				serverConfig.DisableComponents.PluginComponents = []controlplanev1.DisabledPluginComponent{"rke2-metrics-server"}
+				serverConfig.DisableComponents.KubernetesComponents = []controlplanev1.DisabledKubernetesComponent{controlplanev1.KubeProxy}
+			},
+		},
+		{
+			name: "scheduler disabled by the etcd role",
+			role: controlplanev1.ServerRoleEtcd,
+			machineConfig: rke2.ServerConfig{
+				CNI:                      []string{"calico"},
+				DisableAPIserver:         true,
+				DisableControllerManager: true,
+				DisableScheduler:         true,
+				DisableCloudController:   true,
+				DisableKubeProxy:         true,
+			},
+			want: func(_ *controlplanev1.RKE2ServerConfig) {},
+		},
+		{
+			name:          "default CNI",
+			machineConfig: rke2.ServerConfig{},
+			wantErr:       "runs the CNI canal instead of calico",
+		},
+		{
+			name:          "different cluster domain",
+			machineConfig: rke2.ServerConfig{CNI: []string{"calico"}, ClusterDomain: "example.local"},
+			wantErr:       `uses the cluster domain "example.local" instead of ""`,
+		},
+		{
+			name:          "components not matching the server role",
+			role:          controlplanev1.ServerRoleControlPlane,
+			machineConfig: rke2.ServerConfig{CNI: []string{"calico"}},
+			wantErr:       `don't match its server role "control-plane"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := machines["m0"].DeepCopy()
+			if tt.role != "" {
+				machine.Labels[controlplanev1.ServerRoleLabel] = string(tt.role)
+			}
+
+			serverConfig, err := serverConfigFromMachineConfig(rcp, machine, &tt.machineConfig)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+
+			want := rcp.Spec.ServerConfig.DeepCopy()
+			tt.want(want)
+			g.Expect(serverConfig).To(Equal(want))
+		})
+	}
+}
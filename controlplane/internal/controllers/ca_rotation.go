@@ -0,0 +1,257 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/kubeconfig"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/secret"
+)
+
+// reconcileCertificateAuthorityRotation rotates the certificate authorities of the control plane, when requested with
+// the RotateCertificateAuthoritiesAnnotation. Each phase of the rotation distributes a bundle of the previous and next
+// certificate authorities to the datastore of the control plane, to the secrets of the management cluster and to the
+// kubeconfig, then waits for the machines to be rolled out to use it:
+//  1. TrustingNewCA: certificates are still signed by the previous certificate authorities, both are trusted.
+//  2. SigningWithNewCA: certificates are signed by the next certificate authorities, both are trusted.
+//  3. DroppingOldCA: only the next certificate authorities are trusted.
+//
+// Control plane machines are rolled out by the RKE2ControlPlane. Worker machines only trust the certificate authorities
+// known when they joined the cluster, so they must be rolled out before the next phase is started.
+func (r *RKE2ControlPlaneReconciler) reconcileCertificateAuthorityRotation(
+	ctx context.Context,
+	controlPlane *rke2.ControlPlane,
+) (ctrl.Result, error) {
+	logger := controlPlane.Logger()
+	rcp := controlPlane.RCP
+	rotation := rcp.Status.CertificateAuthorityRotation
+
+	if rotation == nil || rotation.Phase == controlplanev1.CertificateAuthorityRotationPhaseCompleted {
+		id, found := rcp.Annotations[controlplanev1.RotateCertificateAuthoritiesAnnotation]
+		if !found || (rotation != nil && rotation.ID == id) || !rcp.Status.Initialized {
+			return ctrl.Result{}, nil
+		}
+
+		logger.Info("Starting rotation of the certificate authorities", "id", id)
+
+		rotation = &controlplanev1.CertificateAuthorityRotationStatus{
+			ID:    id,
+			Phase: controlplanev1.CertificateAuthorityRotationPhaseTrustingNewCA,
+		}
+		rcp.Status.CertificateAuthorityRotation = rotation
+	}
+
+	clusterKey := util.ObjectKey(controlPlane.Cluster)
+	controllerRef := metav1.NewControllerRef(rcp, controlplanev1.GroupVersion.WithKind("RKE2ControlPlane"))
+
+	caRotation, err := controlPlaneCertificates(rcp).LookupOrGenerateRotation(ctx, r.Client, clusterKey, *controllerRef)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to look up or generate the rotated certificate authorities")
+	}
+
+	if rotation.DistributedTime == nil {
+		return r.distributeCertificateAuthorities(ctx, controlPlane, caRotation)
+	}
+
+	// Control plane machines are rolled out together with the other machines needing rollout.
+	if needRollout := controlPlane.MachinesNeedingRollout(); len(needRollout) > 0 {
+		conditions.MarkFalse(rcp, controlplanev1.CertificateAuthoritiesRotatedCondition,
+			controlplanev1.RollingOutMachinesReason, clusterv1.ConditionSeverityInfo,
+			"Rolling out %d control plane machines for phase %s", len(needRollout), rotation.Phase)
+
+		return ctrl.Result{}, nil
+	}
+
+	if rotation.Phase != controlplanev1.CertificateAuthorityRotationPhaseDroppingOldCA {
+		workerMachines, err := r.managementCluster.GetMachinesForCluster(ctx, clusterKey,
+			collections.Not(collections.ControlPlaneMachines(controlPlane.Cluster.Name)),
+			func(machine *clusterv1.Machine) bool {
+				return machine.CreationTimestamp.Before(rotation.DistributedTime)
+			})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if len(workerMachines) > 0 {
+			names := workerMachines.Names()
+			slices.Sort(names)
+
+			logger.Info("Waiting for worker machines to be rolled out", "machines", names, "phase", rotation.Phase)
+			conditions.MarkFalse(rcp, controlplanev1.CertificateAuthoritiesRotatedCondition,
+				controlplanev1.WaitingForWorkerMachinesReason, clusterv1.ConditionSeverityWarning,
+				"Waiting for worker machines %s to be rolled out for phase %s", strings.Join(names, ", "), rotation.Phase)
+
+			return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+		}
+	}
+
+	logger.Info("Completed phase of the rotation of the certificate authorities", "phase", rotation.Phase)
+
+	rotation.DistributedTime = nil
+
+	switch rotation.Phase {
+	case controlplanev1.CertificateAuthorityRotationPhaseTrustingNewCA:
+		rotation.Phase = controlplanev1.CertificateAuthorityRotationPhaseSigningWithNewCA
+	case controlplanev1.CertificateAuthorityRotationPhaseSigningWithNewCA:
+		rotation.Phase = controlplanev1.CertificateAuthorityRotationPhaseDroppingOldCA
+	default:
+		if err := caRotation.Delete(ctx, r.Client, clusterKey); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		rotation.Phase = controlplanev1.CertificateAuthorityRotationPhaseCompleted
+		conditions.MarkTrue(rcp, controlplanev1.CertificateAuthoritiesRotatedCondition)
+		r.recorder.Eventf(rcp, corev1.EventTypeNormal, "CertificateAuthoritiesRotated",
+			"Rotated the certificate authorities (%s)", rotation.ID)
+
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// distributeCertificateAuthorities updates the certificate authorities stored in the datastore of the control plane
+// to the ones of the current phase of the rotation, then updates the secrets of the management cluster and the kubeconfig.
+func (r *RKE2ControlPlaneReconciler) distributeCertificateAuthorities(
+	ctx context.Context,
+	controlPlane *rke2.ControlPlane,
+	caRotation *secret.Rotation,
+) (ctrl.Result, error) {
+	logger := controlPlane.Logger()
+	rcp := controlPlane.RCP
+	rotation := rcp.Status.CertificateAuthorityRotation
+
+	var certificates secret.Certificates
+
+	switch rotation.Phase {
+	case controlplanev1.CertificateAuthorityRotationPhaseTrustingNewCA:
+		certificates = caRotation.TrustingNext()
+	case controlplanev1.CertificateAuthorityRotationPhaseSigningWithNewCA:
+		certificates = caRotation.SigningWithNext()
+	default:
+		certificates = caRotation.Completed()
+	}
+
+	machine := hostCommandMachine("", controlPlane.Machines)
+	if machine == nil {
+		conditions.MarkFalse(rcp, controlplanev1.CertificateAuthoritiesRotatedCondition,
+			controlplanev1.DistributingCertificateAuthoritiesReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for a control plane machine with a node to distribute the certificate authorities")
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	workloadCluster, err := r.GetWorkloadCluster(ctx, controlPlane)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get workload cluster")
+	}
+
+	command := rke2.NewCertificateAuthorityRotationCommand(rcp, certificates, machine.Status.NodeRef.Name)
+
+	pod, err := workloadCluster.GetHostCommandPod(ctx, command)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch {
+	case pod == nil:
+		if err := workloadCluster.StartHostCommand(ctx, command); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		logger.Info("Distributing the certificate authorities", "phase", rotation.Phase, "machine", machine.Name)
+		conditions.MarkFalse(rcp, controlplanev1.CertificateAuthoritiesRotatedCondition,
+			controlplanev1.DistributingCertificateAuthoritiesReason, clusterv1.ConditionSeverityInfo,
+			"Distributing the certificate authorities for phase %s on machine %s", rotation.Phase, machine.Name)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	case pod.Status.Phase == corev1.PodFailed:
+		if err := workloadCluster.DeleteHostCommandPod(ctx, command); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		logger.Info("Failed to distribute the certificate authorities, retrying", "phase", rotation.Phase, "machine", machine.Name)
+		conditions.MarkFalse(rcp, controlplanev1.CertificateAuthoritiesRotatedCondition,
+			controlplanev1.CertificateAuthoritiesDistributionFailedReason, clusterv1.ConditionSeverityWarning,
+			"Failed to distribute the certificate authorities for phase %s on machine %s, check the logs of the rke2-server service on the node",
+			rotation.Phase, machine.Name)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	case pod.Status.Phase != corev1.PodSucceeded:
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	if err := workloadCluster.DeleteHostCommandPod(ctx, command); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	clusterKey := util.ObjectKey(controlPlane.Cluster)
+
+	if err := certificates.Update(ctx, r.Client, clusterKey); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to update the certificate authorities secrets")
+	}
+
+	if err := r.regenerateKubeconfig(ctx, controlPlane); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("Distributed the certificate authorities", "phase", rotation.Phase)
+
+	rotation.DistributedTime = ptr.To(metav1.Now())
+
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// regenerateKubeconfig regenerates the kubeconfig secret owned by the control plane, so that it uses the current
+// certificate authorities.
+func (r *RKE2ControlPlaneReconciler) regenerateKubeconfig(ctx context.Context, controlPlane *rke2.ControlPlane) error {
+	clusterKey := util.ObjectKey(controlPlane.Cluster)
+
+	configSecret, err := secret.GetFromNamespacedName(ctx, r.Client, clusterKey, secret.Kubeconfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to retrieve kubeconfig Secret")
+	}
+
+	if !util.IsControlledBy(configSecret, controlPlane.RCP) {
+		return nil
+	}
+
+	controllerRef := metav1.NewControllerRef(controlPlane.RCP, controlplanev1.GroupVersion.WithKind("RKE2ControlPlane"))
+	endpoint := controlPlane.Cluster.Spec.ControlPlaneEndpoint.String()
+
+	if err := kubeconfig.CreateSecretWithOwner(ctx, r.Client, clusterKey, endpoint, *controllerRef); err != nil {
+		return errors.Wrap(err, "failed to regenerate kubeconfig")
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/kubeconfig"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/secret"
+)
+
+func TestReconcileCertificateAuthorityRotation(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	const version = "v1.30.2+rke2r1"
+
+	cluster, rcp, machines := newFakeControlPlane()
+	cluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: "example.com", Port: 6443}
+	rcp.TypeMeta = metav1.TypeMeta{APIVersion: controlplanev1.GroupVersion.String(), Kind: "RKE2ControlPlane"}
+	rcp.Annotations = map[string]string{controlplanev1.RotateCertificateAuthoritiesAnnotation: "1"}
+	rcp.Spec.Version = version
+
+	for _, machine := range machines {
+		machine.Spec.Version = ptr.To(version)
+		machine.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	}
+
+	worker := healthyMachine("worker")
+	worker.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+
+	fakeClient := newFakeClient()
+	clusterKey := util.ObjectKey(cluster)
+	controllerRef := metav1.NewControllerRef(rcp, controlplanev1.GroupVersion.WithKind("RKE2ControlPlane"))
+
+	g.Expect(controlPlaneCertificates(rcp).LookupOrGenerate(ctx, fakeClient, clusterKey, *controllerRef)).To(Succeed())
+	g.Expect(kubeconfig.CreateSecretWithOwner(ctx, fakeClient, clusterKey, "example.com:6443", *controllerRef)).To(Succeed())
+
+	clusterCA, err := secret.GetFromNamespacedName(ctx, fakeClient, clusterKey, secret.ClusterCA)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	previousCA := clusterCA.Data[secret.TLSCrtDataName]
+
+	workloadCluster := &fakeWorkloadCluster{}
+	managementCluster := &fakeManagementCluster{Machines: collections.FromMachines(machines.UnsortedList()...), Workload: workloadCluster}
+	managementCluster.Machines.Insert(worker)
+	r := &RKE2ControlPlaneReconciler{
+		Client:            fakeClient,
+		recorder:          record.NewFakeRecorder(32),
+		managementCluster: managementCluster,
+	}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	reconcile := func() ctrl.Result {
+		result, err := r.reconcileCertificateAuthorityRotation(ctx, controlPlane)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		return result
+	}
+
+	clusterCAData := func() []byte {
+		clusterCA, err := secret.GetFromNamespacedName(ctx, fakeClient, clusterKey, secret.ClusterCA)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		return clusterCA.Data[secret.TLSCrtDataName]
+	}
+
+	kubeconfigCAData := func() []byte {
+		kubeconfigSecret, err := secret.GetFromNamespacedName(ctx, fakeClient, clusterKey, secret.Kubeconfig)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		config, err := clientcmd.Load(kubeconfigSecret.Data[secret.KubeconfigDataName])
+		g.Expect(err).ToNot(HaveOccurred())
+
+		return config.Clusters[cluster.Name].CertificateAuthorityData
+	}
+
+	// distribute runs the command distributing the certificate authorities of the current phase.
+	distribute := func(phase controlplanev1.CertificateAuthorityRotationPhase) {
+		g.Expect(reconcile().RequeueAfter).To(Equal(DefaultRequeueTime))
+		g.Expect(rcp.Status.CertificateAuthorityRotation.Phase).To(Equal(phase))
+		g.Expect(rcp.Status.CertificateAuthorityRotation.DistributedTime).To(BeNil())
+		g.Expect(workloadCluster.StartedCommands).ToNot(BeEmpty())
+		g.Expect(workloadCluster.StartedCommands[len(workloadCluster.StartedCommands)-1].Files).ToNot(BeEmpty())
+		g.Expect(conditions.GetReason(rcp, controlplanev1.CertificateAuthoritiesRotatedCondition)).
+			To(Equal(controlplanev1.DistributingCertificateAuthoritiesReason))
+
+		workloadCluster.HostCommandPod = &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodSucceeded}}
+		g.Expect(reconcile().Requeue).To(BeTrue())
+		g.Expect(workloadCluster.HostCommandPod).To(BeNil())
+		g.Expect(rcp.Status.CertificateAuthorityRotation.DistributedTime).ToNot(BeNil())
+		g.Expect(kubeconfigCAData()).To(Equal(clusterCAData()))
+	}
+
+	// rollOut simulates the rollout of the control plane machines, which is triggered by MachinesNeedingRollout.
+	rollOut := func() {
+		g.Expect(reconcile()).To(BeZero())
+		g.Expect(conditions.GetReason(rcp, controlplanev1.CertificateAuthoritiesRotatedCondition)).
+			To(Equal(controlplanev1.RollingOutMachinesReason))
+
+		for _, machine := range controlPlane.Machines {
+			machine.CreationTimestamp = *rcp.Status.CertificateAuthorityRotation.DistributedTime
+		}
+	}
+
+	// Worker machines must be rolled out before the certificates are signed by the next certificate authorities.
+	distribute(controlplanev1.CertificateAuthorityRotationPhaseTrustingNewCA)
+	g.Expect(bytes.HasPrefix(clusterCAData(), previousCA)).To(BeTrue())
+	g.Expect(bytes.Count(clusterCAData(), []byte("BEGIN CERTIFICATE"))).To(Equal(2))
+	rollOut()
+
+	g.Expect(reconcile().RequeueAfter).To(Equal(DefaultRequeueTime))
+	g.Expect(conditions.GetReason(rcp, controlplanev1.CertificateAuthoritiesRotatedCondition)).
+		To(Equal(controlplanev1.WaitingForWorkerMachinesReason))
+
+	delete(managementCluster.Machines, worker.Name)
+	g.Expect(reconcile().Requeue).To(BeTrue())
+
+	distribute(controlplanev1.CertificateAuthorityRotationPhaseSigningWithNewCA)
+	g.Expect(bytes.HasSuffix(clusterCAData(), previousCA)).To(BeTrue())
+	g.Expect(bytes.Count(clusterCAData(), []byte("BEGIN CERTIFICATE"))).To(Equal(2))
+	rollOut()
+	g.Expect(reconcile().Requeue).To(BeTrue())
+
+	distribute(controlplanev1.CertificateAuthorityRotationPhaseDroppingOldCA)
+	g.Expect(bytes.Contains(clusterCAData(), previousCA)).To(BeFalse())
+	g.Expect(bytes.Count(clusterCAData(), []byte("BEGIN CERTIFICATE"))).To(Equal(1))
+	rollOut()
+	g.Expect(reconcile()).To(BeZero())
+	g.Expect(rcp.Status.CertificateAuthorityRotation.Phase).To(Equal(controlplanev1.CertificateAuthorityRotationPhaseCompleted))
+	g.Expect(conditions.IsTrue(rcp, controlplanev1.CertificateAuthoritiesRotatedCondition)).To(BeTrue())
+
+	for _, purpose := range []secret.Purpose{secret.Previous(secret.ClusterCA), secret.Next(secret.ClusterCA)} {
+		_, err := secret.GetFromNamespacedName(ctx, fakeClient, clusterKey, purpose)
+		g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	}
+
+	// The rotation is not started again until the annotation changes.
+	startedCommands := len(workloadCluster.StartedCommands)
+	g.Expect(reconcile()).To(BeZero())
+	g.Expect(workloadCluster.StartedCommands).To(HaveLen(startedCommands))
+
+	rcp.Annotations[controlplanev1.RotateCertificateAuthoritiesAnnotation] = "2"
+	g.Expect(reconcile().RequeueAfter).To(Equal(DefaultRequeueTime))
+	g.Expect(rcp.Status.CertificateAuthorityRotation.ID).To(Equal("2"))
+	g.Expect(workloadCluster.StartedCommands).To(HaveLen(startedCommands + 1))
+}
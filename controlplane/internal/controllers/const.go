@@ -26,4 +26,16 @@ const (
 	// preflightFailedRequeueAfter is how long to wait before trying to scale
 	// up/down if some preflight check for those operation has failed.
 	preflightFailedRequeueAfter = 15 * time.Second
+
+	// minReplicasForScaleDownFirst is the minimum number of replicas required to roll out
+	// control plane machines with the ScaleDownFirst strategy without losing etcd quorum.
+	minReplicasForScaleDownFirst = 3
+
+	// defaultEtcdFragmentationThreshold is the default percentage of free space in the database of an etcd member
+	// above which the member is defragmented.
+	defaultEtcdFragmentationThreshold int32 = 50
+
+	// certificatesExpiryCheckInterval is how often the expiry date of the certificates of a control plane machine is
+	// read again, as RKE2 renews the certificates close to their expiry when it is restarted.
+	certificatesExpiryCheckInterval = time.Hour
 )
@@ -0,0 +1,83 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"sigs.k8s.io/cluster-api/util/collections"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+// reconcileEtcdDefragmentation defragments the etcd members of the control plane whose database is fragmented, when
+// enabled with EtcdDefragmentation. A single member is defragmented per reconciliation, as a member does not serve
+// requests while it is defragmented, and only while all the control plane machines are provisioned.
+// A member which fails to be defragmented is reported with an EtcdDefragmentationFailed event, and defragmented again
+// on a later reconciliation of the stable control plane.
+func (r *RKE2ControlPlaneReconciler) reconcileEtcdDefragmentation(ctx context.Context, controlPlane *rke2.ControlPlane) (ctrl.Result, error) {
+	logger := controlPlane.Logger()
+	rcp := controlPlane.RCP
+
+	if rcp.Spec.EtcdDefragmentation == nil || !rcp.Status.Initialized {
+		return ctrl.Result{}, nil
+	}
+
+	if _, found := rcp.Annotations[controlplanev1.LegacyRKE2ControlPlane]; found {
+		return ctrl.Result{}, nil
+	}
+
+	unstableMachines := controlPlane.Machines.Filter(collections.Or(
+		collections.HasDeletionTimestamp,
+		collections.Not(collections.HasNode()),
+	))
+	if int32(controlPlane.Machines.Len()) != controlPlane.DesiredReplicas() || unstableMachines.Len() > 0 {
+		return ctrl.Result{}, nil
+	}
+
+	workloadCluster, err := r.GetWorkloadCluster(ctx, controlPlane)
+	if err != nil {
+		logger.Error(err, "Failed to get workload cluster to defragment etcd members")
+
+		return ctrl.Result{}, nil
+	}
+
+	threshold := ptr.Deref(rcp.Spec.EtcdDefragmentation.FragmentationThreshold, defaultEtcdFragmentationThreshold)
+
+	member, err := workloadCluster.DefragmentEtcdMember(ctx, threshold)
+	if err != nil {
+		logger.Error(err, "Failed to defragment etcd members")
+		r.recorder.Eventf(rcp, corev1.EventTypeWarning, "EtcdDefragmentationFailed", "Failed to defragment etcd members: %v", err)
+
+		return ctrl.Result{}, nil
+	}
+
+	if member == "" {
+		return ctrl.Result{}, nil
+	}
+
+	logger.Info("Defragmented etcd member", "member", member)
+	r.recorder.Eventf(rcp, corev1.EventTypeNormal, "EtcdMemberDefragmented", "Defragmented etcd member %s", member)
+
+	// Requeue to defragment the next member, if any.
+	return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestReconcileEtcdDefragmentation(t *testing.T) {
+	tests := []struct {
+		name              string
+		defragmentation   *controlplanev1.EtcdDefragmentation
+		replicas          int32
+		deletingMachine   bool
+		expectedThreshold int32
+	}{
+		{
+			name: "does nothing when the defragmentation is not enabled",
+		},
+		{
+			name:              "defragments with the default threshold",
+			defragmentation:   &controlplanev1.EtcdDefragmentation{},
+			expectedThreshold: 50,
+		},
+		{
+			name:              "defragments with the configured threshold",
+			defragmentation:   &controlplanev1.EtcdDefragmentation{FragmentationThreshold: ptr.To[int32](30)},
+			expectedThreshold: 30,
+		},
+		{
+			name:            "does nothing while scaling",
+			defragmentation: &controlplanev1.EtcdDefragmentation{},
+			replicas:        5,
+		},
+		{
+			name:            "does nothing while a machine is deleted",
+			defragmentation: &controlplanev1.EtcdDefragmentation{},
+			deletingMachine: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster, rcp, machines := newFakeControlPlane()
+			rcp.Spec.EtcdDefragmentation = tt.defragmentation
+
+			if tt.replicas != 0 {
+				rcp.Spec.Replicas = ptr.To(tt.replicas)
+			}
+
+			if tt.deletingMachine {
+				machines.Oldest().DeletionTimestamp = ptr.To(metav1.Now())
+			}
+
+			recorder := record.NewFakeRecorder(32)
+			workloadCluster := &fakeWorkloadCluster{DefragmentedMember: "m0-a1b2c3"}
+			r := &RKE2ControlPlaneReconciler{
+				Client:            newFakeClient(),
+				recorder:          recorder,
+				managementCluster: &fakeManagementCluster{Machines: machines, Workload: workloadCluster},
+			}
+			controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+			result, err := r.reconcileEtcdDefragmentation(context.Background(), controlPlane)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(workloadCluster.DefragmentThreshold).To(Equal(tt.expectedThreshold))
+
+			if tt.expectedThreshold == 0 {
+				g.Expect(result).To(BeZero())
+				g.Expect(recorder.Events).To(BeEmpty())
+
+				return
+			}
+
+			g.Expect(result.RequeueAfter).To(Equal(DefaultRequeueTime))
+			g.Expect(recorder.Events).To(Receive(ContainSubstring("Defragmented etcd member m0-a1b2c3")))
+		})
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/collections"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+type fakeManagementCluster struct {
+	client.Reader
+
+	Machines collections.Machines
+	Workload *fakeWorkloadCluster
+}
+
+func (f *fakeManagementCluster) GetMachinesForCluster(
+	_ context.Context, _ client.ObjectKey, filters ...collections.Func,
+) (collections.Machines, error) {
+	return f.Machines.Filter(filters...), nil
+}
+
+func (f *fakeManagementCluster) GetWorkloadCluster(_ context.Context, _ client.ObjectKey) (rke2.WorkloadCluster, error) {
+	return f.Workload, nil
+}
+
+// fakeWorkloadCluster embeds the WorkloadCluster interface, so that tests only need to implement the methods they use.
+type fakeWorkloadCluster struct {
+	rke2.WorkloadCluster
+
+	EtcdMembersResult   []string
+	ForwardedLeaders    []string
+	NodeVersions        map[string]string
+	AppliedPlan         *rke2.UpgradePlan
+	DeletedPlan         *rke2.UpgradePlan
+	StartedCommands     []*rke2.HostCommand
+	HostCommandPod      *corev1.Pod
+	EtcdSnapshotsResult []controlplanev1.EtcdSnapshotInfo
+	CertificateExpiries map[string]time.Time
+	DefragmentedMember  string
+	DefragmentThreshold int32
+	SyncedManifests     []*unstructured.Unstructured
+	WorkloadCleanups    int
+	CleanupRemaining    []string
+}
+
+func (f *fakeWorkloadCluster) InitWorkload(_ context.Context, _ *rke2.ControlPlane) error {
+	return nil
+}
+
+func (f *fakeWorkloadCluster) EtcdMembers(_ context.Context) ([]string, error) {
+	return f.EtcdMembersResult, nil
+}
+
+func (f *fakeWorkloadCluster) ForwardEtcdLeadership(_ context.Context, machine *clusterv1.Machine, _ *clusterv1.Machine) error {
+	f.ForwardedLeaders = append(f.ForwardedLeaders, machine.Name)
+
+	return nil
+}
+
+func (f *fakeWorkloadCluster) ApplyUpgradePlan(_ context.Context, plan *rke2.UpgradePlan) error {
+	f.AppliedPlan = plan
+
+	return nil
+}
+
+func (f *fakeWorkloadCluster) DeleteUpgradePlan(_ context.Context, plan *rke2.UpgradePlan) error {
+	f.DeletedPlan = plan
+
+	return nil
+}
+
+func (f *fakeWorkloadCluster) NodeVersion(nodeName string) (string, bool) {
+	version, ok := f.NodeVersions[nodeName]
+
+	return version, ok
+}
+
+func (f *fakeWorkloadCluster) StartHostCommand(_ context.Context, command *rke2.HostCommand) error {
+	f.StartedCommands = append(f.StartedCommands, command)
+
+	return nil
+}
+
+func (f *fakeWorkloadCluster) GetHostCommandPod(_ context.Context, _ *rke2.HostCommand) (*corev1.Pod, error) {
+	return f.HostCommandPod, nil
+}
+
+func (f *fakeWorkloadCluster) DeleteHostCommandPod(_ context.Context, _ *rke2.HostCommand) error {
+	f.HostCommandPod = nil
+
+	return nil
+}
+
+func (f *fakeWorkloadCluster) EtcdSnapshots(_ context.Context) ([]controlplanev1.EtcdSnapshotInfo, error) {
+	return f.EtcdSnapshotsResult, nil
+}
+
+func (f *fakeWorkloadCluster) APIServerCertificateExpiry(_ context.Context, nodeName string) (*time.Time, error) {
+	expiry, found := f.CertificateExpiries[nodeName]
+	if !found {
+		return nil, fmt.Errorf("no certificate for node %s", nodeName)
+	}
+
+	return &expiry, nil
+}
+
+func (f *fakeWorkloadCluster) DefragmentEtcdMember(_ context.Context, thresholdPercent int32) (string, error) {
+	f.DefragmentThreshold = thresholdPercent
+
+	return f.DefragmentedMember, nil
+}
+
+func (f *fakeWorkloadCluster) SyncManifests(
+	_ context.Context,
+	objects []*unstructured.Unstructured,
+	_ []controlplanev1.ManifestReference,
+) ([]controlplanev1.ManifestReference, error) {
+	f.SyncedManifests = objects
+
+	applied := []controlplanev1.ManifestReference{}
+	for _, obj := range objects {
+		applied = append(applied, controlplanev1.ManifestReference{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		})
+	}
+
+	return applied, nil
+}
+
+func (f *fakeWorkloadCluster) CleanupWorkload(_ context.Context, _ *controlplanev1.WorkloadCleanup) ([]string, error) {
+	f.WorkloadCleanups++
+
+	return f.CleanupRemaining, nil
+}
+
+// newFakeControlPlane returns an initialized RKE2ControlPlane with three replicas owned by a Cluster,
+// and its healthy control plane machines, from the oldest to the newest.
+func newFakeControlPlane() (*clusterv1.Cluster, *controlplanev1.RKE2ControlPlane, collections.Machines) {
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: metav1.NamespaceDefault}}
+	rcp := &controlplanev1.RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: metav1.NamespaceDefault,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Cluster",
+				Name:       cluster.Name,
+			}},
+		},
+		Spec:   controlplanev1.RKE2ControlPlaneSpec{Replicas: ptr.To[int32](3)},
+		Status: controlplanev1.RKE2ControlPlaneStatus{Initialized: true},
+	}
+
+	machines := collections.New()
+
+	for i := range 3 {
+		machine := healthyMachine(fmt.Sprintf("m%d", i))
+		machine.CreationTimestamp = metav1.NewTime(time.Now().Add(time.Duration(i) * time.Minute))
+		machine.Labels = map[string]string{
+			clusterv1.ClusterNameLabel:         cluster.Name,
+			clusterv1.MachineControlPlaneLabel: "",
+		}
+		machines.Insert(machine)
+	}
+
+	return cluster, rcp, machines
+}
+
+// newFakeClient returns a fake client supporting the types used by the controllers.
+func newFakeClient(objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(clusterv1.AddToScheme(scheme))
+	utilruntime.Must(controlplanev1.AddToScheme(scheme))
+	utilruntime.Must(bootstrapv1.AddToScheme(scheme))
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objects...).
+		WithStatusSubresource(
+			&controlplanev1.RKE2ControlPlane{},
+			&controlplanev1.RKE2EtcdRestore{},
+			&controlplanev1.RKE2EtcdSnapshot{},
+		).
+		Build()
+}
+
+func machinesToObjects(machines collections.Machines) []client.Object {
+	objects := []client.Object{}
+	for _, machine := range machines.UnsortedList() {
+		objects = append(objects, machine)
+	}
+
+	return objects
+}
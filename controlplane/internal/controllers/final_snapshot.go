@@ -0,0 +1,116 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+// finalEtcdSnapshotName returns the name of the RKE2EtcdSnapshot taking the final etcd snapshot of a RKE2ControlPlane.
+// It includes the UID of the RKE2ControlPlane, so that the snapshot of a previous control plane of the same name,
+// which is retained, is not mistaken for the one of the control plane being deleted.
+func finalEtcdSnapshotName(rcp *controlplanev1.RKE2ControlPlane) string {
+	return fmt.Sprintf("%s-final-%.8s", rcp.Name, rcp.UID)
+}
+
+// reconcileFinalEtcdSnapshot takes a final etcd snapshot of a RKE2ControlPlane being deleted with the Snapshot
+// DeletionPolicy, and returns a non-zero result until it is completed. The snapshot is taken by a RKE2EtcdSnapshot,
+// which is not owned by the RKE2ControlPlane or its cluster, so that it is retained with the location of the snapshot
+// once they are deleted.
+func (r *RKE2ControlPlaneReconciler) reconcileFinalEtcdSnapshot(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	rcp *controlplanev1.RKE2ControlPlane,
+) (ctrl.Result, error) {
+	logger := ctrl.LoggerFrom(ctx)
+
+	// There is no etcd data to snapshot before the control plane is initialized.
+	if rcp.Spec.DeletionPolicy != controlplanev1.DeletionPolicySnapshot || !rcp.Status.Initialized {
+		return ctrl.Result{}, nil
+	}
+
+	snapshot := &controlplanev1.RKE2EtcdSnapshot{}
+	key := client.ObjectKey{Namespace: rcp.Namespace, Name: finalEtcdSnapshotName(rcp)}
+
+	if err := r.Client.Get(ctx, key, snapshot); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to get final etcd snapshot %s", key)
+		}
+
+		snapshot = &controlplanev1.RKE2EtcdSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels:    map[string]string{clusterv1.ClusterNameLabel: cluster.Name},
+			},
+			Spec: controlplanev1.RKE2EtcdSnapshotSpec{ControlPlaneName: rcp.Name},
+		}
+
+		if err := r.Client.Create(ctx, snapshot); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to create final etcd snapshot %s", key)
+		}
+
+		logger.Info("Taking final etcd snapshot before deleting the control plane machines", "snapshot", key.Name)
+	}
+
+	switch snapshot.Status.Phase {
+	case controlplanev1.RKE2EtcdSnapshotPhaseCompleted:
+		if !conditions.IsTrue(rcp, controlplanev1.FinalEtcdSnapshotCreatedCondition) {
+			location := cmp.Or(snapshot.Status.Location, "unknown")
+
+			logger.Info("Took final etcd snapshot", "snapshot", key.Name, "location", location)
+			r.recorder.Eventf(rcp, corev1.EventTypeNormal, "FinalEtcdSnapshotCreated",
+				"Took final etcd snapshot %s, stored at %s", key.Name, location)
+			conditions.MarkTrue(rcp, controlplanev1.FinalEtcdSnapshotCreatedCondition)
+		}
+
+		return ctrl.Result{}, nil
+	case controlplanev1.RKE2EtcdSnapshotPhaseFailed:
+		if conditions.GetReason(rcp, controlplanev1.FinalEtcdSnapshotCreatedCondition) != controlplanev1.FinalEtcdSnapshotFailedReason {
+			r.recorder.Eventf(rcp, corev1.EventTypeWarning, controlplanev1.FinalEtcdSnapshotFailedReason,
+				"Final etcd snapshot %s failed, delete it to retry or set the Delete deletionPolicy to proceed", key.Name)
+		}
+
+		conditions.MarkFalse(rcp, controlplanev1.FinalEtcdSnapshotCreatedCondition,
+			controlplanev1.FinalEtcdSnapshotFailedReason, clusterv1.ConditionSeverityError,
+			"Final etcd snapshot %s failed: %s", key.Name,
+			conditions.GetMessage(snapshot, controlplanev1.EtcdSnapshotCreatedCondition))
+
+		// The failed snapshot is not watched, its deletion is picked up when requeuing.
+		return ctrl.Result{RequeueAfter: deleteRequeueAfter}, nil
+	default:
+		conditions.MarkFalse(rcp, controlplanev1.FinalEtcdSnapshotCreatedCondition,
+			controlplanev1.TakingFinalEtcdSnapshotReason, clusterv1.ConditionSeverityInfo,
+			"Taking final etcd snapshot %s", key.Name)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+func TestReconcileFinalEtcdSnapshot(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cluster, rcp, _ := newFakeControlPlane()
+	rcp.UID = types.UID("0123456789abcdef")
+
+	recorder := record.NewFakeRecorder(32)
+	r := &RKE2ControlPlaneReconciler{
+		Client:   newFakeClient(),
+		recorder: recorder,
+	}
+
+	snapshot := &controlplanev1.RKE2EtcdSnapshot{}
+	key := client.ObjectKey{Namespace: rcp.Namespace, Name: "test-final-01234567"}
+
+	// No snapshot is taken with the Delete deletion policy.
+	result, err := r.reconcileFinalEtcdSnapshot(ctx, cluster, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(apierrors.IsNotFound(r.Client.Get(ctx, key, snapshot))).To(BeTrue())
+
+	// The machines are not deleted until the final snapshot is taken.
+	rcp.Spec.DeletionPolicy = controlplanev1.DeletionPolicySnapshot
+
+	result, err = r.reconcileFinalEtcdSnapshot(ctx, cluster, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(DefaultRequeueTime))
+	g.Expect(r.Client.Get(ctx, key, snapshot)).To(Succeed())
+	g.Expect(snapshot.Spec.ControlPlaneName).To(Equal(rcp.Name))
+	g.Expect(snapshot.Labels).To(HaveKeyWithValue(clusterv1.ClusterNameLabel, cluster.Name))
+	g.Expect(snapshot.OwnerReferences).To(BeEmpty())
+	g.Expect(conditions.GetReason(rcp, controlplanev1.FinalEtcdSnapshotCreatedCondition)).
+		To(Equal(controlplanev1.TakingFinalEtcdSnapshotReason))
+
+	// A failed snapshot blocks the deletion until it is deleted to retry.
+	snapshot.Status.Phase = controlplanev1.RKE2EtcdSnapshotPhaseFailed
+	g.Expect(r.Client.Status().Update(ctx, snapshot)).To(Succeed())
+
+	result, err = r.reconcileFinalEtcdSnapshot(ctx, cluster, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(deleteRequeueAfter))
+	g.Expect(conditions.GetReason(rcp, controlplanev1.FinalEtcdSnapshotCreatedCondition)).
+		To(Equal(controlplanev1.FinalEtcdSnapshotFailedReason))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring(controlplanev1.FinalEtcdSnapshotFailedReason)))
+
+	g.Expect(r.Client.Delete(ctx, snapshot)).To(Succeed())
+
+	_, err = r.reconcileFinalEtcdSnapshot(ctx, cluster, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(r.Client.Get(ctx, key, snapshot)).To(Succeed())
+	g.Expect(snapshot.Status.Phase).To(BeEmpty())
+
+	// The location of the completed snapshot is reported.
+	snapshot.Status.Phase = controlplanev1.RKE2EtcdSnapshotPhaseCompleted
+	snapshot.Status.Location = "s3://snapshots/test-final-01234567-m0-1700000000"
+	g.Expect(r.Client.Status().Update(ctx, snapshot)).To(Succeed())
+
+	result, err = r.reconcileFinalEtcdSnapshot(ctx, cluster, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(conditions.IsTrue(rcp, controlplanev1.FinalEtcdSnapshotCreatedCondition)).To(BeTrue())
+	g.Expect(recorder.Events).To(Receive(ContainSubstring(snapshot.Status.Location)))
+}
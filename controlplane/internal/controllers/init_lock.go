@@ -0,0 +1,89 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/locking"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+// reconcileInitLock reports the init lock held by the control plane Machine initializing the cluster in the
+// InitLockReleased condition, and deletes the Machine once the lock is stale, as it did not get a node within the
+// init timeout, so that the control plane is initialized by the Machine replacing it.
+func (r *RKE2ControlPlaneReconciler) reconcileInitLock(ctx context.Context, controlPlane *rke2.ControlPlane) (ctrl.Result, error) {
+	logger := controlPlane.Logger()
+	rcp := controlPlane.RCP
+
+	if rcp.Status.Initialized {
+		conditions.MarkTrue(rcp, controlplanev1.InitLockReleasedCondition)
+
+		return ctrl.Result{}, nil
+	}
+
+	lock, err := locking.GetInitLock(ctx, r.Client, controlPlane.Cluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch {
+	case lock == nil:
+		conditions.MarkFalse(rcp, controlplanev1.InitLockReleasedCondition,
+			controlplanev1.WaitingForInitLockReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for a control plane machine to acquire the init lock")
+
+		return ctrl.Result{}, nil
+	case !lock.Stale:
+		conditions.MarkFalse(rcp, controlplanev1.InitLockReleasedCondition,
+			controlplanev1.InitLockHeldReason, clusterv1.ConditionSeverityInfo,
+			"Machine %s is initializing the control plane since %s", lock.MachineName, lock.AcquireTime.Format(time.RFC3339))
+
+		return ctrl.Result{}, nil
+	}
+
+	conditions.MarkFalse(rcp, controlplanev1.InitLockReleasedCondition,
+		controlplanev1.InitLockStaleReason, clusterv1.ConditionSeverityWarning,
+		"Machine %s holding the init lock shows no progress", lock.MachineName)
+
+	// The lock is taken over by the Machine replacing the one holding it, which is deleted unless it already is.
+	machine, found := controlPlane.Machines[lock.MachineName]
+	if !found || !machine.DeletionTimestamp.IsZero() || machine.Status.NodeRef != nil {
+		return ctrl.Result{}, nil
+	}
+
+	logger.Info("Deleting the control plane Machine holding the stale init lock", "Machine", machine.Name)
+
+	if err := r.Client.Delete(ctx, machine); err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to delete machine %s holding the stale init lock", machine.Name)
+	}
+
+	r.recorder.Eventf(rcp, corev1.EventTypeWarning, controlplanev1.InitLockStaleReason,
+		"Deleted Machine %s, which did not get a node while holding the init lock", machine.Name)
+
+	return ctrl.Result{RequeueAfter: deleteRequeueAfter}, nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/locking"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestReconcileInitLock(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cluster, rcp, machines := newFakeControlPlane()
+	rcp.Status.Initialized = false
+
+	machine := machines.Oldest()
+	machine.Status.NodeRef = nil
+	machines = collections.FromMachines(machine)
+
+	cl := newFakeClient(cluster, machine)
+	recorder := record.NewFakeRecorder(32)
+	r := &RKE2ControlPlaneReconciler{
+		Client:   cl,
+		recorder: recorder,
+	}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	// The control plane waits for a machine to acquire the init lock.
+	result, err := r.reconcileInitLock(ctx, controlPlane)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(conditions.GetReason(rcp, controlplanev1.InitLockReleasedCondition)).To(Equal(controlplanev1.WaitingForInitLockReason))
+
+	// The machine holding the init lock initializes the control plane.
+	g.Expect(locking.NewControlPlaneInitMutex(cl, time.Hour).Lock(ctx, cluster, machine)).To(BeTrue())
+
+	result, err = r.reconcileInitLock(ctx, controlPlane)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(conditions.GetReason(rcp, controlplanev1.InitLockReleasedCondition)).To(Equal(controlplanev1.InitLockHeldReason))
+	g.Expect(conditions.GetMessage(rcp, controlplanev1.InitLockReleasedCondition)).To(ContainSubstring(machine.Name))
+
+	leases := &coordinationv1.LeaseList{}
+	g.Expect(cl.List(ctx, leases)).To(Succeed())
+	g.Expect(leases.Items).To(HaveLen(1))
+
+	// The machine is not deleted while the lock is not renewed, within the init timeout.
+	lease := &leases.Items[0]
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now().Add(-2 * locking.InitLockLeaseDuration)}
+	g.Expect(cl.Update(ctx, lease)).To(Succeed())
+
+	result, err = r.reconcileInitLock(ctx, controlPlane)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(conditions.GetReason(rcp, controlplanev1.InitLockReleasedCondition)).To(Equal(controlplanev1.InitLockHeldReason))
+
+	// The machine is deleted once the init timeout is reached without the machine getting a node.
+	lease.Spec.AcquireTime = &metav1.MicroTime{Time: time.Now().Add(-2 * time.Hour)}
+	g.Expect(cl.Update(ctx, lease)).To(Succeed())
+
+	result, err = r.reconcileInitLock(ctx, controlPlane)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(deleteRequeueAfter))
+	g.Expect(conditions.GetReason(rcp, controlplanev1.InitLockReleasedCondition)).To(Equal(controlplanev1.InitLockStaleReason))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("Deleted Machine " + machine.Name)))
+	g.Expect(apierrors.IsNotFound(cl.Get(ctx, client.ObjectKeyFromObject(machine), machine))).To(BeTrue())
+
+	// The init lock is released once the control plane is initialized.
+	rcp.Status.Initialized = true
+
+	result, err = r.reconcileInitLock(ctx, controlPlane)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(conditions.IsTrue(rcp, controlplanev1.InitLockReleasedCondition)).To(BeTrue())
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/patch"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	rke2 "github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+	bsutil "github.com/rancher/cluster-api-provider-rke2/pkg/util"
+)
+
+// reconcileInPlaceUpgrade upgrades the RKE2 version of the given machines in place, by applying a
+// system-upgrade-controller Plan in the workload cluster and tracking the version reported by each node.
+// Once a node runs the desired version, the version of the corresponding Machine is updated.
+func (r *RKE2ControlPlaneReconciler) reconcileInPlaceUpgrade(
+	ctx context.Context,
+	controlPlane *rke2.ControlPlane,
+	machinesRequireUpgrade collections.Machines,
+) (ctrl.Result, error) {
+	logger := controlPlane.Logger()
+
+	// If the cluster is not yet initialized, there is no way to connect to the workload cluster. Return early.
+	if !controlPlane.RCP.Status.Initialized {
+		logger.Info("ControlPlane not yet initialized")
+
+		return ctrl.Result{}, nil
+	}
+
+	workloadCluster, err := r.GetWorkloadCluster(ctx, controlPlane)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get workload cluster")
+	}
+
+	if err := workloadCluster.InitWorkload(ctx, controlPlane); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The nodes of the etcd server pool are upgraded first, with their own plan, so that a single node is upgraded at
+	// a time, and the etcd members run the new version before the kube-apiservers.
+	role := controlplanev1.ServerRole("")
+
+	if etcdMachines := machinesRequireUpgrade.Filter(rke2.HasServerRole(controlplanev1.ServerRoleEtcd)); etcdMachines.Len() > 0 {
+		role, machinesRequireUpgrade = controlplanev1.ServerRoleEtcd, etcdMachines
+	}
+
+	plan := rke2.NewUpgradePlan(controlPlane.RCP, role)
+	if err := workloadCluster.ApplyUpgradePlan(ctx, plan); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	errs := []error{}
+	pending := []string{}
+
+	for _, machine := range machinesRequireUpgrade.SortedByCreationTimestamp() {
+		if machine.Status.NodeRef == nil {
+			pending = append(pending, machine.Name)
+
+			continue
+		}
+
+		nodeVersion, found := workloadCluster.NodeVersion(machine.Status.NodeRef.Name)
+		if !found || nodeVersion == "" || !bsutil.CompareVersions(nodeVersion, plan.Version) {
+			pending = append(pending, machine.Name)
+
+			continue
+		}
+
+		patchHelper, err := patch.NewHelper(machine, r.Client)
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		reasons := controlPlane.MachineRolloutReasons(machine)
+		machine.Spec.Version = &plan.Version
+
+		if err := patchHelper.Patch(ctx, machine); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to update version of machine %s", machine.Name))
+
+			continue
+		}
+
+		logger.Info("Machine upgraded in place", "machine", machine.Name, "version", plan.Version)
+		r.recorder.Eventf(controlPlane.RCP, corev1.EventTypeNormal, "UpgradedMachineInPlace",
+			"Upgraded control plane Machine %s in place: %s", machine.Name, strings.Join(reasons, ", "))
+	}
+
+	if len(errs) > 0 {
+		return ctrl.Result{}, kerrors.NewAggregate(errs)
+	}
+
+	if len(pending) > 0 {
+		logger.Info("Waiting for machines to be upgraded in place", "machines", pending, "version", plan.Version)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	// All the machines have been upgraded, the plan is not needed anymore. Deleting it prevents
+	// the system-upgrade-controller from acting on nodes created by a later rollout.
+	if err := workloadCluster.DeleteUpgradePlan(ctx, plan); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{Requeue: true}, nil
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/collections"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestReconcileInPlaceUpgrade(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	const (
+		oldVersion = "v1.30.2+rke2r1"
+		newVersion = "v1.30.3+rke2r1"
+	)
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: metav1.NamespaceDefault}}
+	rcp := &controlplanev1.RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: metav1.NamespaceDefault},
+		Spec: controlplanev1.RKE2ControlPlaneSpec{
+			Version:         newVersion,
+			RolloutStrategy: &controlplanev1.RolloutStrategy{Type: controlplanev1.InPlaceUpgradeStrategyType},
+		},
+		Status: controlplanev1.RKE2ControlPlaneStatus{Initialized: true},
+	}
+
+	upgraded := healthyMachine("upgraded")
+	upgraded.Spec.Version = ptr.To(oldVersion)
+	waiting := healthyMachine("waiting")
+	waiting.Spec.Version = ptr.To(oldVersion)
+	machines := collections.FromMachines(upgraded, waiting)
+
+	fakeClient := fake.NewClientBuilder().WithObjects(upgraded, waiting).Build()
+	workloadCluster := &fakeWorkloadCluster{NodeVersions: map[string]string{
+		"upgraded": newVersion,
+		"waiting":  oldVersion,
+	}}
+	recorder := record.NewFakeRecorder(32)
+	r := &RKE2ControlPlaneReconciler{
+		Client:            fakeClient,
+		recorder:          recorder,
+		managementCluster: &fakeManagementCluster{Workload: workloadCluster},
+		workloadCluster:   workloadCluster,
+	}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	result, err := r.reconcileInPlaceUpgrade(ctx, controlPlane, machines)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(DefaultRequeueTime))
+	g.Expect(workloadCluster.AppliedPlan).ToNot(BeNil())
+	g.Expect(workloadCluster.AppliedPlan.Version).To(Equal(newVersion))
+	g.Expect(workloadCluster.DeletedPlan).To(BeNil())
+
+	machine := &clusterv1.Machine{}
+	g.Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(upgraded), machine)).To(Succeed())
+	g.Expect(*machine.Spec.Version).To(Equal(newVersion))
+	g.Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(waiting), machine)).To(Succeed())
+	g.Expect(*machine.Spec.Version).To(Equal(oldVersion))
+	g.Expect(recorder.Events).To(Receive(Equal(
+		"Normal UpgradedMachineInPlace Upgraded control plane Machine upgraded in place: version v1.30.2+rke2r1 -> v1.30.3+rke2r1")))
+
+	// Once every node runs the desired version, the plan is deleted.
+	workloadCluster.NodeVersions["waiting"] = newVersion
+
+	result, err = r.reconcileInPlaceUpgrade(ctx, controlPlane, collections.FromMachines(waiting))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Requeue).To(BeTrue())
+	g.Expect(workloadCluster.DeletedPlan).ToNot(BeNil())
+	g.Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(waiting), machine)).To(Succeed())
+	g.Expect(*machine.Spec.Version).To(Equal(newVersion))
+}
+
+func TestReconcileInPlaceUpgradeWithEtcdPool(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	const (
+		oldVersion = "v1.30.2+rke2r1"
+		newVersion = "v1.30.3+rke2r1"
+	)
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: metav1.NamespaceDefault}}
+	rcp := &controlplanev1.RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: metav1.NamespaceDefault},
+		Spec: controlplanev1.RKE2ControlPlaneSpec{
+			Version:         newVersion,
+			RolloutStrategy: &controlplanev1.RolloutStrategy{Type: controlplanev1.InPlaceUpgradeStrategyType},
+		},
+		Status: controlplanev1.RKE2ControlPlaneStatus{Initialized: true},
+	}
+
+	server := healthyMachine("server")
+	server.Spec.Version = ptr.To(oldVersion)
+	etcd := healthyMachine("etcd")
+	etcd.Labels = map[string]string{controlplanev1.ServerRoleLabel: string(controlplanev1.ServerRoleEtcd)}
+	etcd.Spec.Version = ptr.To(oldVersion)
+	machines := collections.FromMachines(server, etcd)
+
+	fakeClient := fake.NewClientBuilder().WithObjects(server, etcd).Build()
+	workloadCluster := &fakeWorkloadCluster{NodeVersions: map[string]string{
+		"server": oldVersion,
+		"etcd":   oldVersion,
+	}}
+	r := &RKE2ControlPlaneReconciler{
+		Client:            fakeClient,
+		recorder:          record.NewFakeRecorder(32),
+		managementCluster: &fakeManagementCluster{Workload: workloadCluster},
+		workloadCluster:   workloadCluster,
+	}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	// The nodes of the etcd server pool, which don't have the control plane node role, are upgraded first.
+	result, err := r.reconcileInPlaceUpgrade(ctx, controlPlane, machines)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(DefaultRequeueTime))
+	g.Expect(workloadCluster.AppliedPlan.NodeSelector.MatchLabels).To(HaveKeyWithValue("node-role.kubernetes.io/etcd", "true"))
+
+	workloadCluster.NodeVersions["etcd"] = newVersion
+
+	result, err = r.reconcileInPlaceUpgrade(ctx, controlPlane, machines)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Requeue).To(BeTrue())
+	g.Expect(workloadCluster.DeletedPlan.Name).To(Equal(workloadCluster.AppliedPlan.Name))
+
+	machine := &clusterv1.Machine{}
+	g.Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(etcd), machine)).To(Succeed())
+	g.Expect(*machine.Spec.Version).To(Equal(newVersion))
+
+	// The other nodes are then upgraded with the plan selecting the control plane nodes.
+	etcdPlan := workloadCluster.AppliedPlan
+	workloadCluster.NodeVersions["server"] = newVersion
+
+	result, err = r.reconcileInPlaceUpgrade(ctx, controlPlane, collections.FromMachines(server))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.Requeue).To(BeTrue())
+	g.Expect(workloadCluster.AppliedPlan.Name).ToNot(Equal(etcdPlan.Name))
+	g.Expect(workloadCluster.AppliedPlan.NodeSelector.MatchLabels).ToNot(HaveKey("node-role.kubernetes.io/etcd"))
+	g.Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(server), machine)).To(Succeed())
+	g.Expect(*machine.Spec.Version).To(Equal(newVersion))
+}
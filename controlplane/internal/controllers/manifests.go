@@ -0,0 +1,142 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+// manifestsSourceIndex indexes the RKE2ControlPlanes by the ConfigMaps and Secrets their synchronized manifests and
+// the values of their addons are read from.
+const manifestsSourceIndex = "spec.manifestsSources"
+
+// manifestsSourceKey returns the key of a manifests source in the manifestsSourceIndex.
+func manifestsSourceKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// indexManifestsSource is the indexer function of the manifestsSourceIndex.
+func indexManifestsSource(o client.Object) []string {
+	rcp, ok := o.(*controlplanev1.RKE2ControlPlane)
+	if !ok {
+		return nil
+	}
+
+	keys := addonValuesSourceKeys(rcp)
+
+	ref := rcp.Spec.ManifestsConfigMapReference
+	if rcp.Spec.ManifestsStrategy == controlplanev1.ManifestsStrategySync && ref.Name != "" {
+		keys = append(keys, manifestsSourceKey(cmp.Or(ref.Kind, "ConfigMap"), rcp.Namespace, ref.Name))
+	}
+
+	return keys
+}
+
+// manifestsSourceToRKE2ControlPlanes returns a handler.MapFunc enqueuing the RKE2ControlPlanes synchronizing the
+// manifests or the addon values of the ConfigMap or Secret of the given kind.
+func (r *RKE2ControlPlaneReconciler) manifestsSourceToRKE2ControlPlanes(kind string) handler.MapFunc {
+	return func(ctx context.Context, o client.Object) []ctrl.Request {
+		rcps := &controlplanev1.RKE2ControlPlaneList{}
+		if err := r.Client.List(ctx, rcps,
+			client.MatchingFields{manifestsSourceIndex: manifestsSourceKey(kind, o.GetNamespace(), o.GetName())}); err != nil {
+			return nil
+		}
+
+		result := []ctrl.Request{}
+		for _, rcp := range rcps.Items {
+			result = append(result, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&rcp)})
+		}
+
+		return result
+	}
+}
+
+// reconcileManifests applies the manifests of the ManifestsConfigMapReference to the workload cluster, and prunes the
+// resources removed from them, when the ManifestsStrategy is Sync. The resources applied are tracked in the status.
+// Invalid manifests or an unreachable workload cluster do not hold back the machine operations which follow: the
+// failure is reported in the ManifestsSynced condition, and the manifests are applied again on the next reconciliation.
+func (r *RKE2ControlPlaneReconciler) reconcileManifests(ctx context.Context, controlPlane *rke2.ControlPlane) {
+	logger := controlPlane.Logger()
+	rcp := controlPlane.RCP
+
+	if rcp.Spec.ManifestsStrategy != controlplanev1.ManifestsStrategySync {
+		// The resources applied are left in the workload cluster, like the ones deployed by RKE2.
+		rcp.Status.AppliedManifests = nil
+		conditions.Delete(rcp, controlplanev1.ManifestsSyncedCondition)
+
+		return
+	}
+
+	if !rcp.Status.Initialized {
+		return
+	}
+
+	syncFailed := func(err error) {
+		logger.Error(err, "Failed to synchronize manifests")
+		r.recorder.Eventf(rcp, corev1.EventTypeWarning, "ManifestsSyncFailed", "Failed to synchronize manifests: %v", err)
+		conditions.MarkFalse(rcp,
+			controlplanev1.ManifestsSyncedCondition,
+			controlplanev1.ManifestsSyncFailedReason,
+			clusterv1.ConditionSeverityWarning,
+			err.Error())
+	}
+
+	manifests, err := rke2.GetManifests(ctx, r.Client, rcp)
+	if err != nil {
+		syncFailed(err)
+
+		return
+	}
+
+	objects, err := rke2.ParseManifests(manifests)
+	if err != nil {
+		syncFailed(err)
+
+		return
+	}
+
+	workloadCluster, err := r.GetWorkloadCluster(ctx, controlPlane)
+	if err != nil {
+		syncFailed(err)
+
+		return
+	}
+
+	applied, err := workloadCluster.SyncManifests(ctx, objects, rcp.Status.AppliedManifests)
+	rcp.Status.AppliedManifests = applied
+
+	if err != nil {
+		syncFailed(err)
+
+		return
+	}
+
+	conditions.MarkTrue(rcp, controlplanev1.ManifestsSyncedCondition)
+}
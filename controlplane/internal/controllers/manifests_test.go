@@ -0,0 +1,85 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestReconcileManifests(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	manifests := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "manifests", Namespace: metav1.NamespaceDefault},
+		Data: map[string][]byte{
+			"addons.yaml": []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: addon\n  namespace: addons\n" +
+				"---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: addons\n"),
+			"README.md": []byte("not a manifest"),
+		},
+	}
+
+	cluster, rcp, machines := newFakeControlPlane()
+	rcp.Spec.ManifestsConfigMapReference = corev1.ObjectReference{Kind: "Secret", Name: "manifests"}
+
+	workloadCluster := &fakeWorkloadCluster{}
+	r := &RKE2ControlPlaneReconciler{
+		Client:            newFakeClient(manifests),
+		recorder:          record.NewFakeRecorder(32),
+		managementCluster: &fakeManagementCluster{Machines: machines, Workload: workloadCluster},
+	}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	// The manifests are only written on the nodes by default.
+	r.reconcileManifests(ctx, controlPlane)
+	g.Expect(workloadCluster.SyncedManifests).To(BeNil())
+	g.Expect(conditions.Has(rcp, controlplanev1.ManifestsSyncedCondition)).To(BeFalse())
+
+	g.Expect(indexManifestsSource(rcp)).To(BeEmpty())
+
+	rcp.Spec.ManifestsStrategy = controlplanev1.ManifestsStrategySync
+	g.Expect(indexManifestsSource(rcp)).To(ConsistOf("Secret/default/manifests"))
+
+	// Namespaces are applied before the objects they contain, and the applied resources are tracked in the status.
+	r.reconcileManifests(ctx, controlPlane)
+	g.Expect(workloadCluster.SyncedManifests).To(HaveLen(2))
+	g.Expect(workloadCluster.SyncedManifests[0].GetKind()).To(Equal("Namespace"))
+	g.Expect(rcp.Status.AppliedManifests).To(ConsistOf(
+		controlplanev1.ManifestReference{APIVersion: "v1", Kind: "Namespace", Name: "addons"},
+		controlplanev1.ManifestReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "addons", Name: "addon"},
+	))
+	g.Expect(conditions.IsTrue(rcp, controlplanev1.ManifestsSyncedCondition)).To(BeTrue())
+
+	// Invalid manifests are reported, without changing the applied resources.
+	manifests.Data["addons.yaml"] = []byte("apiVersion: v1\nkind: ConfigMap\n")
+	g.Expect(r.Client.Update(ctx, manifests)).To(Succeed())
+
+	r.reconcileManifests(ctx, controlPlane)
+	g.Expect(conditions.GetReason(rcp, controlplanev1.ManifestsSyncedCondition)).To(Equal(controlplanev1.ManifestsSyncFailedReason))
+	g.Expect(rcp.Status.AppliedManifests).To(HaveLen(2))
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestGetMachineToBeRemediated(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	oldest := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{
+		Name:              "oldest",
+		CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+	}, Status: clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "oldest"}}}
+	provisioning := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{
+		Name:              "provisioning",
+		CreationTimestamp: metav1.NewTime(now.Add(-1 * time.Hour)),
+	}}
+	newest := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{
+		Name:              "newest",
+		CreationTimestamp: metav1.NewTime(now),
+	}, Status: clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "newest"}}}
+
+	g.Expect(getMachineToBeRemediated(collections.FromMachines(oldest, provisioning, newest)).Name).To(Equal("provisioning"))
+	g.Expect(getMachineToBeRemediated(collections.FromMachines(oldest, newest)).Name).To(Equal("oldest"))
+}
+
+func TestCheckRetryLimits(t *testing.T) {
+	now := time.Now().UTC()
+
+	remediationFor := func(timestamp time.Time, retryCount int) map[string]string {
+		data, err := (&RemediationData{
+			Machine:    "m0",
+			Timestamp:  metav1.NewTime(timestamp),
+			RetryCount: retryCount,
+		}).Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return map[string]string{controlplanev1.RemediationForAnnotation: data}
+	}
+
+	tests := []struct {
+		name               string
+		annotations        map[string]string
+		strategy           *controlplanev1.RemediationStrategy
+		expectCanRemediate bool
+		expectRetryCount   int
+	}{
+		{
+			name:               "first remediation",
+			expectCanRemediate: true,
+			expectRetryCount:   0,
+		},
+		{
+			name:               "previous remediation older than the default min healthy period",
+			annotations:        remediationFor(now.Add(-2*time.Hour), 3),
+			expectCanRemediate: true,
+			expectRetryCount:   0,
+		},
+		{
+			name:               "retry within min healthy period",
+			annotations:        remediationFor(now.Add(-10*time.Minute), 1),
+			expectCanRemediate: true,
+			expectRetryCount:   2,
+		},
+		{
+			name:        "retry before retry period expired",
+			annotations: remediationFor(now.Add(-10*time.Minute), 1),
+			strategy: &controlplanev1.RemediationStrategy{
+				RetryPeriod: metav1.Duration{Duration: 20 * time.Minute},
+			},
+			expectCanRemediate: false,
+			expectRetryCount:   1,
+		},
+		{
+			name:        "max retry reached",
+			annotations: remediationFor(now.Add(-10*time.Minute), 3),
+			strategy: &controlplanev1.RemediationStrategy{
+				MaxRetry: ptr.To[int32](3),
+			},
+			expectCanRemediate: false,
+			expectRetryCount:   3,
+		},
+		{
+			name:        "custom min healthy period expired",
+			annotations: remediationFor(now.Add(-10*time.Minute), 3),
+			strategy: &controlplanev1.RemediationStrategy{
+				MaxRetry:         ptr.To[int32](3),
+				MinHealthyPeriod: &metav1.Duration{Duration: 5 * time.Minute},
+			},
+			expectCanRemediate: true,
+			expectRetryCount:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{
+				Name:        "m1",
+				Annotations: tt.annotations,
+			}}
+			controlPlane := &rke2.ControlPlane{RCP: &controlplanev1.RKE2ControlPlane{
+				Spec: controlplanev1.RKE2ControlPlaneSpec{RemediationStrategy: tt.strategy},
+			}}
+
+			data, canRemediate, err := checkRetryLimits(ctrl.Log, machine, controlPlane, now)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(canRemediate).To(Equal(tt.expectCanRemediate))
+			g.Expect(data.Machine).To(Equal("m1"))
+			g.Expect(data.RetryCount).To(Equal(tt.expectRetryCount))
+
+			if !tt.expectCanRemediate {
+				g.Expect(conditions.IsFalse(machine, clusterv1.MachineOwnerRemediatedCondition)).To(BeTrue())
+				g.Expect(conditions.GetReason(machine, clusterv1.MachineOwnerRemediatedCondition)).
+					To(Equal(clusterv1.WaitingForRemediationReason))
+			}
+		})
+	}
+}
+
+func TestUpdateLastRemediationStatus(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	older, err := (&RemediationData{Machine: "m0", Timestamp: metav1.NewTime(now.Add(-time.Hour)), RetryCount: 1}).Marshal()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	newer, err := (&RemediationData{Machine: "m1", Timestamp: metav1.NewTime(now), RetryCount: 2}).Marshal()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	machines := collections.FromMachines(
+		&clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{
+			Name:        "a",
+			Annotations: map[string]string{controlplanev1.RemediationForAnnotation: older},
+		}},
+		&clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{
+			Name:        "b",
+			Annotations: map[string]string{controlplanev1.RemediationForAnnotation: newer},
+		}},
+	)
+
+	rcp := &controlplanev1.RKE2ControlPlane{}
+	g.Expect(updateLastRemediationStatus(rcp, machines)).To(Succeed())
+	g.Expect(rcp.Status.LastRemediation).ToNot(BeNil())
+	g.Expect(rcp.Status.LastRemediation.Machine).To(Equal("m1"))
+	g.Expect(rcp.Status.LastRemediation.RetryCount).To(Equal(int32(2)))
+
+	inProgress, err := (&RemediationData{Machine: "b", Timestamp: metav1.NewTime(now.Add(time.Minute))}).Marshal()
+	g.Expect(err).ToNot(HaveOccurred())
+
+	rcp.Annotations = map[string]string{controlplanev1.RemediationInProgressAnnotation: inProgress}
+	g.Expect(updateLastRemediationStatus(rcp, machines)).To(Succeed())
+	g.Expect(rcp.Status.LastRemediation.Machine).To(Equal("b"))
+	g.Expect(rcp.Status.LastRemediation.RetryCount).To(Equal(int32(0)))
+}
@@ -20,14 +20,13 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blang/semver/v4"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -46,7 +45,6 @@ import (
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/controllers/clustercache"
-	"sigs.k8s.io/cluster-api/controllers/remote"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/certs"
@@ -77,7 +75,10 @@ type RKE2ControlPlaneReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 
+	// APIReader reads the objects from the API server, bypassing the cache of the manager.
+	APIReader           client.Reader
 	SecretCachingClient client.Client
+	ClusterCache        clustercache.ClusterCache
 
 	// WatchFilterValue is the label value used to filter events prior to reconciliation.
 	WatchFilterValue string
@@ -87,12 +88,18 @@ type RKE2ControlPlaneReconciler struct {
 	recorder                  record.EventRecorder
 	controller                controller.Controller
 	workloadCluster           rke2.WorkloadCluster
+
+	// certificatesExpiryChecks records the time the expiry date of the certificates of the machines was last read,
+	// keyed by machine UID.
+	certificatesExpiryChecks sync.Map
 }
 
 //nolint:lll
 //+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=rke2controlplanes,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=rke2controlplanes/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=rke2controlplanes/finalizers,verbs=update
+// +kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=rke2etcdsnapshots,verbs=get;create
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status;machinesets;machines;machines/status;machinepools;machinepools/status,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=secrets;events;configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="bootstrap.cluster.x-k8s.io",resources=rke2configs,verbs=get;list;watch;create;patch;delete
@@ -222,16 +229,55 @@ func patchRKE2ControlPlane(ctx context.Context, patchHelper *patch.Helper, rcp *
 			controlplanev1.ResizedCondition,
 			controlplanev1.MachinesReadyCondition,
 			controlplanev1.AvailableCondition,
+			controlplanev1.CertificateAuthoritiesRotatedCondition,
+			controlplanev1.ServerTokenRotatedCondition,
+			controlplanev1.ManifestsSyncedCondition,
+			controlplanev1.AddonsSyncedCondition,
+			controlplanev1.FinalEtcdSnapshotCreatedCondition,
+			controlplanev1.WorkloadCleanedUpCondition,
+			controlplanev1.InitLockReleasedCondition,
+			controlplanev1.TokenSecretAvailableCondition,
 		}},
 		patch.WithStatusObservedGeneration{},
 	)
 }
 
 // SetupWithManager sets up the controller with the Manager.
-func (r *RKE2ControlPlaneReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, clientQPS float32, clientBurst int) error {
+func (r *RKE2ControlPlaneReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(
+		ctx,
+		&controlplanev1.RKE2ControlPlane{},
+		manifestsSourceIndex,
+		indexManifestsSource,
+	); err != nil {
+		return errors.Wrap(err, "failed to index manifests sources")
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(
+		ctx,
+		&controlplanev1.RKE2ControlPlane{},
+		tokenSecretRefIndex,
+		indexTokenSecretRef,
+	); err != nil {
+		return errors.Wrap(err, "failed to index token secret references")
+	}
+
 	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&controlplanev1.RKE2ControlPlane{}).
 		Owns(&clusterv1.Machine{}).
+		// Only the metadata of the manifests, addon values and token secrets is cached, as the client reads the secrets and config maps directly.
+		WatchesMetadata(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.manifestsSourceToRKE2ControlPlanes("ConfigMap")),
+		).
+		WatchesMetadata(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.manifestsSourceToRKE2ControlPlanes("Secret")),
+		).
+		WatchesMetadata(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.tokenSecretToRKE2ControlPlanes),
+		).
 		Build(r)
 	if err != nil {
 		return errors.Wrap(err, "failed setting up with a controller manager")
@@ -249,41 +295,11 @@ func (r *RKE2ControlPlaneReconciler) SetupWithManager(ctx context.Context, mgr c
 	r.controller = c
 	r.recorder = mgr.GetEventRecorderFor("rke2-control-plane-controller")
 
-	// Set up a clusterCache to provide to controllers
-	// requiring a connection to a remote cluster
-	clusterCache, err := clustercache.SetupWithManager(ctx, mgr, clustercache.Options{
-		SecretClient: r.SecretCachingClient,
-		Cache: clustercache.CacheOptions{
-			Indexes: []clustercache.CacheOptionsIndex{clustercache.NodeProviderIDIndex},
-		},
-		Client: clustercache.ClientOptions{
-			QPS:       clientQPS,
-			Burst:     clientBurst,
-			UserAgent: remote.DefaultClusterAPIUserAgent("rke2-control-plane-controller"),
-			Cache: clustercache.ClientCacheOptions{
-				DisableFor: []client.Object{
-					// Don't cache ConfigMaps & Secrets.
-					&corev1.ConfigMap{},
-					&corev1.Secret{},
-					// Don't cache Pods & DaemonSets (we get/list them e.g. during drain).
-					&corev1.Pod{},
-					&appsv1.DaemonSet{},
-					// Don't cache PersistentVolumes and VolumeAttachments (we get/list them e.g. during wait for volumes to detach)
-					&storagev1.VolumeAttachment{},
-					&corev1.PersistentVolume{},
-				},
-			},
-		},
-	}, controller.Options{})
-	if err != nil {
-		return errors.Wrap(err, "unable to create cluster cache tracker")
-	}
-
 	if r.managementCluster == nil {
 		r.managementCluster = &rke2.Management{
 			Client:              r.Client,
 			SecretCachingClient: r.SecretCachingClient,
-			ClusterCache:        clusterCache,
+			ClusterCache:        r.ClusterCache,
 		}
 	}
 
@@ -317,9 +333,23 @@ func (r *RKE2ControlPlaneReconciler) updateStatus(ctx context.Context, rcp *cont
 		return err
 	}
 
-	rcp.Status.UpdatedReplicas = int32(len(controlPlane.UpToDateMachines()))
-	replicas := int32(len(ownedMachines))
-	desiredReplicas := *rcp.Spec.Replicas
+	// The replicas of the control plane are the machines running all the server roles, the machines of the other
+	// server pools are reported separately.
+	serverPools := controlPlane.ServerPools()
+	upToDateMachines := controlPlane.UpToDateMachines()
+	rcp.Status.ServerPools = nil
+
+	for _, pool := range serverPools[1:] {
+		rcp.Status.ServerPools = append(rcp.Status.ServerPools, controlplanev1.ServerPoolStatus{
+			Role:            pool.Role,
+			Replicas:        int32(pool.Machines.Len()),
+			ReadyReplicas:   int32(pool.Machines.Filter(collections.IsReady()).Len()),
+			UpdatedReplicas: int32(upToDateMachines.Filter(rke2.HasServerRole(pool.Role)).Len()),
+		})
+	}
+
+	rcp.Status.UpdatedReplicas = int32(upToDateMachines.Filter(rke2.HasServerRole("")).Len())
+	replicas := int32(serverPools[0].Machines.Len())
 
 	// set basic data that does not require interacting with the workload cluster
 	// ReadyReplicas and UnavailableReplicas are set in case the function returns before updating them
@@ -327,42 +357,65 @@ func (r *RKE2ControlPlaneReconciler) updateStatus(ctx context.Context, rcp *cont
 	rcp.Status.ReadyReplicas = 0
 	rcp.Status.UnavailableReplicas = replicas
 
+	if err := updateLastRemediationStatus(rcp, ownedMachines); err != nil {
+		return errors.Wrap(err, "failed to update last remediation status")
+	}
+
 	// Return early if the deletion timestamp is set, because we don't want to try to connect to the workload cluster
 	// and we don't want to report resize condition (because it is set to deleting into reconcile delete).
 	if !rcp.DeletionTimestamp.IsZero() {
 		return nil
 	}
 
-	switch {
-	// We are scaling up
-	case replicas < desiredReplicas:
-		conditions.MarkFalse(
-			rcp,
-			controlplanev1.ResizedCondition,
-			controlplanev1.ScalingUpReason,
-			clusterv1.ConditionSeverityWarning,
-			"Scaling up control plane to %d replicas (actual %d)",
-			desiredReplicas,
-			replicas)
+	resizing := false
 
-	// We are scaling down
-	case replicas > desiredReplicas:
-		conditions.MarkFalse(
-			rcp,
-			controlplanev1.ResizedCondition,
-			controlplanev1.ScalingDownReason,
-			clusterv1.ConditionSeverityWarning,
-			"Scaling down control plane to %d replicas (actual %d)",
-			desiredReplicas,
-			replicas)
+	for _, pool := range serverPools {
+		poolReplicas := int32(pool.Machines.Len())
+		name := "control plane"
 
-	default:
-		// make sure last resize operation is marked as completed.
-		// NOTE: we are checking the number of machines ready so we report resize completed only when the machines
-		// are actually provisioned (vs reporting completed immediately after the last machine object is created).
-		if int32(len(readyMachines)) == replicas {
-			conditions.MarkTrue(rcp, controlplanev1.ResizedCondition)
+		if pool.Role != "" {
+			name = fmt.Sprintf("%s server pool", pool.Role)
+		}
+
+		switch {
+		// We are scaling up
+		case poolReplicas < pool.Replicas:
+			conditions.MarkFalse(
+				rcp,
+				controlplanev1.ResizedCondition,
+				controlplanev1.ScalingUpReason,
+				clusterv1.ConditionSeverityWarning,
+				"Scaling up %s to %d replicas (actual %d)",
+				name,
+				pool.Replicas,
+				poolReplicas)
+
+		// We are scaling down
+		case poolReplicas > pool.Replicas:
+			conditions.MarkFalse(
+				rcp,
+				controlplanev1.ResizedCondition,
+				controlplanev1.ScalingDownReason,
+				clusterv1.ConditionSeverityWarning,
+				"Scaling down %s to %d replicas (actual %d)",
+				name,
+				pool.Replicas,
+				poolReplicas)
+
+		default:
+			continue
 		}
+
+		resizing = true
+
+		break
+	}
+
+	// make sure last resize operation is marked as completed.
+	// NOTE: we are checking the number of machines ready so we report resize completed only when the machines
+	// are actually provisioned (vs reporting completed immediately after the last machine object is created).
+	if !resizing && len(readyMachines) == len(ownedMachines) {
+		conditions.MarkTrue(rcp, controlplanev1.ResizedCondition)
 	}
 
 	kubeconfigSecret := corev1.Secret{}
@@ -382,7 +435,7 @@ func (r *RKE2ControlPlaneReconciler) updateStatus(ctx context.Context, rcp *cont
 		return fmt.Errorf("unable to find a value entry in the kubeconfig secret")
 	}
 
-	rcp.Status.ReadyReplicas = int32(len(readyMachines))
+	rcp.Status.ReadyReplicas = int32(readyMachines.Filter(rke2.HasServerRole("")).Len())
 	rcp.Status.UnavailableReplicas = replicas - rcp.Status.ReadyReplicas
 
 	workloadCluster, err := r.getWorkloadCluster(ctx, util.ObjectKey(cluster))
@@ -398,6 +451,15 @@ func (r *RKE2ControlPlaneReconciler) updateStatus(ctx context.Context, rcp *cont
 		rcp.Status.Initialized = true
 	}
 
+	if rcp.Status.Initialized {
+		snapshots, err := workloadCluster.EtcdSnapshots(ctx)
+		if err != nil {
+			logger.Error(err, "Failed to list etcd snapshots")
+		} else {
+			rcp.Status.EtcdSnapshots = snapshots
+		}
+	}
+
 	if len(ownedMachines) == 0 {
 		logger.Info(fmt.Sprintf("no Control Plane Machines exist for RKE2ControlPlane %s/%s", rcp.Namespace, rcp.Name))
 
@@ -410,7 +472,8 @@ func (r *RKE2ControlPlaneReconciler) updateStatus(ctx context.Context, rcp *cont
 		return nil
 	}
 
-	availableCPMachines := readyMachines
+	// Etcd only machines do not run the kube-apiserver, nodes can't be registered through them.
+	availableCPMachines := readyMachines.Filter(collections.Not(rke2.HasServerRole(controlplanev1.ServerRoleEtcd)))
 
 	registrationmethod, err := registration.NewRegistrationMethod(string(rcp.Spec.RegistrationMethod))
 	if err != nil {
@@ -441,11 +504,39 @@ func (r *RKE2ControlPlaneReconciler) updateStatus(ctx context.Context, rcp *cont
 	return nil
 }
 
+// updateLastRemediationStatus surfaces the last remediation performed, either from the remediation in progress
+// or from the newest machine created as a replacement of an unhealthy machine.
+func updateLastRemediationStatus(rcp *controlplanev1.RKE2ControlPlane, machines collections.Machines) error {
+	if v, ok := rcp.Annotations[controlplanev1.RemediationInProgressAnnotation]; ok {
+		remediationData, err := RemediationDataFromAnnotation(v)
+		if err != nil {
+			return err
+		}
+
+		rcp.Status.LastRemediation = remediationData.ToStatus()
+
+		return nil
+	}
+
+	for _, m := range machines.Filter(collections.HasAnnotationKey(controlplanev1.RemediationForAnnotation)).UnsortedList() {
+		remediationData, err := RemediationDataFromAnnotation(m.Annotations[controlplanev1.RemediationForAnnotation])
+		if err != nil {
+			return err
+		}
+
+		if rcp.Status.LastRemediation == nil || rcp.Status.LastRemediation.Timestamp.Time.Before(remediationData.Timestamp.Time) {
+			rcp.Status.LastRemediation = remediationData.ToStatus()
+		}
+	}
+
+	return nil
+}
+
 func (r *RKE2ControlPlaneReconciler) reconcileNormal(
 	ctx context.Context,
 	cluster *clusterv1.Cluster,
 	rcp *controlplanev1.RKE2ControlPlane,
-) (ctrl.Result, error) {
+) (res ctrl.Result, reterr error) {
 	logger := log.FromContext(ctx)
 	logger.Info("Reconcile RKE2 Control Plane")
 
@@ -456,11 +547,7 @@ func (r *RKE2ControlPlaneReconciler) reconcileNormal(
 		return ctrl.Result{}, nil
 	}
 
-	certificates := secret.NewCertificatesForInitialControlPlane()
-	if _, found := rcp.Annotations[controlplanev1.LegacyRKE2ControlPlane]; found {
-		certificates = secret.NewCertificatesForLegacyControlPlane()
-	}
-
+	certificates := controlPlaneCertificates(rcp)
 	controllerRef := metav1.NewControllerRef(rcp, controlplanev1.GroupVersion.WithKind("RKE2ControlPlane"))
 
 	if err := certificates.LookupOrGenerate(ctx, r.Client, util.ObjectKey(cluster), *controllerRef); err != nil {
@@ -503,6 +590,12 @@ func (r *RKE2ControlPlaneReconciler) reconcileNormal(
 		return ctrl.Result{}, err
 	}
 
+	// Adopt the control plane machines with no controller, then wait for the ownership to be updated.
+	adoptableMachines := controlPlaneMachines.Filter(collections.AdoptableControlPlaneMachines(cluster.Name))
+	if len(adoptableMachines) > 0 {
+		return r.adoptMachines(ctx, cluster, rcp, adoptableMachines)
+	}
+
 	ownedMachines := controlPlaneMachines.Filter(collections.OwnedMachines(rcp))
 	if len(ownedMachines) != len(controlPlaneMachines) {
 		logger.Info("Not all control plane machines are owned by this RKE2ControlPlane, refusing to operate in mixed management mode") //nolint:lll
@@ -517,6 +610,15 @@ func (r *RKE2ControlPlaneReconciler) reconcileNormal(
 		return ctrl.Result{}, err
 	}
 
+	// Requeue when RolloutAfter is reached, so that the machines created before it are rolled out on time.
+	if result := rolloutAfterResult(rcp); !result.IsZero() {
+		defer func() {
+			if reterr == nil {
+				res = util.LowestNonZeroResult(res, result)
+			}
+		}()
+	}
+
 	// Aggregate the operational state of all the machines; while aggregating we are adding the
 	// source ref (reason@machine/name) so the problem can be easily tracked down to its source machine.
 	conditions.SetAggregate(controlPlane.RCP, controlplanev1.MachinesReadyCondition,
@@ -524,6 +626,11 @@ func (r *RKE2ControlPlaneReconciler) reconcileNormal(
 		conditions.AddSourceRef(),
 		conditions.WithStepCounterIf(false))
 
+	// Replace the Machine initializing the control plane, if it shows no progress.
+	if result, err := r.reconcileInitLock(ctx, controlPlane); err != nil || !result.IsZero() {
+		return result, err
+	}
+
 	// Updates conditions reporting the status of static pods and the status of the etcd cluster.
 	// NOTE: Conditions reporting RCP operation progress like e.g. Resized or SpecUpToDate are inlined with the rest of the execution.
 	if result, err := r.reconcileControlPlaneConditions(ctx, controlPlane); err != nil || !result.IsZero() {
@@ -542,21 +649,89 @@ func (r *RKE2ControlPlaneReconciler) reconcileNormal(
 		return result, err
 	}
 
+	// While an etcd snapshot is being restored, machines are managed by the RKE2EtcdRestore controller.
+	if restore, found := rcp.Annotations[controlplanev1.EtcdRestoreInProgressAnnotation]; found {
+		logger.Info("Etcd snapshot restore in progress, skipping scale and rollout operations", "restore", restore)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	// Reconcile unhealthy machines by triggering deletion and requeue if it is considered safe to remediate,
+	// otherwise continue with the other RCP operations.
+	if result, err := r.reconcileUnhealthyMachines(ctx, controlPlane); err != nil || !result.IsZero() {
+		return result, err
+	}
+
+	// Distribute the certificate authorities of the current phase of a rotation, if any, before rolling out the
+	// machines to use them.
+	if result, err := r.reconcileCertificateAuthorityRotation(ctx, controlPlane); err != nil || !result.IsZero() {
+		return result, err
+	}
+
+	// Rotate the server token, if requested, before the machines are rolled out or scaled.
+	if result, err := r.reconcileServerTokenRotation(ctx, controlPlane); err != nil || !result.IsZero() {
+		return result, err
+	}
+
+	// Record the certificates expiry date of the machines, used to roll them out before their certificates expire.
+	// The expiry dates which can't be read are retried later, without blocking the other operations.
+	if result := r.reconcileCertificateExpiries(ctx, controlPlane); !result.IsZero() {
+		defer func() {
+			if reterr == nil {
+				res = util.LowestNonZeroResult(res, result)
+			}
+		}()
+	}
+
+	// Apply the manifests to the workload cluster, when they are synchronized.
+	r.reconcileManifests(ctx, controlPlane)
+
+	// Apply the HelmChartConfigs and HelmCharts of the addons to the workload cluster.
+	r.reconcileAddons(ctx, controlPlane)
+
+	// The machines created by the rollouts and scale ups could not join the cluster without a valid server token.
+	if !r.reconcileTokenSecret(ctx, controlPlane) {
+		return ctrl.Result{}, nil
+	}
+
 	// Control plane machines rollout due to configuration changes (e.g. upgrades) takes precedence over other operations.
 	needRollout := controlPlane.MachinesNeedingRollout()
+	needInPlaceUpgrade := controlPlane.MachinesNeedingInPlaceUpgrade()
+
+	// In dry-run mode, the rollout waits for its plan to be approved.
+	if approved, err := r.reconcileRolloutPlan(ctx, controlPlane, needRollout, needInPlaceUpgrade); err != nil || !approved {
+		return ctrl.Result{}, err
+	}
 
 	switch {
 	case len(needRollout) > 0:
-		logger.Info("Rolling out Control Plane machines", "needRollout", needRollout.Names())
+		reasons := controlPlane.RolloutReasons(needRollout)
+
+		logger.Info("Rolling out Control Plane machines", "needRollout", needRollout.Names(), "reasons", reasons)
 		conditions.MarkFalse(controlPlane.RCP,
 			controlplanev1.MachinesSpecUpToDateCondition,
 			controlplanev1.RollingUpdateInProgressReason,
 			clusterv1.ConditionSeverityWarning,
-			"Rolling %d replicas with outdated spec (%d replicas up to date)",
+			"Rolling %d replicas with outdated spec (%d replicas up to date): %s",
 			len(needRollout),
-			len(controlPlane.Machines)-len(needRollout))
+			len(controlPlane.Machines)-len(needRollout),
+			strings.Join(reasons, ", "))
 
 		return r.upgradeControlPlane(ctx, cluster, rcp, controlPlane, needRollout)
+	case len(needInPlaceUpgrade) > 0:
+		reasons := controlPlane.RolloutReasons(needInPlaceUpgrade)
+
+		logger.Info("Upgrading Control Plane machines in place", "needInPlaceUpgrade", needInPlaceUpgrade.Names(), "reasons", reasons)
+		conditions.MarkFalse(controlPlane.RCP,
+			controlplanev1.MachinesSpecUpToDateCondition,
+			controlplanev1.InPlaceUpgradeInProgressReason,
+			clusterv1.ConditionSeverityWarning,
+			"Upgrading %d replicas in place (%d replicas up to date): %s",
+			len(needInPlaceUpgrade),
+			len(controlPlane.Machines)-len(needInPlaceUpgrade),
+			strings.Join(reasons, ", "))
+
+		return r.reconcileInPlaceUpgrade(ctx, controlPlane, needInPlaceUpgrade)
 	default:
 		// make sure last upgrade operation is marked as completed.
 		// NOTE: we are checking the condition already exists in order to avoid to set this condition at the first
@@ -564,39 +739,53 @@ func (r *RKE2ControlPlaneReconciler) reconcileNormal(
 		if conditions.Has(controlPlane.RCP, controlplanev1.MachinesSpecUpToDateCondition) {
 			conditions.MarkTrue(controlPlane.RCP, controlplanev1.MachinesSpecUpToDateCondition)
 		}
-	}
-
-	// If we've made it this far, we can assume that all ownedMachines are up to date
-	numMachines := len(ownedMachines)
-
-	desiredReplicas := int(*rcp.Spec.Replicas)
-
-	switch {
-	// We are creating the first replica
-	case numMachines < desiredReplicas && numMachines == 0:
-		// Create new Machine w/ init
-		logger.Info("Initializing control plane", "Desired", desiredReplicas, "Existing", numMachines)
-		conditions.MarkFalse(controlPlane.RCP,
-			controlplanev1.AvailableCondition,
-			controlplanev1.WaitingForRKE2ServerReason,
-			clusterv1.ConditionSeverityInfo, "")
 
-		return r.initializeControlPlane(ctx, cluster, rcp, controlPlane)
-	// We are scaling up
-	case numMachines < desiredReplicas && numMachines > 0:
-		// Create a new Machine w/ join
-		logger.Info("Scaling up control plane", "Desired", desiredReplicas, "Existing", numMachines)
+		reconcileRolloutAfterCompleted(rcp)
+	}
+
+	// If we've made it this far, we can assume that all ownedMachines are up to date.
+	// The server pools are scaled one after the other, starting with the machines running all the server roles.
+	for _, pool := range controlPlane.ServerPools() {
+		numMachines := pool.Machines.Len()
+		desiredReplicas := int(pool.Replicas)
+
+		switch {
+		// We are creating the first replica
+		case numMachines < desiredReplicas && len(ownedMachines) == 0:
+			// Create new Machine w/ init
+			logger.Info("Initializing control plane", "Desired", desiredReplicas, "Existing", numMachines)
+			conditions.MarkFalse(controlPlane.RCP,
+				controlplanev1.AvailableCondition,
+				controlplanev1.WaitingForRKE2ServerReason,
+				clusterv1.ConditionSeverityInfo, "")
+
+			return r.initializeControlPlane(ctx, cluster, rcp, controlPlane)
+		// We are scaling up
+		case numMachines < desiredReplicas:
+			// Create a new Machine w/ join
+			logger.Info("Scaling up control plane", "Desired", desiredReplicas, "Existing", numMachines, "role", pool.Role)
+
+			return r.scaleUpControlPlane(ctx, cluster, rcp, controlPlane, pool)
+
+		// We are scaling down
+		case numMachines > desiredReplicas:
+			logger.Info("Scaling down control plane", "Desired", desiredReplicas, "Existing", numMachines, "role", pool.Role)
+			// The last parameter (i.e. machines needing to be rolled out) should always be empty here.
+			return r.scaleDownControlPlane(ctx, cluster, rcp, controlPlane, pool, collections.Machines{})
+		}
+	}
 
-		return r.scaleUpControlPlane(ctx, cluster, rcp, controlPlane)
+	// The control plane is stable, run the etcd maintenance operations.
+	return r.reconcileEtcdDefragmentation(ctx, controlPlane)
+}
 
-	// We are scaling down
-	case numMachines > desiredReplicas:
-		logger.Info("Scaling down control plane", "Desired", desiredReplicas, "Existing", numMachines)
-		// The last parameter (i.e. machines needing to be rolled out) should always be empty here.
-		return r.scaleDownControlPlane(ctx, cluster, rcp, controlPlane, collections.Machines{})
+// controlPlaneCertificates returns the certificates managed by the provider for the control plane.
+func controlPlaneCertificates(rcp *controlplanev1.RKE2ControlPlane) secret.Certificates {
+	if _, found := rcp.Annotations[controlplanev1.LegacyRKE2ControlPlane]; found {
+		return secret.NewCertificatesForLegacyControlPlane()
 	}
 
-	return ctrl.Result{}, nil
+	return secret.NewCertificatesForInitialControlPlane()
 }
 
 // GetWorkloadCluster builds a cluster object.
@@ -639,7 +828,10 @@ func (r *RKE2ControlPlaneReconciler) reconcileEtcdMembers(ctx context.Context, c
 			return nil
 		}
 
-		nodeNames = append(nodeNames, machine.Status.NodeRef.Name)
+		// Machines of the control-plane server pool do not host an etcd member.
+		if rke2.RunsEtcd()(machine) {
+			nodeNames = append(nodeNames, machine.Status.NodeRef.Name)
+		}
 	}
 
 	// Potential inconsistencies between the list of members and the list of machines/nodes are
@@ -671,6 +863,129 @@ func (r *RKE2ControlPlaneReconciler) reconcileEtcdMembers(ctx context.Context, c
 	return nil
 }
 
+// rolloutAfterResult returns a result requeuing the RKE2ControlPlane when RolloutAfter is reached, if it is in the
+// future.
+func rolloutAfterResult(rcp *controlplanev1.RKE2ControlPlane) ctrl.Result {
+	if rcp.Spec.RolloutAfter == nil {
+		return ctrl.Result{}
+	}
+
+	if until := time.Until(rcp.Spec.RolloutAfter.Time); until > 0 {
+		return ctrl.Result{RequeueAfter: until}
+	}
+
+	return ctrl.Result{}
+}
+
+// reconcileRolloutAfterCompleted records the completion of the rollout requested by RolloutAfter, once the time is
+// reached and no machine needs to be rolled out anymore.
+func reconcileRolloutAfterCompleted(rcp *controlplanev1.RKE2ControlPlane) {
+	now := metav1.Now()
+	rolloutAfter := rcp.Spec.RolloutAfter
+
+	if rolloutAfter == nil || !rolloutAfter.Before(&now) {
+		return
+	}
+
+	if completed := rcp.Status.RolloutAfterCompletedTime; completed != nil && !completed.Before(rolloutAfter) {
+		return
+	}
+
+	rcp.Status.RolloutAfterCompletedTime = &now
+}
+
+// reconcileCertificateExpiries annotates the control plane machines with the expiry date of their certificates,
+// read from the serving certificate of their kube-apiserver. The machine controller then reports it in the
+// machine status, where it is used to roll out the machines before their certificates expire.
+// The expiry date is read again every certificatesExpiryCheckInterval, as RKE2 renews the certificates of a node when
+// it is restarted close to their expiry, e.g. by an in-place upgrade. A non-zero result is returned if the
+// expiry date of a machine can't be read, which is retried.
+func (r *RKE2ControlPlaneReconciler) reconcileCertificateExpiries(ctx context.Context, controlPlane *rke2.ControlPlane) ctrl.Result {
+	log := ctrl.LoggerFrom(ctx)
+
+	// Certificates are only available once the control plane is initialized.
+	if !controlPlane.RCP.Status.Initialized || controlPlane.Machines.Len() == 0 {
+		return ctrl.Result{}
+	}
+
+	// Etcd only machines do not run the kube-apiserver the expiry date is read from.
+	machines := controlPlane.Machines.Filter(
+		collections.Not(collections.HasDeletionTimestamp),
+		collections.HasNode(),
+		collections.Not(rke2.HasServerRole(controlplanev1.ServerRoleEtcd)),
+		func(machine *clusterv1.Machine) bool {
+			if _, found := machine.Annotations[clusterv1.MachineCertificatesExpiryDateAnnotation]; !found {
+				return true
+			}
+
+			checked, found := r.certificatesExpiryChecks.Load(machine.UID)
+
+			return !found || time.Since(checked.(time.Time)) >= certificatesExpiryCheckInterval //nolint:forcetypeassert
+		},
+	)
+	if machines.Len() == 0 {
+		return ctrl.Result{}
+	}
+
+	workloadCluster, err := r.GetWorkloadCluster(ctx, controlPlane)
+	if err != nil {
+		log.Error(err, "Failed to get workload cluster to read the certificates expiry dates")
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}
+	}
+
+	result := ctrl.Result{}
+
+	for _, machine := range machines {
+		if err := r.reconcileCertificateExpiry(ctx, workloadCluster, machine); err != nil {
+			log.Error(err, "Failed to record the certificates expiry date", "machine", machine.Name)
+
+			result = ctrl.Result{RequeueAfter: DefaultRequeueTime}
+
+			continue
+		}
+
+		r.certificatesExpiryChecks.Store(machine.UID, time.Now())
+	}
+
+	return result
+}
+
+// reconcileCertificateExpiry annotates a control plane machine with the expiry date of its certificates, if it
+// changed.
+func (r *RKE2ControlPlaneReconciler) reconcileCertificateExpiry(
+	ctx context.Context,
+	workloadCluster rke2.WorkloadCluster,
+	machine *clusterv1.Machine,
+) error {
+	expiry, err := workloadCluster.APIServerCertificateExpiry(ctx, machine.Status.NodeRef.Name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get certificates expiry date for machine %s", machine.Name)
+	}
+
+	value := expiry.UTC().Format(time.RFC3339)
+	if machine.Annotations[clusterv1.MachineCertificatesExpiryDateAnnotation] == value {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(machine, r.Client)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create patch helper for machine %s", machine.Name)
+	}
+
+	annotations.AddAnnotations(machine, map[string]string{
+		clusterv1.MachineCertificatesExpiryDateAnnotation: value,
+	})
+
+	if err := patchHelper.Patch(ctx, machine); err != nil {
+		return errors.Wrapf(err, "failed to annotate machine %s with its certificates expiry date", machine.Name)
+	}
+
+	ctrl.LoggerFrom(ctx).V(4).Info("Recorded certificates expiry date", "machine", machine.Name, "expiry", expiry)
+
+	return nil
+}
+
 func (r *RKE2ControlPlaneReconciler) reconcileDelete(ctx context.Context,
 	cluster *clusterv1.Cluster,
 	rcp *controlplanev1.RKE2ControlPlane,
@@ -732,6 +1047,17 @@ func (r *RKE2ControlPlaneReconciler) reconcileDelete(ctx context.Context,
 		return ctrl.Result{RequeueAfter: deleteRequeueAfter}, nil
 	}
 
+	// Take the final etcd snapshot, if requested, while all the etcd members are still running.
+	if result, err := r.reconcileFinalEtcdSnapshot(ctx, cluster, rcp); err != nil || !result.IsZero() {
+		return result, err
+	}
+
+	// Delete the resources of the workload cluster backed by cloud resources, if requested. The worker machines are
+	// already gone, so only the controllers running on the control plane machines can still release them.
+	if result, err := r.reconcileWorkloadCleanup(ctx, cluster, rcp); err != nil || !result.IsZero() {
+		return result, err
+	}
+
 	// Delete control plane machines in parallel
 	machinesToDelete := ownedMachines
 
@@ -881,6 +1207,9 @@ func (r *RKE2ControlPlaneReconciler) reconcileControlPlaneConditions(
 		}
 	}()
 
+	// Report why the machines are rolled out or upgraded in place, if they are.
+	controlPlane.UpdateMachineSpecConditions()
+
 	if err := workloadCluster.InitWorkload(ctx, controlPlane); err != nil {
 		logger.Error(err, "Unable to initialize workload cluster")
 
@@ -889,7 +1218,7 @@ func (r *RKE2ControlPlaneReconciler) reconcileControlPlaneConditions(
 
 	// Update conditions status
 	workloadCluster.UpdateAgentConditions(controlPlane)
-	workloadCluster.UpdateEtcdConditions(controlPlane)
+	workloadCluster.UpdateEtcdConditions(ctx, controlPlane)
 
 	// Patch nodes metadata
 	if err := workloadCluster.UpdateNodeMetadata(ctx, controlPlane); err != nil {
@@ -930,25 +1259,69 @@ func (r *RKE2ControlPlaneReconciler) upgradeControlPlane(
 		return ctrl.Result{}, err
 	}
 
-	switch rcp.Spec.RolloutStrategy.Type {
-	case controlplanev1.RollingUpdateStrategyType:
-		// RolloutStrategy is currently defaulted and validated to be RollingUpdate.
+	strategyType := controlplanev1.RollingUpdateStrategyType
+	if rcp.Spec.RolloutStrategy != nil && rcp.Spec.RolloutStrategy.Type != "" {
+		strategyType = rcp.Spec.RolloutStrategy.Type
+	}
+
+	// The server pools are rolled out one after the other, starting with the machines running etcd.
+	var pool *rke2.ServerPool
+
+	for _, p := range controlPlane.ServerPools() {
+		if machinesRequireUpgrade.Filter(rke2.HasServerRole(p.Role)).Len() > 0 {
+			pool = p
+
+			break
+		}
+	}
+
+	if pool == nil {
+		return ctrl.Result{}, nil
+	}
+
+	switch strategyType {
+	case controlplanev1.RollingUpdateStrategyType, controlplanev1.InPlaceUpgradeStrategyType:
+		// Changes which can't be upgraded in place are rolled out like RollingUpdate.
 		// Defaulted to 1 if not specified
 		maxSurge := intstr.FromInt(1)
-		if rcp.Spec.RolloutStrategy.RollingUpdate != nil && rcp.Spec.RolloutStrategy.RollingUpdate.MaxSurge != nil {
+		if rcp.Spec.RolloutStrategy != nil && rcp.Spec.RolloutStrategy.RollingUpdate != nil && rcp.Spec.RolloutStrategy.RollingUpdate.MaxSurge != nil {
 			maxSurge = *rcp.Spec.RolloutStrategy.RollingUpdate.MaxSurge
 		}
 
-		maxNodes := *rcp.Spec.Replicas + int32(maxSurge.IntValue())
-		if int32(controlPlane.Machines.Len()) < maxNodes {
+		maxNodes := pool.Replicas + int32(maxSurge.IntValue())
+		if int32(pool.Machines.Len()) < maxNodes {
 			// scaleUpControlPlane ensures that we don't continue scaling up while waiting for Machines to have NodeRefs
-			return r.scaleUpControlPlane(ctx, cluster, rcp, controlPlane)
+			return r.scaleUpControlPlane(ctx, cluster, rcp, controlPlane, pool)
+		}
+
+		return r.scaleDownControlPlane(ctx, cluster, rcp, controlPlane, pool, machinesRequireUpgrade)
+	case controlplanev1.ScaleDownFirstStrategyType:
+		// Once the outdated Machine is gone, create its replacement.
+		if int32(pool.Machines.Len()) < pool.Replicas {
+			return r.scaleUpControlPlane(ctx, cluster, rcp, controlPlane, pool)
+		}
+
+		// Removing a Machine from a control plane with 1 or 2 etcd members would leave etcd without quorum.
+		// Machines of the control-plane server pool do not host an etcd member.
+		if etcdReplicas := controlPlane.EtcdReplicas(); pool.Role != controlplanev1.ServerRoleControlPlane &&
+			etcdReplicas < minReplicasForScaleDownFirst {
+			logger.Info("Refusing to roll out control plane machines with ScaleDownFirst strategy, not enough replicas to preserve etcd quorum",
+				"replicas", etcdReplicas)
+			conditions.MarkFalse(rcp,
+				controlplanev1.MachinesSpecUpToDateCondition,
+				controlplanev1.RolloutBlockedReason,
+				clusterv1.ConditionSeverityWarning,
+				"ScaleDownFirst rollout strategy requires at least %d replicas to preserve etcd quorum (actual %d)",
+				minReplicasForScaleDownFirst,
+				etcdReplicas)
+
+			return ctrl.Result{}, nil
 		}
 
-		return r.scaleDownControlPlane(ctx, cluster, rcp, controlPlane, machinesRequireUpgrade)
+		return r.scaleDownControlPlane(ctx, cluster, rcp, controlPlane, pool, machinesRequireUpgrade)
 	default:
-		err := fmt.Errorf("unknown rollout strategy type %q", rcp.Spec.RolloutStrategy.Type)
-		logger.Error(err, "RolloutStrategy type is not set to RollingUpdateStrategyType, unable to determine the strategy for rolling out machines")
+		err := fmt.Errorf("unknown rollout strategy type %q", strategyType)
+		logger.Error(err, "RolloutStrategy type is not supported, unable to determine the strategy for rolling out machines")
 
 		return ctrl.Result{}, nil
 	}
@@ -1035,8 +1408,8 @@ func (r *RKE2ControlPlaneReconciler) reconcilePreTerminateHook(ctx context.Conte
 	_, found := controlPlane.RCP.Annotations[controlplanev1.LegacyRKE2ControlPlane]
 
 	// If we have more than 1 Machine and etcd is managed we forward etcd leadership and remove the member
-	// to keep the etcd cluster healthy.
-	if controlPlane.Machines.Len() > 1 && !found {
+	// to keep the etcd cluster healthy. Machines of the control-plane server pool do not host an etcd member.
+	if controlPlane.Machines.Len() > 1 && !found && rke2.RunsEtcd()(deletingMachine) {
 		workloadCluster, err := r.GetWorkloadCluster(ctx, controlPlane)
 		if err != nil {
 			return ctrl.Result{}, errors.Wrapf(err,
@@ -1046,7 +1419,7 @@ func (r *RKE2ControlPlaneReconciler) reconcilePreTerminateHook(ctx context.Conte
 		// Note: In regular deletion cases (remediation, scale down) the leader should have been already moved.
 		// We're doing this again here in case the Machine became leader again or the Machine deletion was
 		// triggered in another way (e.g. a user running kubectl delete machine)
-		etcdLeaderCandidate := controlPlane.Machines.Filter(collections.Not(collections.HasDeletionTimestamp)).Newest()
+		etcdLeaderCandidate := controlPlane.Machines.Filter(collections.Not(collections.HasDeletionTimestamp), rke2.RunsEtcd()).Newest()
 		if etcdLeaderCandidate != nil {
 			if err := workloadCluster.ForwardEtcdLeadership(ctx, deletingMachine, etcdLeaderCandidate); err != nil {
 				return ctrl.Result{}, errors.Wrapf(err, "failed to move leadership to candidate Machine %s", etcdLeaderCandidate.Name)
@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"context"
+	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -12,6 +14,7 @@ import (
 	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/collections"
 	"sigs.k8s.io/cluster-api/util/conditions"
@@ -252,3 +255,100 @@ var _ = Describe("Reconcile control plane conditions", func() {
 			"Control plane node missing-machine does not have a corresponding machine"))
 	})
 })
+
+func TestReconcileCertificateExpiries(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cluster, rcp, machines := newFakeControlPlane()
+	expiry := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	annotated := machines["m0"]
+	annotated.Annotations = map[string]string{clusterv1.MachineCertificatesExpiryDateAnnotation: "2029-01-01T00:00:00Z"}
+
+	provisioning := machines["m2"]
+	provisioning.Status.NodeRef = nil
+
+	for _, machine := range machines {
+		machine.UID = types.UID(machine.Name)
+	}
+
+	fakeClient := newFakeClient(machinesToObjects(machines)...)
+	workload := &fakeWorkloadCluster{CertificateExpiries: map[string]time.Time{"m1": expiry}}
+	r := &RKE2ControlPlaneReconciler{
+		Client:            fakeClient,
+		managementCluster: &fakeManagementCluster{Machines: machines, Workload: workload},
+	}
+
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	expectAnnotations := func(expectedAnnotations map[string]string) {
+		for name, expected := range expectedAnnotations {
+			machine := &clusterv1.Machine{}
+			g.Expect(fakeClient.Get(ctx, client.ObjectKey{Namespace: metav1.NamespaceDefault, Name: name}, machine)).To(Succeed())
+			g.Expect(machine.Annotations[clusterv1.MachineCertificatesExpiryDateAnnotation]).To(Equal(expected), name)
+		}
+	}
+
+	// The machines whose expiry date can't be read are skipped, and retried.
+	g.Expect(r.reconcileCertificateExpiries(ctx, controlPlane).RequeueAfter).To(Equal(DefaultRequeueTime))
+	expectAnnotations(map[string]string{
+		"m0": "2029-01-01T00:00:00Z",
+		"m1": "2030-01-01T00:00:00Z",
+		"m2": "",
+	})
+
+	// The expiry date of an annotated machine is read again, as its certificates may have been renewed.
+	workload.CertificateExpiries["m0"] = expiry.AddDate(1, 0, 0)
+
+	g.Expect(r.reconcileCertificateExpiries(ctx, controlPlane).RequeueAfter).To(BeZero())
+	expectAnnotations(map[string]string{"m0": "2031-01-01T00:00:00Z"})
+
+	// The expiry date is not read again within the check interval.
+	workload.CertificateExpiries["m1"] = expiry.AddDate(2, 0, 0)
+
+	g.Expect(r.reconcileCertificateExpiries(ctx, controlPlane).RequeueAfter).To(BeZero())
+	expectAnnotations(map[string]string{"m1": "2030-01-01T00:00:00Z"})
+}
+
+func TestRolloutAfterResult(t *testing.T) {
+	g := NewWithT(t)
+
+	_, rcp, _ := newFakeControlPlane()
+	g.Expect(rolloutAfterResult(rcp).RequeueAfter).To(BeZero())
+
+	// The control plane is requeued when RolloutAfter is reached.
+	rcp.Spec.RolloutAfter = &metav1.Time{Time: time.Now().Add(time.Hour)}
+	g.Expect(rolloutAfterResult(rcp).RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+
+	rcp.Spec.RolloutAfter = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+	g.Expect(rolloutAfterResult(rcp).RequeueAfter).To(BeZero())
+}
+
+func TestReconcileRolloutAfterCompleted(t *testing.T) {
+	g := NewWithT(t)
+
+	_, rcp, _ := newFakeControlPlane()
+
+	reconcileRolloutAfterCompleted(rcp)
+	g.Expect(rcp.Status.RolloutAfterCompletedTime).To(BeNil())
+
+	// The rollout is not completed before RolloutAfter is reached.
+	rcp.Spec.RolloutAfter = &metav1.Time{Time: time.Now().Add(time.Hour)}
+	reconcileRolloutAfterCompleted(rcp)
+	g.Expect(rcp.Status.RolloutAfterCompletedTime).To(BeNil())
+
+	rcp.Spec.RolloutAfter = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+	reconcileRolloutAfterCompleted(rcp)
+	g.Expect(rcp.Status.RolloutAfterCompletedTime).ToNot(BeNil())
+
+	// The completion time is kept until RolloutAfter changes.
+	completed := *rcp.Status.RolloutAfterCompletedTime
+	reconcileRolloutAfterCompleted(rcp)
+	g.Expect(*rcp.Status.RolloutAfterCompletedTime).To(Equal(completed))
+
+	// A later RolloutAfter is completed again.
+	rcp.Status.RolloutAfterCompletedTime = &metav1.Time{Time: time.Now().Add(-2 * time.Hour)}
+	reconcileRolloutAfterCompleted(rcp)
+	g.Expect(rcp.Status.RolloutAfterCompletedTime.After(rcp.Spec.RolloutAfter.Time)).To(BeTrue())
+}
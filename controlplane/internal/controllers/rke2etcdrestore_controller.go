@@ -0,0 +1,477 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/clustercache"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+// RKE2EtcdRestoreReconciler reconciles a RKE2EtcdRestore object.
+type RKE2EtcdRestoreReconciler struct {
+	client.Client
+
+	SecretCachingClient client.Client
+	ClusterCache        clustercache.ClusterCache
+
+	managementCluster rke2.ManagementCluster
+	recorder          record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=rke2etcdrestores,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=rke2etcdrestores/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=rke2etcdrestores/finalizers,verbs=update
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RKE2EtcdRestoreReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&controlplanev1.RKE2EtcdRestore{}).
+		Watches(
+			&controlplanev1.RKE2ControlPlane{},
+			handler.EnqueueRequestsFromMapFunc(r.rke2ControlPlaneToRKE2EtcdRestores(ctx)),
+		).
+		Complete(r)
+	if err != nil {
+		return errors.Wrap(err, "failed setting up with a controller manager")
+	}
+
+	r.recorder = mgr.GetEventRecorderFor("rke2-etcd-restore-controller")
+
+	if r.managementCluster == nil {
+		r.managementCluster = &rke2.Management{
+			Client:              r.Client,
+			SecretCachingClient: r.SecretCachingClient,
+			ClusterCache:        r.ClusterCache,
+		}
+	}
+
+	return nil
+}
+
+// Reconcile restores an etcd snapshot on a RKE2ControlPlane. The control plane is scaled down to a single
+// machine, the snapshot is restored on it and the control plane is then scaled back to the desired replicas.
+func (r *RKE2EtcdRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, reterr error) {
+	logger := log.FromContext(ctx)
+
+	restore := &controlplanev1.RKE2EtcdRestore{}
+	if err := r.Get(ctx, req.NamespacedName, restore); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	logger = logger.WithValues("controlPlane", restore.Spec.ControlPlaneName, "snapshot", restore.Spec.Snapshot.Name)
+	ctx = log.IntoContext(ctx, logger)
+
+	patchHelper, err := patch.NewHelper(restore, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	defer func() {
+		conditions.SetSummary(restore,
+			conditions.WithConditions(
+				controlplanev1.ControlPlaneScaledDownCondition,
+				controlplanev1.EtcdRestoredCondition,
+				controlplanev1.MachinesRejoinedCondition,
+			),
+		)
+
+		if err := patchHelper.Patch(ctx, restore); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+	}()
+
+	if !restore.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.reconcileDelete(ctx, restore)
+	}
+
+	controllerutil.AddFinalizer(restore, controlplanev1.RKE2EtcdRestoreFinalizer)
+
+	return r.reconcileNormal(ctx, restore)
+}
+
+func (r *RKE2EtcdRestoreReconciler) reconcileNormal(ctx context.Context, restore *controlplanev1.RKE2EtcdRestore) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	switch restore.Status.Phase {
+	case controlplanev1.RKE2EtcdRestorePhaseCompleted, controlplanev1.RKE2EtcdRestorePhaseFailed:
+		return ctrl.Result{}, r.releaseControlPlane(ctx, restore)
+	case "":
+		restore.Status.Phase = controlplanev1.RKE2EtcdRestorePhasePending
+	}
+
+	rcp := &controlplanev1.RKE2ControlPlane{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: restore.Namespace, Name: restore.Spec.ControlPlaneName}, rcp); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("RKE2ControlPlane not found")
+			conditions.MarkFalse(restore, controlplanev1.ControlPlaneScaledDownCondition,
+				controlplanev1.WaitingForControlPlaneReason, clusterv1.ConditionSeverityInfo,
+				"RKE2ControlPlane %s not found", restore.Spec.ControlPlaneName)
+
+			return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	cluster, err := util.GetOwnerCluster(ctx, r.Client, rcp.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if cluster == nil {
+		logger.Info("Cluster Controller has not yet set OwnerRef on the RKE2ControlPlane")
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	machines, err := r.managementCluster.GetMachinesForCluster(ctx, util.ObjectKey(cluster), collections.ControlPlaneMachines(cluster.Name))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch restore.Status.Phase {
+	case controlplanev1.RKE2EtcdRestorePhasePending:
+		return r.reconcilePending(ctx, restore, rcp, machines)
+	case controlplanev1.RKE2EtcdRestorePhaseScalingDown:
+		return r.reconcileScaleDown(ctx, restore, machines)
+	case controlplanev1.RKE2EtcdRestorePhaseRestoring:
+		return r.reconcileRestore(ctx, restore, rcp, cluster, machines)
+	case controlplanev1.RKE2EtcdRestorePhaseRejoining:
+		return r.reconcileRejoin(restore, rcp)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// reconcilePending selects the machine the snapshot is restored on, and holds the RKE2ControlPlane so that
+// it does not scale, roll out or remediate machines while the restore is in progress.
+func (r *RKE2EtcdRestoreReconciler) reconcilePending(
+	ctx context.Context,
+	restore *controlplanev1.RKE2EtcdRestore,
+	rcp *controlplanev1.RKE2ControlPlane,
+	machines collections.Machines,
+) (ctrl.Result, error) {
+	if !rcp.Status.Initialized {
+		conditions.MarkFalse(restore, controlplanev1.ControlPlaneScaledDownCondition,
+			controlplanev1.WaitingForControlPlaneReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for RKE2ControlPlane %s to be initialized", rcp.Name)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	if holder, found := rcp.Annotations[controlplanev1.EtcdRestoreInProgressAnnotation]; found && holder != restore.Name {
+		conditions.MarkFalse(restore, controlplanev1.ControlPlaneScaledDownCondition,
+			controlplanev1.WaitingForControlPlaneReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for RKE2EtcdRestore %s to complete", holder)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	machine := hostCommandMachine(restore.Spec.MachineName, machines)
+	if machine == nil {
+		r.fail(restore, controlplanev1.ControlPlaneScaledDownCondition, controlplanev1.MachineNotFoundReason,
+			"No control plane machine with a node available to restore the snapshot on")
+
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(rcp, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	annotations := rcp.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[controlplanev1.EtcdRestoreInProgressAnnotation] = restore.Name
+	rcp.SetAnnotations(annotations)
+
+	if err := patchHelper.Patch(ctx, rcp); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to hold RKE2ControlPlane %s", rcp.Name)
+	}
+
+	log.FromContext(ctx).Info("Starting etcd snapshot restore", "machine", machine.Name)
+
+	restore.Status.MachineName = machine.Name
+	restore.Status.Phase = controlplanev1.RKE2EtcdRestorePhaseScalingDown
+	conditions.MarkFalse(restore, controlplanev1.ControlPlaneScaledDownCondition,
+		controlplanev1.ScalingDownReason, clusterv1.ConditionSeverityInfo, "")
+
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// reconcileScaleDown deletes all the control plane machines but the one the snapshot is restored on.
+// Etcd members are removed by the RKE2ControlPlane controller while the machines are deleted.
+func (r *RKE2EtcdRestoreReconciler) reconcileScaleDown(
+	ctx context.Context,
+	restore *controlplanev1.RKE2EtcdRestore,
+	machines collections.Machines,
+) (ctrl.Result, error) {
+	if _, found := machines[restore.Status.MachineName]; !found {
+		r.fail(restore, controlplanev1.ControlPlaneScaledDownCondition, controlplanev1.MachineNotFoundReason,
+			fmt.Sprintf("Machine %s not found", restore.Status.MachineName))
+
+		return ctrl.Result{}, r.releaseControlPlane(ctx, restore)
+	}
+
+	others := machines.Filter(func(machine *clusterv1.Machine) bool {
+		return machine.Name != restore.Status.MachineName
+	})
+
+	for _, machine := range others.Filter(collections.Not(collections.HasDeletionTimestamp)) {
+		log.FromContext(ctx).Info("Deleting control plane machine before restoring etcd snapshot", "machine", machine.Name)
+
+		if err := r.Client.Delete(ctx, machine); err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to delete control plane machine %s", machine.Name)
+		}
+	}
+
+	if len(others) > 0 {
+		conditions.MarkFalse(restore, controlplanev1.ControlPlaneScaledDownCondition,
+			controlplanev1.ScalingDownReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for %d control plane machines to be deleted", len(others))
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	conditions.MarkTrue(restore, controlplanev1.ControlPlaneScaledDownCondition)
+	restore.Status.Phase = controlplanev1.RKE2EtcdRestorePhaseRestoring
+
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// reconcileRestore runs the restore on the remaining control plane node. The workload cluster is
+// unavailable while RKE2 restarts, so connection errors are not reported as reconcile errors.
+func (r *RKE2EtcdRestoreReconciler) reconcileRestore(
+	ctx context.Context,
+	restore *controlplanev1.RKE2EtcdRestore,
+	rcp *controlplanev1.RKE2ControlPlane,
+	cluster *clusterv1.Cluster,
+	machines collections.Machines,
+) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	machine, found := machines[restore.Status.MachineName]
+	if !found || machine.Status.NodeRef == nil {
+		r.fail(restore, controlplanev1.EtcdRestoredCondition, controlplanev1.MachineNotFoundReason,
+			fmt.Sprintf("Machine %s not found or without a node", restore.Status.MachineName))
+
+		return ctrl.Result{}, r.releaseControlPlane(ctx, restore)
+	}
+
+	workloadCluster, err := r.managementCluster.GetWorkloadCluster(ctx, util.ObjectKey(cluster))
+	if err != nil {
+		logger.Info("Could not connect to workload cluster, waiting for it to be available", "err", err.Error())
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	restoreCommand := rke2.NewEtcdRestoreCommand(restore, rcp, machine.Status.NodeRef.Name)
+
+	pod, err := workloadCluster.GetHostCommandPod(ctx, restoreCommand)
+	if err != nil {
+		logger.Info("Could not get etcd restore pod, waiting for the workload cluster to be available", "err", err.Error())
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	started := conditions.GetReason(restore, controlplanev1.EtcdRestoredCondition) == controlplanev1.EtcdRestoreInProgressReason
+
+	switch {
+	case pod == nil && !started:
+		if err := workloadCluster.StartHostCommand(ctx, restoreCommand); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		logger.Info("Started etcd snapshot restore", "machine", machine.Name, "node", machine.Status.NodeRef.Name)
+		conditions.MarkFalse(restore, controlplanev1.EtcdRestoredCondition,
+			controlplanev1.EtcdRestoreInProgressReason, clusterv1.ConditionSeverityInfo,
+			"Restoring snapshot on machine %s", machine.Name)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	case pod != nil && pod.Status.Phase == corev1.PodFailed:
+		if err := workloadCluster.DeleteHostCommandPod(ctx, restoreCommand); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		r.fail(restore, controlplanev1.EtcdRestoredCondition, controlplanev1.EtcdRestoreFailedReason,
+			fmt.Sprintf("Restore failed on machine %s, check the rke2-etcd-restore unit logs on the node", machine.Name))
+
+		return ctrl.Result{}, r.releaseControlPlane(ctx, restore)
+	case pod != nil && pod.Status.Phase != corev1.PodSucceeded:
+		conditions.MarkFalse(restore, controlplanev1.EtcdRestoredCondition,
+			controlplanev1.EtcdRestoreInProgressReason, clusterv1.ConditionSeverityInfo,
+			"Restoring snapshot on machine %s", machine.Name)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	case pod != nil:
+		if err := workloadCluster.DeleteHostCommandPod(ctx, restoreCommand); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// The restore pod is not part of the restored etcd data, so once it is gone the snapshot has been restored.
+	logger.Info("Etcd snapshot restored", "machine", machine.Name)
+	r.recorder.Eventf(restore, corev1.EventTypeNormal, "EtcdRestored",
+		"Restored snapshot %s on machine %s", restore.Spec.Snapshot.Name, machine.Name)
+
+	conditions.MarkTrue(restore, controlplanev1.EtcdRestoredCondition)
+	conditions.MarkFalse(restore, controlplanev1.MachinesRejoinedCondition,
+		controlplanev1.WaitingForMachinesReason, clusterv1.ConditionSeverityInfo, "")
+	restore.Status.Phase = controlplanev1.RKE2EtcdRestorePhaseRejoining
+
+	// Releasing the RKE2ControlPlane lets it scale back to the desired replicas.
+	return ctrl.Result{RequeueAfter: DefaultRequeueTime}, r.releaseControlPlane(ctx, restore)
+}
+
+// reconcileRejoin waits for the RKE2ControlPlane to be scaled back to the desired replicas.
+func (r *RKE2EtcdRestoreReconciler) reconcileRejoin(
+	restore *controlplanev1.RKE2EtcdRestore,
+	rcp *controlplanev1.RKE2ControlPlane,
+) (ctrl.Result, error) {
+	desiredReplicas := int32(1)
+	if rcp.Spec.Replicas != nil {
+		desiredReplicas = *rcp.Spec.Replicas
+	}
+
+	if rcp.Status.Replicas != desiredReplicas || rcp.Status.ReadyReplicas < desiredReplicas {
+		conditions.MarkFalse(restore, controlplanev1.MachinesRejoinedCondition,
+			controlplanev1.WaitingForMachinesReason, clusterv1.ConditionSeverityInfo,
+			"%d of %d replicas ready", rcp.Status.ReadyReplicas, desiredReplicas)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	conditions.MarkTrue(restore, controlplanev1.MachinesRejoinedCondition)
+	restore.Status.Phase = controlplanev1.RKE2EtcdRestorePhaseCompleted
+
+	return ctrl.Result{}, nil
+}
+
+func (r *RKE2EtcdRestoreReconciler) reconcileDelete(ctx context.Context, restore *controlplanev1.RKE2EtcdRestore) error {
+	if err := r.releaseControlPlane(ctx, restore); err != nil {
+		return err
+	}
+
+	controllerutil.RemoveFinalizer(restore, controlplanev1.RKE2EtcdRestoreFinalizer)
+
+	return nil
+}
+
+// releaseControlPlane removes the restore in progress annotation from the RKE2ControlPlane, if it is held by the restore.
+func (r *RKE2EtcdRestoreReconciler) releaseControlPlane(ctx context.Context, restore *controlplanev1.RKE2EtcdRestore) error {
+	rcp := &controlplanev1.RKE2ControlPlane{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: restore.Namespace, Name: restore.Spec.ControlPlaneName}, rcp); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if rcp.Annotations[controlplanev1.EtcdRestoreInProgressAnnotation] != restore.Name {
+		return nil
+	}
+
+	patchHelper, err := patch.NewHelper(rcp, r.Client)
+	if err != nil {
+		return err
+	}
+
+	delete(rcp.Annotations, controlplanev1.EtcdRestoreInProgressAnnotation)
+
+	if err := patchHelper.Patch(ctx, rcp); err != nil {
+		return errors.Wrapf(err, "failed to release RKE2ControlPlane %s", rcp.Name)
+	}
+
+	return nil
+}
+
+func (r *RKE2EtcdRestoreReconciler) fail(
+	restore *controlplanev1.RKE2EtcdRestore,
+	condition clusterv1.ConditionType,
+	reason string,
+	message string,
+) {
+	conditions.MarkFalse(restore, condition, reason, clusterv1.ConditionSeverityError, "%s", message)
+	restore.Status.Phase = controlplanev1.RKE2EtcdRestorePhaseFailed
+
+	r.recorder.Event(restore, corev1.EventTypeWarning, reason, message)
+}
+
+// hostCommandMachine returns the machine with the given name, or the oldest machine if name is empty.
+// Only machines with a node, which are not being deleted and run all the server roles, are considered.
+func hostCommandMachine(name string, machines collections.Machines) *clusterv1.Machine {
+	candidates := machines.Filter(collections.Not(collections.HasDeletionTimestamp), rke2.HasServerRole(""), func(machine *clusterv1.Machine) bool {
+		return machine.Status.NodeRef != nil
+	})
+
+	if name != "" {
+		return candidates[name]
+	}
+
+	return candidates.Oldest()
+}
+
+func (r *RKE2EtcdRestoreReconciler) rke2ControlPlaneToRKE2EtcdRestores(ctx context.Context) handler.MapFunc {
+	log := log.FromContext(ctx)
+
+	return func(ctx context.Context, o client.Object) []ctrl.Request {
+		rcp, ok := o.(*controlplanev1.RKE2ControlPlane)
+		if !ok {
+			log.Error(nil, fmt.Sprintf("Expected a RKE2ControlPlane but got a %T", o))
+
+			return nil
+		}
+
+		restores := &controlplanev1.RKE2EtcdRestoreList{}
+		if err := r.List(ctx, restores, client.InNamespace(rcp.Namespace)); err != nil {
+			log.Error(err, "Failed to list RKE2EtcdRestores")
+
+			return nil
+		}
+
+		requests := []ctrl.Request{}
+
+		for _, restore := range restores.Items {
+			if restore.Spec.ControlPlaneName == rcp.Name {
+				requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&restore)})
+			}
+		}
+
+		return requests
+	}
+}
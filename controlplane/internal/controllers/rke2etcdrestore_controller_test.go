@@ -0,0 +1,156 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+type etcdRestoreTestEnv struct {
+	client     client.Client
+	reconciler *RKE2EtcdRestoreReconciler
+	management *fakeManagementCluster
+	workload   *fakeWorkloadCluster
+	rcp        *controlplanev1.RKE2ControlPlane
+	restore    *controlplanev1.RKE2EtcdRestore
+}
+
+func newEtcdRestoreTestEnv() *etcdRestoreTestEnv {
+	cluster, rcp, machines := newFakeControlPlane()
+	restore := &controlplanev1.RKE2EtcdRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: metav1.NamespaceDefault},
+		Spec: controlplanev1.RKE2EtcdRestoreSpec{
+			ControlPlaneName: rcp.Name,
+			Snapshot:         controlplanev1.EtcdRestoreSnapshot{Name: "snapshot", Source: controlplanev1.EtcdSnapshotSourceLocal},
+		},
+	}
+
+	fakeClient := newFakeClient(append([]client.Object{cluster, rcp, restore}, machinesToObjects(machines)...)...)
+	workload := &fakeWorkloadCluster{}
+	management := &fakeManagementCluster{Machines: machines, Workload: workload}
+
+	return &etcdRestoreTestEnv{
+		client: fakeClient,
+		reconciler: &RKE2EtcdRestoreReconciler{
+			Client:            fakeClient,
+			managementCluster: management,
+			recorder:          record.NewFakeRecorder(32),
+		},
+		management: management,
+		workload:   workload,
+		rcp:        rcp,
+		restore:    restore,
+	}
+}
+
+func (e *etcdRestoreTestEnv) reconcile(g *WithT) {
+	_, err := e.reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(e.restore)})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(e.client.Get(context.Background(), client.ObjectKeyFromObject(e.restore), e.restore)).To(Succeed())
+	g.Expect(e.client.Get(context.Background(), client.ObjectKeyFromObject(e.rcp), e.rcp)).To(Succeed())
+}
+
+func TestRKE2EtcdRestoreReconcile(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	env := newEtcdRestoreTestEnv()
+
+	// The control plane is held and the oldest machine is selected.
+	env.reconcile(g)
+	g.Expect(env.restore.Status.Phase).To(Equal(controlplanev1.RKE2EtcdRestorePhaseScalingDown))
+	g.Expect(env.restore.Status.MachineName).To(Equal("m0"))
+	g.Expect(env.rcp.Annotations).To(HaveKeyWithValue(controlplanev1.EtcdRestoreInProgressAnnotation, env.restore.Name))
+
+	// All the other machines are deleted.
+	env.reconcile(g)
+	g.Expect(env.restore.Status.Phase).To(Equal(controlplanev1.RKE2EtcdRestorePhaseScalingDown))
+
+	machines := &clusterv1.MachineList{}
+	g.Expect(env.client.List(ctx, machines)).To(Succeed())
+	g.Expect(machines.Items).To(HaveLen(1))
+	g.Expect(machines.Items[0].Name).To(Equal("m0"))
+
+	env.management.Machines = collections.FromMachines(env.management.Machines["m0"])
+	env.reconcile(g)
+	g.Expect(env.restore.Status.Phase).To(Equal(controlplanev1.RKE2EtcdRestorePhaseRestoring))
+	g.Expect(conditions.IsTrue(env.restore, controlplanev1.ControlPlaneScaledDownCondition)).To(BeTrue())
+
+	// The restore is started on the node of the remaining machine.
+	env.reconcile(g)
+	g.Expect(env.workload.StartedCommands).To(HaveLen(1))
+	g.Expect(env.workload.StartedCommands[0].NodeName).To(Equal("m0"))
+	g.Expect(env.workload.StartedCommands[0].Command).
+		To(ContainElement("--cluster-reset-restore-path=/var/lib/rancher/rke2/server/db/snapshots/snapshot"))
+	g.Expect(conditions.GetReason(env.restore, controlplanev1.EtcdRestoredCondition)).
+		To(Equal(controlplanev1.EtcdRestoreInProgressReason))
+
+	// The restore pod is not part of the restored etcd data, once it is gone the control plane is released.
+	env.reconcile(g)
+	g.Expect(env.workload.StartedCommands).To(HaveLen(1))
+	g.Expect(env.restore.Status.Phase).To(Equal(controlplanev1.RKE2EtcdRestorePhaseRejoining))
+	g.Expect(conditions.IsTrue(env.restore, controlplanev1.EtcdRestoredCondition)).To(BeTrue())
+	g.Expect(env.rcp.Annotations).ToNot(HaveKey(controlplanev1.EtcdRestoreInProgressAnnotation))
+
+	env.reconcile(g)
+	g.Expect(env.restore.Status.Phase).To(Equal(controlplanev1.RKE2EtcdRestorePhaseRejoining))
+	g.Expect(conditions.IsFalse(env.restore, controlplanev1.MachinesRejoinedCondition)).To(BeTrue())
+
+	env.rcp.Status.Replicas = 3
+	env.rcp.Status.ReadyReplicas = 3
+	g.Expect(env.client.Status().Update(ctx, env.rcp)).To(Succeed())
+
+	env.reconcile(g)
+	g.Expect(env.restore.Status.Phase).To(Equal(controlplanev1.RKE2EtcdRestorePhaseCompleted))
+	g.Expect(conditions.IsTrue(env.restore, clusterv1.ReadyCondition)).To(BeTrue())
+}
+
+func TestRKE2EtcdRestoreReconcileFailure(t *testing.T) {
+	g := NewWithT(t)
+	env := newEtcdRestoreTestEnv()
+
+	env.restore.Status.Phase = controlplanev1.RKE2EtcdRestorePhaseRestoring
+	env.restore.Status.MachineName = "m0"
+	conditions.MarkFalse(env.restore, controlplanev1.EtcdRestoredCondition,
+		controlplanev1.EtcdRestoreInProgressReason, clusterv1.ConditionSeverityInfo, "")
+	g.Expect(env.client.Status().Update(context.Background(), env.restore)).To(Succeed())
+
+	env.rcp.Annotations = map[string]string{controlplanev1.EtcdRestoreInProgressAnnotation: env.restore.Name}
+	g.Expect(env.client.Update(context.Background(), env.rcp)).To(Succeed())
+
+	env.workload.HostCommandPod = &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed}}
+
+	env.reconcile(g)
+	g.Expect(env.restore.Status.Phase).To(Equal(controlplanev1.RKE2EtcdRestorePhaseFailed))
+	g.Expect(conditions.GetReason(env.restore, controlplanev1.EtcdRestoredCondition)).
+		To(Equal(controlplanev1.EtcdRestoreFailedReason))
+	g.Expect(env.workload.HostCommandPod).To(BeNil())
+	g.Expect(env.rcp.Annotations).ToNot(HaveKey(controlplanev1.EtcdRestoreInProgressAnnotation))
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/clustercache"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+// RKE2EtcdSnapshotReconciler reconciles a RKE2EtcdSnapshot object.
+type RKE2EtcdSnapshotReconciler struct {
+	client.Client
+
+	SecretCachingClient client.Client
+	ClusterCache        clustercache.ClusterCache
+
+	managementCluster rke2.ManagementCluster
+	recorder          record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=rke2etcdsnapshots,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=rke2etcdsnapshots/status,verbs=get;update;patch
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RKE2EtcdSnapshotReconciler) SetupWithManager(_ context.Context, mgr ctrl.Manager) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&controlplanev1.RKE2EtcdSnapshot{}).
+		Complete(r)
+	if err != nil {
+		return errors.Wrap(err, "failed setting up with a controller manager")
+	}
+
+	r.recorder = mgr.GetEventRecorderFor("rke2-etcd-snapshot-controller")
+
+	if r.managementCluster == nil {
+		r.managementCluster = &rke2.Management{
+			Client:              r.Client,
+			SecretCachingClient: r.SecretCachingClient,
+			ClusterCache:        r.ClusterCache,
+		}
+	}
+
+	return nil
+}
+
+// Reconcile takes an on-demand etcd snapshot on a control plane node of a RKE2ControlPlane.
+func (r *RKE2EtcdSnapshotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, reterr error) {
+	logger := log.FromContext(ctx)
+
+	snapshot := &controlplanev1.RKE2EtcdSnapshot{}
+	if err := r.Get(ctx, req.NamespacedName, snapshot); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !snapshot.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	logger = logger.WithValues("controlPlane", snapshot.Spec.ControlPlaneName)
+	ctx = log.IntoContext(ctx, logger)
+
+	patchHelper, err := patch.NewHelper(snapshot, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	defer func() {
+		conditions.SetSummary(snapshot, conditions.WithConditions(controlplanev1.EtcdSnapshotCreatedCondition))
+
+		if err := patchHelper.Patch(ctx, snapshot); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+	}()
+
+	return r.reconcileNormal(ctx, snapshot)
+}
+
+func (r *RKE2EtcdSnapshotReconciler) reconcileNormal(ctx context.Context, snapshot *controlplanev1.RKE2EtcdSnapshot) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	switch snapshot.Status.Phase {
+	case controlplanev1.RKE2EtcdSnapshotPhaseCompleted, controlplanev1.RKE2EtcdSnapshotPhaseFailed:
+		return ctrl.Result{}, nil
+	case "":
+		snapshot.Status.Phase = controlplanev1.RKE2EtcdSnapshotPhasePending
+	}
+
+	rcp := &controlplanev1.RKE2ControlPlane{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: snapshot.Namespace, Name: snapshot.Spec.ControlPlaneName}, rcp); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("RKE2ControlPlane not found")
+			conditions.MarkFalse(snapshot, controlplanev1.EtcdSnapshotCreatedCondition,
+				controlplanev1.WaitingForControlPlaneReason, clusterv1.ConditionSeverityInfo,
+				"RKE2ControlPlane %s not found", snapshot.Spec.ControlPlaneName)
+
+			return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	cluster, err := util.GetOwnerCluster(ctx, r.Client, rcp.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if cluster == nil {
+		logger.Info("Cluster Controller has not yet set OwnerRef on the RKE2ControlPlane")
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	_, restoreInProgress := rcp.Annotations[controlplanev1.EtcdRestoreInProgressAnnotation]
+	if !rcp.Status.Initialized || restoreInProgress {
+		conditions.MarkFalse(snapshot, controlplanev1.EtcdSnapshotCreatedCondition,
+			controlplanev1.WaitingForControlPlaneReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for RKE2ControlPlane %s to be initialized and not restoring a snapshot", rcp.Name)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	machines, err := r.managementCluster.GetMachinesForCluster(ctx, util.ObjectKey(cluster), collections.ControlPlaneMachines(cluster.Name))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if snapshot.Status.Phase == controlplanev1.RKE2EtcdSnapshotPhasePending {
+		machine := hostCommandMachine(snapshot.Spec.MachineName, machines)
+		if machine == nil {
+			r.fail(snapshot, controlplanev1.MachineNotFoundReason, "No control plane machine with a node available to take the snapshot")
+
+			return ctrl.Result{}, nil
+		}
+
+		snapshot.Status.MachineName = machine.Name
+		snapshot.Status.Phase = controlplanev1.RKE2EtcdSnapshotPhaseRunning
+	}
+
+	machine, found := machines[snapshot.Status.MachineName]
+	if !found || machine.Status.NodeRef == nil {
+		r.fail(snapshot, controlplanev1.MachineNotFoundReason,
+			fmt.Sprintf("Machine %s not found or without a node", snapshot.Status.MachineName))
+
+		return ctrl.Result{}, nil
+	}
+
+	workloadCluster, err := r.managementCluster.GetWorkloadCluster(ctx, util.ObjectKey(cluster))
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get workload cluster")
+	}
+
+	command := rke2.NewEtcdSnapshotCommand(snapshot, machine.Status.NodeRef.Name)
+
+	pod, err := workloadCluster.GetHostCommandPod(ctx, command)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	started := conditions.GetReason(snapshot, controlplanev1.EtcdSnapshotCreatedCondition) == controlplanev1.EtcdSnapshotInProgressReason
+
+	switch {
+	case pod == nil && !started:
+		if err := workloadCluster.StartHostCommand(ctx, command); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		logger.Info("Started etcd snapshot", "machine", machine.Name, "node", machine.Status.NodeRef.Name)
+		conditions.MarkFalse(snapshot, controlplanev1.EtcdSnapshotCreatedCondition,
+			controlplanev1.EtcdSnapshotInProgressReason, clusterv1.ConditionSeverityInfo,
+			"Taking snapshot on machine %s", machine.Name)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	case pod == nil:
+		r.fail(snapshot, controlplanev1.EtcdSnapshotFailedReason, fmt.Sprintf("Snapshot pod %s not found", command.Name))
+
+		return ctrl.Result{}, nil
+	case pod.Status.Phase == corev1.PodFailed:
+		if err := workloadCluster.DeleteHostCommandPod(ctx, command); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		r.fail(snapshot, controlplanev1.EtcdSnapshotFailedReason,
+			fmt.Sprintf("Snapshot failed on machine %s, check the logs of the rke2-server service on the node", machine.Name))
+
+		return ctrl.Result{}, nil
+	case pod.Status.Phase != corev1.PodSucceeded:
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	if err := workloadCluster.DeleteHostCommandPod(ctx, command); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	snapshots, err := workloadCluster.EtcdSnapshots(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	snapshot.Status.Location = etcdSnapshotLocation(snapshots, cmp.Or(snapshot.Spec.SnapshotName, snapshot.Name), machine.Status.NodeRef.Name)
+
+	logger.Info("Etcd snapshot taken", "machine", machine.Name, "location", snapshot.Status.Location)
+	r.recorder.Eventf(snapshot, corev1.EventTypeNormal, "EtcdSnapshotCreated", "Took etcd snapshot on machine %s", machine.Name)
+
+	conditions.MarkTrue(snapshot, controlplanev1.EtcdSnapshotCreatedCondition)
+	snapshot.Status.Phase = controlplanev1.RKE2EtcdSnapshotPhaseCompleted
+
+	return ctrl.Result{}, nil
+}
+
+// etcdSnapshotLocation returns the location of the newest snapshot of the given base name taken on the given node,
+// preferring the copy uploaded to S3, or an empty string if RKE2 doesn't report it.
+func etcdSnapshotLocation(snapshots []controlplanev1.EtcdSnapshotInfo, baseName, nodeName string) string {
+	var newest *controlplanev1.EtcdSnapshotInfo
+
+	// RKE2 appends the node name and a timestamp to the base name of the snapshots.
+	prefix := baseName + "-" + nodeName + "-"
+
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+		if !strings.HasPrefix(snapshot.Name, prefix) {
+			continue
+		}
+
+		if newest == nil || compareEtcdSnapshots(snapshot, newest) > 0 {
+			newest = snapshot
+		}
+	}
+
+	if newest == nil {
+		return ""
+	}
+
+	return newest.Location
+}
+
+// compareEtcdSnapshots orders the snapshots by creation time, then by name, which ends with the timestamp of the
+// snapshot, and then puts the copies uploaded to S3 after the local ones.
+func compareEtcdSnapshots(a, b *controlplanev1.EtcdSnapshotInfo) int {
+	switch {
+	case a.CreationTime == nil && b.CreationTime != nil:
+		return -1
+	case a.CreationTime != nil && b.CreationTime == nil:
+		return 1
+	case a.CreationTime != nil && !a.CreationTime.Equal(b.CreationTime):
+		return a.CreationTime.Compare(b.CreationTime.Time)
+	}
+
+	if a.Name != b.Name {
+		return cmp.Compare(a.Name, b.Name)
+	}
+
+	isS3 := func(snapshot *controlplanev1.EtcdSnapshotInfo) bool {
+		return snapshot.Source == controlplanev1.EtcdSnapshotSourceS3
+	}
+
+	switch {
+	case isS3(a) && !isS3(b):
+		return 1
+	case !isS3(a) && isS3(b):
+		return -1
+	}
+
+	return 0
+}
+
+func (r *RKE2EtcdSnapshotReconciler) fail(snapshot *controlplanev1.RKE2EtcdSnapshot, reason, message string) {
+	conditions.MarkFalse(snapshot, controlplanev1.EtcdSnapshotCreatedCondition, reason, clusterv1.ConditionSeverityError, "%s", message)
+	snapshot.Status.Phase = controlplanev1.RKE2EtcdSnapshotPhaseFailed
+
+	r.recorder.Event(snapshot, corev1.EventTypeWarning, reason, message)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+func TestRKE2EtcdSnapshotReconcile(t *testing.T) {
+	tests := []struct {
+		name        string
+		podPhase    corev1.PodPhase
+		expectPhase controlplanev1.RKE2EtcdSnapshotPhase
+	}{
+		{
+			name:        "snapshot succeeded",
+			podPhase:    corev1.PodSucceeded,
+			expectPhase: controlplanev1.RKE2EtcdSnapshotPhaseCompleted,
+		},
+		{
+			name:        "snapshot failed",
+			podPhase:    corev1.PodFailed,
+			expectPhase: controlplanev1.RKE2EtcdSnapshotPhaseFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			cluster, rcp, machines := newFakeControlPlane()
+			snapshot := &controlplanev1.RKE2EtcdSnapshot{
+				ObjectMeta: metav1.ObjectMeta{Name: "snapshot", Namespace: metav1.NamespaceDefault},
+				Spec:       controlplanev1.RKE2EtcdSnapshotSpec{ControlPlaneName: rcp.Name, MachineName: "m1"},
+			}
+
+			fakeClient := newFakeClient(append([]client.Object{cluster, rcp, snapshot}, machinesToObjects(machines)...)...)
+			workload := &fakeWorkloadCluster{
+				EtcdSnapshotsResult: []controlplanev1.EtcdSnapshotInfo{
+					{Name: "snapshot-m1-1700000000", Location: "file:///var/lib/rancher/rke2/server/db/snapshots/snapshot-m1-1700000000"},
+					{Name: "snapshot-m1-1700000000", Location: "s3://snapshots/snapshot-m1-1700000000", Source: controlplanev1.EtcdSnapshotSourceS3},
+					{Name: "other-m1-1600000000", Location: "s3://snapshots/other-m1-1600000000", Source: controlplanev1.EtcdSnapshotSourceS3},
+				},
+			}
+			r := &RKE2EtcdSnapshotReconciler{
+				Client:            fakeClient,
+				managementCluster: &fakeManagementCluster{Machines: machines, Workload: workload},
+				recorder:          record.NewFakeRecorder(32),
+			}
+
+			reconcile := func() {
+				_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(snapshot)})
+				g.Expect(err).ToNot(HaveOccurred())
+				g.Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(snapshot), snapshot)).To(Succeed())
+			}
+
+			reconcile()
+			g.Expect(snapshot.Status.Phase).To(Equal(controlplanev1.RKE2EtcdSnapshotPhaseRunning))
+			g.Expect(snapshot.Status.MachineName).To(Equal("m1"))
+			g.Expect(workload.StartedCommands).To(HaveLen(1))
+			g.Expect(workload.StartedCommands[0].NodeName).To(Equal("m1"))
+			g.Expect(conditions.GetReason(snapshot, controlplanev1.EtcdSnapshotCreatedCondition)).
+				To(Equal(controlplanev1.EtcdSnapshotInProgressReason))
+
+			workload.HostCommandPod = &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning}}
+			reconcile()
+			g.Expect(snapshot.Status.Phase).To(Equal(controlplanev1.RKE2EtcdSnapshotPhaseRunning))
+
+			workload.HostCommandPod = &corev1.Pod{Status: corev1.PodStatus{Phase: tt.podPhase}}
+			reconcile()
+			g.Expect(snapshot.Status.Phase).To(Equal(tt.expectPhase))
+			g.Expect(workload.HostCommandPod).To(BeNil())
+			g.Expect(workload.StartedCommands).To(HaveLen(1))
+			g.Expect(conditions.IsTrue(snapshot, clusterv1.ReadyCondition)).
+				To(Equal(tt.expectPhase == controlplanev1.RKE2EtcdSnapshotPhaseCompleted))
+
+			if tt.expectPhase == controlplanev1.RKE2EtcdSnapshotPhaseCompleted {
+				g.Expect(snapshot.Status.Location).To(Equal("s3://snapshots/snapshot-m1-1700000000"))
+			}
+		})
+	}
+}
+
+func TestEtcdSnapshotLocation(t *testing.T) {
+	older := &metav1.Time{Time: time.Unix(1700000000, 0)}
+	newer := &metav1.Time{Time: time.Unix(1800000000, 0)}
+
+	tests := []struct {
+		name      string
+		snapshots []controlplanev1.EtcdSnapshotInfo
+		want      string
+	}{
+		{
+			name: "no snapshot",
+			snapshots: []controlplanev1.EtcdSnapshotInfo{
+				{Name: "other-m1-1700000000", Location: "file:///snapshots/other-m1-1700000000"},
+				{Name: "snapshot-m2-1700000000", Location: "file:///snapshots/snapshot-m2-1700000000"},
+			},
+		},
+		{
+			name: "newest snapshot before an older snapshot uploaded to S3",
+			snapshots: []controlplanev1.EtcdSnapshotInfo{
+				{Name: "snapshot-m1-1800000000", Location: "file:///snapshots/snapshot-m1-1800000000", CreationTime: newer},
+				{
+					Name:         "snapshot-m1-1700000000",
+					Location:     "s3://snapshots/snapshot-m1-1700000000",
+					Source:       controlplanev1.EtcdSnapshotSourceS3,
+					CreationTime: older,
+				},
+			},
+			want: "file:///snapshots/snapshot-m1-1800000000",
+		},
+		{
+			name: "newest snapshot uploaded to S3",
+			snapshots: []controlplanev1.EtcdSnapshotInfo{
+				{Name: "snapshot-m1-1700000000", Location: "file:///snapshots/snapshot-m1-1700000000", CreationTime: older},
+				{Name: "snapshot-m1-1800000000", Location: "file:///snapshots/snapshot-m1-1800000000", CreationTime: newer},
+				{
+					Name:         "snapshot-m1-1800000000",
+					Location:     "s3://snapshots/snapshot-m1-1800000000",
+					Source:       controlplanev1.EtcdSnapshotSourceS3,
+					CreationTime: newer,
+				},
+			},
+			want: "s3://snapshots/snapshot-m1-1800000000",
+		},
+		{
+			name: "snapshots without creation time",
+			snapshots: []controlplanev1.EtcdSnapshotInfo{
+				{Name: "snapshot-m1-1800000000", Location: "file:///snapshots/snapshot-m1-1800000000"},
+				{Name: "snapshot-m1-1700000000", Location: "file:///snapshots/snapshot-m1-1700000000"},
+			},
+			want: "file:///snapshots/snapshot-m1-1800000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(etcdSnapshotLocation(tt.snapshots, "snapshot", "m1")).To(Equal(tt.want))
+		})
+	}
+}
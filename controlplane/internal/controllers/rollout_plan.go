@@ -0,0 +1,241 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+// reconcileRolloutPlan reports the plan of the rollout of the given machines when the RolloutDryRunAnnotation is set,
+// and returns false until the plan is approved with the ApproveRolloutPlanAnnotation, in which case the rollout
+// must not proceed. The approval is bound to the ID of the plan: once approved, the plan keeps its ID while the
+// machines remaining to be rolled out were part of it, and any other plan needs to be approved again.
+func (r *RKE2ControlPlaneReconciler) reconcileRolloutPlan(
+	ctx context.Context,
+	controlPlane *rke2.ControlPlane,
+	needRollout collections.Machines,
+	needInPlaceUpgrade collections.Machines,
+) (bool, error) {
+	logger := controlPlane.Logger()
+	rcp := controlPlane.RCP
+
+	if _, found := rcp.Annotations[controlplanev1.RolloutDryRunAnnotation]; !found || len(needRollout)+len(needInPlaceUpgrade) == 0 {
+		rcp.Status.RolloutPlan = nil
+
+		return true, nil
+	}
+
+	plan, err := computeRolloutPlan(ctx, controlPlane, needRollout, needInPlaceUpgrade)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to compute rollout plan")
+	}
+
+	plan.ID = rolloutPlanID(plan)
+
+	// The steps of an approved plan are removed as the rollout progresses, which does not require a new approval.
+	if previous := rcp.Status.RolloutPlan; previous != nil && previous.Approved && rolloutPlanIncludes(previous, plan) {
+		plan.ID = previous.ID
+	}
+
+	plan.Approved = rcp.Annotations[controlplanev1.ApproveRolloutPlanAnnotation] == plan.ID
+	rcp.Status.RolloutPlan = plan
+
+	if !plan.Approved {
+		logger.Info("Waiting for the rollout plan to be approved", "id", plan.ID, "steps", len(plan.Steps))
+		conditions.MarkFalse(rcp,
+			controlplanev1.MachinesSpecUpToDateCondition,
+			controlplanev1.RolloutPlanPendingApprovalReason,
+			clusterv1.ConditionSeverityInfo,
+			"Rollout of %d replicas waiting for approval, set the %s annotation to %s to approve the plan in status.rolloutPlan",
+			len(plan.Steps),
+			controlplanev1.ApproveRolloutPlanAnnotation,
+			plan.ID)
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// rolloutPlanID returns the ID of the given plan, computed from the machines it rolls out, the action taken on each
+// of them and the reasons of their rollout. The failure domains are left out, as they are only an estimate.
+func rolloutPlanID(plan *controlplanev1.RolloutPlan) string {
+	keys := make([]string, 0, len(plan.Steps))
+	for _, step := range plan.Steps {
+		keys = append(keys, rolloutPlanStepKey(step))
+	}
+
+	sort.Strings(keys)
+
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// rolloutPlanIncludes returns true if every step of the given plan is a step of the approved plan.
+func rolloutPlanIncludes(approved *controlplanev1.RolloutPlan, plan *controlplanev1.RolloutPlan) bool {
+	keys := make(map[string]bool, len(approved.Steps))
+	for _, step := range approved.Steps {
+		keys[rolloutPlanStepKey(step)] = true
+	}
+
+	for _, step := range plan.Steps {
+		if !keys[rolloutPlanStepKey(step)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rolloutPlanStepKey returns the key identifying a step of a rollout plan.
+func rolloutPlanStepKey(step controlplanev1.RolloutPlanStep) string {
+	reasons := append([]string{}, step.Reasons...)
+	sort.Strings(reasons)
+
+	return fmt.Sprintf("%s/%s/%s", step.Machine, step.Action, strings.Join(reasons, ","))
+}
+
+// computeRolloutPlan simulates the rollout of the given machines, without acting on them: the server pools are rolled
+// out one after the other, the machine to replace is selected like for a scale down, and its replacement is created in
+// the failure domain selected for a scale up. The machines upgraded in place are listed last, as the rollout of the
+// other machines takes precedence.
+func computeRolloutPlan(
+	ctx context.Context,
+	controlPlane *rke2.ControlPlane,
+	needRollout collections.Machines,
+	needInPlaceUpgrade collections.Machines,
+) (*controlplanev1.RolloutPlan, error) {
+	rcp := controlPlane.RCP
+	plan := &controlplanev1.RolloutPlan{Generation: rcp.Generation}
+
+	// The replacements are created before the machines they replace are deleted, unless the rollout strategy
+	// deletes the machines first.
+	scaleDownFirst := rcp.Spec.RolloutStrategy != nil && rcp.Spec.RolloutStrategy.Type == controlplanev1.ScaleDownFirstStrategyType
+	if rcp.Spec.RolloutStrategy != nil && rcp.Spec.RolloutStrategy.RollingUpdate != nil &&
+		rcp.Spec.RolloutStrategy.RollingUpdate.MaxSurge != nil {
+		scaleDownFirst = scaleDownFirst || rcp.Spec.RolloutStrategy.RollingUpdate.MaxSurge.IntValue() == 0
+	}
+
+	// The simulated control plane shares everything but its machines with the actual one.
+	simulated := *controlPlane
+	simulated.Machines = controlPlane.Machines.Filter(collections.Not(collections.HasDeletionTimestamp))
+	outdated := needRollout.Filter(collections.Not(collections.HasDeletionTimestamp))
+
+	for replacements := 0; len(outdated) > 0; replacements++ {
+		role := nextRolloutRole(&simulated, outdated)
+		step := controlplanev1.RolloutPlanStep{Action: controlplanev1.RolloutPlanActionReplace, Role: role}
+
+		if !scaleDownFirst {
+			step.ReplacementFailureDomain = simulateScaleUp(ctx, &simulated, role, replacements)
+		}
+
+		machine, err := selectMachineForScaleDown(ctx, &simulated, simulatedServerPool(&simulated, role), outdated)
+		if err != nil {
+			return nil, err
+		}
+
+		step.Machine = machine.Name
+		step.FailureDomain = ptr.Deref(machine.Spec.FailureDomain, "")
+		step.Reasons = controlPlane.MachineRolloutReasons(machine)
+
+		if len(step.Reasons) == 0 {
+			step.Reasons = []string{fmt.Sprintf("%s annotation set", clusterv1.DeleteMachineAnnotation)}
+		}
+
+		delete(simulated.Machines, machine.Name)
+		delete(outdated, machine.Name)
+
+		if scaleDownFirst {
+			step.ReplacementFailureDomain = simulateScaleUp(ctx, &simulated, role, replacements)
+		}
+
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	for _, machine := range needInPlaceUpgrade.SortedByCreationTimestamp() {
+		plan.Steps = append(plan.Steps, controlplanev1.RolloutPlanStep{
+			Machine:       machine.Name,
+			Action:        controlplanev1.RolloutPlanActionUpgradeInPlace,
+			Role:          rke2.ServerRoleOf(machine),
+			FailureDomain: ptr.Deref(machine.Spec.FailureDomain, ""),
+			Reasons:       controlPlane.MachineRolloutReasons(machine),
+		})
+	}
+
+	return plan, nil
+}
+
+// nextRolloutRole returns the role of the server pool rolled out first, among the pools of the outdated machines.
+func nextRolloutRole(controlPlane *rke2.ControlPlane, outdated collections.Machines) controlplanev1.ServerRole {
+	for _, pool := range controlPlane.ServerPools() {
+		if outdated.Filter(rke2.HasServerRole(pool.Role)).Len() > 0 {
+			return pool.Role
+		}
+	}
+
+	return ""
+}
+
+// simulatedServerPool returns the server pool running the given role in the simulated control plane.
+func simulatedServerPool(controlPlane *rke2.ControlPlane, role controlplanev1.ServerRole) *rke2.ServerPool {
+	for _, pool := range controlPlane.ServerPools() {
+		if pool.Role == role {
+			return pool
+		}
+	}
+
+	return &rke2.ServerPool{Role: role, Machines: collections.New()}
+}
+
+// simulateScaleUp adds an up-to-date machine to the given server pool of the simulated control plane, and returns
+// the failure domain it is created in.
+func simulateScaleUp(ctx context.Context, controlPlane *rke2.ControlPlane, role controlplanev1.ServerRole, index int) string {
+	failureDomain := controlPlane.NextFailureDomainForScaleUp(ctx, simulatedServerPool(controlPlane, role))
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              fmt.Sprintf("%s-replacement-%d", controlPlane.RCP.Name, index),
+			Labels:            serverPoolLabels(controlPlane.Cluster.Name, role),
+			CreationTimestamp: metav1.NewTime(time.Now().Add(time.Duration(index) * time.Second)),
+		},
+		Spec: clusterv1.MachineSpec{
+			Version:       controlPlane.Version(),
+			FailureDomain: failureDomain,
+		},
+	}
+	controlPlane.Machines.Insert(machine)
+
+	return ptr.Deref(failureDomain, "")
+}
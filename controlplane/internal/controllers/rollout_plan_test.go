@@ -0,0 +1,142 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestReconcileRolloutPlan(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cluster, rcp, machines := newFakeControlPlane()
+	cluster.Status.FailureDomains = clusterv1.FailureDomains{
+		"a": {ControlPlane: true},
+		"b": {ControlPlane: true},
+		"c": {ControlPlane: true},
+	}
+	rcp.Generation = 2
+	rcp.Spec.Version = "v1.31.1+rke2r1"
+
+	for name, failureDomain := range map[string]string{"m0": "a", "m1": "b", "m2": "c"} {
+		machines[name].Spec.FailureDomain = ptr.To(failureDomain)
+		machines[name].Spec.Version = ptr.To("v1.30.2+rke2r1")
+	}
+
+	r := &RKE2ControlPlaneReconciler{recorder: record.NewFakeRecorder(32)}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	// Without the dry-run annotation, the rollout proceeds without plan.
+	approved, err := r.reconcileRolloutPlan(ctx, controlPlane, machines, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(approved).To(BeTrue())
+	g.Expect(rcp.Status.RolloutPlan).To(BeNil())
+
+	rcp.Annotations = map[string]string{controlplanev1.RolloutDryRunAnnotation: ""}
+
+	approved, err = r.reconcileRolloutPlan(ctx, controlPlane, machines, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(approved).To(BeFalse())
+	g.Expect(conditions.GetReason(rcp, controlplanev1.MachinesSpecUpToDateCondition)).
+		To(Equal(controlplanev1.RolloutPlanPendingApprovalReason))
+
+	plan := rcp.Status.RolloutPlan
+	g.Expect(plan).ToNot(BeNil())
+	g.Expect(plan.Generation).To(Equal(int64(2)))
+	g.Expect(plan.Approved).To(BeFalse())
+	g.Expect(plan.Steps).To(HaveLen(3))
+
+	// Each machine is replaced by a machine created in its failure domain, and the actual machines are left untouched.
+	replaced := []string{}
+	failureDomains := []string{}
+
+	for _, step := range plan.Steps {
+		g.Expect(step.Action).To(Equal(controlplanev1.RolloutPlanActionReplace))
+		g.Expect(step.ReplacementFailureDomain).To(Equal(step.FailureDomain))
+		g.Expect(step.Reasons).To(ConsistOf("version v1.30.2+rke2r1 -> v1.31.1+rke2r1"))
+
+		replaced = append(replaced, step.Machine)
+		failureDomains = append(failureDomains, step.FailureDomain)
+	}
+
+	g.Expect(replaced).To(ConsistOf("m0", "m1", "m2"))
+	g.Expect(failureDomains).To(ConsistOf("a", "b", "c"))
+	g.Expect(controlPlane.Machines.Names()).To(ConsistOf("m0", "m1", "m2"))
+
+	// The plan must be approved with its ID.
+	g.Expect(plan.ID).ToNot(BeEmpty())
+
+	rcp.Annotations[controlplanev1.ApproveRolloutPlanAnnotation] = "2"
+
+	approved, err = r.reconcileRolloutPlan(ctx, controlPlane, machines, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(approved).To(BeFalse())
+
+	planID := plan.ID
+	rcp.Annotations[controlplanev1.ApproveRolloutPlanAnnotation] = planID
+
+	approved, err = r.reconcileRolloutPlan(ctx, controlPlane, machines, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(approved).To(BeTrue())
+	g.Expect(rcp.Status.RolloutPlan.Approved).To(BeTrue())
+	g.Expect(rcp.Status.RolloutPlan.ID).To(Equal(planID))
+
+	// The approval holds while the machines of the plan are rolled out.
+	remaining := machines.Filter(func(machine *clusterv1.Machine) bool { return machine.Name != "m0" })
+
+	approved, err = r.reconcileRolloutPlan(ctx, controlPlane, remaining, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(approved).To(BeTrue())
+	g.Expect(rcp.Status.RolloutPlan.Steps).To(HaveLen(2))
+	g.Expect(rcp.Status.RolloutPlan.ID).To(Equal(planID))
+
+	// A plan taking other actions requires a new approval.
+	rcp.Spec.RolloutStrategy = &controlplanev1.RolloutStrategy{Type: controlplanev1.InPlaceUpgradeStrategyType}
+
+	approved, err = r.reconcileRolloutPlan(ctx, controlPlane, nil, machines)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(approved).To(BeFalse())
+	g.Expect(rcp.Status.RolloutPlan.ID).ToNot(Equal(planID))
+
+	// Machines upgraded in place are listed after the machines replaced.
+	rcp.Annotations[controlplanev1.ApproveRolloutPlanAnnotation] = rcp.Status.RolloutPlan.ID
+
+	approved, err = r.reconcileRolloutPlan(ctx, controlPlane, nil, machines)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(approved).To(BeTrue())
+	g.Expect(rcp.Status.RolloutPlan.Steps).To(HaveLen(3))
+	g.Expect(rcp.Status.RolloutPlan.Steps[0].Machine).To(Equal("m0"))
+	g.Expect(rcp.Status.RolloutPlan.Steps[0].Action).To(Equal(controlplanev1.RolloutPlanActionUpgradeInPlace))
+
+	// Once the rollout is completed, the plan is cleared.
+	approved, err = r.reconcileRolloutPlan(ctx, controlPlane, nil, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(approved).To(BeTrue())
+	g.Expect(rcp.Status.RolloutPlan).To(BeNil())
+}
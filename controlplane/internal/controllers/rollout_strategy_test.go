@@ -0,0 +1,161 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestUpgradeControlPlaneScaleDownFirst(t *testing.T) {
+	tests := []struct {
+		name            string
+		replicas        int32
+		expectBlocked   bool
+		expectDeletions int
+	}{
+		{
+			name:          "refuses to roll out a single replica",
+			replicas:      1,
+			expectBlocked: true,
+		},
+		{
+			name:          "refuses to roll out two replicas",
+			replicas:      2,
+			expectBlocked: true,
+		},
+		{
+			name:            "deletes an outdated machine before creating its replacement",
+			replicas:        3,
+			expectDeletions: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: metav1.NamespaceDefault}}
+			rcp := &controlplanev1.RKE2ControlPlane{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: metav1.NamespaceDefault},
+				Spec: controlplanev1.RKE2ControlPlaneSpec{
+					Replicas:        ptr.To(tt.replicas),
+					RolloutStrategy: &controlplanev1.RolloutStrategy{Type: controlplanev1.ScaleDownFirstStrategyType},
+				},
+				Status: controlplanev1.RKE2ControlPlaneStatus{Initialized: true},
+			}
+
+			machines := collections.New()
+			for i := range tt.replicas {
+				machines.Insert(healthyMachine(fmt.Sprintf("m%d", i)))
+			}
+
+			fakeClient := fake.NewClientBuilder().Build()
+			for _, m := range machines.UnsortedList() {
+				g.Expect(fakeClient.Create(ctx, m)).To(Succeed())
+			}
+
+			workloadCluster := &fakeWorkloadCluster{}
+			r := &RKE2ControlPlaneReconciler{
+				Client:            fakeClient,
+				recorder:          record.NewFakeRecorder(32),
+				managementCluster: &fakeManagementCluster{Workload: workloadCluster},
+				workloadCluster:   workloadCluster,
+			}
+			controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+			_, err := r.upgradeControlPlane(ctx, cluster, rcp, controlPlane, machines)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			g.Expect(conditions.GetReason(rcp, controlplanev1.MachinesSpecUpToDateCondition) == controlplanev1.RolloutBlockedReason).
+				To(Equal(tt.expectBlocked))
+
+			remaining := &clusterv1.MachineList{}
+			g.Expect(fakeClient.List(ctx, remaining)).To(Succeed())
+			g.Expect(remaining.Items).To(HaveLen(int(tt.replicas) - tt.expectDeletions))
+			g.Expect(workloadCluster.ForwardedLeaders).To(HaveLen(tt.expectDeletions))
+		})
+	}
+}
+
+func TestUpgradeControlPlaneServerPools(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cluster, rcp, machines := newFakeControlPlane()
+	rcp.Spec.RolloutStrategy = &controlplanev1.RolloutStrategy{Type: controlplanev1.ScaleDownFirstStrategyType}
+	rcp.Spec.ServerPools = []controlplanev1.ServerPool{
+		{Role: controlplanev1.ServerRoleEtcd, Replicas: ptr.To[int32](1)},
+		{Role: controlplanev1.ServerRoleControlPlane, Replicas: ptr.To[int32](1)},
+	}
+
+	for _, role := range []controlplanev1.ServerRole{controlplanev1.ServerRoleEtcd, controlplanev1.ServerRoleControlPlane} {
+		machine := healthyMachine(string(role))
+		machine.Labels = map[string]string{controlplanev1.ServerRoleLabel: string(role)}
+		machines.Insert(machine)
+	}
+
+	fakeClient := newFakeClient(machinesToObjects(machines)...)
+	workloadCluster := &fakeWorkloadCluster{}
+	r := &RKE2ControlPlaneReconciler{
+		Client:            fakeClient,
+		recorder:          record.NewFakeRecorder(32),
+		managementCluster: &fakeManagementCluster{Workload: workloadCluster},
+		workloadCluster:   workloadCluster,
+	}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	// The etcd server pool is rolled out before the control-plane one, and etcd quorum is counted across the pools.
+	needRollout := collections.FromMachines(machines["etcd"], machines["control-plane"])
+
+	_, err := r.upgradeControlPlane(ctx, cluster, rcp, controlPlane, needRollout)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(conditions.Has(rcp, controlplanev1.MachinesSpecUpToDateCondition)).To(BeFalse())
+
+	remaining := &clusterv1.MachineList{}
+	g.Expect(fakeClient.List(ctx, remaining)).To(Succeed())
+	g.Expect(remaining.Items).To(HaveLen(4))
+	g.Expect(remaining.Items).ToNot(ContainElement(HaveField("Name", "etcd")))
+	g.Expect(workloadCluster.ForwardedLeaders).To(HaveLen(1))
+}
+
+func healthyMachine(name string) *clusterv1.Machine {
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+		Status:     clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: name}},
+	}
+	conditions.MarkTrue(machine, controlplanev1.MachineAgentHealthyCondition)
+	conditions.MarkTrue(machine, controlplanev1.MachineEtcdMemberHealthyCondition)
+
+	return machine
+}
@@ -70,10 +70,12 @@ func (r *RKE2ControlPlaneReconciler) initializeControlPlane(
 		)
 	}
 
+	// The cluster is initialized by a machine running all the server roles.
+	pool := controlPlane.ServerPools()[0]
 	bootstrapSpec := controlPlane.InitialControlPlaneConfig()
-	fd := controlPlane.NextFailureDomainForScaleUp(ctx)
+	fd := controlPlane.NextFailureDomainForScaleUp(ctx, pool)
 
-	if err := r.cloneConfigsAndGenerateMachine(ctx, cluster, rcp, bootstrapSpec, fd); err != nil {
+	if err := r.cloneConfigsAndGenerateMachine(ctx, cluster, rcp, pool, bootstrapSpec, fd); err != nil {
 		logger.Error(err, "Failed to create initial control plane Machine")
 		r.recorder.Eventf(
 			rcp,
@@ -96,6 +98,7 @@ func (r *RKE2ControlPlaneReconciler) scaleUpControlPlane(
 	cluster *clusterv1.Cluster,
 	rcp *controlplanev1.RKE2ControlPlane,
 	controlPlane *rke2.ControlPlane,
+	pool *rke2.ServerPool,
 ) (ctrl.Result, error) {
 	logger := controlPlane.Logger()
 
@@ -106,9 +109,9 @@ func (r *RKE2ControlPlaneReconciler) scaleUpControlPlane(
 
 	// Create the bootstrap configuration
 	bootstrapSpec := controlPlane.JoinControlPlaneConfig()
-	fd := controlPlane.NextFailureDomainForScaleUp(ctx)
+	fd := controlPlane.NextFailureDomainForScaleUp(ctx, pool)
 
-	if err := r.cloneConfigsAndGenerateMachine(ctx, cluster, rcp, bootstrapSpec, fd); err != nil {
+	if err := r.cloneConfigsAndGenerateMachine(ctx, cluster, rcp, pool, bootstrapSpec, fd); err != nil {
 		logger.Error(err, "Failed to create additional control plane Machine")
 		r.recorder.Eventf(
 			rcp,
@@ -132,12 +135,13 @@ func (r *RKE2ControlPlaneReconciler) scaleDownControlPlane(
 	cluster *clusterv1.Cluster,
 	rcp *controlplanev1.RKE2ControlPlane,
 	controlPlane *rke2.ControlPlane,
+	pool *rke2.ServerPool,
 	outdatedMachines collections.Machines,
 ) (ctrl.Result, error) {
 	logger := controlPlane.Logger()
 
 	// Pick the Machine that we should scale down.
-	machineToDelete, err := selectMachineForScaleDown(ctx, controlPlane, outdatedMachines)
+	machineToDelete, err := selectMachineForScaleDown(ctx, controlPlane, pool, outdatedMachines)
 	if err != nil {
 		return ctrl.Result{}, errors.Wrap(err, "failed to select machine for scale down")
 	}
@@ -155,8 +159,8 @@ func (r *RKE2ControlPlaneReconciler) scaleDownControlPlane(
 	}
 
 	// If etcd leadership is on machine that is about to be deleted, move it to the newest member available.
-	if _, found := controlPlane.RCP.Annotations[controlplanev1.LegacyRKE2ControlPlane]; !found {
-		etcdLeaderCandidate := controlPlane.Machines.Newest()
+	if _, found := controlPlane.RCP.Annotations[controlplanev1.LegacyRKE2ControlPlane]; !found && rke2.RunsEtcd()(machineToDelete) {
+		etcdLeaderCandidate := controlPlane.Machines.Filter(rke2.RunsEtcd()).Newest()
 		if err := r.workloadCluster.ForwardEtcdLeadership(ctx, machineToDelete, etcdLeaderCandidate); err != nil {
 			logger.Error(err, "Failed to move leadership to candidate machine", "candidate", etcdLeaderCandidate.Name)
 
@@ -175,6 +179,14 @@ func (r *RKE2ControlPlaneReconciler) scaleDownControlPlane(
 		return ctrl.Result{}, err
 	}
 
+	if _, found := outdatedMachines[machineToDelete.Name]; found {
+		reasons := controlPlane.MachineRolloutReasons(machineToDelete)
+
+		logger.Info("Rolled out control plane machine", "reasons", reasons)
+		r.recorder.Eventf(rcp, corev1.EventTypeNormal, "RolledOutMachine",
+			"Rolled out control plane Machine %s: %s", machineToDelete.Name, strings.Join(reasons, ", "))
+	}
+
 	// Requeue the control plane, in case there are additional operations to perform
 	return ctrl.Result{Requeue: true}, nil
 }
@@ -251,6 +263,11 @@ loopmachines:
 		}
 
 		for _, condition := range allMachineHealthConditions {
+			// Machines of the control-plane server pool do not host an etcd member.
+			if condition == controlplanev1.MachineEtcdMemberHealthyCondition && !rke2.RunsEtcd()(machine) {
+				continue
+			}
+
 			if err := preflightCheckCondition("machine", machine, condition); err != nil {
 				machineErrors = append(machineErrors, err)
 			}
@@ -289,9 +306,11 @@ func preflightCheckCondition(kind string, obj conditions.Getter, condition clust
 func selectMachineForScaleDown(
 	ctx context.Context,
 	controlPlane *rke2.ControlPlane,
+	pool *rke2.ServerPool,
 	outdatedMachines collections.Machines,
 ) (*clusterv1.Machine, error) {
-	machines := controlPlane.Machines
+	machines := pool.Machines
+	outdatedMachines = outdatedMachines.Filter(rke2.HasServerRole(pool.Role))
 
 	switch {
 	case controlPlane.MachineWithDeleteAnnotation(outdatedMachines).Len() > 0:
@@ -315,6 +334,7 @@ func (r *RKE2ControlPlaneReconciler) cloneConfigsAndGenerateMachine(
 	ctx context.Context,
 	cluster *clusterv1.Cluster,
 	rcp *controlplanev1.RKE2ControlPlane,
+	pool *rke2.ServerPool,
 	bootstrapSpec *bootstrapv1.RKE2ConfigSpec,
 	failureDomain *string,
 ) error {
@@ -329,16 +349,16 @@ func (r *RKE2ControlPlaneReconciler) cloneConfigsAndGenerateMachine(
 		UID:        rcp.UID,
 	}
 
-	rcp.Spec.MachineTemplate.InfrastructureRef.Namespace = cmp.Or(rcp.Spec.MachineTemplate.InfrastructureRef.Namespace, rcp.Namespace)
+	pool.InfrastructureRef.Namespace = cmp.Or(pool.InfrastructureRef.Namespace, rcp.Namespace)
 
 	// Clone the infrastructure template
 	infraRef, err := external.CreateFromTemplate(ctx, &external.CreateFromTemplateInput{
 		Client:      r.Client,
-		TemplateRef: &rcp.Spec.MachineTemplate.InfrastructureRef,
+		TemplateRef: pool.InfrastructureRef,
 		Namespace:   rcp.Namespace,
 		OwnerRef:    infraCloneOwner,
 		ClusterName: cluster.Name,
-		Labels:      rke2.ControlPlaneLabelsForCluster(cluster.Name),
+		Labels:      serverPoolLabels(cluster.Name, pool.Role),
 	})
 	if err != nil {
 		// Safe to return early here since no resources have been created yet.
@@ -346,14 +366,14 @@ func (r *RKE2ControlPlaneReconciler) cloneConfigsAndGenerateMachine(
 	}
 
 	// Clone the bootstrap configuration
-	bootstrapRef, err := r.generateRKE2Config(ctx, rcp, cluster, bootstrapSpec)
+	bootstrapRef, err := r.generateRKE2Config(ctx, rcp, cluster, pool.Role, bootstrapSpec)
 	if err != nil {
 		errs = append(errs, errors.Wrap(err, "failed to generate bootstrap config"))
 	}
 
 	// Only proceed to generating the Machine if we haven't encountered an error
 	if len(errs) == 0 {
-		if err := r.generateMachine(ctx, rcp, cluster, infraRef, bootstrapRef, failureDomain); err != nil {
+		if err := r.generateMachine(ctx, rcp, cluster, pool.Role, infraRef, bootstrapRef, failureDomain); err != nil {
 			errs = append(errs, errors.Wrap(err, "failed to create Machine"))
 		}
 	}
@@ -367,6 +387,10 @@ func (r *RKE2ControlPlaneReconciler) cloneConfigsAndGenerateMachine(
 		return kerrors.NewAggregate(errs)
 	}
 
+	// Remove the annotation tracking that a remediation is in progress.
+	// A remediation is completed when the replacement machine has been created above.
+	delete(rcp.Annotations, controlplanev1.RemediationInProgressAnnotation)
+
 	return nil
 }
 
@@ -394,6 +418,7 @@ func (r *RKE2ControlPlaneReconciler) generateRKE2Config(
 	ctx context.Context,
 	rcp *controlplanev1.RKE2ControlPlane,
 	cluster *clusterv1.Cluster,
+	role controlplanev1.ServerRole,
 	spec *bootstrapv1.RKE2ConfigSpec,
 ) (*corev1.ObjectReference, error) {
 	// Create an owner reference without a controller reference because the owning controller is the machine controller
@@ -408,7 +433,7 @@ func (r *RKE2ControlPlaneReconciler) generateRKE2Config(
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            names.SimpleNameGenerator.GenerateName(rcp.Name + "-"),
 			Namespace:       rcp.Namespace,
-			Labels:          rke2.ControlPlaneLabelsForCluster(cluster.Name),
+			Labels:          serverPoolLabels(cluster.Name, role),
 			OwnerReferences: []metav1.OwnerReference{owner},
 		},
 		Spec: *spec,
@@ -433,6 +458,7 @@ func (r *RKE2ControlPlaneReconciler) generateMachine(
 	ctx context.Context,
 	rcp *controlplanev1.RKE2ControlPlane,
 	cluster *clusterv1.Cluster,
+	role controlplanev1.ServerRole,
 	infraRef,
 	bootstrapRef *corev1.ObjectReference,
 	failureDomain *string,
@@ -447,7 +473,7 @@ func (r *RKE2ControlPlaneReconciler) generateMachine(
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      names.SimpleNameGenerator.GenerateName(rcp.Name + "-"),
 			Namespace: rcp.Namespace,
-			Labels:    rke2.ControlPlaneLabelsForCluster(cluster.Name),
+			Labels:    serverPoolLabels(cluster.Name, role),
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(rcp, controlplanev1.GroupVersion.WithKind("RKE2ControlPlane")),
 			},
@@ -473,10 +499,19 @@ func (r *RKE2ControlPlaneReconciler) generateMachine(
 		return errors.Wrap(err, "failed to marshal cluster configuration")
 	}
 
-	machine.SetAnnotations(map[string]string{
+	annotations := map[string]string{
 		controlplanev1.RKE2ServerConfigurationAnnotation: string(serverConfig),
 		controlplanev1.PreTerminateHookCleanupAnnotation: "",
-	})
+	}
+
+	// In case this machine is being created as a consequence of a remediation, then add an annotation
+	// tracking remediating data.
+	// NOTE: This is required in order to track remediation retries.
+	if remediationData, ok := rcp.Annotations[controlplanev1.RemediationInProgressAnnotation]; ok {
+		annotations[controlplanev1.RemediationForAnnotation] = remediationData
+	}
+
+	machine.SetAnnotations(annotations)
 
 	if err := r.Client.Create(ctx, machine); err != nil {
 		return errors.Wrap(err, "failed to create machine")
@@ -484,3 +519,14 @@ func (r *RKE2ControlPlaneReconciler) generateMachine(
 
 	return nil
 }
+
+// serverPoolLabels returns the labels of the objects created for a control plane machine of the server pool running
+// the given role.
+func serverPoolLabels(clusterName string, role controlplanev1.ServerRole) map[string]string {
+	labels := rke2.ControlPlaneLabelsForCluster(clusterName)
+	if role != "" {
+		labels[controlplanev1.ServerRoleLabel] = string(role)
+	}
+
+	return labels
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestScaleServerPools(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	etcdTemplate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{}}},
+	}}
+	etcdTemplate.SetAPIVersion("infrastructure.cluster.x-k8s.io/v1beta1")
+	etcdTemplate.SetKind("GenericInfrastructureMachineTemplate")
+	etcdTemplate.SetNamespace(corev1.NamespaceDefault)
+	etcdTemplate.SetName("etcd")
+
+	cluster, rcp, machines := newFakeControlPlane()
+	rcp.Spec.ServerPools = []controlplanev1.ServerPool{{
+		Role:     controlplanev1.ServerRoleEtcd,
+		Replicas: ptr.To[int32](1),
+		InfrastructureRef: corev1.ObjectReference{
+			APIVersion: etcdTemplate.GetAPIVersion(),
+			Kind:       etcdTemplate.GetKind(),
+			Name:       etcdTemplate.GetName(),
+		},
+	}}
+
+	controlPlaneMachine := healthyMachine("control-plane-0")
+	controlPlaneMachine.Labels = map[string]string{controlplanev1.ServerRoleLabel: string(controlplanev1.ServerRoleControlPlane)}
+	machines.Insert(controlPlaneMachine)
+
+	fakeClient := newFakeClient(append(machinesToObjects(machines), etcdTemplate)...)
+	workloadCluster := &fakeWorkloadCluster{}
+	r := &RKE2ControlPlaneReconciler{
+		Client:          fakeClient,
+		recorder:        record.NewFakeRecorder(32),
+		workloadCluster: workloadCluster,
+	}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	pools := controlPlane.ServerPools()
+	g.Expect(pools).To(HaveLen(3))
+
+	// Machines of a server pool are created from the template of the pool, and labeled with its role.
+	_, err := r.scaleUpControlPlane(ctx, cluster, rcp, controlPlane, pools[1])
+	g.Expect(err).ToNot(HaveOccurred())
+
+	etcdMachines := &clusterv1.MachineList{}
+	g.Expect(fakeClient.List(ctx, etcdMachines, client.MatchingLabels{controlplanev1.ServerRoleLabel: "etcd"})).To(Succeed())
+	g.Expect(etcdMachines.Items).To(HaveLen(1))
+	g.Expect(etcdMachines.Items[0].Spec.InfrastructureRef.Kind).To(Equal("GenericInfrastructureMachine"))
+
+	etcdConfigs := &bootstrapv1.RKE2ConfigList{}
+	g.Expect(fakeClient.List(ctx, etcdConfigs, client.MatchingLabels{controlplanev1.ServerRoleLabel: "etcd"})).To(Succeed())
+	g.Expect(etcdConfigs.Items).To(HaveLen(1))
+
+	infraMachine := &unstructured.Unstructured{}
+	infraMachine.SetAPIVersion(etcdTemplate.GetAPIVersion())
+	infraMachine.SetKind("GenericInfrastructureMachine")
+	g.Expect(fakeClient.Get(ctx, client.ObjectKey{
+		Namespace: corev1.NamespaceDefault,
+		Name:      etcdMachines.Items[0].Spec.InfrastructureRef.Name,
+	}, infraMachine)).To(Succeed())
+	g.Expect(infraMachine.GetAnnotations()).To(HaveKeyWithValue(clusterv1.TemplateClonedFromNameAnnotation, "etcd"))
+
+	// The machines of the removed control-plane server pool are scaled down, without moving etcd leadership.
+	g.Expect(pools[2].Role).To(Equal(controlplanev1.ServerRoleControlPlane))
+	g.Expect(pools[2].Replicas).To(BeZero())
+
+	_, err = r.scaleDownControlPlane(ctx, cluster, rcp, controlPlane, pools[2], nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(workloadCluster.ForwardedLeaders).To(BeEmpty())
+
+	remaining := &clusterv1.MachineList{}
+	g.Expect(fakeClient.List(ctx, remaining, client.MatchingLabels{controlplanev1.ServerRoleLabel: "control-plane"})).To(Succeed())
+	g.Expect(remaining.Items).To(BeEmpty())
+}
@@ -0,0 +1,428 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"slices"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+	bsutil "github.com/rancher/cluster-api-provider-rke2/pkg/util"
+)
+
+const (
+	// serverTokenLength is the length of the random part of the server tokens, like the one generated by the
+	// bootstrap provider.
+	serverTokenLength = 16
+
+	// nextServerTokenKey is the key of the token secret storing the new token while it is rotated.
+	nextServerTokenKey = "next"
+)
+
+// reconcileServerTokenRotation rotates the server token of the control plane, stored in the token secret of the
+// cluster, when requested with the RotateServerTokenAnnotation. The rotation follows the steps of `rke2 token rotate`:
+//  1. RotatingToken: the bootstrap data stored in the datastore of the control plane is encrypted with a new token on
+//     a control plane node, then the token secret is updated, so that the machines not yet provisioned are
+//     bootstrapped with it.
+//  2. UpdatingServers: the token is updated in the configuration of the control plane nodes, which are restarted one
+//     at a time to accept it.
+//  3. UpdatingAgents: the token is updated in the configuration of the worker nodes, used when they restart.
+//
+// The nodes of the machines created after the rotation already use the new token. The other operations of the
+// control plane, e.g. rollouts, wait for the rotation to be completed. The rotation is refused when the token is read
+// from a Secret of the user referenced with the TokenSecretRef.
+func (r *RKE2ControlPlaneReconciler) reconcileServerTokenRotation(
+	ctx context.Context,
+	controlPlane *rke2.ControlPlane,
+) (ctrl.Result, error) {
+	logger := controlPlane.Logger()
+	rcp := controlPlane.RCP
+	rotation := rcp.Status.ServerTokenRotation
+
+	if rotation == nil || rotation.Phase == controlplanev1.ServerTokenRotationPhaseCompleted {
+		id, found := rcp.Annotations[controlplanev1.RotateServerTokenAnnotation]
+		if !found || (rotation != nil && rotation.ID == id) || !rcp.Status.Initialized {
+			return ctrl.Result{}, nil
+		}
+
+		// The token of a Secret referenced with the TokenSecretRef is managed by the user, and is never overwritten.
+		if rcp.Spec.TokenSecretRef != nil {
+			conditions.MarkFalse(rcp, controlplanev1.ServerTokenRotatedCondition,
+				controlplanev1.ServerTokenRotationNotSupportedReason, clusterv1.ConditionSeverityWarning,
+				"The server token of the %s Secret referenced by spec.tokenSecretRef is managed by the user, and is not rotated",
+				rcp.Spec.TokenSecretRef.Name)
+
+			return ctrl.Result{}, nil
+		}
+
+		logger.Info("Starting rotation of the server token", "id", id)
+
+		rotation = &controlplanev1.ServerTokenRotationStatus{
+			ID:    id,
+			Phase: controlplanev1.ServerTokenRotationPhaseRotatingToken,
+		}
+		rcp.Status.ServerTokenRotation = rotation
+	}
+
+	tokenSecret := &corev1.Secret{}
+	tokenKey := bsutil.TokenSecretKey(rcp, controlPlane.Cluster.Name)
+
+	if err := r.Client.Get(ctx, tokenKey, tokenSecret); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to get token secret %s", tokenKey)
+	}
+
+	if _, err := bsutil.TokenFromSecret(tokenSecret); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch rotation.Phase {
+	case controlplanev1.ServerTokenRotationPhaseRotatingToken:
+		return r.rotateServerToken(ctx, controlPlane, tokenSecret)
+	case controlplanev1.ServerTokenRotationPhaseUpdatingServers:
+		return r.updateServersToken(ctx, controlPlane, string(tokenSecret.Data["value"]))
+	default:
+		return r.updateAgentsToken(ctx, controlPlane, tokenSecret)
+	}
+}
+
+// rotateServerToken encrypts the bootstrap data stored in the datastore of the control plane with a new token, then
+// stores the new token in the token secret.
+func (r *RKE2ControlPlaneReconciler) rotateServerToken(
+	ctx context.Context,
+	controlPlane *rke2.ControlPlane,
+	tokenSecret *corev1.Secret,
+) (ctrl.Result, error) {
+	logger := controlPlane.Logger()
+	rcp := controlPlane.RCP
+	rotation := rcp.Status.ServerTokenRotation
+
+	// The new token is stored before it is used, so that the same token is used when the rotation is retried.
+	if len(tokenSecret.Data[nextServerTokenKey]) == 0 {
+		newToken, err := bsutil.Random(serverTokenLength)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to generate server token")
+		}
+
+		tokenSecret.Data[nextServerTokenKey] = []byte(newToken)
+
+		if err := r.Client.Update(ctx, tokenSecret); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to store the new server token")
+		}
+	}
+
+	machine := hostCommandMachine("", controlPlane.Machines)
+	if machine == nil {
+		conditions.MarkFalse(rcp, controlplanev1.ServerTokenRotatedCondition,
+			controlplanev1.RotatingServerTokenReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for a control plane machine with a node to rotate the server token")
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	workloadCluster, err := r.GetWorkloadCluster(ctx, controlPlane)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get workload cluster")
+	}
+
+	command := rke2.NewServerTokenRotationCommand(rcp,
+		string(tokenSecret.Data["value"]), string(tokenSecret.Data[nextServerTokenKey]), machine.Status.NodeRef.Name)
+
+	pod, err := workloadCluster.GetHostCommandPod(ctx, command)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch {
+	case pod == nil:
+		if err := workloadCluster.StartHostCommand(ctx, command); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		logger.Info("Rotating the server token", "machine", machine.Name)
+		conditions.MarkFalse(rcp, controlplanev1.ServerTokenRotatedCondition,
+			controlplanev1.RotatingServerTokenReason, clusterv1.ConditionSeverityInfo,
+			"Rotating the server token on machine %s", machine.Name)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	case pod.Status.Phase == corev1.PodFailed:
+		if err := workloadCluster.DeleteHostCommandPod(ctx, command); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		logger.Info("Failed to rotate the server token, retrying", "machine", machine.Name)
+		conditions.MarkFalse(rcp, controlplanev1.ServerTokenRotatedCondition,
+			controlplanev1.ServerTokenRotationFailedReason, clusterv1.ConditionSeverityWarning,
+			"Failed to rotate the server token on machine %s, check the logs of the rke2-server service on the node",
+			machine.Name)
+
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	case pod.Status.Phase != corev1.PodSucceeded:
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	// The token secret is updated before the pod is deleted, so that the update is retried when it fails. The new
+	// token is kept until the rotation is completed, so that it is not generated again.
+	if !bytes.Equal(tokenSecret.Data["value"], tokenSecret.Data[nextServerTokenKey]) {
+		tokenSecret.Data["value"] = tokenSecret.Data[nextServerTokenKey]
+
+		if err := r.Client.Update(ctx, tokenSecret); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to update the server token")
+		}
+	}
+
+	if err := workloadCluster.DeleteHostCommandPod(ctx, command); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("Rotated the server token")
+
+	rotation.RotatedTime = ptr.To(metav1.Now())
+	rotation.Phase = controlplanev1.ServerTokenRotationPhaseUpdatingServers
+	rotation.UpdatedMachines = nil
+
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// updateServersToken updates the token in the configuration of the control plane nodes, oldest first, and restarts
+// them one at a time, once the previous one is healthy.
+func (r *RKE2ControlPlaneReconciler) updateServersToken(
+	ctx context.Context,
+	controlPlane *rke2.ControlPlane,
+	token string,
+) (ctrl.Result, error) {
+	rcp := controlPlane.RCP
+	rotation := rcp.Status.ServerTokenRotation
+
+	workloadCluster, err := r.GetWorkloadCluster(ctx, controlPlane)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get workload cluster")
+	}
+
+	for _, machine := range controlPlane.Machines.SortedByCreationTimestamp() {
+		if slices.Contains(rotation.UpdatedMachines, machine.Name) {
+			if !conditions.IsTrue(machine, controlplanev1.MachineAgentHealthyCondition) {
+				conditions.MarkFalse(rcp, controlplanev1.ServerTokenRotatedCondition,
+					controlplanev1.UpdatingNodesTokenReason, clusterv1.ConditionSeverityInfo,
+					"Waiting for machine %s to be healthy after its restart", machine.Name)
+
+				return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+			}
+
+			continue
+		}
+
+		if !needsNodeTokenUpdate(controlPlane, machine) {
+			continue
+		}
+
+		if _, err := r.updateNodeToken(ctx, controlPlane, workloadCluster, machine, token, rke2.RKE2ServerService); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// The next machine is restarted once this one is restarted and healthy.
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	controlPlane.Logger().Info("Updated the server token of the control plane machines")
+
+	rotation.Phase = controlplanev1.ServerTokenRotationPhaseUpdatingAgents
+	rotation.UpdatedMachines = nil
+
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// updateAgentsToken updates the token in the configuration of the worker nodes, all at once, as they only use it
+// when they restart, then completes the rotation.
+func (r *RKE2ControlPlaneReconciler) updateAgentsToken(
+	ctx context.Context,
+	controlPlane *rke2.ControlPlane,
+	tokenSecret *corev1.Secret,
+) (ctrl.Result, error) {
+	rcp := controlPlane.RCP
+	rotation := rcp.Status.ServerTokenRotation
+
+	workerMachines, err := r.managementCluster.GetMachinesForCluster(ctx, util.ObjectKey(controlPlane.Cluster),
+		collections.Not(collections.ControlPlaneMachines(controlPlane.Cluster.Name)))
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	workloadCluster, err := r.GetWorkloadCluster(ctx, controlPlane)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get workload cluster")
+	}
+
+	completed := true
+
+	for _, machine := range workerMachines.SortedByCreationTimestamp() {
+		if slices.Contains(rotation.UpdatedMachines, machine.Name) || !needsNodeTokenUpdate(controlPlane, machine) {
+			continue
+		}
+
+		agentToken, err := r.joinedWithAgentToken(ctx, machine)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if agentToken {
+			continue
+		}
+
+		updated, err := r.updateNodeToken(ctx, controlPlane, workloadCluster, machine, string(tokenSecret.Data["value"]), "")
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		completed = completed && updated
+	}
+
+	if !completed {
+		return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+	}
+
+	delete(tokenSecret.Data, nextServerTokenKey)
+
+	if err := r.Client.Update(ctx, tokenSecret); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to update the server token")
+	}
+
+	controlPlane.Logger().Info("Completed rotation of the server token")
+
+	rotation.Phase = controlplanev1.ServerTokenRotationPhaseCompleted
+	rotation.UpdatedMachines = nil
+
+	conditions.MarkTrue(rcp, controlplanev1.ServerTokenRotatedCondition)
+	r.recorder.Eventf(rcp, corev1.EventTypeNormal, "ServerTokenRotated", "Rotated the server token (%s)", rotation.ID)
+
+	return ctrl.Result{}, nil
+}
+
+// needsNodeTokenUpdate returns true if the node of the machine was configured with the previous token. Machines
+// without a node yet, or being deleted, are skipped, as their configuration can't be updated.
+func needsNodeTokenUpdate(controlPlane *rke2.ControlPlane, machine *clusterv1.Machine) bool {
+	rotation := controlPlane.RCP.Status.ServerTokenRotation
+
+	if !machine.CreationTimestamp.Before(rotation.RotatedTime) || !machine.DeletionTimestamp.IsZero() {
+		return false
+	}
+
+	if machine.Status.NodeRef == nil {
+		controlPlane.Logger().Info("Skipping update of the server token of a machine without node, "+
+			"it must be remediated if it fails to join the cluster", "machine", machine.Name)
+
+		return false
+	}
+
+	return true
+}
+
+// joinedWithAgentToken returns true if the worker machine joined the cluster with its own agent token, which is not
+// affected by the rotation of the server token.
+func (r *RKE2ControlPlaneReconciler) joinedWithAgentToken(ctx context.Context, machine *clusterv1.Machine) (bool, error) {
+	ref := machine.Spec.Bootstrap.ConfigRef
+	if ref == nil || ref.GroupVersionKind().GroupKind() != bootstrapv1.GroupVersion.WithKind("RKE2Config").GroupKind() {
+		return false, nil
+	}
+
+	config := &bootstrapv1.RKE2Config{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: machine.Namespace, Name: ref.Name}, config); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, errors.Wrapf(err, "failed to get the bootstrap config of machine %s", machine.Name)
+	}
+
+	_, found := config.Annotations[bootstrapv1.AgentTokenIDAnnotation]
+
+	return found, nil
+}
+
+// updateNodeToken runs the command updating the token in the configuration of the node of the machine, and restarting
+// the given service, if any. It returns true once the command succeeded, and the machine is recorded as updated.
+func (r *RKE2ControlPlaneReconciler) updateNodeToken(
+	ctx context.Context,
+	controlPlane *rke2.ControlPlane,
+	workloadCluster rke2.WorkloadCluster,
+	machine *clusterv1.Machine,
+	token string,
+	service string,
+) (bool, error) {
+	logger := controlPlane.Logger()
+	rcp := controlPlane.RCP
+	rotation := rcp.Status.ServerTokenRotation
+
+	command := rke2.NewNodeTokenUpdateCommand(rcp, machine.Name, machine.Status.NodeRef.Name, token, service)
+
+	pod, err := workloadCluster.GetHostCommandPod(ctx, command)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case pod == nil:
+		if err := workloadCluster.StartHostCommand(ctx, command); err != nil {
+			return false, err
+		}
+
+		logger.Info("Updating the server token of the node", "machine", machine.Name, "phase", rotation.Phase)
+		conditions.MarkFalse(rcp, controlplanev1.ServerTokenRotatedCondition,
+			controlplanev1.UpdatingNodesTokenReason, clusterv1.ConditionSeverityInfo,
+			"Updating the server token of machine %s for phase %s", machine.Name, rotation.Phase)
+
+		return false, nil
+	case pod.Status.Phase == corev1.PodFailed:
+		if err := workloadCluster.DeleteHostCommandPod(ctx, command); err != nil {
+			return false, err
+		}
+
+		logger.Info("Failed to update the server token of the node, retrying", "machine", machine.Name)
+		conditions.MarkFalse(rcp, controlplanev1.ServerTokenRotatedCondition,
+			controlplanev1.NodeTokenUpdateFailedReason, clusterv1.ConditionSeverityWarning,
+			"Failed to update the server token of machine %s for phase %s", machine.Name, rotation.Phase)
+
+		return false, nil
+	case pod.Status.Phase != corev1.PodSucceeded:
+		return false, nil
+	}
+
+	if err := workloadCluster.DeleteHostCommandPod(ctx, command); err != nil {
+		return false, err
+	}
+
+	logger.Info("Updated the server token of the node", "machine", machine.Name)
+
+	rotation.UpdatedMachines = append(rotation.UpdatedMachines, machine.Name)
+
+	return true, nil
+}
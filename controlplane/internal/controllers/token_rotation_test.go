@@ -0,0 +1,205 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestReconcileServerTokenRotation(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cluster, rcp, machines := newFakeControlPlane()
+	rcp.Annotations = map[string]string{controlplanev1.RotateServerTokenAnnotation: "1"}
+	rcp.Spec.HostCommandImage = "registry.example.com/library/busybox"
+
+	for _, machine := range machines {
+		machine.CreationTimestamp = metav1.NewTime(machine.CreationTimestamp.Add(-time.Hour))
+	}
+
+	worker := healthyMachine("worker")
+	worker.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+
+	// Workers joined with their own agent token are not affected by the rotation.
+	agentWorker := healthyMachine("agent-worker")
+	agentWorker.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	agentWorker.Spec.Bootstrap.ConfigRef = &corev1.ObjectReference{
+		APIVersion: bootstrapv1.GroupVersion.String(),
+		Kind:       "RKE2Config",
+		Name:       "agent-worker",
+	}
+	agentWorkerConfig := &bootstrapv1.RKE2Config{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "agent-worker",
+			Namespace:   metav1.NamespaceDefault,
+			Annotations: map[string]string{bootstrapv1.AgentTokenIDAnnotation: "abcdef"},
+		},
+	}
+
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-token", Namespace: metav1.NamespaceDefault},
+		Data:       map[string][]byte{"value": []byte("previous")},
+	}
+
+	workloadCluster := &fakeWorkloadCluster{}
+	managementCluster := &fakeManagementCluster{Machines: collections.FromMachines(machines.UnsortedList()...), Workload: workloadCluster}
+	managementCluster.Machines.Insert(worker, agentWorker)
+	r := &RKE2ControlPlaneReconciler{
+		Client:            newFakeClient(tokenSecret, agentWorkerConfig),
+		recorder:          record.NewFakeRecorder(32),
+		managementCluster: managementCluster,
+	}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	reconcile := func() ctrl.Result {
+		result, err := r.reconcileServerTokenRotation(ctx, controlPlane)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		return result
+	}
+
+	token := func() (string, string) {
+		g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(tokenSecret), tokenSecret)).To(Succeed())
+
+		return string(tokenSecret.Data["value"]), string(tokenSecret.Data[nextServerTokenKey])
+	}
+
+	// succeed completes the last command started.
+	succeed := func() *rke2.HostCommand {
+		g.Expect(workloadCluster.StartedCommands).ToNot(BeEmpty())
+		workloadCluster.HostCommandPod = &corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodSucceeded}}
+
+		return workloadCluster.StartedCommands[len(workloadCluster.StartedCommands)-1]
+	}
+
+	// The new token is stored before the token is rotated on the oldest control plane machine.
+	g.Expect(reconcile().RequeueAfter).To(Equal(DefaultRequeueTime))
+	g.Expect(conditions.GetReason(rcp, controlplanev1.ServerTokenRotatedCondition)).To(Equal(controlplanev1.RotatingServerTokenReason))
+
+	current, next := token()
+	g.Expect(current).To(Equal("previous"))
+	g.Expect(next).ToNot(BeEmpty())
+
+	command := succeed()
+	g.Expect(command.NodeName).To(Equal("m0"))
+	g.Expect(command.Image).To(Equal("registry.example.com/library/busybox"))
+	g.Expect(command.Files).To(Equal(map[string]string{"token": "previous", "new-token": next}))
+
+	g.Expect(reconcile().Requeue).To(BeTrue())
+	g.Expect(rcp.Status.ServerTokenRotation.Phase).To(Equal(controlplanev1.ServerTokenRotationPhaseUpdatingServers))
+	g.Expect(rcp.Status.ServerTokenRotation.RotatedTime).ToNot(BeNil())
+	g.Expect(workloadCluster.HostCommandPod).To(BeNil())
+
+	current, _ = token()
+	g.Expect(current).To(Equal(next))
+
+	// The control plane machines are updated and restarted one at a time.
+	for _, name := range []string{"m0", "m1", "m2"} {
+		g.Expect(reconcile().RequeueAfter).To(Equal(DefaultRequeueTime))
+
+		command = succeed()
+		g.Expect(command.NodeName).To(Equal(name))
+		g.Expect(command.Image).To(Equal("registry.example.com/library/busybox"))
+		g.Expect(command.Files).To(Equal(map[string]string{"token": next}))
+		g.Expect(command.Command).To(ContainElement(rke2.RKE2ServerService))
+
+		g.Expect(reconcile().RequeueAfter).To(Equal(DefaultRequeueTime))
+		g.Expect(rcp.Status.ServerTokenRotation.UpdatedMachines).To(ContainElement(name))
+	}
+
+	// A restarted machine must be healthy before the next one is restarted.
+	conditions.MarkFalse(machines["m2"], controlplanev1.MachineAgentHealthyCondition, "Restarting", clusterv1.ConditionSeverityInfo, "")
+	g.Expect(reconcile().RequeueAfter).To(Equal(DefaultRequeueTime))
+	g.Expect(conditions.GetMessage(rcp, controlplanev1.ServerTokenRotatedCondition)).To(ContainSubstring("m2"))
+
+	conditions.MarkTrue(machines["m2"], controlplanev1.MachineAgentHealthyCondition)
+	g.Expect(reconcile().Requeue).To(BeTrue())
+	g.Expect(rcp.Status.ServerTokenRotation.Phase).To(Equal(controlplanev1.ServerTokenRotationPhaseUpdatingAgents))
+
+	// The worker machines are updated without restart.
+	g.Expect(reconcile().RequeueAfter).To(Equal(DefaultRequeueTime))
+
+	command = succeed()
+	g.Expect(command.NodeName).To(Equal("worker"))
+	g.Expect(command.Command).ToNot(ContainElement(rke2.RKE2ServerService))
+
+	g.Expect(reconcile()).To(BeZero())
+	g.Expect(rcp.Status.ServerTokenRotation.UpdatedMachines).ToNot(ContainElement("agent-worker"))
+	g.Expect(rcp.Status.ServerTokenRotation.Phase).To(Equal(controlplanev1.ServerTokenRotationPhaseCompleted))
+	g.Expect(conditions.IsTrue(rcp, controlplanev1.ServerTokenRotatedCondition)).To(BeTrue())
+
+	current, next = token()
+	g.Expect(current).ToNot(Equal("previous"))
+	g.Expect(next).To(BeEmpty())
+
+	// The rotation is not started again until the annotation changes.
+	startedCommands := len(workloadCluster.StartedCommands)
+	g.Expect(reconcile()).To(BeZero())
+	g.Expect(workloadCluster.StartedCommands).To(HaveLen(startedCommands))
+}
+
+func TestReconcileServerTokenRotationWithTokenSecretRef(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cluster, rcp, machines := newFakeControlPlane()
+	rcp.Annotations = map[string]string{controlplanev1.RotateServerTokenAnnotation: "1"}
+	rcp.Spec.TokenSecretRef = &corev1.LocalObjectReference{Name: "user-token"}
+
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "user-token", Namespace: metav1.NamespaceDefault},
+		Data:       map[string][]byte{"value": []byte("user")},
+	}
+
+	workloadCluster := &fakeWorkloadCluster{}
+	r := &RKE2ControlPlaneReconciler{
+		Client:            newFakeClient(tokenSecret),
+		recorder:          record.NewFakeRecorder(32),
+		managementCluster: &fakeManagementCluster{Machines: machines, Workload: workloadCluster},
+	}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	// The token of the user is not rotated.
+	result, err := r.reconcileServerTokenRotation(ctx, controlPlane)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result).To(BeZero())
+	g.Expect(rcp.Status.ServerTokenRotation).To(BeNil())
+	g.Expect(conditions.GetReason(rcp, controlplanev1.ServerTokenRotatedCondition)).
+		To(Equal(controlplanev1.ServerTokenRotationNotSupportedReason))
+	g.Expect(workloadCluster.StartedCommands).To(BeEmpty())
+
+	g.Expect(r.Client.Get(ctx, client.ObjectKeyFromObject(tokenSecret), tokenSecret)).To(Succeed())
+	g.Expect(tokenSecret.Data).To(Equal(map[string][]byte{"value": []byte("user")}))
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+	bsutil "github.com/rancher/cluster-api-provider-rke2/pkg/util"
+)
+
+// tokenSecretRefIndex indexes the RKE2ControlPlanes by the name of the token secret they reference.
+const tokenSecretRefIndex = "spec.tokenSecretRef.name"
+
+// indexTokenSecretRef is the indexer function of the tokenSecretRefIndex.
+func indexTokenSecretRef(o client.Object) []string {
+	rcp, ok := o.(*controlplanev1.RKE2ControlPlane)
+	if !ok || rcp.Spec.TokenSecretRef == nil {
+		return nil
+	}
+
+	return []string{rcp.Spec.TokenSecretRef.Name}
+}
+
+// tokenSecretToRKE2ControlPlanes is a handler.MapFunc enqueuing the RKE2ControlPlanes referencing a token secret, so
+// that its validity is reported as soon as it changes.
+func (r *RKE2ControlPlaneReconciler) tokenSecretToRKE2ControlPlanes(ctx context.Context, o client.Object) []ctrl.Request {
+	rcps := &controlplanev1.RKE2ControlPlaneList{}
+	if err := r.Client.List(ctx, rcps,
+		client.InNamespace(o.GetNamespace()), client.MatchingFields{tokenSecretRefIndex: o.GetName()}); err != nil {
+		return nil
+	}
+
+	result := []ctrl.Request{}
+	for _, rcp := range rcps.Items {
+		result = append(result, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&rcp)})
+	}
+
+	return result
+}
+
+// reconcileTokenSecret checks that the Secret referenced by the TokenSecretRef holds a valid server token, and reports
+// it in the TokenSecretAvailable condition. It returns false when the token is invalid, in which case no machine must
+// be created, as it could not be bootstrapped.
+func (r *RKE2ControlPlaneReconciler) reconcileTokenSecret(ctx context.Context, controlPlane *rke2.ControlPlane) bool {
+	logger := controlPlane.Logger()
+	rcp := controlPlane.RCP
+
+	if rcp.Spec.TokenSecretRef == nil {
+		conditions.Delete(rcp, controlplanev1.TokenSecretAvailableCondition)
+
+		return true
+	}
+
+	key := bsutil.TokenSecretKey(rcp, controlPlane.Cluster.Name)
+
+	secret := &corev1.Secret{}
+
+	err := r.Client.Get(ctx, key, secret)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to get token secret %s", key)
+	} else {
+		_, err = bsutil.TokenFromSecret(secret)
+	}
+
+	if err != nil {
+		logger.Info("Waiting for the token secret to hold a valid server token", "secret", key, "reason", err.Error())
+		conditions.MarkFalse(rcp,
+			controlplanev1.TokenSecretAvailableCondition,
+			controlplanev1.TokenSecretInvalidReason,
+			clusterv1.ConditionSeverityWarning,
+			err.Error())
+
+		return false
+	}
+
+	conditions.MarkTrue(rcp, controlplanev1.TokenSecretAvailableCondition)
+
+	return true
+}
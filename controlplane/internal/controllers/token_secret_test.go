@@ -0,0 +1,73 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2"
+)
+
+func TestReconcileTokenSecret(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cluster, rcp, machines := newFakeControlPlane()
+
+	r := &RKE2ControlPlaneReconciler{
+		Client:   newFakeClient(),
+		recorder: record.NewFakeRecorder(32),
+	}
+	controlPlane := &rke2.ControlPlane{RCP: rcp, Cluster: cluster, Machines: machines}
+
+	// The token secret generated by the bootstrap provider is not reported.
+	g.Expect(r.reconcileTokenSecret(ctx, controlPlane)).To(BeTrue())
+	g.Expect(conditions.Has(rcp, controlplanev1.TokenSecretAvailableCondition)).To(BeFalse())
+
+	rcp.Spec.TokenSecretRef = &corev1.LocalObjectReference{Name: "user-token"}
+	g.Expect(indexTokenSecretRef(rcp)).To(ConsistOf("user-token"))
+
+	// A missing secret is reported.
+	g.Expect(r.reconcileTokenSecret(ctx, controlPlane)).To(BeFalse())
+	g.Expect(conditions.GetReason(rcp, controlplanev1.TokenSecretAvailableCondition)).
+		To(Equal(controlplanev1.TokenSecretInvalidReason))
+
+	// A secret without token in its value key is reported.
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "user-token", Namespace: metav1.NamespaceDefault},
+		Data:       map[string][]byte{"token": []byte("user")},
+	}
+	g.Expect(r.Client.Create(ctx, secret)).To(Succeed())
+
+	g.Expect(r.reconcileTokenSecret(ctx, controlPlane)).To(BeFalse())
+	g.Expect(conditions.GetMessage(rcp, controlplanev1.TokenSecretAvailableCondition)).To(ContainSubstring("has no value"))
+
+	secret.Data = map[string][]byte{"value": []byte("user")}
+	g.Expect(r.Client.Update(ctx, secret)).To(Succeed())
+
+	g.Expect(r.reconcileTokenSecret(ctx, controlPlane)).To(BeTrue())
+	g.Expect(conditions.IsTrue(rcp, controlplanev1.TokenSecretAvailableCondition)).To(BeTrue())
+}
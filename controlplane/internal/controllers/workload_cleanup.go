@@ -0,0 +1,128 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+// defaultWorkloadCleanupTimeout is how long the resources of the workload cluster are waited for when the
+// WorkloadCleanup has no timeout.
+const defaultWorkloadCleanupTimeout = 10 * time.Minute
+
+// reconcileWorkloadCleanup deletes the resources of the workload cluster selected by the WorkloadCleanup of a
+// RKE2ControlPlane being deleted, and returns a non-zero result until their cloud resources are released, or the
+// timeout is reached.
+//
+// The cleanup runs once the worker machines are deleted: the cloud resources are only released if the cloud
+// controller manager and the volume provisioners run on the control plane machines, and the volumes attached to
+// the deleted worker nodes may not be released before the timeout.
+//
+// The timeout is measured from the transition of the WorkloadCleanedUp condition, whose message is therefore not
+// changed while the cleanup is in progress; the remaining resources are reported in the Resized condition.
+func (r *RKE2ControlPlaneReconciler) reconcileWorkloadCleanup(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	rcp *controlplanev1.RKE2ControlPlane,
+) (ctrl.Result, error) {
+	logger := ctrl.LoggerFrom(ctx)
+	cleanup := rcp.Spec.WorkloadCleanup
+
+	// The workload cluster is not reachable before the control plane is initialized.
+	if cleanup == nil || !rcp.Status.Initialized {
+		return ctrl.Result{}, nil
+	}
+
+	if conditions.IsTrue(rcp, controlplanev1.WorkloadCleanedUpCondition) ||
+		conditions.GetReason(rcp, controlplanev1.WorkloadCleanedUpCondition) == controlplanev1.WorkloadCleanupTimedOutReason {
+		return ctrl.Result{}, nil
+	}
+
+	conditions.MarkFalse(rcp, controlplanev1.WorkloadCleanedUpCondition,
+		controlplanev1.CleaningUpWorkloadReason, clusterv1.ConditionSeverityInfo,
+		"Deleting the resources of the workload cluster and waiting for them to be released")
+
+	timeout := defaultWorkloadCleanupTimeout
+	if cleanup.Timeout != nil {
+		timeout = cleanup.Timeout.Duration
+	}
+
+	started := conditions.GetLastTransitionTime(rcp, controlplanev1.WorkloadCleanedUpCondition)
+
+	remaining, err := r.cleanupWorkload(ctx, cluster, cleanup)
+	if err == nil && len(remaining) == 0 {
+		logger.Info("Deleted the resources of the workload cluster")
+		conditions.MarkTrue(rcp, controlplanev1.WorkloadCleanedUpCondition)
+
+		return ctrl.Result{}, nil
+	}
+
+	if time.Since(started.Time) >= timeout {
+		message := "Timed out after " + timeout.String() + " deleting the resources of the workload cluster"
+
+		switch {
+		case len(remaining) > 0:
+			message += ", remaining: " + strings.Join(remaining, ", ")
+		case err != nil:
+			message += ": " + err.Error()
+		}
+
+		logger.Info(message)
+		r.recorder.Event(rcp, corev1.EventTypeWarning, controlplanev1.WorkloadCleanupTimedOutReason, message)
+		conditions.MarkFalse(rcp, controlplanev1.WorkloadCleanedUpCondition,
+			controlplanev1.WorkloadCleanupTimedOutReason, clusterv1.ConditionSeverityWarning, "%s", message)
+
+		return ctrl.Result{}, nil
+	}
+
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("Waiting for the resources of the workload cluster to be released", "remaining", remaining)
+	conditions.MarkFalse(rcp, controlplanev1.ResizedCondition, clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo,
+		"Waiting for %d resources of the workload cluster to be released", len(remaining))
+
+	return ctrl.Result{RequeueAfter: DefaultRequeueTime}, nil
+}
+
+// cleanupWorkload deletes the resources of the workload cluster selected by the WorkloadCleanup, and returns the ones
+// still holding cloud resources.
+func (r *RKE2ControlPlaneReconciler) cleanupWorkload(
+	ctx context.Context,
+	cluster *clusterv1.Cluster,
+	cleanup *controlplanev1.WorkloadCleanup,
+) ([]string, error) {
+	workloadCluster, err := r.managementCluster.GetWorkloadCluster(ctx, util.ObjectKey(cluster))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get workload cluster")
+	}
+
+	return workloadCluster.CleanupWorkload(ctx, cleanup)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+func TestReconcileWorkloadCleanup(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	cluster, rcp, machines := newFakeControlPlane()
+
+	workloadCluster := &fakeWorkloadCluster{}
+	recorder := record.NewFakeRecorder(32)
+	r := &RKE2ControlPlaneReconciler{
+		Client:            newFakeClient(),
+		managementCluster: &fakeManagementCluster{Machines: machines, Workload: workloadCluster},
+		recorder:          recorder,
+	}
+
+	// Nothing is deleted without a workload cleanup.
+	result, err := r.reconcileWorkloadCleanup(ctx, cluster, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(workloadCluster.WorkloadCleanups).To(BeZero())
+
+	// The machines are not deleted while resources are not released.
+	rcp.Spec.WorkloadCleanup = &controlplanev1.WorkloadCleanup{Timeout: &metav1.Duration{Duration: time.Hour}}
+	workloadCluster.CleanupRemaining = []string{"Service default/ingress", "PersistentVolume pvc-0a1b2c"}
+
+	result, err = r.reconcileWorkloadCleanup(ctx, cluster, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(DefaultRequeueTime))
+	g.Expect(conditions.GetReason(rcp, controlplanev1.WorkloadCleanedUpCondition)).
+		To(Equal(controlplanev1.CleaningUpWorkloadReason))
+	g.Expect(conditions.GetMessage(rcp, controlplanev1.ResizedCondition)).To(ContainSubstring("Waiting for 2 resources"))
+
+	// The machines are deleted anyway once the timeout is reached.
+	for i := range rcp.Status.Conditions {
+		if rcp.Status.Conditions[i].Type == controlplanev1.WorkloadCleanedUpCondition {
+			rcp.Status.Conditions[i].LastTransitionTime = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		}
+	}
+
+	result, err = r.reconcileWorkloadCleanup(ctx, cluster, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(conditions.GetReason(rcp, controlplanev1.WorkloadCleanedUpCondition)).
+		To(Equal(controlplanev1.WorkloadCleanupTimedOutReason))
+	g.Expect(recorder.Events).To(Receive(ContainSubstring("Service default/ingress, PersistentVolume pvc-0a1b2c")))
+
+	// The cleanup is not retried after the timeout.
+	_, err = r.reconcileWorkloadCleanup(ctx, cluster, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(workloadCluster.WorkloadCleanups).To(Equal(2))
+
+	// The machines are deleted once the resources are released.
+	conditions.Delete(rcp, controlplanev1.WorkloadCleanedUpCondition)
+	workloadCluster.CleanupRemaining = nil
+
+	result, err = r.reconcileWorkloadCleanup(ctx, cluster, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(conditions.IsTrue(rcp, controlplanev1.WorkloadCleanedUpCondition)).To(BeTrue())
+}
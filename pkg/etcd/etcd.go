@@ -37,8 +37,10 @@ type GRPCDial func(ctx context.Context, addr string) (net.Conn, error)
 // etcd wraps the etcd client from etcd's clientv3 package.
 // This interface is implemented by both the clientv3 package and the backoff adapter that adds retries to the client.
 type etcd interface {
+	AlarmDisarm(ctx context.Context, m *clientv3.AlarmMember) (*clientv3.AlarmResponse, error)
 	AlarmList(ctx context.Context) (*clientv3.AlarmResponse, error)
 	Close() error
+	Defragment(ctx context.Context, endpoint string) (*clientv3.DefragmentResponse, error)
 	Endpoints() []string
 	MemberList(ctx context.Context) (*clientv3.MemberListResponse, error)
 	MemberRemove(ctx context.Context, id uint64) (*clientv3.MemberRemoveResponse, error)
@@ -83,6 +85,10 @@ const (
 // for read and write operations to etcd.
 const DefaultCallTimeout = 15 * time.Second
 
+// DefragmentTimeout represents the duration that the etcd client waits at most for the defragmentation
+// of a member, which blocks the member and lasts longer than other operations on large databases.
+const DefragmentTimeout = 2 * time.Minute
+
 // AlarmTypeName provides a text translation for AlarmType codes.
 var AlarmTypeName = map[AlarmType]string{
 	AlarmOK:      "NONE",
@@ -113,6 +119,12 @@ type Member struct {
 	// IsLearner indicates if the member is raft learner.
 	IsLearner bool
 
+	// RaftTerm is the current raft term of the member.
+	RaftTerm uint64
+
+	// RaftAppliedIndex is the index of the last raft entry applied by the member.
+	RaftAppliedIndex uint64
+
 	// Alarms is the list of alarms for a member.
 	Alarms []AlarmType
 }
@@ -129,6 +141,38 @@ func pbMemberToMember(m *etcdserverpb.Member) *Member {
 	}
 }
 
+// MemberStatus describes the status of the etcd member a client is connected to.
+type MemberStatus struct {
+	// ID is the ID of the member.
+	ID uint64
+
+	// LeaderID is the ID of the leader of the cluster, as seen by the member.
+	LeaderID uint64
+
+	// DBSize is the size of the backend database of the member, in bytes, including free space.
+	DBSize int64
+
+	// DBSizeInUse is the size of the backend database of the member actually in use, in bytes.
+	DBSizeInUse int64
+
+	// IsLearner indicates if the member is raft learner.
+	IsLearner bool
+
+	// RaftTerm is the current raft term of the member.
+	RaftTerm uint64
+
+	// RaftAppliedIndex is the index of the last raft entry applied by the member.
+	RaftAppliedIndex uint64
+
+	// Errors contains the alarm errors reported by the member.
+	Errors []string
+}
+
+// IsLeader returns true if the member is the leader of the cluster.
+func (s *MemberStatus) IsLeader() bool {
+	return s.ID == s.LeaderID
+}
+
 // ClientConfiguration describes the configuration for an etcd client.
 type ClientConfiguration struct {
 	Endpoint    string
@@ -299,3 +343,49 @@ func (c *Client) Alarms(ctx context.Context) ([]MemberAlarm, error) {
 
 	return memberAlarms, nil
 }
+
+// Status retrieves the status of the member the client is connected to.
+func (c *Client) Status(ctx context.Context) (*MemberStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.CallTimeout)
+	defer cancel()
+
+	response, err := c.EtcdClient.Status(ctx, c.Endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get status of etcd member %s", c.Endpoint)
+	}
+
+	return &MemberStatus{
+		ID:               response.Header.GetMemberId(),
+		LeaderID:         response.Leader,
+		DBSize:           response.DbSize,
+		DBSizeInUse:      response.DbSizeInUse,
+		IsLearner:        response.IsLearner,
+		RaftTerm:         response.RaftTerm,
+		RaftAppliedIndex: response.RaftAppliedIndex,
+		Errors:           response.Errors,
+	}, nil
+}
+
+// Defragment defragments the backend database of the member the client is connected to, releasing its free space.
+// NOTE: the member does not serve any request while it is defragmented.
+func (c *Client) Defragment(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, DefragmentTimeout)
+	defer cancel()
+
+	_, err := c.EtcdClient.Defragment(ctx, c.Endpoint)
+
+	return errors.Wrapf(err, "failed to defragment etcd member %s", c.Endpoint)
+}
+
+// AlarmDisarm disarms the given alarm.
+func (c *Client) AlarmDisarm(ctx context.Context, alarm MemberAlarm) error {
+	ctx, cancel := context.WithTimeout(ctx, c.CallTimeout)
+	defer cancel()
+
+	_, err := c.EtcdClient.AlarmDisarm(ctx, &clientv3.AlarmMember{
+		MemberID: alarm.MemberID,
+		Alarm:    etcdserverpb.AlarmType(alarm.Type),
+	})
+
+	return errors.Wrapf(err, "failed to disarm alarm %s of etcd member %v", AlarmTypeName[alarm.Type], alarm.MemberID)
+}
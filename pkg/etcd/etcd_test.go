@@ -58,6 +58,12 @@ func TestEtcdMembers_WithErrors(t *testing.T) {
 
 	err = client.RemoveMember(ctx, 1234)
 	g.Expect(err).To(HaveOccurred())
+
+	err = client.Defragment(ctx)
+	g.Expect(err).To(HaveOccurred())
+
+	err = client.AlarmDisarm(ctx, MemberAlarm{MemberID: 1234, Type: AlarmNoSpace})
+	g.Expect(err).To(HaveOccurred())
 }
 
 func TestEtcdMembers_WithSuccess(t *testing.T) {
@@ -101,3 +107,41 @@ func TestEtcdMembers_WithSuccess(t *testing.T) {
 	g.Expect(updatedMembers[0].PeerURLs).To(HaveLen(2))
 	g.Expect(updatedMembers[0].PeerURLs).To(Equal([]string{"https://1.2.3.4:2000", "https://4.5.6.7:2000"}))
 }
+
+func TestEtcdMaintenance(t *testing.T) {
+	g := NewWithT(t)
+
+	fakeEtcdClient := &etcdfake.FakeEtcdClient{
+		EtcdEndpoints: []string{"https://etcd-instance:2379"},
+		AlarmResponse: &clientv3.AlarmResponse{},
+		StatusResponse: &clientv3.StatusResponse{
+			Header:           &etcdserverpb.ResponseHeader{MemberId: 1234},
+			Leader:           5678,
+			DbSize:           200,
+			DbSizeInUse:      50,
+			RaftTerm:         3,
+			RaftAppliedIndex: 42,
+		},
+		DefragmentResponse: &clientv3.DefragmentResponse{},
+	}
+
+	client, err := newEtcdClient(ctx, fakeEtcdClient, DefaultCallTimeout)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	status, err := client.Status(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(status.ID).To(Equal(uint64(1234)))
+	g.Expect(status.IsLeader()).To(BeFalse())
+	g.Expect(status.DBSize).To(Equal(int64(200)))
+	g.Expect(status.DBSizeInUse).To(Equal(int64(50)))
+	g.Expect(status.RaftTerm).To(Equal(uint64(3)))
+	g.Expect(status.RaftAppliedIndex).To(Equal(uint64(42)))
+
+	g.Expect(client.Defragment(ctx)).To(Succeed())
+	g.Expect(fakeEtcdClient.DefragmentedMembers).To(Equal([]string{"https://etcd-instance:2379"}))
+
+	g.Expect(client.AlarmDisarm(ctx, MemberAlarm{MemberID: 1234, Type: AlarmNoSpace})).To(Succeed())
+	g.Expect(fakeEtcdClient.DisarmedAlarms).To(HaveLen(1))
+	g.Expect(fakeEtcdClient.DisarmedAlarms[0].MemberID).To(Equal(uint64(1234)))
+	g.Expect(fakeEtcdClient.DisarmedAlarms[0].Alarm).To(Equal(etcdserverpb.AlarmType_NOSPACE))
+}
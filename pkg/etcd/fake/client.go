@@ -26,6 +26,7 @@ import (
 // FakeEtcdClient represents a testing fake client for etcd interactions.
 type FakeEtcdClient struct { //nolint:revive
 	AlarmResponse        *clientv3.AlarmResponse
+	DefragmentResponse   *clientv3.DefragmentResponse
 	EtcdEndpoints        []string
 	MemberListResponse   *clientv3.MemberListResponse
 	MemberRemoveResponse *clientv3.MemberRemoveResponse
@@ -35,6 +36,8 @@ type FakeEtcdClient struct { //nolint:revive
 	ErrorResponse        error
 	MovedLeader          uint64
 	RemovedMember        uint64
+	DefragmentedMembers  []string
+	DisarmedAlarms       []*clientv3.AlarmMember
 }
 
 // Endpoints returns available etcd endpoint.
@@ -59,6 +62,20 @@ func (c *FakeEtcdClient) AlarmList(_ context.Context) (*clientv3.AlarmResponse,
 	return c.AlarmResponse, c.ErrorResponse
 }
 
+// AlarmDisarm disarms an alarm on etcd cluster.
+func (c *FakeEtcdClient) AlarmDisarm(_ context.Context, m *clientv3.AlarmMember) (*clientv3.AlarmResponse, error) {
+	c.DisarmedAlarms = append(c.DisarmedAlarms, m)
+
+	return c.AlarmResponse, c.ErrorResponse
+}
+
+// Defragment defragments the etcd member.
+func (c *FakeEtcdClient) Defragment(_ context.Context, endpoint string) (*clientv3.DefragmentResponse, error) {
+	c.DefragmentedMembers = append(c.DefragmentedMembers, endpoint)
+
+	return c.DefragmentResponse, c.ErrorResponse
+}
+
 // MemberList returnl a list of etcd members for the cluster.
 func (c *FakeEtcdClient) MemberList(_ context.Context) (*clientv3.MemberListResponse, error) {
 	return c.MemberListResponse, c.ErrorResponse
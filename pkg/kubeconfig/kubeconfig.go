@@ -86,6 +86,9 @@ func generateKubeconfig(ctx context.Context, c client.Client, clusterName client
 		return nil, errors.Wrap(err, "failed to generate a kubeconfig")
 	}
 
+	// Trust all the certificate authorities of the cluster CA secret, which is a bundle while they are rotated.
+	cfg.Clusters[clusterName.Name].CertificateAuthorityData = clusterCA.Data[secret.TLSCrtDataName]
+
 	out, err := clientcmd.Write(*cfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to serialize config to yaml")
@@ -155,6 +158,7 @@ func CreateSecret(ctx context.Context, c client.Client, cluster *clusterv1.Clust
 }
 
 // CreateSecretWithOwner creates the Kubeconfig secret for the given cluster name, namespace, endpoint, and owner reference.
+// If the secret already exists, the kubeconfig it stores is regenerated.
 func CreateSecretWithOwner(ctx context.Context, c client.Client, clusterName client.ObjectKey, endpoint string, owner metav1.OwnerReference) error {
 	server := fmt.Sprintf("https://%s", endpoint)
 
@@ -163,7 +167,21 @@ func CreateSecretWithOwner(ctx context.Context, c client.Client, clusterName cli
 		return err
 	}
 
-	return c.Create(ctx, GenerateSecretWithOwner(clusterName, out, owner))
+	kubeconfigSecret := GenerateSecretWithOwner(clusterName, out, owner)
+
+	err = c.Create(ctx, kubeconfigSecret)
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	existing := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(kubeconfigSecret), existing); err != nil {
+		return err
+	}
+
+	existing.Data = kubeconfigSecret.Data
+
+	return c.Update(ctx, existing)
 }
 
 // GenerateSecret returns a Kubernetes secret for the given Cluster and kubeconfig data.
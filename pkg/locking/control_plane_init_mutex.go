@@ -19,43 +19,97 @@ package locking
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
-	corev1 "k8s.io/api/core/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
-const semaphoreInformationKey = "lock-information"
+const (
+	// InitTimeoutAnnotation records on the lease of the init lock the init timeout of the Machine holding it, so that
+	// whether the lock is stale can be decided by any controller.
+	InitTimeoutAnnotation = "controlplane.cluster.x-k8s.io/init-timeout"
 
-// ControlPlaneInitMutex uses a ConfigMap to synchronize cluster initialization.
+	// InitLockLeaseDuration is the duration of the lease of the init lock. The lease is renewed by the bootstrap
+	// controller while the Machine holding it initializes the control plane, but whether the lock is stale is decided
+	// by the progress of the Machine, so that it is not taken over while the bootstrap controller is unavailable.
+	InitLockLeaseDuration = 2 * time.Minute
+
+	// InitLockRenewInterval is the interval the init lock is renewed at by the Machine holding it, three times within
+	// its lease duration so that it is renewed in time despite a failure.
+	InitLockRenewInterval = InitLockLeaseDuration / 3
+
+	// DefaultInitTimeout is the time the Machine holding the init lock renews it for without getting a node.
+	DefaultInitTimeout = 20 * time.Minute
+)
+
+// ControlPlaneInitMutex uses a Lease to synchronize cluster initialization.
 type ControlPlaneInitMutex struct {
-	client client.Client
+	client      client.Client
+	initTimeout time.Duration
 }
 
-// NewControlPlaneInitMutex returns a lock that can be held by a control plane node before init.
-func NewControlPlaneInitMutex(client client.Client) *ControlPlaneInitMutex {
+// NewControlPlaneInitMutex returns a lock that can be held by a control plane node before init. The lock can be taken
+// over by another control plane node once the node holding it did not get a NodeRef within the init timeout.
+func NewControlPlaneInitMutex(client client.Client, initTimeout time.Duration) *ControlPlaneInitMutex {
+	if initTimeout == 0 {
+		initTimeout = DefaultInitTimeout
+	}
+
 	return &ControlPlaneInitMutex{
-		client: client,
+		client:      client,
+		initTimeout: initTimeout,
+	}
+}
+
+// InitLock describes the control plane init lock of a cluster.
+type InitLock struct {
+	// MachineName is the name of the Machine holding the lock.
+	MachineName string
+
+	// AcquireTime is the time the lock was acquired by the Machine.
+	AcquireTime time.Time
+
+	// Stale is true if the lock can be taken over by another Machine, as the Machine holding it is gone or being
+	// deleted, or did not get a NodeRef within its init timeout.
+	Stale bool
+}
+
+// GetInitLock returns the control plane init lock of a cluster, or nil if it is not held.
+func GetInitLock(ctx context.Context, c client.Client, cluster *clusterv1.Cluster) (*InitLock, error) {
+	lease := &coordinationv1.Lease{}
+	if err := c.Get(ctx, client.ObjectKey{
+		Namespace: cluster.Namespace,
+		Name:      leaseName(cluster.Name),
+	}, lease); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil //nolint:nilnil
+		}
+
+		return nil, errors.Wrap(err, "failed to get init lock")
 	}
+
+	return getInitLock(ctx, c, lease)
 }
 
 // Lock allows a control plane node to be the first and only node to initialize an RKE2 cluster.
 func (c *ControlPlaneInitMutex) Lock(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) bool {
-	sema := newSemaphore()
-	cmName := configMapName(cluster.Name)
-	log := ctrl.LoggerFrom(ctx, "ConfigMap", klog.KRef(cluster.Namespace, cmName))
+	lease := &coordinationv1.Lease{}
+	name := leaseName(cluster.Name)
+	log := ctrl.LoggerFrom(ctx, "Lease", klog.KRef(cluster.Namespace, name))
 	err := c.client.Get(ctx, client.ObjectKey{
 		Namespace: cluster.Namespace,
-		Name:      cmName,
-	}, sema.ConfigMap)
+		Name:      name,
+	}, lease)
 
 	switch {
 	case apierrors.IsNotFound(err):
@@ -64,47 +118,49 @@ func (c *ControlPlaneInitMutex) Lock(ctx context.Context, cluster *clusterv1.Clu
 		log.Error(err, "Failed to acquire init lock")
 
 		return false
-	default: // Successfully found an existing config map.
-		info, err := sema.information()
+	default: // Successfully found an existing lease.
+		// The machine requesting the lock is the machine holding the lock, therefore the lock is renewed.
+		if ptr.Deref(lease.Spec.HolderIdentity, "") == machine.Name {
+			return c.renew(ctx, lease, machine)
+		}
+
+		lock, err := getInitLock(ctx, c.client, lease)
 		if err != nil {
 			log.Error(err, "Failed to get information about the existing init lock")
 
 			return false
 		}
-		// The machine requesting the lock is the machine that created the lock, therefore the lock is acquired.
-		if info.MachineName == machine.Name {
-			return true
-		}
 
-		// If the machine that created the lock can not be found unlock the mutex.
-		if err := c.client.Get(ctx, client.ObjectKey{
-			Namespace: cluster.Namespace,
-			Name:      info.MachineName,
-		}, &clusterv1.Machine{}); err != nil {
-			log.Error(err, "Failed to get machine holding init lock")
+		if !lock.Stale {
+			log.Info(fmt.Sprintf("Waiting for Machine %s to initialize", lock.MachineName))
 
-			if apierrors.IsNotFound(err) {
-				c.Unlock(ctx, cluster)
-			}
+			return false
 		}
 
-		log.Info(fmt.Sprintf("Waiting for Machine %s to initialize", info.MachineName))
+		// The machine holding the lock shows no progress, the lock is taken over; the update fails on conflict if
+		// another machine took it over in the meantime.
+		log.Info(fmt.Sprintf("Taking over the init lock held by Machine %s, which shows no progress", lock.MachineName))
 
-		return false
-	}
+		c.setHolder(lease, machine)
+		lease.Spec.LeaseTransitions = ptr.To(ptr.Deref(lease.Spec.LeaseTransitions, 0) + 1)
 
-	// Adds owner reference, namespace and name
-	sema.setMetadata(cluster)
-	// Adds the additional information
-	if err := sema.setInformation(&information{MachineName: machine.Name}); err != nil {
-		log.Error(err, "Failed to acquire init lock while setting semaphore information")
+		if err := c.client.Update(ctx, lease); err != nil {
+			log.Error(err, "Cannot take over the init lock")
 
-		return false
+			return false
+		}
+
+		return true
 	}
 
+	// Adds owner reference, namespace and name
+	setMetadata(lease, cluster)
+	// Adds the holder, lease duration and init timeout
+	c.setHolder(lease, machine)
+
 	log.Info("Attempting to acquire the lock")
 
-	err = c.client.Create(ctx, sema.ConfigMap)
+	err = c.client.Create(ctx, lease)
 
 	switch {
 	case apierrors.IsAlreadyExists(err):
@@ -120,15 +176,61 @@ func (c *ControlPlaneInitMutex) Lock(ctx context.Context, cluster *clusterv1.Clu
 	}
 }
 
+// Renew renews the lock held by a control plane node, and returns false if the node does not hold it, or did not get a
+// NodeRef within the init timeout, in which case the lock is stale and no longer renewed.
+func (c *ControlPlaneInitMutex) Renew(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) bool {
+	lease := &coordinationv1.Lease{}
+	name := leaseName(cluster.Name)
+	log := ctrl.LoggerFrom(ctx, "Lease", klog.KRef(cluster.Namespace, name))
+
+	if err := c.client.Get(ctx, client.ObjectKey{
+		Namespace: cluster.Namespace,
+		Name:      name,
+	}, lease); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "Failed to renew init lock")
+		}
+
+		return false
+	}
+
+	if ptr.Deref(lease.Spec.HolderIdentity, "") != machine.Name {
+		return false
+	}
+
+	return c.renew(ctx, lease, machine)
+}
+
+func (c *ControlPlaneInitMutex) renew(ctx context.Context, lease *coordinationv1.Lease, machine *clusterv1.Machine) bool {
+	log := ctrl.LoggerFrom(ctx, "Lease", klog.KObj(lease))
+
+	if machine.Status.NodeRef == nil && lease.Spec.AcquireTime != nil && time.Since(lease.Spec.AcquireTime.Time) > c.initTimeout {
+		log.Info(fmt.Sprintf("Machine %s did not get a node within %s, the init lock is no longer renewed", machine.Name, c.initTimeout))
+
+		return false
+	}
+
+	lease.Spec.RenewTime = ptr.To(metav1.NowMicro())
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(InitLockLeaseDuration.Seconds()))
+
+	if err := c.client.Update(ctx, lease); err != nil {
+		log.Error(err, "Failed to renew the init lock")
+
+		return false
+	}
+
+	return true
+}
+
 // Unlock releases the lock.
 func (c *ControlPlaneInitMutex) Unlock(ctx context.Context, cluster *clusterv1.Cluster) bool {
-	sema := newSemaphore()
-	cmName := configMapName(cluster.Name)
-	log := ctrl.LoggerFrom(ctx, "ConfigMap", klog.KRef(cluster.Namespace, cmName))
+	lease := &coordinationv1.Lease{}
+	name := leaseName(cluster.Name)
+	log := ctrl.LoggerFrom(ctx, "Lease", klog.KRef(cluster.Namespace, name))
 	err := c.client.Get(ctx, client.ObjectKey{
 		Namespace: cluster.Namespace,
-		Name:      cmName,
-	}, sema.ConfigMap)
+		Name:      name,
+	}, lease)
 
 	switch {
 	case apierrors.IsNotFound(err):
@@ -140,13 +242,13 @@ func (c *ControlPlaneInitMutex) Unlock(ctx context.Context, cluster *clusterv1.C
 
 		return false
 	default:
-		// Delete the config map semaphore if there is no error fetching it
-		if err := c.client.Delete(ctx, sema.ConfigMap); err != nil {
+		// Delete the lease if there is no error fetching it
+		if err := c.client.Delete(ctx, lease); err != nil {
 			if apierrors.IsNotFound(err) {
 				return true
 			}
 
-			log.Error(err, "Error deleting the config map underlying the control plane init lock")
+			log.Error(err, "Error deleting the lease underlying the control plane init lock")
 
 			return false
 		}
@@ -155,54 +257,82 @@ func (c *ControlPlaneInitMutex) Unlock(ctx context.Context, cluster *clusterv1.C
 	}
 }
 
-type information struct {
-	MachineName string `json:"machineName"`
-}
+// getInitLock returns the lock described by a lease, which is stale if the Machine holding it is gone or being
+// deleted, or if the Machine has no NodeRef once the init timeout recorded on the lease is reached.
+func getInitLock(ctx context.Context, c client.Client, lease *coordinationv1.Lease) (*InitLock, error) {
+	lock := &InitLock{
+		MachineName: ptr.Deref(lease.Spec.HolderIdentity, ""),
+	}
 
-type semaphore struct {
-	*corev1.ConfigMap
-}
+	if lease.Spec.AcquireTime != nil {
+		lock.AcquireTime = lease.Spec.AcquireTime.Time
+	}
 
-func newSemaphore() *semaphore {
-	return &semaphore{&corev1.ConfigMap{}}
-}
+	if lock.MachineName == "" {
+		lock.Stale = true
 
-func configMapName(clusterName string) string {
-	return fmt.Sprintf("%s-lock", clusterName)
-}
+		return lock, nil
+	}
+
+	machine := &clusterv1.Machine{}
+	err := c.Get(ctx, client.ObjectKey{
+		Namespace: lease.Namespace,
+		Name:      lock.MachineName,
+	}, machine)
 
-func (s semaphore) information() (*information, error) {
-	li := &information{}
-	if err := json.Unmarshal([]byte(s.Data[semaphoreInformationKey]), li); err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal semaphore information")
+	switch {
+	case apierrors.IsNotFound(err):
+		lock.Stale = true
+	case err != nil:
+		return nil, errors.Wrapf(err, "failed to get machine %s holding init lock", lock.MachineName)
+	default:
+		lock.Stale = !machine.DeletionTimestamp.IsZero() ||
+			(machine.Status.NodeRef == nil && time.Since(lock.AcquireTime) > initTimeout(lease))
 	}
 
-	return li, nil
+	return lock, nil
 }
 
-func (s semaphore) setInformation(information *information) error {
-	b, err := json.Marshal(information)
+// initTimeout returns the init timeout recorded on the lease, or the default one.
+func initTimeout(lease *coordinationv1.Lease) time.Duration {
+	timeout, err := time.ParseDuration(lease.Annotations[InitTimeoutAnnotation])
 	if err != nil {
-		return errors.Wrap(err, "failed to marshal semaphore information")
+		return DefaultInitTimeout
+	}
+
+	return timeout
+}
+
+func leaseName(clusterName string) string {
+	return fmt.Sprintf("%s-lock", clusterName)
+}
+
+func (c *ControlPlaneInitMutex) setHolder(lease *coordinationv1.Lease, machine *clusterv1.Machine) {
+	now := metav1.NowMicro()
+
+	if lease.Annotations == nil {
+		lease.Annotations = map[string]string{}
 	}
 
-	s.Data = map[string]string{}
-	s.Data[semaphoreInformationKey] = string(b)
+	lease.Annotations[InitTimeoutAnnotation] = c.initTimeout.String()
 
-	return nil
+	lease.Spec.HolderIdentity = ptr.To(machine.Name)
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(InitLockLeaseDuration.Seconds()))
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
 }
 
-func (s *semaphore) setMetadata(cluster *clusterv1.Cluster) {
-	s.ObjectMeta = metav1.ObjectMeta{
+func setMetadata(lease *coordinationv1.Lease, cluster *clusterv1.Cluster) {
+	lease.ObjectMeta = metav1.ObjectMeta{
 		Namespace: cluster.Namespace,
-		Name:      configMapName(cluster.Name),
+		Name:      leaseName(cluster.Name),
 		Labels: map[string]string{
 			clusterv1.ClusterNameLabel: cluster.Name,
 		},
 		OwnerReferences: []metav1.OwnerReference{
 			{
-				APIVersion: cluster.APIVersion,
-				Kind:       cluster.Kind,
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Cluster",
 				Name:       cluster.Name,
 				UID:        cluster.UID,
 			},
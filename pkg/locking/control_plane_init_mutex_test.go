@@ -21,15 +21,18 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -49,12 +52,10 @@ func TestControlPlaneInitMutex_DoubleLock(t *testing.T) {
 
 	scheme := runtime.NewScheme()
 	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
-	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(coordinationv1.AddToScheme(scheme)).To(Succeed())
 
 	client := fake.NewClientBuilder().WithScheme(scheme).Build()
-	l := &ControlPlaneInitMutex{
-		client: client,
-	}
+	l := NewControlPlaneInitMutex(client, 0)
 
 	uid := types.UID("test-uid")
 	cluster := &clusterv1.Cluster{
@@ -81,7 +82,7 @@ func TestControlPlaneInitMutex_Lock(t *testing.T) {
 
 	scheme := runtime.NewScheme()
 	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
-	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(coordinationv1.AddToScheme(scheme)).To(Succeed())
 
 	uid := types.UID("test-uid")
 	tests := []struct {
@@ -90,40 +91,38 @@ func TestControlPlaneInitMutex_Lock(t *testing.T) {
 		shouldAcquire bool
 	}{
 		{
-			name: "should successfully acquire lock if the config cannot be found",
+			name: "should successfully acquire lock if the lease cannot be found",
 			client: &fakeClient{
 				Client:   fake.NewClientBuilder().WithScheme(scheme).Build(),
-				getError: apierrors.NewNotFound(schema.GroupResource{Group: "", Resource: "configmaps"}, fmt.Sprintf("%s-controlplane", uid)),
+				getError: apierrors.NewNotFound(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, fmt.Sprintf("%s-controlplane", uid)),
 			},
 			shouldAcquire: true,
 		},
 		{
 			name: "should not acquire lock if already exits",
 			client: &fakeClient{
-				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      configMapName(clusterName),
-						Namespace: clusterNamespace,
-					},
-				}).Build(),
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+					newLease("existent-machine", time.Now()),
+					newMachine("existent-machine", false),
+				).Build(),
 			},
 			shouldAcquire: false,
 		},
 		{
-			name: "should not acquire lock if cannot create config map",
+			name: "should not acquire lock if cannot create lease",
 			client: &fakeClient{
 				Client:      fake.NewClientBuilder().WithScheme(scheme).Build(),
-				getError:    apierrors.NewNotFound(schema.GroupResource{Group: "", Resource: "configmaps"}, configMapName(clusterName)),
+				getError:    apierrors.NewNotFound(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, leaseName(clusterName)),
 				createError: errors.New("create error"),
 			},
 			shouldAcquire: false,
 		},
 		{
-			name: "should not acquire lock if config map already exists while creating",
+			name: "should not acquire lock if lease already exists while creating",
 			client: &fakeClient{
 				Client:      fake.NewClientBuilder().WithScheme(scheme).Build(),
-				getError:    apierrors.NewNotFound(schema.GroupResource{Group: "", Resource: "configmaps"}, fmt.Sprintf("%s-controlplane", uid)),
-				createError: apierrors.NewAlreadyExists(schema.GroupResource{Group: "", Resource: "configmaps"}, fmt.Sprintf("%s-controlplane", uid)),
+				getError:    apierrors.NewNotFound(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, fmt.Sprintf("%s-controlplane", uid)),
+				createError: apierrors.NewAlreadyExists(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, fmt.Sprintf("%s-controlplane", uid)),
 			},
 			shouldAcquire: false,
 		},
@@ -134,9 +133,7 @@ func TestControlPlaneInitMutex_Lock(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			gs := NewWithT(t)
 
-			l := &ControlPlaneInitMutex{
-				client: tc.client,
-			}
+			l := NewControlPlaneInitMutex(tc.client, 0)
 
 			cluster := &clusterv1.Cluster{
 				ObjectMeta: metav1.ObjectMeta{
@@ -156,56 +153,84 @@ func TestControlPlaneInitMutex_Lock(t *testing.T) {
 	}
 }
 
-func TestControlPlaneInitMutex_LockWithMachineDeletion(t *testing.T) {
+func TestControlPlaneInitMutex_LockTakeover(t *testing.T) {
 	g := NewWithT(t)
 
 	scheme := runtime.NewScheme()
 	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
-	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(coordinationv1.AddToScheme(scheme)).To(Succeed())
 
 	newMachineName := "new-machine"
+	expiredRenewTime := time.Now().Add(-2 * InitLockLeaseDuration)
+	timedOutAcquireTime := time.Now().Add(-2 * DefaultInitTimeout)
 	tests := []struct {
 		name                string
-		client              client.Client
+		objects             []client.Object
 		expectedMachineName string
 	}{
 		{
-			name: "should not give the lock to new machine if the machine that created it does exist",
-			client: &fakeClient{
-				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
-					&corev1.ConfigMap{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      configMapName(clusterName),
-							Namespace: clusterNamespace,
-						},
-						Data: map[string]string{
-							"lock-information": "{\"machineName\":\"existent-machine\"}",
-						},
-					},
-					&clusterv1.Machine{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      "existent-machine",
-							Namespace: clusterNamespace,
-						},
-					},
-				).Build(),
+			name: "should not give the lock to new machine if the machine holding it renews it",
+			objects: []client.Object{
+				newLease("existent-machine", time.Now()),
+				newMachine("existent-machine", false),
 			},
 			expectedMachineName: "existent-machine",
 		},
 		{
-			name: "should give the lock to new machine if the machine that created it does not exist",
-			client: &fakeClient{
-				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
-					&corev1.ConfigMap{
-						ObjectMeta: metav1.ObjectMeta{
-							Name:      configMapName(clusterName),
-							Namespace: clusterNamespace,
-						},
-						Data: map[string]string{
-							"lock-information": "{\"machineName\":\"non-existent-machine\"}",
-						},
-					},
-				).Build(),
+			name: "should not give the lock to new machine if the machine holding it is not renewed within the init timeout",
+			objects: []client.Object{
+				newLease("existent-machine", expiredRenewTime),
+				newMachine("existent-machine", false),
+			},
+			expectedMachineName: "existent-machine",
+		},
+		{
+			name: "should not give the lock to new machine if the machine holding it got a node",
+			objects: []client.Object{
+				newLease("existent-machine", timedOutAcquireTime),
+				newMachine("existent-machine", true),
+			},
+			expectedMachineName: "existent-machine",
+		},
+		{
+			name: "should give the lock to new machine if the machine holding it does not exist",
+			objects: []client.Object{
+				newLease("non-existent-machine", time.Now()),
+			},
+			expectedMachineName: newMachineName,
+		},
+		{
+			name: "should give the lock to new machine if the machine holding it is being deleted",
+			objects: []client.Object{
+				newLease("deleted-machine", time.Now()),
+				func() client.Object {
+					machine := newMachine("deleted-machine", false)
+					machine.DeletionTimestamp = ptr.To(metav1.Now())
+					machine.Finalizers = []string{clusterv1.MachineFinalizer}
+
+					return machine
+				}(),
+			},
+			expectedMachineName: newMachineName,
+		},
+		{
+			name: "should give the lock to new machine if the machine holding it did not get a node within the init timeout",
+			objects: []client.Object{
+				newLease("stale-machine", timedOutAcquireTime),
+				newMachine("stale-machine", false),
+			},
+			expectedMachineName: newMachineName,
+		},
+		{
+			name: "should give the lock to new machine if the machine holding it did not get a node within its init timeout",
+			objects: []client.Object{
+				func() client.Object {
+					lease := newLease("stale-machine", expiredRenewTime)
+					lease.Annotations = map[string]string{InitTimeoutAnnotation: InitLockLeaseDuration.String()}
+
+					return lease
+				}(),
+				newMachine("stale-machine", false),
 			},
 			expectedMachineName: newMachineName,
 		},
@@ -213,9 +238,10 @@ func TestControlPlaneInitMutex_LockWithMachineDeletion(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			l := &ControlPlaneInitMutex{
-				client: tc.client,
-			}
+			gs := NewWithT(t)
+
+			client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.objects...).Build()
+			l := NewControlPlaneInitMutex(client, 0)
 
 			cluster := &clusterv1.Cluster{
 				ObjectMeta: metav1.ObjectMeta{
@@ -229,31 +255,62 @@ func TestControlPlaneInitMutex_LockWithMachineDeletion(t *testing.T) {
 				},
 			}
 
-			g.Eventually(func(g Gomega) error {
-				l.Lock(ctx, cluster, machine)
+			gs.Expect(l.Lock(ctx, cluster, machine)).To(Equal(tc.expectedMachineName == newMachineName))
 
-				cm := &corev1.ConfigMap{}
-				g.Expect(tc.client.Get(ctx, client.ObjectKey{
-					Name:      configMapName(clusterName),
-					Namespace: cluster.Namespace,
-				}, cm)).To(Succeed())
+			lock, err := GetInitLock(ctx, client, cluster)
+			gs.Expect(err).ToNot(HaveOccurred())
+			gs.Expect(lock.MachineName).To(Equal(tc.expectedMachineName))
+		})
+	}
+}
 
-				info, err := semaphore{cm}.information()
-				g.Expect(err).To(BeNil())
+func TestControlPlaneInitMutex_Renew(t *testing.T) {
+	g := NewWithT(t)
 
-				g.Expect(info.MachineName).To(Equal(tc.expectedMachineName))
+	scheme := runtime.NewScheme()
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(coordinationv1.AddToScheme(scheme)).To(Succeed())
 
-				return nil
-			}, "20s").Should(Succeed())
-		})
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: clusterNamespace,
+			Name:      clusterName,
+		},
 	}
+
+	renewTime := time.Now().Add(-time.Minute)
+	holder := newMachine("holder", false)
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newLease(holder.Name, renewTime), holder).Build()
+	l := NewControlPlaneInitMutex(client, time.Hour)
+
+	renewed := func() bool {
+		lease := &coordinationv1.Lease{}
+		g.Expect(client.Get(ctx, types.NamespacedName{Namespace: clusterNamespace, Name: leaseName(clusterName)}, lease)).To(Succeed())
+
+		return lease.Spec.RenewTime.After(renewTime)
+	}
+
+	// The lock is not renewed by another machine.
+	g.Expect(l.Renew(ctx, cluster, newMachine("other", false))).To(BeFalse())
+	g.Expect(renewed()).To(BeFalse())
+
+	// The lock is renewed by the machine holding it, within the init timeout.
+	g.Expect(l.Renew(ctx, cluster, holder)).To(BeTrue())
+	g.Expect(renewed()).To(BeTrue())
+
+	// The lock is no longer renewed by the machine holding it, once the init timeout is reached without a node.
+	l = NewControlPlaneInitMutex(client, time.Nanosecond)
+	g.Expect(l.Renew(ctx, cluster, holder)).To(BeFalse())
+
+	// The lock is renewed by the machine holding it once it got a node.
+	g.Expect(l.Renew(ctx, cluster, newMachine(holder.Name, true))).To(BeTrue())
 }
 
 func TestControlPlaneInitMutex_UnLock(t *testing.T) {
 	uid := types.UID("test-uid")
-	configMap := &corev1.ConfigMap{
+	lease := &coordinationv1.Lease{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      configMapName(clusterName),
+			Name:      leaseName(clusterName),
 			Namespace: clusterNamespace,
 		},
 	}
@@ -263,38 +320,38 @@ func TestControlPlaneInitMutex_UnLock(t *testing.T) {
 		shouldRelease bool
 	}{
 		{
-			name: "should release lock by deleting config map",
+			name: "should release lock by deleting lease",
 			client: &fakeClient{
 				Client: fake.NewClientBuilder().Build(),
 			},
 			shouldRelease: true,
 		},
 		{
-			name: "should not release lock if cannot delete config map",
+			name: "should not release lock if cannot delete lease",
 			client: &fakeClient{
-				Client:      fake.NewClientBuilder().WithObjects(configMap).Build(),
+				Client:      fake.NewClientBuilder().WithObjects(lease).Build(),
 				deleteError: errors.New("delete error"),
 			},
 			shouldRelease: false,
 		},
 		{
-			name: "should release lock if config map disappears on deletion",
+			name: "should release lock if lease disappears on deletion",
 			client: &fakeClient{
-				Client:      fake.NewClientBuilder().WithObjects(configMap).Build(),
-				deleteError: apierrors.NewNotFound(schema.GroupResource{Group: "", Resource: "configmaps"}, fmt.Sprintf("%s-controlplane", uid)),
+				Client:      fake.NewClientBuilder().WithObjects(lease).Build(),
+				deleteError: apierrors.NewNotFound(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, fmt.Sprintf("%s-controlplane", uid)),
 			},
 			shouldRelease: true,
 		},
 		{
-			name: "should release lock if config map does not exist",
+			name: "should release lock if lease does not exist",
 			client: &fakeClient{
 				Client:   fake.NewClientBuilder().Build(),
-				getError: apierrors.NewNotFound(schema.GroupResource{Group: "", Resource: "configmaps"}, fmt.Sprintf("%s-controlplane", uid)),
+				getError: apierrors.NewNotFound(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, fmt.Sprintf("%s-controlplane", uid)),
 			},
 			shouldRelease: true,
 		},
 		{
-			name: "should not release lock if error while getting config map",
+			name: "should not release lock if error while getting lease",
 			client: &fakeClient{
 				Client:   fake.NewClientBuilder().Build(),
 				getError: errors.New("get error"),
@@ -308,9 +365,7 @@ func TestControlPlaneInitMutex_UnLock(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			gs := NewWithT(t)
 
-			l := &ControlPlaneInitMutex{
-				client: tc.client,
-			}
+			l := NewControlPlaneInitMutex(tc.client, 0)
 
 			cluster := &clusterv1.Cluster{
 				ObjectMeta: metav1.ObjectMeta{
@@ -325,6 +380,36 @@ func TestControlPlaneInitMutex_UnLock(t *testing.T) {
 	}
 }
 
+func newLease(holder string, acquireTime time.Time) *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      leaseName(clusterName),
+			Namespace: clusterNamespace,
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.To(holder),
+			LeaseDurationSeconds: ptr.To(int32(InitLockLeaseDuration.Seconds())),
+			AcquireTime:          &metav1.MicroTime{Time: acquireTime},
+			RenewTime:            &metav1.MicroTime{Time: acquireTime},
+		},
+	}
+}
+
+func newMachine(name string, hasNode bool) *clusterv1.Machine {
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: clusterNamespace,
+		},
+	}
+
+	if hasNode {
+		machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: name}
+	}
+
+	return machine
+}
+
 type fakeClient struct {
 	client.Client
 	getError    error
@@ -0,0 +1,170 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+// helmAPIVersion is the API version of the HelmChart and HelmChartConfig resources of the RKE2 helm controller.
+const helmAPIVersion = "helm.cattle.io/v1"
+
+// GetAddonManifests returns the manifests of the HelmChartConfigs and HelmCharts of the addons of the
+// RKE2ControlPlane, keyed by file name, with the values referenced from Secrets and ConfigMaps.
+func GetAddonManifests(ctx context.Context, cl ctrlclient.Client, rcp *controlplanev1.RKE2ControlPlane) (map[string][]byte, error) {
+	addons := rcp.Spec.ServerConfig.Addons
+	if addons == nil {
+		return nil, nil
+	}
+
+	manifests := map[string][]byte{}
+
+	for _, chart := range addons.Charts {
+		values, err := getAddonValues(ctx, cl, rcp.Namespace, chart.ValuesContent, chart.ValuesFrom)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the values of chart %s", chart.Name)
+		}
+
+		obj := newHelmObject("HelmChartConfig", chart.Name)
+		setNestedString(obj, values, "spec", "valuesContent")
+
+		if err := addManifest(manifests, "capi-helmchartconfig-"+chart.Name+".yaml", obj); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, chart := range addons.HelmCharts {
+		values, err := getAddonValues(ctx, cl, rcp.Namespace, chart.ValuesContent, chart.ValuesFrom)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the values of helm chart %s", chart.Name)
+		}
+
+		obj := newHelmObject("HelmChart", chart.Name)
+		setNestedString(obj, chart.Chart, "spec", "chart")
+		setNestedString(obj, chart.Repo, "spec", "repo")
+		setNestedString(obj, chart.Version, "spec", "version")
+		setNestedString(obj, chart.TargetNamespace, "spec", "targetNamespace")
+		setNestedString(obj, values, "spec", "valuesContent")
+
+		if chart.CreateNamespace {
+			_ = unstructured.SetNestedField(obj.Object, true, "spec", "createNamespace")
+		}
+
+		if err := addManifest(manifests, "capi-helmchart-"+chart.Name+".yaml", obj); err != nil {
+			return nil, err
+		}
+	}
+
+	return manifests, nil
+}
+
+// newHelmObject returns a resource of the RKE2 helm controller of the given kind and name, in the kube-system namespace.
+func newHelmObject(kind, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	obj.SetAPIVersion(helmAPIVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(metav1.NamespaceSystem)
+	obj.SetName(name)
+
+	return obj
+}
+
+// setNestedString sets the field of the given path of the object to the given value, unless it is empty.
+func setNestedString(obj *unstructured.Unstructured, value string, fields ...string) {
+	if value == "" {
+		return
+	}
+
+	_ = unstructured.SetNestedField(obj.Object, value, fields...)
+}
+
+// addManifest adds the YAML manifest of the given object to the manifests.
+func addManifest(manifests map[string][]byte, name string, obj *unstructured.Unstructured) error {
+	content, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal %s %s", obj.GetKind(), obj.GetName())
+	}
+
+	manifests[name] = content
+
+	return nil
+}
+
+// getAddonValues returns the values content, or the values referenced by the given source in the given namespace.
+func getAddonValues(
+	ctx context.Context,
+	cl ctrlclient.Client,
+	namespace string,
+	content string,
+	source *controlplanev1.AddonValuesSource,
+) (string, error) {
+	switch {
+	case source == nil:
+		return content, nil
+	case source.SecretKeyRef != nil:
+		ref := source.SecretKeyRef
+		key := ctrlclient.ObjectKey{Namespace: namespace, Name: ref.Name}
+
+		secret := &corev1.Secret{}
+		if err := cl.Get(ctx, key, secret); err != nil {
+			if apierrors.IsNotFound(err) && ptr.Deref(ref.Optional, false) {
+				return "", nil
+			}
+
+			return "", errors.Wrapf(err, "failed to get secret %s", key)
+		}
+
+		values, found := secret.Data[ref.Key]
+		if !found && !ptr.Deref(ref.Optional, false) {
+			return "", errors.Errorf("secret %s has no key %s", key, ref.Key)
+		}
+
+		return string(values), nil
+	case source.ConfigMapKeyRef != nil:
+		ref := source.ConfigMapKeyRef
+		key := ctrlclient.ObjectKey{Namespace: namespace, Name: ref.Name}
+
+		configMap := &corev1.ConfigMap{}
+		if err := cl.Get(ctx, key, configMap); err != nil {
+			if apierrors.IsNotFound(err) && ptr.Deref(ref.Optional, false) {
+				return "", nil
+			}
+
+			return "", errors.Wrapf(err, "failed to get config map %s", key)
+		}
+
+		values, found := configMap.Data[ref.Key]
+		if !found && !ptr.Deref(ref.Optional, false) {
+			return "", errors.Errorf("config map %s has no key %s", key, ref.Key)
+		}
+
+		return values, nil
+	default:
+		return content, nil
+	}
+}
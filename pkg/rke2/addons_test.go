@@ -0,0 +1,98 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+func TestGetAddonManifests(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	values := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cilium-values", Namespace: metav1.NamespaceDefault},
+		Data:       map[string][]byte{"values.yaml": []byte("kubeProxyReplacement: true\n")},
+	}
+	cl := fake.NewClientBuilder().WithObjects(values).Build()
+
+	rcp := &controlplanev1.RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "rcp", Namespace: metav1.NamespaceDefault},
+		Spec: controlplanev1.RKE2ControlPlaneSpec{
+			ServerConfig: controlplanev1.RKE2ServerConfig{
+				Addons: &controlplanev1.Addons{
+					Charts: []controlplanev1.ChartConfig{{
+						Name: "rke2-cilium",
+						ValuesFrom: &controlplanev1.AddonValuesSource{SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "cilium-values"},
+							Key:                  "values.yaml",
+						}},
+					}},
+					HelmCharts: []controlplanev1.HelmChart{{
+						Name:            "cert-manager",
+						Chart:           "cert-manager",
+						Repo:            "https://charts.jetstack.io",
+						TargetNamespace: "cert-manager",
+						CreateNamespace: true,
+						ValuesContent:   "crds:\n  enabled: true\n",
+					}},
+				},
+			},
+		},
+	}
+
+	manifests, err := GetAddonManifests(ctx, cl, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(manifests).To(HaveKey("capi-helmchartconfig-rke2-cilium.yaml"))
+	g.Expect(manifests).To(HaveKey("capi-helmchart-cert-manager.yaml"))
+
+	objects, err := ParseManifests(manifests)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(objects).To(HaveLen(2))
+	g.Expect(objects[0].GetKind()).To(Equal("HelmChart"))
+	g.Expect(objects[0].GetNamespace()).To(Equal(metav1.NamespaceSystem))
+	g.Expect(objects[0].Object["spec"]).To(Equal(map[string]interface{}{
+		"chart":           "cert-manager",
+		"repo":            "https://charts.jetstack.io",
+		"targetNamespace": "cert-manager",
+		"createNamespace": true,
+		"valuesContent":   "crds:\n  enabled: true\n",
+	}))
+	g.Expect(objects[1].GetKind()).To(Equal("HelmChartConfig"))
+	g.Expect(objects[1].Object["spec"]).To(Equal(map[string]interface{}{"valuesContent": "kubeProxyReplacement: true\n"}))
+
+	// A missing key fails, unless it is optional.
+	rcp.Spec.ServerConfig.Addons.Charts[0].ValuesFrom.SecretKeyRef.Key = "missing"
+
+	_, err = GetAddonManifests(ctx, cl, rcp)
+	g.Expect(err).To(HaveOccurred())
+
+	rcp.Spec.ServerConfig.Addons.Charts[0].ValuesFrom.SecretKeyRef.Optional = ptr.To(true)
+
+	manifests, err = GetAddonManifests(ctx, cl, rcp)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(manifests).To(HaveLen(2))
+}
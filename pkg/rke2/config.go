@@ -18,9 +18,11 @@ package rke2
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"strings"
 
+	"gopkg.in/yaml.v3"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -140,6 +142,7 @@ type ServerConfig struct {
 	AirgapExtraRegistry       string `yaml:"airgap-extra-registry,omitempty"`
 	DisableAPIserver          bool   `yaml:"disable-apiserver,omitempty"`
 	DisableControllerManager  bool   `yaml:"disable-controller-manager,omitempty"`
+	DisableEtcd               bool   `yaml:"disable-etcd,omitempty"`
 	EgressSelectorMode        string `yaml:"egress-selector-mode,omitempty"`
 	EnablePprof               bool   `yaml:"enable-pprof,omitempty"`
 	EnableServiceLoadBalancer bool   `yaml:"enable-servicelb,omitempty"`
@@ -158,6 +161,7 @@ type ServerConfigOpts struct {
 	Token                string
 	ServerURL            string
 	ServerConfig         controlplanev1.RKE2ServerConfig
+	ServerRole           controlplanev1.ServerRole
 	AgentConfig          bootstrapv1.RKE2AgentConfig
 	Ctx                  context.Context
 	Client               client.Client
@@ -260,6 +264,16 @@ func newRKE2ServerConfig(opts ServerConfigOpts) (*ServerConfig, []bootstrapv1.Fi
 		}
 	}
 
+	// Machines of a server pool only run the components of their role.
+	switch opts.ServerRole {
+	case controlplanev1.ServerRoleEtcd:
+		rke2ServerConfig.DisableAPIserver = true
+		rke2ServerConfig.DisableControllerManager = true
+		rke2ServerConfig.DisableScheduler = true
+	case controlplanev1.ServerRoleControlPlane:
+		rke2ServerConfig.DisableEtcd = true
+	}
+
 	rke2ServerConfig.EtcdDisableSnapshots = opts.ServerConfig.Etcd.BackupConfig.DisableAutomaticSnapshots
 	rke2ServerConfig.EtcdExposeMetrics = opts.ServerConfig.Etcd.ExposeMetrics
 
@@ -368,6 +382,52 @@ func newRKE2ServerConfig(opts ServerConfigOpts) (*ServerConfig, []bootstrapv1.Fi
 	return rke2ServerConfig, files, nil
 }
 
+// ServerConfigFromBootstrapData returns the RKE2 server configuration written by cloud-init bootstrap data, or nil
+// if the bootstrap data doesn't write it, e.g. when it is an Ignition configuration.
+func ServerConfigFromBootstrapData(data []byte) (*ServerConfig, error) {
+	cloudConfig := struct {
+		WriteFiles []struct {
+			Path     string `yaml:"path"`
+			Encoding string `yaml:"encoding"`
+			Content  string `yaml:"content"`
+		} `yaml:"write_files"`
+	}{}
+
+	if err := yaml.Unmarshal(data, &cloudConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse bootstrap data: %w", err)
+	}
+
+	for _, file := range cloudConfig.WriteFiles {
+		if file.Path != DefaultRKE2ConfigLocation {
+			continue
+		}
+
+		content := []byte(file.Content)
+
+		switch file.Encoding {
+		case "":
+		case "b64", "base64":
+			decoded, err := base64.StdEncoding.DecodeString(file.Content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", DefaultRKE2ConfigLocation, err)
+			}
+
+			content = decoded
+		default:
+			return nil, nil //nolint:nilnil
+		}
+
+		serverConfig := &ServerConfig{}
+		if err := yaml.Unmarshal(content, serverConfig); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", DefaultRKE2ConfigLocation, err)
+		}
+
+		return serverConfig, nil
+	}
+
+	return nil, nil //nolint:nilnil
+}
+
 type rke2AgentConfig struct {
 	ContainerRuntimeEndpoint       string   `yaml:"container-runtime-endpoint,omitempty"`
 	CloudProviderConfig            string   `yaml:"cloud-provider-config,omitempty"`
@@ -599,6 +659,12 @@ func GenerateJoinControlPlaneConfig(opts ServerConfigOpts) (*ServerConfig, []boo
 		return nil, nil, fmt.Errorf("failed to generate rke2 agent config: %w", err)
 	}
 
+	// The role of the machines of a server pool is set on their node, to tell the nodes running etcd apart.
+	if opts.ServerRole != "" {
+		rke2AgentConfig.NodeLabels = append(append([]string{}, rke2AgentConfig.NodeLabels...),
+			fmt.Sprintf("%s=%s", controlplanev1.ServerRoleLabel, opts.ServerRole))
+	}
+
 	rke2ServerConfig.rke2AgentConfig = *rke2AgentConfig
 
 	return rke2ServerConfig, append(serverFiles, agentFiles...), nil
@@ -237,6 +237,23 @@ var _ = Describe("RKE2ServerConfig", func() {
 		Expect(files[2].Owner).To(Equal(consts.DefaultFileOwner))
 		Expect(files[2].Permissions).To(Equal("0640"))
 	})
+
+	It("should only run the components of the server role", func() {
+		opts.ServerRole = controlplanev1.ServerRoleEtcd
+		rke2ServerConfig, _, err := newRKE2ServerConfig(*opts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rke2ServerConfig.DisableAPIserver).To(BeTrue())
+		Expect(rke2ServerConfig.DisableControllerManager).To(BeTrue())
+		Expect(rke2ServerConfig.DisableScheduler).To(BeTrue())
+		Expect(rke2ServerConfig.DisableEtcd).To(BeFalse())
+
+		opts.ServerRole = controlplanev1.ServerRoleControlPlane
+		rke2ServerConfig, _, err = newRKE2ServerConfig(*opts)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rke2ServerConfig.DisableAPIserver).To(BeFalse())
+		Expect(rke2ServerConfig.DisableControllerManager).To(BeFalse())
+		Expect(rke2ServerConfig.DisableEtcd).To(BeTrue())
+	})
 })
 
 var _ = Describe("RKE2 Agent Config", func() {
@@ -416,3 +433,42 @@ var _ = Describe("componentMapToSlice", func() {
 		Expect(componentMapToSlice(extraMount, input)).To(Equal(expected))
 	})
 })
+
+var _ = Describe("ServerConfigFromBootstrapData", func() {
+	It("should read the server config written by cloud-init", func() {
+		data := []byte(`## template: jinja
+#cloud-config
+write_files:
+-   path: /etc/rancher/rke2/registries.yaml
+    content: |
+      mirrors: {}
+-   path: /etc/rancher/rke2/config.yaml
+    owner: root:root
+    permissions: '0640'
+    content: |
+      cni:
+      - multus
+      - cilium
+      cluster-domain: example.local
+      disable:
+      - rke2-ingress-nginx
+      disable-kube-proxy: true
+      cloud-provider-name: external
+`)
+
+		serverConfig, err := ServerConfigFromBootstrapData(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(serverConfig).ToNot(BeNil())
+		Expect(serverConfig.CNI).To(Equal([]string{"multus", "cilium"}))
+		Expect(serverConfig.ClusterDomain).To(Equal("example.local"))
+		Expect(serverConfig.DisableComponents).To(Equal([]string{"rke2-ingress-nginx"}))
+		Expect(serverConfig.DisableKubeProxy).To(BeTrue())
+		Expect(serverConfig.CloudProviderName).To(Equal("external"))
+	})
+
+	It("should return nil if the bootstrap data doesn't write the server config", func() {
+		serverConfig, err := ServerConfigFromBootstrapData([]byte(`{"ignition":{"version":"3.1.0"}}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(serverConfig).To(BeNil())
+	})
+})
@@ -18,7 +18,11 @@ package rke2
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -29,11 +33,13 @@ import (
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apiserver/pkg/storage/names"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/controllers/external"
 	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	capifd "sigs.k8s.io/cluster-api/util/failuredomains"
 	"sigs.k8s.io/cluster-api/util/patch"
 
@@ -137,6 +143,78 @@ func (c *ControlPlane) AsOwnerReference() *metav1.OwnerReference {
 	}
 }
 
+// ServerPool is a group of control plane machines running the same server role. The Replicas machines of the
+// RKE2ControlPlane, running all the server roles, form the server pool with an empty role.
+type ServerPool struct {
+	// Role is the server role run by the machines of the pool, empty if they run all the server roles.
+	Role controlplanev1.ServerRole
+
+	// Replicas is the desired number of machines of the pool.
+	Replicas int32
+
+	// InfrastructureRef is the infrastructure template used to create the machines of the pool.
+	InfrastructureRef *corev1.ObjectReference
+
+	// Machines are the machines of the pool.
+	Machines collections.Machines
+}
+
+// serverRoles is the order the server pools are scaled and rolled out in: the machines running etcd are rolled out
+// before the machines running the kube-apiserver only.
+var serverRoles = []controlplanev1.ServerRole{"", controlplanev1.ServerRoleEtcd, controlplanev1.ServerRoleControlPlane}
+
+// ServerPools returns the server pools of the control plane, starting with the machines running all the server roles.
+// Server pools removed from the RKE2ControlPlane are returned with no desired replicas while they still have machines.
+func (c *ControlPlane) ServerPools() []*ServerPool {
+	pools := []*ServerPool{}
+
+	for _, role := range serverRoles {
+		pool := &ServerPool{
+			Role:     role,
+			Machines: c.Machines.Filter(HasServerRole(role)),
+		}
+
+		switch spec := serverPoolSpec(c.RCP, role); {
+		case role == "":
+			pool.Replicas = ptr.Deref(c.RCP.Spec.Replicas, 0)
+			pool.InfrastructureRef = c.InfrastructureRef()
+		case spec != nil:
+			pool.Replicas = ptr.Deref(spec.Replicas, 1)
+			pool.InfrastructureRef = &spec.InfrastructureRef
+		case pool.Machines.Len() == 0:
+			continue
+		}
+
+		pools = append(pools, pool)
+	}
+
+	return pools
+}
+
+// EtcdReplicas returns the desired number of etcd members of the control plane.
+func (c *ControlPlane) EtcdReplicas() int32 {
+	replicas := int32(0)
+
+	for _, pool := range c.ServerPools() {
+		if pool.Role != controlplanev1.ServerRoleControlPlane {
+			replicas += pool.Replicas
+		}
+	}
+
+	return replicas
+}
+
+// DesiredReplicas returns the desired number of machines of the control plane, across all the server pools.
+func (c *ControlPlane) DesiredReplicas() int32 {
+	replicas := int32(0)
+
+	for _, pool := range c.ServerPools() {
+		replicas += pool.Replicas
+	}
+
+	return replicas
+}
+
 // MachineInFailureDomainWithMostMachines returns the first matching failure domain with machines that has the most control-plane machines on it.
 func (c *ControlPlane) MachineInFailureDomainWithMostMachines(ctx context.Context, machines collections.Machines) (*clusterv1.Machine, error) {
 	fd := c.FailureDomainWithMostMachines(ctx, machines)
@@ -172,16 +250,24 @@ func (c *ControlPlane) FailureDomainWithMostMachines(ctx context.Context, machin
 		return notInFailureDomains.Oldest().Spec.FailureDomain
 	}
 
-	return capifd.PickMost(ctx, c.Cluster.Status.FailureDomains.FilterControlPlane(), c.Machines, machines)
+	// Machines are spread across the failure domains within their server pool.
+	poolMachines := c.Machines
+	if machine := machines.Oldest(); machine != nil {
+		poolMachines = c.Machines.Filter(HasServerRole(ServerRoleOf(machine)))
+	}
+
+	return capifd.PickMost(ctx, c.Cluster.Status.FailureDomains.FilterControlPlane(), poolMachines, machines)
 }
 
-// NextFailureDomainForScaleUp returns the failure domain with the fewest number of up-to-date machines.
-func (c *ControlPlane) NextFailureDomainForScaleUp(ctx context.Context) *string {
+// NextFailureDomainForScaleUp returns the failure domain with the fewest number of up-to-date machines of the server pool.
+func (c *ControlPlane) NextFailureDomainForScaleUp(ctx context.Context, pool *ServerPool) *string {
 	if len(c.Cluster.Status.FailureDomains.FilterControlPlane()) == 0 {
 		return nil
 	}
 
-	return capifd.PickFewest(ctx, c.FailureDomains().FilterControlPlane(), c.Machines, c.UpToDateMachines())
+	upToDateMachines := c.UpToDateMachines().Filter(HasServerRole(pool.Role))
+
+	return capifd.PickFewest(ctx, c.FailureDomains().FilterControlPlane(), pool.Machines, upToDateMachines)
 }
 
 // InitialControlPlaneConfig returns a new RKE2ConfigSpec that is to be used for an initializing control plane.
@@ -295,13 +381,96 @@ func (c *ControlPlane) MachinesNeedingRollout() collections.Machines {
 	return machines.AnyFilter(
 		// Machines that do not match with RCP config.
 		collections.Not(matchesRCPConfiguration(c.infraResources, c.rke2Configs, c.RCP)),
+		// Machines that do not use the certificate authorities of the current rotation phase.
+		needsCertificateAuthorityRotation(c.RCP),
+		// Machines whose certificates expire soon.
+		shouldRolloutBefore(&c.reconciliationTime, c.RCP.Spec.RolloutBefore),
+		// Machines created before the RolloutAfter time, once it is reached.
+		shouldRolloutAfter(&c.reconciliationTime, c.RCP.Spec.RolloutAfter),
+	).Difference(c.MachinesNeedingInPlaceUpgrade())
+}
+
+// MachinesNeedingInPlaceUpgrade returns a list of machines that only need their RKE2 version to be upgraded,
+// and that can be upgraded in place. This list is always empty if the InPlaceUpgrade rollout strategy is not used.
+func (c *ControlPlane) MachinesNeedingInPlaceUpgrade() collections.Machines {
+	if !c.InPlaceUpgradeEnabled() {
+		return collections.New()
+	}
+
+	desiredVersion := c.RCP.GetDesiredVersion()
+
+	return c.Machines.Filter(
+		collections.Not(collections.HasDeletionTimestamp),
+		collections.Not(matchesKubernetesOrRKE2Version(desiredVersion)),
+		matchesRCPConfigurationIgnoringVersion(c.infraResources, c.rke2Configs, c.RCP),
+		canBeUpgradedInPlace(desiredVersion),
 	)
 }
 
+// InPlaceUpgradeEnabled returns true if the control plane uses the InPlaceUpgrade rollout strategy.
+func (c *ControlPlane) InPlaceUpgradeEnabled() bool {
+	return c.RCP.Spec.RolloutStrategy != nil && c.RCP.Spec.RolloutStrategy.Type == controlplanev1.InPlaceUpgradeStrategyType
+}
+
 // UpToDateMachines returns the machines that are up to date with the control
 // plane's configuration and therefore do not require rollout.
 func (c *ControlPlane) UpToDateMachines() collections.Machines {
-	return c.Machines.Difference(c.MachinesNeedingRollout())
+	return c.Machines.Difference(c.MachinesNeedingRollout()).Difference(c.MachinesNeedingInPlaceUpgrade())
+}
+
+// MachineRolloutReasons returns the reasons why the machine must be rolled out or upgraded in place, e.g. the version or
+// the fields of the configuration which changed, or nothing if the machine is up to date.
+func (c *ControlPlane) MachineRolloutReasons(machine *clusterv1.Machine) []string {
+	reasons := machineSpecDiff(c.infraResources, c.rke2Configs, c.RCP, machine)
+
+	if needsCertificateAuthorityRotation(c.RCP)(machine) {
+		reasons = append(reasons, fmt.Sprintf("certificate authorities rotation phase %s", c.RCP.Status.CertificateAuthorityRotation.Phase))
+	}
+
+	if shouldRolloutBefore(&c.reconciliationTime, c.RCP.Spec.RolloutBefore)(machine) {
+		reasons = append(reasons, fmt.Sprintf("certificates expire on %s", certificatesExpiryDate(machine).Format(time.RFC3339)))
+	}
+
+	if shouldRolloutAfter(&c.reconciliationTime, c.RCP.Spec.RolloutAfter)(machine) {
+		reasons = append(reasons, fmt.Sprintf("rolloutAfter %s reached", c.RCP.Spec.RolloutAfter.Format(time.RFC3339)))
+	}
+
+	return reasons
+}
+
+// RolloutReasons returns the distinct reasons why the given machines must be rolled out or upgraded in place,
+// in the order of the machine names.
+func (c *ControlPlane) RolloutReasons(machines collections.Machines) []string {
+	names := machines.Names()
+	sort.Strings(names)
+
+	reasons := []string{}
+
+	for _, name := range names {
+		for _, reason := range c.MachineRolloutReasons(machines[name]) {
+			if !slices.Contains(reasons, reason) {
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+
+	return reasons
+}
+
+// UpdateMachineSpecConditions sets the MachineSpecUpToDate condition of the machines, reporting the reasons why they
+// must be rolled out or upgraded in place.
+func (c *ControlPlane) UpdateMachineSpecConditions() {
+	for _, machine := range c.Machines {
+		reasons := c.MachineRolloutReasons(machine)
+		if len(reasons) == 0 {
+			conditions.MarkTrue(machine, controlplanev1.MachineSpecUpToDateCondition)
+
+			continue
+		}
+
+		conditions.MarkFalse(machine, controlplanev1.MachineSpecUpToDateCondition, controlplanev1.MachineSpecOutdatedReason,
+			clusterv1.ConditionSeverityInfo, "%s", strings.Join(reasons, ", "))
+	}
 }
 
 // getInfraResources fetches the external infrastructure resource for each machine in the collection
@@ -370,6 +539,16 @@ func (c *ControlPlane) HasUnhealthyMachine() bool {
 	return len(c.UnhealthyMachines()) > 0
 }
 
+// MachinesToBeRemediatedByRCP returns the list of control plane machines to be remediated by RCP.
+func (c *ControlPlane) MachinesToBeRemediatedByRCP() collections.Machines {
+	return c.Machines.Filter(collections.IsUnhealthyAndOwnerRemediated)
+}
+
+// HasHealthyMachineStillProvisioning returns true if any healthy machine in the control plane is still in the process of being provisioned.
+func (c *ControlPlane) HasHealthyMachineStillProvisioning() bool {
+	return len(c.HealthyMachines().Filter(collections.Not(collections.HasNode()))) > 0
+}
+
 // PatchMachines patches the machines in the control plane.
 func (c *ControlPlane) PatchMachines(ctx context.Context) error {
 	errList := []error{}
@@ -381,6 +560,7 @@ func (c *ControlPlane) PatchMachines(ctx context.Context) error {
 				controlplanev1.MachineAgentHealthyCondition,
 				controlplanev1.MachineEtcdMemberHealthyCondition,
 				controlplanev1.NodeMetadataUpToDate,
+				controlplanev1.MachineSpecUpToDateCondition,
 			}}); err != nil {
 				if machine.Status.NodeRef != nil {
 					_ = machine.Status.NodeRef.Name
@@ -0,0 +1,203 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+func serverPoolMachine(name string, role controlplanev1.ServerRole) *clusterv1.Machine {
+	machine := &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}}}
+	if role != "" {
+		machine.Labels[controlplanev1.ServerRoleLabel] = string(role)
+	}
+
+	return machine
+}
+
+func TestServerPools(t *testing.T) {
+	g := NewWithT(t)
+
+	etcdTemplate := corev1.ObjectReference{
+		APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
+		Kind:       "GenericInfrastructureMachineTemplate",
+		Name:       "etcd",
+	}
+
+	rcp := &controlplanev1.RKE2ControlPlane{
+		Spec: controlplanev1.RKE2ControlPlaneSpec{
+			Replicas: ptr.To[int32](1),
+			MachineTemplate: controlplanev1.RKE2ControlPlaneMachineTemplate{
+				InfrastructureRef: corev1.ObjectReference{Name: "server"},
+			},
+			ServerPools: []controlplanev1.ServerPool{
+				{Role: controlplanev1.ServerRoleEtcd, Replicas: ptr.To[int32](3), InfrastructureRef: etcdTemplate},
+			},
+		},
+	}
+
+	controlPlane := &ControlPlane{
+		RCP: rcp,
+		Machines: collections.FromMachines(
+			serverPoolMachine("server", ""),
+			serverPoolMachine("etcd-0", controlplanev1.ServerRoleEtcd),
+			serverPoolMachine("control-plane-0", controlplanev1.ServerRoleControlPlane),
+		),
+	}
+
+	pools := controlPlane.ServerPools()
+	g.Expect(pools).To(HaveLen(3))
+
+	g.Expect(pools[0].Role).To(BeEmpty())
+	g.Expect(pools[0].Replicas).To(Equal(int32(1)))
+	g.Expect(pools[0].InfrastructureRef.Name).To(Equal("server"))
+	g.Expect(pools[0].Machines.Names()).To(ConsistOf("server"))
+
+	g.Expect(pools[1].Role).To(Equal(controlplanev1.ServerRoleEtcd))
+	g.Expect(pools[1].Replicas).To(Equal(int32(3)))
+	g.Expect(pools[1].InfrastructureRef.Name).To(Equal("etcd"))
+	g.Expect(pools[1].Machines.Names()).To(ConsistOf("etcd-0"))
+
+	// The control-plane server pool was removed, its machines are scaled down.
+	g.Expect(pools[2].Role).To(Equal(controlplanev1.ServerRoleControlPlane))
+	g.Expect(pools[2].Replicas).To(BeZero())
+	g.Expect(pools[2].Machines.Names()).To(ConsistOf("control-plane-0"))
+
+	g.Expect(controlPlane.EtcdReplicas()).To(Equal(int32(4)))
+	g.Expect(controlPlane.DesiredReplicas()).To(Equal(int32(4)))
+
+	delete(controlPlane.Machines, "control-plane-0")
+	g.Expect(controlPlane.ServerPools()).To(HaveLen(2))
+
+	// Machines of a server pool are rolled out when they are not created from the template of their pool.
+	clonedFrom := func(name string) *unstructured.Unstructured {
+		infraMachine := &unstructured.Unstructured{}
+		infraMachine.SetAnnotations(map[string]string{
+			clusterv1.TemplateClonedFromNameAnnotation:      name,
+			clusterv1.TemplateClonedFromGroupKindAnnotation: etcdTemplate.GroupVersionKind().GroupKind().String(),
+		})
+
+		return infraMachine
+	}
+
+	etcdMachine := serverPoolMachine("etcd-0", controlplanev1.ServerRoleEtcd)
+
+	g.Expect(matchesTemplateClonedFrom(map[string]*unstructured.Unstructured{"etcd-0": clonedFrom("etcd")}, rcp)(etcdMachine)).To(BeTrue())
+	g.Expect(matchesTemplateClonedFrom(map[string]*unstructured.Unstructured{"etcd-0": clonedFrom("server")}, rcp)(etcdMachine)).To(BeFalse())
+}
+
+func TestMachineRolloutReasons(t *testing.T) {
+	g := NewWithT(t)
+
+	rcp := &controlplanev1.RKE2ControlPlane{
+		Spec: controlplanev1.RKE2ControlPlaneSpec{
+			Version: "v1.31.1+rke2r1",
+			ServerConfig: controlplanev1.RKE2ServerConfig{
+				TLSSan: []string{"example.com"},
+			},
+			RKE2ConfigSpec: bootstrapv1.RKE2ConfigSpec{
+				AgentConfig: bootstrapv1.RKE2AgentConfig{NodeLabels: []string{"hello=world"}},
+			},
+		},
+	}
+
+	outdated := serverPoolMachine("outdated", "")
+	outdated.Spec.Version = ptr.To("v1.30.2+rke2r1")
+	outdated.Spec.Bootstrap.ConfigRef = &corev1.ObjectReference{Name: "outdated"}
+	outdated.Annotations = map[string]string{controlplanev1.RKE2ServerConfigurationAnnotation: "{}"}
+
+	upToDate := serverPoolMachine("up-to-date", "")
+	upToDate.Spec.Version = ptr.To("v1.31.1+rke2r1")
+	upToDate.Annotations = map[string]string{controlplanev1.RKE2ServerConfigurationAnnotation: `{"tlsSan":["example.com"]}`}
+
+	controlPlane := &ControlPlane{
+		RCP:      rcp,
+		Machines: collections.FromMachines(outdated, upToDate),
+		rke2Configs: map[string]*bootstrapv1.RKE2Config{
+			"outdated": {Spec: bootstrapv1.RKE2ConfigSpec{
+				AgentConfig: bootstrapv1.RKE2AgentConfig{NodeLabels: []string{"hello=world"}},
+			}},
+		},
+	}
+
+	g.Expect(controlPlane.MachineRolloutReasons(outdated)).To(Equal([]string{
+		"version v1.30.2+rke2r1 -> v1.31.1+rke2r1",
+		"serverConfig.tlsSan changed",
+	}))
+	g.Expect(controlPlane.MachineRolloutReasons(upToDate)).To(BeEmpty())
+
+	// Once the server configuration is up to date, the changes of the RKE2 configuration are reported.
+	outdated.Annotations[controlplanev1.RKE2ServerConfigurationAnnotation] = `{"tlsSan":["example.com"]}`
+	controlPlane.rke2Configs["outdated"].Spec.AgentConfig.NodeLabels = nil
+	controlPlane.rke2Configs["outdated"].Spec.PreRKE2Commands = []string{"echo"}
+
+	g.Expect(controlPlane.RolloutReasons(controlPlane.Machines)).To(Equal([]string{
+		"version v1.30.2+rke2r1 -> v1.31.1+rke2r1",
+		"preRKE2Commands changed",
+		"agentConfig.nodeLabels changed",
+	}))
+
+	controlPlane.UpdateMachineSpecConditions()
+	g.Expect(conditions.IsTrue(upToDate, controlplanev1.MachineSpecUpToDateCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(outdated, controlplanev1.MachineSpecUpToDateCondition)).
+		To(Equal(controlplanev1.MachineSpecOutdatedReason))
+	g.Expect(conditions.GetMessage(outdated, controlplanev1.MachineSpecUpToDateCondition)).
+		To(Equal("version v1.30.2+rke2r1 -> v1.31.1+rke2r1, preRKE2Commands changed, agentConfig.nodeLabels changed"))
+}
+
+func TestMachinesNeedingInPlaceUpgrade(t *testing.T) {
+	g := NewWithT(t)
+
+	rcp := &controlplanev1.RKE2ControlPlane{
+		Spec: controlplanev1.RKE2ControlPlaneSpec{
+			Version:         "v1.31.1+rke2r1",
+			RolloutStrategy: &controlplanev1.RolloutStrategy{Type: controlplanev1.InPlaceUpgradeStrategyType},
+			ServerPools: []controlplanev1.ServerPool{
+				{Role: controlplanev1.ServerRoleEtcd, Replicas: ptr.To[int32](1)},
+				{Role: controlplanev1.ServerRoleControlPlane, Replicas: ptr.To[int32](1)},
+			},
+		},
+	}
+
+	controlPlane := &ControlPlane{RCP: rcp, Machines: collections.New()}
+
+	for _, machine := range []*clusterv1.Machine{
+		serverPoolMachine("server", ""),
+		serverPoolMachine("etcd-0", controlplanev1.ServerRoleEtcd),
+		serverPoolMachine("control-plane-0", controlplanev1.ServerRoleControlPlane),
+	} {
+		machine.Spec.Version = ptr.To("v1.30.2+rke2r1")
+		controlPlane.Machines.Insert(machine)
+	}
+
+	// The machines of every server pool are upgraded in place, the etcd only nodes having their own upgrade plan.
+	g.Expect(controlPlane.MachinesNeedingInPlaceUpgrade().Names()).To(ConsistOf("server", "etcd-0", "control-plane-0"))
+	g.Expect(controlPlane.MachinesNeedingRollout()).To(BeEmpty())
+}
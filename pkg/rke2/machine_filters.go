@@ -2,9 +2,15 @@ package rke2
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"strings"
+	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/utils/ptr"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/collections"
@@ -28,98 +34,144 @@ func matchesRCPConfiguration(
 	)
 }
 
-// matchesRKE2BootstrapConfig checks if machine's RKE2ConfigSpec is equivalent with RCP's RKE2ConfigSpec.
-func matchesRKE2BootstrapConfig(machineConfigs map[string]*bootstrapv1.RKE2Config, rcp *controlplanev1.RKE2ControlPlane) collections.Func {
+// matchesRCPConfigurationIgnoringVersion returns a filter to find all machines that matches with RCP config,
+// except for the Kubernetes version.
+func matchesRCPConfigurationIgnoringVersion(
+	infraConfigs map[string]*unstructured.Unstructured,
+	machineConfigs map[string]*bootstrapv1.RKE2Config,
+	rcp *controlplanev1.RKE2ControlPlane,
+) func(machine *clusterv1.Machine) bool {
+	return collections.And(
+		matchesRKE2BootstrapConfig(machineConfigs, rcp),
+		matchesTemplateClonedFrom(infraConfigs, rcp),
+	)
+}
+
+// canBeUpgradedInPlace returns a filter to find all machines running a RKE2 version which can be upgraded
+// in place to the given version; downgrades are not supported.
+func canBeUpgradedInPlace(rke2Version string) func(*clusterv1.Machine) bool {
 	return func(machine *clusterv1.Machine) bool {
-		if machine == nil {
-			return true
+		if machine == nil || machine.Spec.Version == nil {
+			return false
 		}
 
-		// Check if RCP and machine RKE2Config matches, if not return
-		if match := matchServerConfig(rcp, machine); !match {
+		machineVersion, err := version.ParseSemantic(*machine.Spec.Version)
+		if err != nil {
 			return false
 		}
 
-		bootstrapRef := machine.Spec.Bootstrap.ConfigRef
-		if bootstrapRef == nil {
-			// Missing bootstrap reference should not be considered as unmatching.
-			// This is a safety precaution to avoid selecting machines that are broken, which in the future should be remediated separately.
-			return true
+		desiredVersion, err := version.ParseSemantic(rke2Version)
+		if err != nil {
+			return false
 		}
 
-		machineConfig, found := machineConfigs[machine.Name]
-		if !found {
-			// Return true here because failing to get KubeadmConfig should not be considered as unmatching.
-			// This is a safety precaution to avoid rolling out machines if the client or the api-server is misbehaving.
+		return desiredVersion.AtLeast(machineVersion)
+	}
+}
+
+// matchesRKE2BootstrapConfig checks if machine's RKE2ConfigSpec is equivalent with RCP's RKE2ConfigSpec.
+func matchesRKE2BootstrapConfig(machineConfigs map[string]*bootstrapv1.RKE2Config, rcp *controlplanev1.RKE2ControlPlane) collections.Func {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil {
 			return true
 		}
 
-		if _, ok := machineConfig.Annotations["cluster-api.cattle.io/turtles-system-agent"]; ok {
-			files := []bootstrapv1.File{}
+		return len(rke2BootstrapConfigDiff(machineConfigs, rcp, machine)) == 0
+	}
+}
+
+// rke2BootstrapConfigDiff returns the fields of the server configuration and of the RKE2ConfigSpec of the machine
+// which differ from the ones of the RCP.
+func rke2BootstrapConfigDiff(
+	machineConfigs map[string]*bootstrapv1.RKE2Config,
+	rcp *controlplanev1.RKE2ControlPlane,
+	machine *clusterv1.Machine,
+) []string {
+	// Check if RCP and machine RKE2Config matches, if not return
+	if diff := serverConfigDiff(rcp, machine); len(diff) > 0 {
+		return diff
+	}
 
-			for _, file := range machineConfig.Spec.Files {
-				switch file.Path {
-				case "/etc/rancher/agent/connect-info-config.json", "/opt/system-agent-install.sh",
-					"/etc/rancher/agent/config.yaml": // Filter out files that are injected by the Rancher Turtles webhook
-					continue
-				}
+	bootstrapRef := machine.Spec.Bootstrap.ConfigRef
+	if bootstrapRef == nil {
+		// Missing bootstrap reference should not be considered as unmatching.
+		// This is a safety precaution to avoid selecting machines that are broken, which in the future should be remediated separately.
+		return nil
+	}
 
-				files = append(files, file)
-			}
+	machineConfig, found := machineConfigs[machine.Name]
+	if !found {
+		// Failing to get RKE2Config should not be considered as unmatching.
+		// This is a safety precaution to avoid rolling out machines if the client or the api-server is misbehaving.
+		return nil
+	}
+
+	if _, ok := machineConfig.Annotations["cluster-api.cattle.io/turtles-system-agent"]; ok {
+		files := []bootstrapv1.File{}
 
-			if len(files) == 0 {
-				machineConfig.Spec.Files = nil // Set to nil because rcp.Spec.RKE2ConfigSpec.Files will be nil if no files are present
-			} else {
-				machineConfig.Spec.Files = files
+		for _, file := range machineConfig.Spec.Files {
+			switch file.Path {
+			case "/etc/rancher/agent/connect-info-config.json", "/opt/system-agent-install.sh",
+				"/etc/rancher/agent/config.yaml": // Filter out files that are injected by the Rancher Turtles webhook
+				continue
 			}
 
-			cmds := []string{}
+			files = append(files, file)
+		}
 
-			for _, cmd := range machineConfig.Spec.PostRKE2Commands { // Filter out commands that are injected by the Rancher Turtles webhook
-				if cmd == "sh /opt/system-agent-install.sh" {
-					continue
-				}
+		if len(files) == 0 {
+			machineConfig.Spec.Files = nil // Set to nil because rcp.Spec.RKE2ConfigSpec.Files will be nil if no files are present
+		} else {
+			machineConfig.Spec.Files = files
+		}
 
-				cmds = append(cmds, cmd)
-			}
+		cmds := []string{}
 
-			if len(cmds) == 0 {
-				machineConfig.Spec.PostRKE2Commands = nil // Set to nil because rcp.Spec.RKE2ConfigSpec.PostRKE2Commands will be nil if no commands are present
-			} else {
-				machineConfig.Spec.PostRKE2Commands = cmds
+		for _, cmd := range machineConfig.Spec.PostRKE2Commands { // Filter out commands that are injected by the Rancher Turtles webhook
+			if cmd == "sh /opt/system-agent-install.sh" {
+				continue
 			}
+
+			cmds = append(cmds, cmd)
 		}
 
-		// Check if RCP AgentConfig and machineBootstrapConfig matches
-		return reflect.DeepEqual(machineConfig.Spec, rcp.Spec.RKE2ConfigSpec)
+		if len(cmds) == 0 {
+			machineConfig.Spec.PostRKE2Commands = nil // Set to nil because rcp.Spec.RKE2ConfigSpec.PostRKE2Commands will be nil if no commands are present
+		} else {
+			machineConfig.Spec.PostRKE2Commands = cmds
+		}
 	}
+
+	// Check if RCP AgentConfig and machineBootstrapConfig matches
+	return fieldsDiff("", reflect.ValueOf(machineConfig.Spec), reflect.ValueOf(rcp.Spec.RKE2ConfigSpec))
 }
 
 // matchServerConfig checks if RKE2Configs in the ControlPlane object and the machine annotation match.
 func matchServerConfig(rcp *controlplanev1.RKE2ControlPlane, machine *clusterv1.Machine) bool {
+	return len(serverConfigDiff(rcp, machine)) == 0
+}
+
+// serverConfigDiff returns the fields of the server configuration recorded in the machine annotation which differ from
+// the ones of the RCP.
+func serverConfigDiff(rcp *controlplanev1.RKE2ControlPlane, machine *clusterv1.Machine) []string {
 	machineServerConfigStr, ok := machine.GetAnnotations()[controlplanev1.RKE2ServerConfigurationAnnotation]
 	if !ok {
 		// We don't have enough information to make a decision; don't' trigger a roll out.
-		return true
+		return nil
 	}
 
 	machineServerConfig := &controlplanev1.RKE2ServerConfig{}
 	// RKE2ServerConfig annotation is not correct, need to rollout new machine
 	if err := json.Unmarshal([]byte(machineServerConfigStr), &machineServerConfig); err != nil {
-		return false
+		return []string{"serverConfig annotation is invalid"}
 	}
 
 	if machineServerConfig == nil {
 		machineServerConfig = &controlplanev1.RKE2ServerConfig{}
 	}
 
-	rcpServerConfig := &rcp.Spec.ServerConfig
-	if rcpServerConfig == nil {
-		rcpServerConfig = &controlplanev1.RKE2ServerConfig{}
-	}
-
 	// Compare and return
-	return reflect.DeepEqual(machineServerConfig, rcpServerConfig)
+	return fieldsDiff("serverConfig", reflect.ValueOf(*machineServerConfig), reflect.ValueOf(rcp.Spec.ServerConfig))
 }
 
 // matchesTemplateClonedFrom returns a filter to find all machines that match a given RCP infra template.
@@ -129,30 +181,173 @@ func matchesTemplateClonedFrom(infraConfigs map[string]*unstructured.Unstructure
 			return false
 		}
 
-		infraObj, found := infraConfigs[machine.Name]
-		if !found {
-			// Return true here because failing to get infrastructure machine should not be considered as unmatching.
-			return true
+		return templateClonedFromDiff(infraConfigs, rcp, machine) == ""
+	}
+}
+
+// templateClonedFromDiff returns the change of the infrastructure template the infrastructure machine of the machine
+// was cloned from, or an empty string if it was cloned from the current RCP infra template.
+func templateClonedFromDiff(
+	infraConfigs map[string]*unstructured.Unstructured,
+	rcp *controlplanev1.RKE2ControlPlane,
+	machine *clusterv1.Machine,
+) string {
+	infraObj, found := infraConfigs[machine.Name]
+	if !found {
+		// Failing to get infrastructure machine should not be considered as unmatching.
+		return ""
+	}
+
+	clonedFromName, ok1 := infraObj.GetAnnotations()[clusterv1.TemplateClonedFromNameAnnotation]
+	clonedFromGroupKind, ok2 := infraObj.GetAnnotations()[clusterv1.TemplateClonedFromGroupKindAnnotation]
+
+	if !ok1 || !ok2 {
+		// All rcp cloned infra machines should have this annotation.
+		// Missing the annotation may be due to older version machines or adopted machines.
+		// Should not be considered as mismatch.
+		return ""
+	}
+
+	// Machines of a server pool are created from the infrastructure template of their pool.
+	infraRef := rcp.Spec.MachineTemplate.InfrastructureRef
+
+	if role := ServerRoleOf(machine); role != "" {
+		pool := serverPoolSpec(rcp, role)
+		if pool == nil {
+			// Machines of a removed server pool are scaled down rather than rolled out.
+			return ""
 		}
 
-		clonedFromName, ok1 := infraObj.GetAnnotations()[clusterv1.TemplateClonedFromNameAnnotation]
-		clonedFromGroupKind, ok2 := infraObj.GetAnnotations()[clusterv1.TemplateClonedFromGroupKindAnnotation]
+		infraRef = pool.InfrastructureRef
+	}
 
-		if !ok1 || !ok2 {
-			// All rcp cloned infra machines should have this annotation.
-			// Missing the annotation may be due to older version machines or adopted machines.
-			// Should not be considered as mismatch.
-			return true
+	// Check if the machine's infrastructure reference has been created from the current RCP infrastructure template.
+	infraGroupKind := infraRef.GroupVersionKind().GroupKind().String()
+	if clonedFromName != infraRef.Name || clonedFromGroupKind != infraGroupKind {
+		return fmt.Sprintf("infrastructure template %s/%s -> %s/%s", clonedFromGroupKind, clonedFromName, infraGroupKind, infraRef.Name)
+	}
+
+	return ""
+}
+
+// machineSpecDiff returns the differences between the machine and the RCP configuration which require the machine to be
+// rolled out or upgraded in place, e.g. "version v1.30.2+rke2r1 -> v1.31.1+rke2r1" or "serverConfig.tlsSan changed".
+func machineSpecDiff(
+	infraConfigs map[string]*unstructured.Unstructured,
+	machineConfigs map[string]*bootstrapv1.RKE2Config,
+	rcp *controlplanev1.RKE2ControlPlane,
+	machine *clusterv1.Machine,
+) []string {
+	diff := []string{}
+
+	if desiredVersion := rcp.GetDesiredVersion(); !matchesKubernetesOrRKE2Version(desiredVersion)(machine) {
+		diff = append(diff, fmt.Sprintf("version %s -> %s", ptr.Deref(machine.Spec.Version, "unknown"), desiredVersion))
+	}
+
+	diff = append(diff, rke2BootstrapConfigDiff(machineConfigs, rcp, machine)...)
+
+	if templateDiff := templateClonedFromDiff(infraConfigs, rcp, machine); templateDiff != "" {
+		diff = append(diff, templateDiff)
+	}
+
+	return diff
+}
+
+// fieldsDiff returns the paths of the fields which differ between the current and desired values, named after their
+// JSON names, e.g. "serverConfig.tlsSan changed". Structs are compared field by field, other values as a whole.
+func fieldsDiff(path string, current, desired reflect.Value) []string {
+	if reflect.DeepEqual(current.Interface(), desired.Interface()) {
+		return nil
+	}
+
+	if current.Kind() == reflect.Ptr && !current.IsNil() && !desired.IsNil() {
+		return fieldsDiff(path, current.Elem(), desired.Elem())
+	}
+
+	if current.Kind() != reflect.Struct {
+		return []string{fieldChanged(path)}
+	}
+
+	diff := []string{}
+
+	for i := range current.NumField() {
+		field := current.Type().Field(i)
+		if !field.IsExported() {
+			continue
 		}
 
-		// Check if the machine's infrastructure reference has been created from the current RCP infrastructure template.
-		if clonedFromName != rcp.Spec.MachineTemplate.InfrastructureRef.Name ||
-			clonedFromGroupKind != rcp.Spec.MachineTemplate.InfrastructureRef.GroupVersionKind().GroupKind().String() {
-			return false
+		fieldPath := path
+
+		switch name := strings.Split(field.Tag.Get("json"), ",")[0]; {
+		case name == "-":
+			continue
+		case name == "" && field.Anonymous:
+			// Inlined fields are named after the fields of the embedded struct.
+		case name == "":
+			fieldPath = joinFieldPath(path, field.Name)
+		default:
+			fieldPath = joinFieldPath(path, name)
 		}
 
-		return true
+		diff = append(diff, fieldsDiff(fieldPath, current.Field(i), desired.Field(i))...)
+	}
+
+	// Structs differing only by their unexported fields are reported as a whole.
+	if len(diff) == 0 {
+		return []string{fieldChanged(path)}
+	}
+
+	return diff
+}
+
+// joinFieldPath returns the path of a field of the struct at the given path.
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
+
+// fieldChanged returns the difference reported for the field at the given path.
+func fieldChanged(path string) string {
+	if path == "" {
+		return "spec changed"
+	}
+
+	return path + " changed"
+}
+
+// ServerRoleOf returns the server role run by a control plane machine of a server pool, or an empty role if the machine
+// runs all the server roles.
+func ServerRoleOf(machine *clusterv1.Machine) controlplanev1.ServerRole {
+	return controlplanev1.ServerRole(machine.Labels[controlplanev1.ServerRoleLabel])
+}
+
+// HasServerRole returns a filter to find the control plane machines running the given server role, an empty role
+// matching the machines running all the server roles.
+func HasServerRole(role controlplanev1.ServerRole) collections.Func {
+	return func(machine *clusterv1.Machine) bool {
+		return machine != nil && ServerRoleOf(machine) == role
+	}
+}
+
+// RunsEtcd returns a filter to find the control plane machines hosting an etcd member.
+func RunsEtcd() collections.Func {
+	return func(machine *clusterv1.Machine) bool {
+		return machine != nil && ServerRoleOf(machine) != controlplanev1.ServerRoleControlPlane
+	}
+}
+
+// serverPoolSpec returns the spec of the server pool running the given role, or nil if there is none.
+func serverPoolSpec(rcp *controlplanev1.RKE2ControlPlane, role controlplanev1.ServerRole) *controlplanev1.ServerPool {
+	for i := range rcp.Spec.ServerPools {
+		if rcp.Spec.ServerPools[i].Role == role {
+			return &rcp.Spec.ServerPools[i]
+		}
 	}
+
+	return nil
 }
 
 // matchesKubernetesVersion returns a filter to find all machines that match a given Kubernetes or RKE2 version.
@@ -170,11 +365,76 @@ func matchesKubernetesOrRKE2Version(rke2Version string) func(*clusterv1.Machine)
 			return bsutil.CompareVersions(*machine.Spec.Version, rke2Version)
 		}
 
+		// A version which can't be converted is reported as not matching, so that the machine is rolled out.
 		rcpKubeVersion, err := bsutil.Rke2ToKubeVersion(rke2Version)
 		if err != nil {
-			return true
+			return false
 		}
 
 		return bsutil.CompareVersions(*machine.Spec.Version, rcpKubeVersion)
 	}
 }
+
+// needsCertificateAuthorityRotation returns a filter to find all machines created before the certificate authorities
+// of the current phase of a rotation were distributed to the control plane, and which must be rolled out to use them.
+func needsCertificateAuthorityRotation(rcp *controlplanev1.RKE2ControlPlane) func(*clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		rotation := rcp.Status.CertificateAuthorityRotation
+		if machine == nil || rotation == nil || rotation.DistributedTime == nil {
+			return false
+		}
+
+		return machine.CreationTimestamp.Before(rotation.DistributedTime)
+	}
+}
+
+// shouldRolloutBefore returns a filter to find all machines whose certificates expire within the
+// RolloutBefore.CertificatesExpiryDays of the reconciliation time.
+func shouldRolloutBefore(reconciliationTime *metav1.Time, rolloutBefore *controlplanev1.RolloutBefore) func(*clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil || rolloutBefore == nil || rolloutBefore.CertificatesExpiryDays == nil {
+			return false
+		}
+
+		expiryDate := certificatesExpiryDate(machine)
+		if expiryDate == nil || reconciliationTime == nil {
+			return false
+		}
+
+		rolloutDate := reconciliationTime.Add(time.Duration(*rolloutBefore.CertificatesExpiryDays) * 24 * time.Hour)
+
+		return rolloutDate.After(*expiryDate)
+	}
+}
+
+// shouldRolloutAfter returns a filter to find all machines created before RolloutAfter, once the reconciliation
+// time is past RolloutAfter.
+func shouldRolloutAfter(reconciliationTime, rolloutAfter *metav1.Time) func(*clusterv1.Machine) bool {
+	return func(machine *clusterv1.Machine) bool {
+		if machine == nil || reconciliationTime == nil || rolloutAfter == nil {
+			return false
+		}
+
+		return reconciliationTime.After(rolloutAfter.Time) && machine.CreationTimestamp.Before(rolloutAfter)
+	}
+}
+
+// certificatesExpiryDate returns the expiry date of the certificates of the machine, read from its status or,
+// until the status is updated by the machine controller, from the certificates expiry annotation.
+func certificatesExpiryDate(machine *clusterv1.Machine) *time.Time {
+	if machine.Status.CertificatesExpiryDate != nil {
+		return &machine.Status.CertificatesExpiryDate.Time
+	}
+
+	value, found := machine.Annotations[clusterv1.MachineCertificatesExpiryDateAnnotation]
+	if !found {
+		return nil
+	}
+
+	expiryDate, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+
+	return &expiryDate
+}
@@ -1,11 +1,15 @@
 package rke2
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/collections"
@@ -144,6 +148,45 @@ var _ = Describe("matchAgentConfig", func() {
 	)
 })
 
+var _ = Describe("machine spec diff", func() {
+	It("should report the changed fields of the server configuration", func() {
+		changed := rcp.DeepCopy()
+		changed.Spec.ServerConfig.CNI = "cilium"
+		changed.Spec.ServerConfig.Etcd.BackupConfig.Retention = "10"
+
+		Expect(serverConfigDiff(changed, &machine)).To(ConsistOf("serverConfig.cni changed", "serverConfig.etcd.backupConfig.retention changed"))
+	})
+
+	It("should report an invalid server configuration annotation", func() {
+		invalid := machine.DeepCopy()
+		invalid.Annotations[controlplanev1.RKE2ServerConfigurationAnnotation] = "{"
+
+		Expect(serverConfigDiff(&rcp, invalid)).To(ConsistOf("serverConfig annotation is invalid"))
+	})
+
+	It("should report the version and infrastructure template changes", func() {
+		infraMachine := &unstructured.Unstructured{}
+		infraMachine.SetAnnotations(map[string]string{
+			clusterv1.TemplateClonedFromNameAnnotation:      "old-template",
+			clusterv1.TemplateClonedFromGroupKindAnnotation: "GenericInfrastructureMachineTemplate.infrastructure.cluster.x-k8s.io",
+		})
+
+		changed := rcp.DeepCopy()
+		changed.Spec.Version = "v1.25.0+rke2r1"
+		changed.Spec.MachineTemplate.InfrastructureRef = corev1.ObjectReference{
+			APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1",
+			Kind:       "GenericInfrastructureMachineTemplate",
+			Name:       "new-template",
+		}
+
+		Expect(machineSpecDiff(map[string]*unstructured.Unstructured{"machine-test": infraMachine}, nil, changed, &machine)).To(Equal([]string{
+			"version v1.24.6 -> v1.25.0+rke2r1",
+			"infrastructure template GenericInfrastructureMachineTemplate.infrastructure.cluster.x-k8s.io/old-template -> " +
+				"GenericInfrastructureMachineTemplate.infrastructure.cluster.x-k8s.io/new-template",
+		}))
+	})
+})
+
 var _ = Describe("matching Kubernetes Version", func() {
 	It("should match version", func() {
 		machineCollection := collections.FromMachines(&machine)
@@ -158,4 +201,97 @@ var _ = Describe("matching Kubernetes Version", func() {
 		Expect(len(matches)).To(Equal(1))
 		machine.Spec.Version = &k8sMachineVersion
 	})
+
+	It("should not match when the desired version is another Kubernetes version", func() {
+		machineCollection := collections.FromMachines(&machine)
+		matches := machineCollection.AnyFilter(matchesKubernetesOrRKE2Version("v1.22.0"))
+		Expect(len(matches)).To(Equal(0))
+	})
+})
+
+var _ = Describe("in place upgrade eligibility", func() {
+	It("should allow upgrading to a newer version", func() {
+		machineCollection := collections.FromMachines(&machine)
+		matches := machineCollection.Filter(canBeUpgradedInPlace("v1.25.2+rke2r1"))
+		Expect(len(matches)).To(Equal(1))
+	})
+
+	It("should not allow downgrades", func() {
+		machineCollection := collections.FromMachines(&machine)
+		matches := machineCollection.Filter(canBeUpgradedInPlace("v1.23.9+rke2r1"))
+		Expect(len(matches)).To(Equal(0))
+	})
+})
+
+var _ = Describe("rollout before certificates expiry", func() {
+	var (
+		now           = v1.Now()
+		rolloutBefore = &controlplanev1.RolloutBefore{CertificatesExpiryDays: ptr.To[int32](30)}
+	)
+
+	It("should not roll out machines without RolloutBefore", func() {
+		expiringMachine := machine.DeepCopy()
+		expiringMachine.Status.CertificatesExpiryDate = &v1.Time{Time: now.Add(24 * time.Hour)}
+
+		Expect(shouldRolloutBefore(&now, nil)(expiringMachine)).To(BeFalse())
+	})
+
+	It("should not roll out machines without certificates expiry date", func() {
+		Expect(shouldRolloutBefore(&now, rolloutBefore)(machine.DeepCopy())).To(BeFalse())
+	})
+
+	It("should roll out machines whose certificates expire within the given days", func() {
+		expiringMachine := machine.DeepCopy()
+		expiringMachine.Status.CertificatesExpiryDate = &v1.Time{Time: now.Add(29 * 24 * time.Hour)}
+
+		Expect(shouldRolloutBefore(&now, rolloutBefore)(expiringMachine)).To(BeTrue())
+	})
+
+	It("should not roll out machines whose certificates expire later", func() {
+		validMachine := machine.DeepCopy()
+		validMachine.Status.CertificatesExpiryDate = &v1.Time{Time: now.Add(31 * 24 * time.Hour)}
+
+		Expect(shouldRolloutBefore(&now, rolloutBefore)(validMachine)).To(BeFalse())
+	})
+
+	It("should read the certificates expiry date from the annotation until the status is updated", func() {
+		expiringMachine := machine.DeepCopy()
+		expiringMachine.Annotations[clusterv1.MachineCertificatesExpiryDateAnnotation] =
+			now.Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+		Expect(shouldRolloutBefore(&now, rolloutBefore)(expiringMachine)).To(BeTrue())
+	})
+})
+
+var _ = Describe("rollout after", func() {
+	var (
+		now          = v1.Now()
+		rolloutAfter = v1.NewTime(now.Add(-time.Hour))
+	)
+
+	It("should roll out machines created before RolloutAfter", func() {
+		oldMachine := machine.DeepCopy()
+		oldMachine.CreationTimestamp = v1.NewTime(now.Add(-2 * time.Hour))
+
+		Expect(shouldRolloutAfter(&now, &rolloutAfter)(oldMachine)).To(BeTrue())
+	})
+
+	It("should not roll out machines created after RolloutAfter", func() {
+		newMachine := machine.DeepCopy()
+		newMachine.CreationTimestamp = v1.NewTime(now.Add(-time.Minute))
+
+		Expect(shouldRolloutAfter(&now, &rolloutAfter)(newMachine)).To(BeFalse())
+	})
+
+	It("should not roll out machines before RolloutAfter is reached", func() {
+		oldMachine := machine.DeepCopy()
+		oldMachine.CreationTimestamp = v1.NewTime(now.Add(-2 * time.Hour))
+		futureRolloutAfter := v1.NewTime(now.Add(time.Hour))
+
+		Expect(shouldRolloutAfter(&now, &futureRolloutAfter)(oldMachine)).To(BeFalse())
+	})
+
+	It("should not roll out machines without RolloutAfter", func() {
+		Expect(shouldRolloutAfter(&now, nil)(machine.DeepCopy())).To(BeFalse())
+	})
 })
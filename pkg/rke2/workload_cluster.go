@@ -21,6 +21,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -29,9 +30,11 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/rest"
+	"k8s.io/utils/ptr"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
@@ -48,11 +51,16 @@ import (
 
 const (
 	labelNodeRoleControlPlane = "node-role.kubernetes.io/master"
+	labelNodeRoleEtcd         = "node-role.kubernetes.io/etcd"
 	remoteEtcdTimeout         = 30 * time.Second
 	etcdDialTimeout           = 10 * time.Second
 	etcdCallTimeout           = 15 * time.Second
 	minimalNodeCount          = 2
 	rke2ServingSecretKey      = "rke2-serving" //nolint: gosec
+
+	// maxEtcdAppliedIndexLag is the number of raft entries an etcd member can lag behind the leader while being healthy,
+	// which is the gap etcd tolerates between the committed and applied entries of a member before rejecting requests.
+	maxEtcdAppliedIndexLag = 5000
 )
 
 // ErrControlPlaneMinNodes is returned when the control plane has fewer than 2 nodes.
@@ -66,7 +74,7 @@ type WorkloadCluster interface {
 
 	ClusterStatus(ctx context.Context) ClusterStatus
 	UpdateAgentConditions(controlPlane *ControlPlane)
-	UpdateEtcdConditions(controlPlane *ControlPlane)
+	UpdateEtcdConditions(ctx context.Context, controlPlane *ControlPlane)
 	// Upgrade related tasks.
 
 	//	AllowBootstrapTokensToGetNodes(ctx context.Context) error
@@ -76,6 +84,35 @@ type WorkloadCluster interface {
 	ForwardEtcdLeadership(ctx context.Context, machine *clusterv1.Machine, leaderCandidate *clusterv1.Machine) error
 	ReconcileEtcdMembers(ctx context.Context, nodeNames []string, version semver.Version) ([]string, error)
 	EtcdMembers(ctx context.Context) ([]string, error)
+
+	// Etcd maintenance tasks.
+	DefragmentEtcdMember(ctx context.Context, thresholdPercent int32) (string, error)
+
+	// In place upgrade tasks.
+	ApplyUpgradePlan(ctx context.Context, plan *UpgradePlan) error
+	DeleteUpgradePlan(ctx context.Context, plan *UpgradePlan) error
+	NodeVersion(nodeName string) (string, bool)
+
+	// Host command tasks.
+	StartHostCommand(ctx context.Context, command *HostCommand) error
+	GetHostCommandPod(ctx context.Context, command *HostCommand) (*corev1.Pod, error)
+	DeleteHostCommandPod(ctx context.Context, command *HostCommand) error
+
+	// Etcd snapshot tasks.
+	EtcdSnapshots(ctx context.Context) ([]controlplanev1.EtcdSnapshotInfo, error)
+
+	// Certificate tasks.
+	APIServerCertificateExpiry(ctx context.Context, nodeName string) (*time.Time, error)
+
+	// Manifests tasks.
+	SyncManifests(
+		ctx context.Context,
+		objects []*unstructured.Unstructured,
+		applied []controlplanev1.ManifestReference,
+	) ([]controlplanev1.ManifestReference, error)
+
+	// Deletion tasks.
+	CleanupWorkload(ctx context.Context, cleanup *controlplanev1.WorkloadCleanup) ([]string, error)
 }
 
 // Workload defines operations on workload clusters.
@@ -85,6 +122,7 @@ type Workload struct {
 	Nodes               map[string]*corev1.Node
 	nodePatchHelpers    map[string]*patch.Helper
 	etcdClientGenerator etcd.ClientFor
+	restConfig          *rest.Config
 }
 
 // NewWorkload is creating a new ClusterWorkload instance.
@@ -102,6 +140,7 @@ func (m *Management) NewWorkload(
 
 	restConfig = rest.CopyConfig(restConfig)
 	restConfig.Timeout = remoteEtcdTimeout
+	workload.restConfig = restConfig
 
 	// Retrieves the etcd CA key Pair
 	etcdKeyPair, err := m.getEtcdCAKeyPair(ctx, m.SecretCachingClient, clusterKey)
@@ -198,6 +237,8 @@ type ClusterStatus struct {
 	HasRKE2ServingSecret bool
 }
 
+// getControlPlaneNodes returns the nodes running the kube-apiserver, and the etcd only nodes of the server pools,
+// which are not labeled as control plane nodes.
 func (w *Workload) getControlPlaneNodes(ctx context.Context) (*corev1.NodeList, error) {
 	nodes := &corev1.NodeList{}
 	labels := map[string]string{
@@ -208,6 +249,32 @@ func (w *Workload) getControlPlaneNodes(ctx context.Context) (*corev1.NodeList,
 		return nil, err
 	}
 
+	etcdNodes := &corev1.NodeList{}
+	if err := w.Client.List(ctx, etcdNodes, ctrlclient.MatchingLabels{labelNodeRoleEtcd: "true"}); err != nil {
+		return nil, err
+	}
+
+	for _, etcdNode := range etcdNodes.Items {
+		if !slices.ContainsFunc(nodes.Items, func(node corev1.Node) bool { return node.Name == etcdNode.Name }) {
+			nodes.Items = append(nodes.Items, etcdNode)
+		}
+	}
+
+	return nodes, nil
+}
+
+// getEtcdNodes returns the control plane nodes hosting an etcd member, which excludes the nodes of the control-plane
+// server pool.
+func (w *Workload) getEtcdNodes(ctx context.Context) (*corev1.NodeList, error) {
+	nodes, err := w.getControlPlaneNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes.Items = slices.DeleteFunc(nodes.Items, func(node corev1.Node) bool {
+		return controlplanev1.ServerRole(node.Labels[controlplanev1.ServerRoleLabel]) == controlplanev1.ServerRoleControlPlane
+	})
+
 	return nodes, nil
 }
 
@@ -513,11 +580,14 @@ func aggregateFromMachinesToRCP(input aggregateFromMachinesToRCPInput) {
 // UpdateEtcdConditions is responsible for updating machine conditions reflecting the status of all the etcd members.
 // This operation is best effort, in the sense that in case of problems in retrieving member status, it sets
 // the condition to Unknown state without returning any error.
-func (w *Workload) UpdateEtcdConditions(controlPlane *ControlPlane) {
-	w.updateManagedEtcdConditions(controlPlane)
+func (w *Workload) UpdateEtcdConditions(ctx context.Context, controlPlane *ControlPlane) {
+	w.updateManagedEtcdConditions(ctx, controlPlane)
 }
 
-func (w *Workload) updateManagedEtcdConditions(controlPlane *ControlPlane) {
+func (w *Workload) updateManagedEtcdConditions(ctx context.Context, controlPlane *ControlPlane) {
+	members := []controlplanev1.EtcdMemberStatus{}
+	memberMachines := map[string]*clusterv1.Machine{}
+
 	// NOTE: This methods uses control plane nodes only to get in contact with etcd but then it relies on etcd
 	// as ultimate source of truth for the list of members and for their health.
 	for k := range w.Nodes {
@@ -542,6 +612,11 @@ func (w *Workload) updateManagedEtcdConditions(controlPlane *ControlPlane) {
 			}
 		}
 
+		// Machines of the control-plane server pool do not host an etcd member.
+		if !RunsEtcd()(machine) {
+			continue
+		}
+
 		// If the machine is deleting, report all the conditions as deleting
 		if !machine.ObjectMeta.DeletionTimestamp.IsZero() {
 			conditions.MarkFalse(machine, controlplanev1.MachineEtcdMemberHealthyCondition, clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
@@ -549,6 +624,80 @@ func (w *Workload) updateManagedEtcdConditions(controlPlane *ControlPlane) {
 			continue
 		}
 
+		// Clusters without an etcd certificate secret can't be inspected.
+		if w.etcdClientGenerator == nil {
+			conditions.MarkTrue(machine, controlplanev1.MachineEtcdMemberHealthyCondition)
+
+			continue
+		}
+
+		member, err := w.getEtcdMemberStatus(ctx, node.Name)
+		if err != nil {
+			conditions.MarkUnknown(machine, controlplanev1.MachineEtcdMemberHealthyCondition,
+				controlplanev1.EtcdMemberInspectionFailedReason, "Failed to get the status of the etcd member: %v", err)
+
+			continue
+		}
+
+		member.MachineName = machine.Name
+		members = append(members, *member)
+		memberMachines[member.Name] = machine
+	}
+
+	if w.etcdClientGenerator == nil {
+		return
+	}
+
+	setEtcdMembersAppliedIndexLag(members)
+
+	for _, member := range members {
+		markEtcdMemberHealth(memberMachines[member.Name], member)
+	}
+
+	slices.SortFunc(members, func(a, b controlplanev1.EtcdMemberStatus) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	controlPlane.RCP.Status.EtcdMembers = members
+}
+
+// setEtcdMembersAppliedIndexLag sets the number of raft entries each member has not yet applied compared to the leader,
+// if the status of the leader is known.
+// NOTE: the status of the members is not read at the same time, so small lags are expected on a healthy cluster.
+func setEtcdMembersAppliedIndexLag(members []controlplanev1.EtcdMemberStatus) {
+	leaderIndex := slices.IndexFunc(members, func(member controlplanev1.EtcdMemberStatus) bool {
+		return member.Leader
+	})
+	if leaderIndex < 0 {
+		return
+	}
+
+	leaderAppliedIndex := members[leaderIndex].RaftAppliedIndex
+
+	for i := range members {
+		members[i].AppliedIndexLag = ptr.To(max(leaderAppliedIndex-members[i].RaftAppliedIndex, 0))
+	}
+}
+
+// markEtcdMemberHealth sets the EtcdMemberHealthy condition of the machine from the status of its etcd member.
+// Members reporting alarms, lagging behind the leader, or not yet promoted to voting members are not healthy,
+// so that the control plane waits for them before removing another member.
+func markEtcdMemberHealth(machine *clusterv1.Machine, member controlplanev1.EtcdMemberStatus) {
+	switch {
+	case len(member.Alarms) > 0:
+		conditions.MarkFalse(machine, controlplanev1.MachineEtcdMemberHealthyCondition,
+			controlplanev1.EtcdMemberUnhealthyReason, clusterv1.ConditionSeverityError,
+			"Etcd member reports alarms: %s", strings.Join(member.Alarms, ", "))
+	case member.Learner:
+		conditions.MarkFalse(machine, controlplanev1.MachineEtcdMemberHealthyCondition,
+			controlplanev1.EtcdMemberLearnerReason, clusterv1.ConditionSeverityInfo,
+			"Etcd member is a raft learner, not yet promoted to a voting member")
+	case ptr.Deref(member.AppliedIndexLag, 0) > maxEtcdAppliedIndexLag:
+		conditions.MarkFalse(machine, controlplanev1.MachineEtcdMemberHealthyCondition,
+			controlplanev1.EtcdMemberLaggingReason, clusterv1.ConditionSeverityWarning,
+			"Etcd member lags %d raft entries behind the leader (term %d, applied index %d)",
+			*member.AppliedIndexLag, member.RaftTerm, member.RaftAppliedIndex)
+	default:
 		conditions.MarkTrue(machine, controlplanev1.MachineEtcdMemberHealthyCondition)
 	}
 }
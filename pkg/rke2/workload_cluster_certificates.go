@@ -0,0 +1,120 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/proxy"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/secret"
+)
+
+const (
+	// apiServerPort is the port the kube-apiserver listens on.
+	apiServerPort = 6443
+
+	// certificateAuthorityRotationDir is the directory the certificate authorities are written to on the node.
+	certificateAuthorityRotationDir = "/var/lib/rancher/rke2/server/tls-rotation"
+
+	// certificateAuthorityRotationScript completes the certificate authorities with the current ones which are not managed
+	// by the provider, updates the certificate authorities stored in the datastore, and removes the private keys from the node.
+	// The rotation is forced, as the new certificate authorities are not signed by the previous ones.
+	certificateAuthorityRotationScript = `dir=$1; tls=$2; mkdir -p "$dir/etcd"
+for f in request-header-ca.crt request-header-ca.key service.key etcd/peer-ca.crt etcd/peer-ca.key etcd/server-ca.crt etcd/server-ca.key; do
+  [ -e "$dir/$f" ] || [ ! -e "$tls/$f" ] || cp "$tls/$f" "$dir/$f"
+done
+rc=0; rke2 certificate rotate-ca --path="$dir" --force || rc=$?; rm -rf "$dir"; exit $rc`
+)
+
+// NewCertificateAuthorityRotationCommand returns the HostCommand updating the certificate authorities of the
+// control plane to the given ones for the current phase of the rotation.
+// NOTE: the certificate authorities are only read from the datastore when the rke2-server service starts,
+// so the control plane machines must be rolled out to use them.
+func NewCertificateAuthorityRotationCommand(
+	rcp *controlplanev1.RKE2ControlPlane,
+	certificates secret.Certificates,
+	nodeName string,
+) *HostCommand {
+	rotation := rcp.Status.CertificateAuthorityRotation
+
+	files := map[string]string{}
+
+	for _, file := range certificates.AsFiles() {
+		path, err := filepath.Rel(secret.DefaultCertificatesDir, file.Path)
+		if err != nil || strings.HasPrefix(path, "..") {
+			continue
+		}
+
+		files[path] = file.Content
+	}
+
+	return &HostCommand{
+		Name:     fmt.Sprintf("rke2-ca-rotation-%s-%s", rcp.Name, strings.ToLower(string(rotation.Phase))),
+		NodeName: nodeName,
+		Image:    hostCommandImage(rcp),
+		Command: []string{
+			"/bin/sh", "-c", certificateAuthorityRotationScript, "sh",
+			certificateAuthorityRotationDir, secret.DefaultCertificatesDir,
+		},
+		Files:    files,
+		FilesDir: certificateAuthorityRotationDir,
+	}
+}
+
+// APIServerCertificateExpiry returns the expiry date of the serving certificate of the kube-apiserver running on the node,
+// reached through a port forward to the kube-apiserver static pod.
+// NOTE: RKE2 renews its certificates when they expire within 90 days, but only when the rke2-server service restarts.
+func (w *Workload) APIServerCertificateExpiry(ctx context.Context, nodeName string) (*time.Time, error) {
+	dialer, err := proxy.NewDialer(proxy.Proxy{
+		Kind:       "pods",
+		Namespace:  metav1.NamespaceSystem,
+		KubeConfig: w.restConfig,
+		Port:       apiServerPort,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create dialer to the kube-apiserver of node %s", nodeName)
+	}
+
+	rawConn, err := dialer.DialContextWithAddr(ctx, "kube-apiserver-"+nodeName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial to the kube-apiserver of node %s", nodeName)
+	}
+
+	// The certificate is not verified, only its expiry date is read.
+	conn := tls.Client(rawConn, &tls.Config{InsecureSkipVerify: true}) //nolint:gosec
+	defer conn.Close()
+
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return nil, errors.Wrapf(err, "failed TLS handshake with the kube-apiserver of node %s", nodeName)
+	}
+
+	peerCertificates := conn.ConnectionState().PeerCertificates
+	if len(peerCertificates) == 0 {
+		return nil, errors.Errorf("kube-apiserver of node %s did not present a certificate", nodeName)
+	}
+
+	return &peerCertificates[0].NotAfter, nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"sigs.k8s.io/cluster-api/util/certs"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/secret"
+)
+
+func TestNewCertificateAuthorityRotationCommand(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	rcp := &controlplanev1.RKE2ControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: metav1.NamespaceDefault},
+		Status: controlplanev1.RKE2ControlPlaneStatus{
+			CertificateAuthorityRotation: &controlplanev1.CertificateAuthorityRotationStatus{
+				ID:    "1",
+				Phase: controlplanev1.CertificateAuthorityRotationPhaseTrustingNewCA,
+			},
+		},
+	}
+
+	certificates := secret.NewCertificatesForInitialControlPlane()
+	for _, certificate := range certificates {
+		certificate.SetKeyPair(&certs.KeyPair{Cert: []byte(certificate.GetPurpose() + " cert"), Key: []byte(certificate.GetPurpose() + " key")})
+	}
+
+	command := NewCertificateAuthorityRotationCommand(rcp, certificates, "node-1")
+	g.Expect(command.Name).To(Equal("rke2-ca-rotation-test-trustingnewca"))
+	g.Expect(command.NodeName).To(Equal("node-1"))
+	g.Expect(command.Image).To(Equal(DefaultHostCommandImage))
+	g.Expect(command.FilesDir).To(Equal(certificateAuthorityRotationDir))
+	g.Expect(command.Files).To(Equal(map[string]string{
+		"server-ca.crt":      "ca cert",
+		"server-ca.key":      "ca key",
+		"client-ca.crt":      "cca cert",
+		"client-ca.key":      "cca key",
+		"etcd/peer-ca.crt":   "peer-etcd cert",
+		"etcd/peer-ca.key":   "peer-etcd key",
+		"etcd/server-ca.crt": "etcd cert",
+		"etcd/server-ca.key": "etcd key",
+	}))
+
+	// The image of the host commands can be overridden, e.g. for air-gapped clusters.
+	rcp.Spec.HostCommandImage = "registry.example.com/library/busybox"
+	g.Expect(NewCertificateAuthorityRotationCommand(rcp, certificates, "node-1").Image).
+		To(Equal("registry.example.com/library/busybox"))
+
+	// The files are passed to the pod through a secret, deleted together with the pod.
+	w := &Workload{Client: fake.NewClientBuilder().Build()}
+	g.Expect(w.StartHostCommand(ctx, command)).To(Succeed())
+
+	pod, err := w.GetHostCommandPod(ctx, command)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pod.Spec.InitContainers).To(HaveLen(1))
+	g.Expect(pod.Spec.Volumes[0].Secret.Items).To(ContainElement(corev1.KeyToPath{Key: "etcd_peer-ca.crt", Path: "etcd/peer-ca.crt"}))
+	g.Expect(pod.Spec.Volumes[1].HostPath.Path).To(Equal(certificateAuthorityRotationDir))
+
+	filesSecret := &corev1.Secret{}
+	secretKey := client.ObjectKey{Namespace: metav1.NamespaceSystem, Name: command.Name}
+	g.Expect(w.Client.Get(ctx, secretKey, filesSecret)).To(Succeed())
+	g.Expect(filesSecret.StringData).To(HaveKeyWithValue("etcd_peer-ca.crt", "peer-etcd cert"))
+
+	g.Expect(w.DeleteHostCommandPod(ctx, command)).To(Succeed())
+	g.Expect(apierrors.IsNotFound(w.Client.Get(ctx, secretKey, filesSecret))).To(BeTrue())
+}
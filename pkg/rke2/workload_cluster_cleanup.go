@@ -0,0 +1,187 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"context"
+	"slices"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+// workloadCleanupAnnotation marks the PersistentVolumes whose claim is deleted by a WorkloadCleanup.
+const workloadCleanupAnnotation = "controlplane.cluster.x-k8s.io/workload-cleanup"
+
+// CleanupWorkload deletes the resources of the workload cluster selected by the WorkloadCleanup, and returns the
+// ones still holding cloud resources, e.g. "Service default/ingress" or "PersistentVolume pvc-0a1b2c", which are
+// waited for until they are released by the cloud controller manager or the volume provisioner.
+func (w *Workload) CleanupWorkload(ctx context.Context, cleanup *controlplanev1.WorkloadCleanup) ([]string, error) {
+	selector := labels.Everything()
+
+	if cleanup.Selector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(cleanup.Selector); err != nil {
+			return nil, errors.Wrap(err, "invalid workload cleanup selector")
+		}
+	}
+
+	remaining := []string{}
+
+	var errs []error
+
+	if cleansUp(cleanup, controlplanev1.WorkloadCleanupLoadBalancerServices) {
+		services, err := w.cleanupLoadBalancerServices(ctx, cleanup.Namespaces, selector)
+		remaining = append(remaining, services...)
+		errs = append(errs, err)
+	}
+
+	if cleansUp(cleanup, controlplanev1.WorkloadCleanupPersistentVolumeClaims) {
+		volumes, err := w.cleanupPersistentVolumeClaims(ctx, cleanup.Namespaces, selector)
+		remaining = append(remaining, volumes...)
+		errs = append(errs, err)
+	}
+
+	return remaining, kerrors.NewAggregate(errs)
+}
+
+// cleansUp returns true if the WorkloadCleanup deletes the given kind of resources, all of them by default.
+func cleansUp(cleanup *controlplanev1.WorkloadCleanup, resource controlplanev1.WorkloadCleanupResource) bool {
+	return len(cleanup.Resources) == 0 || slices.Contains(cleanup.Resources, resource)
+}
+
+// inNamespaces returns true if the namespace is one of the given ones, or if no namespace is given.
+func inNamespaces(namespaces []string, namespace string) bool {
+	return len(namespaces) == 0 || slices.Contains(namespaces, namespace)
+}
+
+// cleanupLoadBalancerServices deletes the selected Services of type LoadBalancer, and returns the ones which still
+// exist, as the cloud controller manager removes their finalizer once their load balancer is released.
+func (w *Workload) cleanupLoadBalancerServices(ctx context.Context, namespaces []string, selector labels.Selector) ([]string, error) {
+	services := &corev1.ServiceList{}
+	if err := w.Client.List(ctx, services, ctrlclient.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.Wrap(err, "failed to list services")
+	}
+
+	remaining := []string{}
+
+	var errs []error
+
+	for i := range services.Items {
+		service := &services.Items[i]
+		if service.Spec.Type != corev1.ServiceTypeLoadBalancer || !inNamespaces(namespaces, service.Namespace) {
+			continue
+		}
+
+		remaining = append(remaining, "Service "+service.Namespace+"/"+service.Name)
+
+		if !service.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if err := w.Client.Delete(ctx, service); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, errors.Wrapf(err, "failed to delete service %s/%s", service.Namespace, service.Name))
+		}
+	}
+
+	return remaining, kerrors.NewAggregate(errs)
+}
+
+// cleanupPersistentVolumeClaims deletes the selected PersistentVolumeClaims, and returns the ones which still exist,
+// along with the PersistentVolumes with the Delete reclaim policy they were bound to, which are not yet deleted by
+// their provisioner.
+//
+// The volumes are annotated before their claim is deleted, so that they are still waited for once it is gone, and the
+// volumes released by other claims are not.
+func (w *Workload) cleanupPersistentVolumeClaims(ctx context.Context, namespaces []string, selector labels.Selector) ([]string, error) {
+	claims := &corev1.PersistentVolumeClaimList{}
+	if err := w.Client.List(ctx, claims, ctrlclient.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.Wrap(err, "failed to list persistent volume claims")
+	}
+
+	volumes := &corev1.PersistentVolumeList{}
+	if err := w.Client.List(ctx, volumes); err != nil {
+		return nil, errors.Wrap(err, "failed to list persistent volumes")
+	}
+
+	remaining := []string{}
+
+	var errs []error
+
+	for i := range claims.Items {
+		claim := &claims.Items[i]
+		if !inNamespaces(namespaces, claim.Namespace) {
+			continue
+		}
+
+		remaining = append(remaining, "PersistentVolumeClaim "+claim.Namespace+"/"+claim.Name)
+
+		if !claim.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if err := w.markCleanedUpVolume(ctx, volumes, claim); err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		if err := w.Client.Delete(ctx, claim); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, errors.Wrapf(err, "failed to delete persistent volume claim %s/%s", claim.Namespace, claim.Name))
+		}
+	}
+
+	for _, volume := range volumes.Items {
+		if _, found := volume.Annotations[workloadCleanupAnnotation]; found {
+			remaining = append(remaining, "PersistentVolume "+volume.Name)
+		}
+	}
+
+	return remaining, kerrors.NewAggregate(errs)
+}
+
+// markCleanedUpVolume annotates the volume a claim is bound to, if it has the Delete reclaim policy.
+func (w *Workload) markCleanedUpVolume(ctx context.Context, volumes *corev1.PersistentVolumeList, claim *corev1.PersistentVolumeClaim) error {
+	for i := range volumes.Items {
+		volume := &volumes.Items[i]
+		if volume.Name != claim.Spec.VolumeName ||
+			volume.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimDelete ||
+			volume.Spec.ClaimRef == nil || volume.Spec.ClaimRef.UID != claim.UID {
+			continue
+		}
+
+		if _, found := volume.Annotations[workloadCleanupAnnotation]; found {
+			return nil
+		}
+
+		patch := ctrlclient.MergeFrom(volume.DeepCopy())
+		metav1.SetMetaDataAnnotation(&volume.ObjectMeta, workloadCleanupAnnotation, "")
+
+		if err := w.Client.Patch(ctx, volume, patch); err != nil {
+			return errors.Wrapf(err, "failed to annotate persistent volume %s", volume.Name)
+		}
+	}
+
+	return nil
+}
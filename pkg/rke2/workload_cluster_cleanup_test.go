@@ -0,0 +1,139 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+func TestCleanupWorkload(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	service := func(namespace, name string, serviceType corev1.ServiceType, labels map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+			Spec:       corev1.ServiceSpec{Type: serviceType},
+		}
+	}
+
+	claim := func(namespace, name, volumeName string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: volumeName},
+		}
+	}
+
+	volume := func(
+		name, claimNamespace, claimName string,
+		phase corev1.PersistentVolumePhase,
+		policy corev1.PersistentVolumeReclaimPolicy,
+	) *corev1.PersistentVolume {
+		return &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.PersistentVolumeSpec{
+				ClaimRef:                      &corev1.ObjectReference{Namespace: claimNamespace, Name: claimName},
+				PersistentVolumeReclaimPolicy: policy,
+			},
+			Status: corev1.PersistentVolumeStatus{Phase: phase},
+		}
+	}
+
+	lbLabels := map[string]string{"cleanup": "true"}
+	objects := []client.Object{
+		service("apps", "ingress", corev1.ServiceTypeLoadBalancer, lbLabels),
+		service("apps", "internal", corev1.ServiceTypeLoadBalancer, nil),
+		service("apps", "web", corev1.ServiceTypeClusterIP, lbLabels),
+		service("other", "ingress", corev1.ServiceTypeLoadBalancer, lbLabels),
+		claim("apps", "data", "pvc-data"),
+		claim("apps", "logs", "pvc-logs"),
+		claim("other", "data", "pvc-other"),
+		volume("pvc-data", "apps", "data", corev1.VolumeBound, corev1.PersistentVolumeReclaimDelete),
+		volume("pvc-logs", "apps", "logs", corev1.VolumeBound, corev1.PersistentVolumeReclaimRetain),
+		volume("pvc-other", "other", "data", corev1.VolumeBound, corev1.PersistentVolumeReclaimDelete),
+		volume("pvc-released", "apps", "previous", corev1.VolumeReleased, corev1.PersistentVolumeReclaimDelete),
+	}
+
+	cl := fake.NewClientBuilder().WithObjects(objects...).WithStatusSubresource(&corev1.PersistentVolume{}).Build()
+	w := &Workload{Client: cl}
+
+	exists := func(obj client.Object) bool {
+		err := cl.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		g.Expect(err == nil || apierrors.IsNotFound(err)).To(BeTrue())
+
+		return err == nil
+	}
+
+	// The load balancers matching the selector are deleted, in the selected namespaces.
+	remaining, err := w.CleanupWorkload(ctx, &controlplanev1.WorkloadCleanup{
+		Resources:  []controlplanev1.WorkloadCleanupResource{controlplanev1.WorkloadCleanupLoadBalancerServices},
+		Namespaces: []string{"apps"},
+		Selector:   &metav1.LabelSelector{MatchLabels: lbLabels},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remaining).To(ConsistOf("Service apps/ingress"))
+	g.Expect(exists(service("apps", "ingress", "", nil))).To(BeFalse())
+	g.Expect(exists(service("apps", "internal", "", nil))).To(BeTrue())
+	g.Expect(exists(service("apps", "web", "", nil))).To(BeTrue())
+	g.Expect(exists(service("other", "ingress", "", nil))).To(BeTrue())
+	g.Expect(exists(claim("apps", "data", ""))).To(BeTrue())
+
+	// The claims are deleted, and the volumes they were bound to are waited for until their provisioner deletes them.
+	// The volume released by another claim is not waited for.
+	cleanup := &controlplanev1.WorkloadCleanup{
+		Resources:  []controlplanev1.WorkloadCleanupResource{controlplanev1.WorkloadCleanupPersistentVolumeClaims},
+		Namespaces: []string{"apps"},
+	}
+
+	remaining, err = w.CleanupWorkload(ctx, cleanup)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remaining).To(ConsistOf("PersistentVolumeClaim apps/data", "PersistentVolumeClaim apps/logs", "PersistentVolume pvc-data"))
+	g.Expect(exists(claim("apps", "data", ""))).To(BeFalse())
+	g.Expect(exists(claim("apps", "logs", ""))).To(BeFalse())
+	g.Expect(exists(claim("other", "data", ""))).To(BeTrue())
+
+	// The volumes are still waited for once their claim is gone.
+	remaining, err = w.CleanupWorkload(ctx, cleanup)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remaining).To(ConsistOf("PersistentVolume pvc-data"))
+
+	g.Expect(cl.Delete(ctx, volume("pvc-data", "", "", "", ""))).To(Succeed())
+
+	remaining, err = w.CleanupWorkload(ctx, cleanup)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remaining).To(BeEmpty())
+
+	// All the resources are cleaned up by default.
+	remaining, err = w.CleanupWorkload(ctx, &controlplanev1.WorkloadCleanup{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(remaining).To(ConsistOf(
+		"Service apps/internal",
+		"Service other/ingress",
+		"PersistentVolumeClaim other/data",
+		"PersistentVolume pvc-other",
+	))
+}
@@ -23,11 +23,14 @@ import (
 
 	"github.com/blang/semver/v4"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/etcd"
 	etcdutil "github.com/rancher/cluster-api-provider-rke2/pkg/etcd/util"
 )
 
@@ -104,7 +107,7 @@ func (w *Workload) RemoveEtcdMemberForMachine(ctx context.Context, machine *clus
 }
 
 func (w *Workload) removeMemberForNode(ctx context.Context, name string) error {
-	controlPlaneNodes, err := w.getControlPlaneNodes(ctx)
+	controlPlaneNodes, err := w.getEtcdNodes(ctx)
 	if err != nil {
 		return err
 	}
@@ -174,9 +177,9 @@ func (w *Workload) ForwardEtcdLeadership(ctx context.Context, machine *clusterv1
 		return nil
 	}
 
-	nodes, err := w.getControlPlaneNodes(ctx)
+	nodes, err := w.getEtcdNodes(ctx)
 	if err != nil {
-		return errors.Wrap(err, "failed to list control plane nodes")
+		return errors.Wrap(err, "failed to list etcd nodes")
 	}
 
 	nodeNames := make([]string, 0, len(nodes.Items))
@@ -233,9 +236,9 @@ func (w *Workload) EtcdMembers(ctx context.Context) ([]string, error) {
 		return []string{}, nil
 	}
 
-	nodes, err := w.getControlPlaneNodes(ctx)
+	nodes, err := w.getEtcdNodes(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to list control plane nodes")
+		return nil, errors.Wrap(err, "failed to list etcd nodes")
 	}
 
 	nodeNames := make([]string, 0, len(nodes.Items))
@@ -261,3 +264,154 @@ func (w *Workload) EtcdMembers(ctx context.Context) ([]string, error) {
 
 	return names, nil
 }
+
+// getEtcdMemberStatus returns the status of the etcd member hosted on the node, with its active alarms and raft progress.
+func (w *Workload) getEtcdMemberStatus(ctx context.Context, nodeName string) (*controlplanev1.EtcdMemberStatus, error) {
+	etcdClient, err := w.etcdClientGenerator.ForFirstAvailableNode(ctx, []string{nodeName})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create etcd client")
+	}
+	defer etcdClient.Close()
+
+	status, err := etcdClient.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := etcdClient.Members(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	member := memberForID(members, status.ID)
+	if member == nil {
+		return nil, errors.Errorf("etcd member %v not found in the members of the cluster", status.ID)
+	}
+
+	alarms := []string{}
+
+	for _, alarm := range member.Alarms {
+		if alarm != etcd.AlarmOK {
+			alarms = append(alarms, etcd.AlarmTypeName[alarm])
+		}
+	}
+
+	return &controlplanev1.EtcdMemberStatus{
+		Name:             member.Name,
+		DBSize:           resource.NewQuantity(status.DBSize, resource.BinarySI),
+		DBSizeInUse:      resource.NewQuantity(status.DBSizeInUse, resource.BinarySI),
+		Alarms:           alarms,
+		Leader:           status.IsLeader(),
+		Learner:          status.IsLearner,
+		RaftTerm:         int64(status.RaftTerm),         //nolint:gosec
+		RaftAppliedIndex: int64(status.RaftAppliedIndex), //nolint:gosec
+	}, nil
+}
+
+// DefragmentEtcdMember defragments one etcd member whose database free space is at least the given percentage of its
+// size, then disarms its NOSPACE alarm if any. Followers are defragmented first, so that the leader is defragmented last,
+// and only one member is defragmented per call, as a member does not serve requests while it is defragmented.
+// It returns the name of the defragmented member, or an empty string if no member needed to be defragmented.
+func (w *Workload) DefragmentEtcdMember(ctx context.Context, thresholdPercent int32) (string, error) {
+	// Return early for clusters without an etcd certificate secret
+	if w.etcdClientGenerator == nil {
+		return "", nil
+	}
+
+	nodes, err := w.getEtcdNodes(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list etcd nodes")
+	}
+
+	var (
+		candidateNode   string
+		candidateStatus *etcd.MemberStatus
+	)
+
+	for _, node := range nodes.Items {
+		status, err := w.getEtcdStatusForNode(ctx, node.Name)
+		if err != nil {
+			// Members are not defragmented while any member is unavailable.
+			return "", err
+		}
+
+		if !needsDefragmentation(status, thresholdPercent) {
+			continue
+		}
+
+		if candidateStatus == nil || (candidateStatus.IsLeader() && !status.IsLeader()) {
+			candidateNode, candidateStatus = node.Name, status
+		}
+	}
+
+	if candidateStatus == nil {
+		return "", nil
+	}
+
+	etcdClient, err := w.etcdClientGenerator.ForFirstAvailableNode(ctx, []string{candidateNode})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create etcd client")
+	}
+	defer etcdClient.Close()
+
+	members, err := etcdClient.Members(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	member := memberForID(members, candidateStatus.ID)
+	if member == nil {
+		return "", errors.Errorf("etcd member %v not found in the members of the cluster", candidateStatus.ID)
+	}
+
+	log.FromContext(ctx).Info("Defragmenting etcd member", "member", member.Name,
+		"dbSize", candidateStatus.DBSize, "dbSizeInUse", candidateStatus.DBSizeInUse)
+
+	if err := etcdClient.Defragment(ctx); err != nil {
+		return "", err
+	}
+
+	for _, alarm := range member.Alarms {
+		if alarm != etcd.AlarmNoSpace {
+			continue
+		}
+
+		if err := etcdClient.AlarmDisarm(ctx, etcd.MemberAlarm{MemberID: member.ID, Type: alarm}); err != nil {
+			return "", err
+		}
+	}
+
+	return member.Name, nil
+}
+
+// getEtcdStatusForNode returns the status of the etcd member hosted on the node.
+func (w *Workload) getEtcdStatusForNode(ctx context.Context, nodeName string) (*etcd.MemberStatus, error) {
+	etcdClient, err := w.etcdClientGenerator.ForFirstAvailableNode(ctx, []string{nodeName})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create etcd client for node %s", nodeName)
+	}
+	defer etcdClient.Close()
+
+	return etcdClient.Status(ctx)
+}
+
+// needsDefragmentation returns true if the free space of the database of the etcd member is at least the given
+// percentage of its size.
+func needsDefragmentation(status *etcd.MemberStatus, thresholdPercent int32) bool {
+	if status.DBSize <= 0 {
+		return false
+	}
+
+	return (status.DBSize-status.DBSizeInUse)*100 >= int64(thresholdPercent)*status.DBSize
+}
+
+// memberForID returns the etcd member with the given ID.
+func memberForID(members []*etcd.Member, id uint64) *etcd.Member {
+	for _, member := range members {
+		if member.ID == id {
+			return member
+		}
+	}
+
+	return nil
+}
@@ -33,11 +33,15 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
 	etcdfake "github.com/rancher/cluster-api-provider-rke2/pkg/etcd/fake"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 func TestRemoveEtcdMemberForMachine(t *testing.T) {
@@ -474,6 +478,229 @@ func TestReconcileEtcdMembers(t *testing.T) {
 	}
 }
 
+func TestDefragmentEtcdMember(t *testing.T) {
+	// newEtcdClients returns the etcd clients of three members, cp1 being the leader, with the given database sizes in use
+	// for a database size of 100. The leader reports a NOSPACE alarm.
+	newEtcdClients := func(dbSizesInUse map[string]int64) map[string]*etcdfake.FakeEtcdClient {
+		ids := map[string]uint64{"cp1": 1, "cp2": 2, "cp3": 3}
+		clients := map[string]*etcdfake.FakeEtcdClient{}
+
+		for name, id := range ids {
+			clients[name] = &etcdfake.FakeEtcdClient{
+				MemberListResponse: &clientv3.MemberListResponse{
+					Members: []*pb.Member{
+						{Name: "cp1-a1b2c3", ID: 1},
+						{Name: "cp2-d4e5f6", ID: 2},
+						{Name: "cp3-g7h8i9", ID: 3},
+					},
+				},
+				AlarmResponse: &clientv3.AlarmResponse{
+					Alarms: []*pb.AlarmMember{{MemberID: 1, Alarm: pb.AlarmType_NOSPACE}},
+				},
+				StatusResponse: &clientv3.StatusResponse{
+					Header:      &pb.ResponseHeader{MemberId: id},
+					Leader:      1,
+					DbSize:      100,
+					DbSizeInUse: dbSizesInUse[name],
+				},
+				DefragmentResponse: &clientv3.DefragmentResponse{},
+			}
+		}
+
+		return clients
+	}
+
+	tests := []struct {
+		name                 string
+		dbSizesInUse         map[string]int64
+		expectedDefragmented string
+	}{
+		{
+			name:         "does nothing if no member is fragmented",
+			dbSizesInUse: map[string]int64{"cp1": 60, "cp2": 90, "cp3": 100},
+		},
+		{
+			name:                 "defragments a follower before the leader",
+			dbSizesInUse:         map[string]int64{"cp1": 10, "cp2": 60, "cp3": 50},
+			expectedDefragmented: "cp3",
+		},
+		{
+			name:                 "defragments the leader last",
+			dbSizesInUse:         map[string]int64{"cp1": 10, "cp2": 60, "cp3": 60},
+			expectedDefragmented: "cp1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			etcdClients := newEtcdClients(tt.dbSizesInUse)
+			w := &Workload{
+				Client: &fakeClient{list: &corev1.NodeList{
+					Items: []corev1.Node{nodeNamed("cp1"), nodeNamed("cp2"), nodeNamed("cp3")},
+				}},
+				etcdClientGenerator: &fakeEtcdClientGenerator{
+					forNodesClientFunc: func(nodeNames []string) (*etcd.Client, error) {
+						return &etcd.Client{EtcdClient: etcdClients[nodeNames[0]], Endpoint: nodeNames[0]}, nil
+					},
+				},
+			}
+
+			member, err := w.DefragmentEtcdMember(ctx, 50)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			for name, etcdClient := range etcdClients {
+				if name != tt.expectedDefragmented {
+					g.Expect(etcdClient.DefragmentedMembers).To(BeEmpty())
+					g.Expect(etcdClient.DisarmedAlarms).To(BeEmpty())
+
+					continue
+				}
+
+				g.Expect(member).To(HavePrefix(name))
+				g.Expect(etcdClient.DefragmentedMembers).To(Equal([]string{name}))
+
+				// Only the NOSPACE alarm of the defragmented member is disarmed.
+				if name == "cp1" {
+					g.Expect(etcdClient.DisarmedAlarms).To(HaveLen(1))
+				} else {
+					g.Expect(etcdClient.DisarmedAlarms).To(BeEmpty())
+				}
+			}
+
+			if tt.expectedDefragmented == "" {
+				g.Expect(member).To(BeEmpty())
+			}
+		})
+	}
+
+	t.Run("does nothing if a member is unavailable", func(t *testing.T) {
+		g := NewWithT(t)
+
+		etcdClients := newEtcdClients(map[string]int64{"cp1": 10, "cp2": 10, "cp3": 10})
+		w := &Workload{
+			Client: &fakeClient{list: &corev1.NodeList{
+				Items: []corev1.Node{nodeNamed("cp1"), nodeNamed("cp2"), nodeNamed("cp3")},
+			}},
+			etcdClientGenerator: &fakeEtcdClientGenerator{
+				forNodesClientFunc: func(nodeNames []string) (*etcd.Client, error) {
+					if nodeNames[0] == "cp3" {
+						return nil, errors.New("no client")
+					}
+
+					return &etcd.Client{EtcdClient: etcdClients[nodeNames[0]], Endpoint: nodeNames[0]}, nil
+				},
+			},
+		}
+
+		_, err := w.DefragmentEtcdMember(ctx, 50)
+		g.Expect(err).To(HaveOccurred())
+
+		for _, etcdClient := range etcdClients {
+			g.Expect(etcdClient.DefragmentedMembers).To(BeEmpty())
+		}
+	})
+}
+
+func TestUpdateEtcdConditions(t *testing.T) {
+	g := NewWithT(t)
+
+	members := []*pb.Member{
+		{Name: "cp1-a1b2c3", ID: 1},
+		{Name: "cp2-d4e5f6", ID: 2},
+		{Name: "cp3-g7h8i9", ID: 3},
+		{Name: "cp4-j1k2l3", ID: 4, IsLearner: true},
+	}
+
+	newEtcdClient := func(status *clientv3.StatusResponse, alarms []*pb.AlarmMember) *etcd.Client {
+		status.Leader = 2
+		status.RaftTerm = 4
+		status.DbSize = 2 * 1024 * 1024
+		status.DbSizeInUse = 1024 * 1024
+
+		return &etcd.Client{EtcdClient: &etcdfake.FakeEtcdClient{
+			MemberListResponse: &clientv3.MemberListResponse{Members: members},
+			AlarmResponse:      &clientv3.AlarmResponse{Alarms: alarms},
+			StatusResponse:     status,
+		}}
+	}
+
+	etcdClients := map[string]*etcd.Client{
+		"cp1": newEtcdClient(&clientv3.StatusResponse{Header: &pb.ResponseHeader{MemberId: 1}, RaftAppliedIndex: 10000},
+			[]*pb.AlarmMember{{MemberID: 1, Alarm: pb.AlarmType_NOSPACE}}),
+		"cp2": newEtcdClient(&clientv3.StatusResponse{Header: &pb.ResponseHeader{MemberId: 2}, RaftAppliedIndex: 10000}, nil),
+		"cp3": newEtcdClient(&clientv3.StatusResponse{Header: &pb.ResponseHeader{MemberId: 3}, RaftAppliedIndex: 4000}, nil),
+		"cp4": newEtcdClient(&clientv3.StatusResponse{Header: &pb.ResponseHeader{MemberId: 4}, RaftAppliedIndex: 9000, IsLearner: true}, nil),
+	}
+
+	machines := collections.New()
+	nodes := map[string]*corev1.Node{}
+
+	for _, name := range []string{"cp1", "cp2", "cp3", "cp4", "cp5"} {
+		machines.Insert(&clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     clusterv1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: name}},
+		})
+
+		node := nodeNamed(name)
+		nodes[name] = &node
+	}
+
+	w := &Workload{
+		Nodes: nodes,
+		etcdClientGenerator: &fakeEtcdClientGenerator{
+			forNodesClientFunc: func(nodeNames []string) (*etcd.Client, error) {
+				etcdClient, found := etcdClients[nodeNames[0]]
+				if !found {
+					return nil, errors.New("no client")
+				}
+
+				return etcdClient, nil
+			},
+		},
+	}
+
+	controlPlane := &ControlPlane{RCP: &controlplanev1.RKE2ControlPlane{}, Machines: machines}
+	w.UpdateEtcdConditions(ctx, controlPlane)
+
+	g.Expect(conditions.GetReason(machines["cp1"], controlplanev1.MachineEtcdMemberHealthyCondition)).
+		To(Equal(controlplanev1.EtcdMemberUnhealthyReason))
+	g.Expect(conditions.GetMessage(machines["cp1"], controlplanev1.MachineEtcdMemberHealthyCondition)).To(ContainSubstring("NOSPACE"))
+	g.Expect(conditions.IsTrue(machines["cp2"], controlplanev1.MachineEtcdMemberHealthyCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(machines["cp3"], controlplanev1.MachineEtcdMemberHealthyCondition)).
+		To(Equal(controlplanev1.EtcdMemberLaggingReason))
+	g.Expect(conditions.GetMessage(machines["cp3"], controlplanev1.MachineEtcdMemberHealthyCondition)).To(ContainSubstring("lags 6000"))
+	g.Expect(conditions.GetReason(machines["cp4"], controlplanev1.MachineEtcdMemberHealthyCondition)).
+		To(Equal(controlplanev1.EtcdMemberLearnerReason))
+	g.Expect(conditions.IsUnknown(machines["cp5"], controlplanev1.MachineEtcdMemberHealthyCondition)).To(BeTrue())
+
+	etcdMembers := controlPlane.RCP.Status.EtcdMembers
+	g.Expect(etcdMembers).To(HaveLen(4))
+	g.Expect(etcdMembers[0].Name).To(Equal("cp1-a1b2c3"))
+	g.Expect(etcdMembers[0].MachineName).To(Equal("cp1"))
+	g.Expect(etcdMembers[0].Alarms).To(Equal([]string{"NOSPACE"}))
+	g.Expect(etcdMembers[0].DBSize.String()).To(Equal("2Mi"))
+	g.Expect(etcdMembers[0].DBSizeInUse.String()).To(Equal("1Mi"))
+	g.Expect(etcdMembers[0].Leader).To(BeFalse())
+	g.Expect(etcdMembers[0].AppliedIndexLag).To(Equal(ptr.To[int64](0)))
+	g.Expect(etcdMembers[1].Alarms).To(BeEmpty())
+	g.Expect(etcdMembers[1].Leader).To(BeTrue())
+	g.Expect(etcdMembers[1].RaftTerm).To(Equal(int64(4)))
+	g.Expect(etcdMembers[1].RaftAppliedIndex).To(Equal(int64(10000)))
+	g.Expect(etcdMembers[2].AppliedIndexLag).To(Equal(ptr.To[int64](6000)))
+	g.Expect(etcdMembers[3].Learner).To(BeTrue())
+	g.Expect(etcdMembers[3].AppliedIndexLag).To(Equal(ptr.To[int64](1000)))
+
+	// The lag is not known without the status of the leader.
+	delete(etcdClients, "cp2")
+	w.UpdateEtcdConditions(ctx, controlPlane)
+
+	g.Expect(conditions.IsTrue(machines["cp3"], controlplanev1.MachineEtcdMemberHealthyCondition)).To(BeTrue())
+	g.Expect(controlPlane.RCP.Status.EtcdMembers).To(HaveLen(3))
+	g.Expect(controlPlane.RCP.Status.EtcdMembers[1].AppliedIndexLag).To(BeNil())
+}
+
 type fakeEtcdClientGenerator struct {
 	forNodesClient     *etcd.Client
 	forNodesClientFunc func([]string) (*etcd.Client, error)
@@ -595,3 +822,40 @@ func nodeNamed(name string) corev1.Node {
 	}
 	return node
 }
+
+func TestGetEtcdNodes(t *testing.T) {
+	g := NewWithT(t)
+
+	server := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "server",
+		Labels: map[string]string{labelNodeRoleControlPlane: "true", labelNodeRoleEtcd: "true"},
+	}}
+	etcdOnly := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "etcd",
+		Labels: map[string]string{labelNodeRoleEtcd: "true", controlplanev1.ServerRoleLabel: "etcd"},
+	}}
+	controlPlaneOnly := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "control-plane",
+		Labels: map[string]string{labelNodeRoleControlPlane: "true", controlplanev1.ServerRoleLabel: "control-plane"},
+	}}
+	worker := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker"}}
+
+	w := &Workload{Client: fake.NewClientBuilder().WithObjects(server, etcdOnly, controlPlaneOnly, worker).Build()}
+
+	nodeNames := func(nodes *corev1.NodeList) []string {
+		names := []string{}
+		for _, node := range nodes.Items {
+			names = append(names, node.Name)
+		}
+
+		return names
+	}
+
+	nodes, err := w.getControlPlaneNodes(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(nodeNames(nodes)).To(ConsistOf("server", "etcd", "control-plane"))
+
+	nodes, err = w.getEtcdNodes(ctx)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(nodeNames(nodes)).To(ConsistOf("server", "etcd"))
+}
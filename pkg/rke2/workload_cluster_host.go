@@ -0,0 +1,198 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+const (
+	// DefaultHostCommandImage is the image used to run commands on the control plane nodes.
+	DefaultHostCommandImage = "docker.io/library/busybox"
+
+	hostCommandLabel = "controlplane.cluster.x-k8s.io/host-command"
+
+	// hostPath is the PATH used to run commands in the host namespaces.
+	hostPath = "PATH=/usr/local/bin:/usr/local/sbin:/usr/bin:/usr/sbin:/bin:/sbin:/opt/rke2/bin"
+)
+
+// HostCommand is a command run in the host namespaces of a workload cluster node, by a privileged pod.
+// The image must provide nsenter.
+type HostCommand struct {
+	Name     string
+	NodeName string
+	Image    string
+	Command  []string
+
+	// Files are written to FilesDir on the node before the command is run, by path relative to FilesDir.
+	// They are passed through a secret, deleted together with the pod.
+	Files    map[string]string
+	FilesDir string
+}
+
+// hostCommandImage returns the image of the HostCommands run on the nodes of the RKE2ControlPlane.
+func hostCommandImage(rcp *controlplanev1.RKE2ControlPlane) string {
+	return cmp.Or(rcp.Spec.HostCommandImage, DefaultHostCommandImage)
+}
+
+func (c *HostCommand) secret() *corev1.Secret {
+	data := map[string]string{}
+	for path, content := range c.Files {
+		data[secretKey(path)] = content
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.Name,
+			Namespace: metav1.NamespaceSystem,
+			Labels:    map[string]string{hostCommandLabel: c.Name},
+		},
+		StringData: data,
+	}
+}
+
+// secretKey returns a valid secret key for a file path.
+func secretKey(path string) string {
+	return strings.ReplaceAll(path, "/", "_")
+}
+
+func (c *HostCommand) pod() *corev1.Pod {
+	command := append([]string{"nsenter", "--target", "1", "--mount", "--uts", "--ipc", "--net", "--pid", "--", "env", hostPath},
+		c.Command...)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.Name,
+			Namespace: metav1.NamespaceSystem,
+			Labels:    map[string]string{hostCommandLabel: c.Name},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:          c.NodeName,
+			HostPID:           true,
+			RestartPolicy:     corev1.RestartPolicyNever,
+			PriorityClassName: "system-node-critical",
+			Tolerations:       []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{{
+				Name:    "command",
+				Image:   c.Image,
+				Command: command,
+				SecurityContext: &corev1.SecurityContext{
+					Privileged: ptr.To(true),
+				},
+			}},
+		},
+	}
+
+	if len(c.Files) == 0 {
+		return pod
+	}
+
+	items := []corev1.KeyToPath{}
+	for path := range c.Files {
+		items = append(items, corev1.KeyToPath{Key: secretKey(path), Path: path})
+	}
+
+	slices.SortFunc(items, func(a, b corev1.KeyToPath) int { return strings.Compare(a.Path, b.Path) })
+
+	// The files are copied by an init container, as the secret volume is not visible in the host mount namespace.
+	pod.Spec.InitContainers = []corev1.Container{{
+		Name:    "files",
+		Image:   c.Image,
+		Command: []string{"/bin/sh", "-c", "cp -RL /files/* /host/"},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "files", MountPath: "/files", ReadOnly: true},
+			{Name: "host", MountPath: "/host"},
+		},
+	}}
+	pod.Spec.Volumes = []corev1.Volume{
+		{
+			Name: "files",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{
+				SecretName:  c.Name,
+				Items:       items,
+				DefaultMode: ptr.To[int32](0o600),
+			}},
+		},
+		{
+			Name: "host",
+			VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{
+				Path: c.FilesDir,
+				Type: ptr.To(corev1.HostPathDirectoryOrCreate),
+			}},
+		},
+	}
+
+	return pod
+}
+
+// StartHostCommand creates the pod running the command on the node.
+func (w *Workload) StartHostCommand(ctx context.Context, command *HostCommand) error {
+	if len(command.Files) > 0 {
+		if err := w.Client.Create(ctx, command.secret()); err != nil && !apierrors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, "failed to create secret %s", command.Name)
+		}
+	}
+
+	if err := w.Client.Create(ctx, command.pod()); err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "failed to create pod %s", command.Name)
+	}
+
+	return nil
+}
+
+// GetHostCommandPod returns the pod running the command, or nil if it does not exist.
+func (w *Workload) GetHostCommandPod(ctx context.Context, command *HostCommand) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+
+	err := w.Client.Get(ctx, ctrlclient.ObjectKey{Namespace: metav1.NamespaceSystem, Name: command.Name}, pod)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get pod %s", command.Name)
+	}
+
+	return pod, nil
+}
+
+// DeleteHostCommandPod deletes the pod running the command, and the secret storing its files.
+func (w *Workload) DeleteHostCommandPod(ctx context.Context, command *HostCommand) error {
+	if err := w.Client.Delete(ctx, command.pod()); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete pod %s", command.Name)
+	}
+
+	if len(command.Files) > 0 {
+		if err := w.Client.Delete(ctx, command.secret()); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete secret %s", command.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestHostCommandPod(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	w := &Workload{Client: fake.NewClientBuilder().Build()}
+	command := &HostCommand{
+		Name:     "rke2-etcd-snapshot-test",
+		NodeName: "node-1",
+		Image:    DefaultHostCommandImage,
+		Command:  []string{"rke2", "etcd-snapshot", "save"},
+	}
+
+	pod, err := w.GetHostCommandPod(ctx, command)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pod).To(BeNil())
+
+	g.Expect(w.StartHostCommand(ctx, command)).To(Succeed())
+	g.Expect(w.StartHostCommand(ctx, command)).To(Succeed())
+
+	pod, err = w.GetHostCommandPod(ctx, command)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pod).ToNot(BeNil())
+	g.Expect(pod.Namespace).To(Equal(metav1.NamespaceSystem))
+	g.Expect(pod.Spec.NodeName).To(Equal("node-1"))
+	g.Expect(pod.Spec.HostPID).To(BeTrue())
+	g.Expect(pod.Spec.Containers[0].Command).To(HaveExactElements(
+		"nsenter", "--target", "1", "--mount", "--uts", "--ipc", "--net", "--pid", "--", "env", hostPath,
+		"rke2", "etcd-snapshot", "save"))
+
+	g.Expect(w.DeleteHostCommandPod(ctx, command)).To(Succeed())
+	g.Expect(w.DeleteHostCommandPod(ctx, command)).To(Succeed())
+
+	pod, err = w.GetHostCommandPod(ctx, command)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pod).To(BeNil())
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"cmp"
+	"context"
+	"path"
+	"slices"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	utilyaml "sigs.k8s.io/cluster-api/util/yaml"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+// manifestsFieldOwner is the field manager of the manifests applied to the workload cluster.
+const manifestsFieldOwner = "rke2-control-plane-manifests"
+
+// GetManifests returns the manifests of the ManifestsConfigMapReference of the RKE2ControlPlane, keyed by file name.
+// The reference defaults to a ConfigMap, and is restricted to the namespace of the RKE2ControlPlane, as the manifests
+// are written in the user data of the nodes or applied to the workload cluster.
+func GetManifests(ctx context.Context, cl ctrlclient.Client, rcp *controlplanev1.RKE2ControlPlane) (map[string][]byte, error) {
+	ref := rcp.Spec.ManifestsConfigMapReference
+	if ref.Name == "" {
+		return nil, nil
+	}
+
+	if ref.Namespace != "" && ref.Namespace != rcp.Namespace {
+		return nil, errors.Errorf("manifests %s %s/%s are not in the namespace of the control plane", ref.Kind, ref.Namespace, ref.Name)
+	}
+
+	key := ctrlclient.ObjectKey{Namespace: rcp.Namespace, Name: ref.Name}
+
+	if ref.Kind == "Secret" {
+		secret := &corev1.Secret{}
+		if err := cl.Get(ctx, key, secret); err != nil {
+			return nil, errors.Wrapf(err, "failed to get manifests secret %s", key)
+		}
+
+		return secret.Data, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := cl.Get(ctx, key, configMap); err != nil {
+		return nil, errors.Wrapf(err, "failed to get manifests config map %s", key)
+	}
+
+	manifests := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+	for name, content := range configMap.Data {
+		manifests[name] = []byte(content)
+	}
+
+	for name, content := range configMap.BinaryData {
+		manifests[name] = content
+	}
+
+	return manifests, nil
+}
+
+// ParseManifests returns the objects of the given manifests, ordered by file name, then by position in the file.
+// Like the RKE2 deploy controller, only the .yaml, .yml and .json files are parsed. Namespaces and
+// CustomResourceDefinitions are ordered first, as the other objects may depend on them.
+func ParseManifests(manifests map[string][]byte) ([]*unstructured.Unstructured, error) {
+	names := make([]string, 0, len(manifests))
+	for name := range manifests {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	objects := []*unstructured.Unstructured{}
+
+	for _, name := range names {
+		switch path.Ext(name) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+
+		parsed, err := utilyaml.ToUnstructured(manifests[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse manifest %s", name)
+		}
+
+		for i := range parsed {
+			if parsed[i].GetKind() == "" || parsed[i].GetName() == "" {
+				return nil, errors.Errorf("manifest %s contains an object without kind or name", name)
+			}
+
+			objects = append(objects, &parsed[i])
+		}
+	}
+
+	slices.SortStableFunc(objects, func(a, b *unstructured.Unstructured) int {
+		return cmp.Compare(manifestApplyOrder(a), manifestApplyOrder(b))
+	})
+
+	return objects, nil
+}
+
+// manifestApplyOrder returns the order in which the given object is applied.
+func manifestApplyOrder(obj *unstructured.Unstructured) int {
+	switch obj.GetKind() {
+	case "Namespace":
+		return 0
+	case "CustomResourceDefinition":
+		return 1
+	default:
+		return 2 //nolint:mnd
+	}
+}
+
+// manifestReference returns the reference of the given object.
+func manifestReference(obj *unstructured.Unstructured) controlplanev1.ManifestReference {
+	return controlplanev1.ManifestReference{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
+
+// SyncManifests applies the given objects to the workload cluster with server-side apply, then deletes the resources
+// previously applied which are no longer part of them. The namespaced objects without namespace are applied in the
+// default namespace. It returns the resources applied, which include the previously applied resources when the
+// objects can't all be applied, or when they can't be deleted, so that their pruning is retried.
+func (w *Workload) SyncManifests(
+	ctx context.Context,
+	objects []*unstructured.Unstructured,
+	applied []controlplanev1.ManifestReference,
+) ([]controlplanev1.ManifestReference, error) {
+	logger := log.FromContext(ctx)
+	synced := []controlplanev1.ManifestReference{}
+	errs := []error{}
+
+	for _, obj := range objects {
+		obj = obj.DeepCopy()
+
+		namespaced, err := w.Client.IsObjectNamespaced(obj)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to get the scope of %s %s", obj.GetKind(), obj.GetName()))
+
+			continue
+		}
+
+		switch {
+		case !namespaced:
+			obj.SetNamespace("")
+		case obj.GetNamespace() == "":
+			obj.SetNamespace(metav1.NamespaceDefault)
+		}
+
+		ref := manifestReference(obj)
+
+		if err := w.Client.Patch(ctx, obj, ctrlclient.Apply,
+			ctrlclient.FieldOwner(manifestsFieldOwner), ctrlclient.ForceOwnership); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to apply %s %s", ref.Kind, ctrlclient.ObjectKeyFromObject(obj)))
+
+			continue
+		}
+
+		if !slices.Contains(synced, ref) {
+			synced = append(synced, ref)
+		}
+	}
+
+	// The resources previously applied are only pruned once all the objects are applied, to not delete a resource
+	// which failed to be applied, e.g. because its kind changed version.
+	for _, ref := range applied {
+		if slices.Contains(synced, ref) {
+			continue
+		}
+
+		if len(errs) > 0 {
+			synced = append(synced, ref)
+
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(ref.APIVersion)
+		obj.SetKind(ref.Kind)
+		obj.SetNamespace(ref.Namespace)
+		obj.SetName(ref.Name)
+
+		if err := w.Client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, errors.Wrapf(err, "failed to prune %s %s", ref.Kind, ctrlclient.ObjectKeyFromObject(obj)))
+			synced = append(synced, ref)
+
+			continue
+		}
+
+		logger.Info("Pruned manifest resource", "kind", ref.Kind, "namespace", ref.Namespace, "name", ref.Name)
+	}
+
+	return synced, kerrors.NewAggregate(errs)
+}
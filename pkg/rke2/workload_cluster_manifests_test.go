@@ -0,0 +1,127 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+func TestParseManifests(t *testing.T) {
+	g := NewWithT(t)
+
+	objects, err := ParseManifests(map[string][]byte{
+		"b.yaml":   []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n"),
+		"a.yml":    []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: ns\n"),
+		"c.json":   []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "c"}}`),
+		"d.skip":   []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: d\n"),
+		"README":   []byte("not a manifest"),
+		"e.yaml":   []byte("---\n---\n"),
+		"f.yaml.1": []byte("not a manifest"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	names := []string{}
+	for _, obj := range objects {
+		names = append(names, obj.GetName())
+	}
+
+	g.Expect(names).To(Equal([]string{"ns", "a", "b", "c"}))
+
+	_, err = ParseManifests(map[string][]byte{"a.yaml": []byte("apiVersion: v1\nkind: ConfigMap\n")})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestSyncManifests(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	restMapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+
+	// The fake client does not support server-side apply, which is emulated with a create or update.
+	cl := fake.NewClientBuilder().
+		WithRESTMapper(restMapper).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				if patch.Type() != types.ApplyPatchType {
+					return c.Patch(ctx, obj, patch, opts...)
+				}
+
+				existing := obj.DeepCopyObject().(client.Object)
+				if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); apierrors.IsNotFound(err) {
+					return c.Create(ctx, obj)
+				}
+
+				obj.SetResourceVersion(existing.GetResourceVersion())
+
+				return c.Update(ctx, obj)
+			},
+		}).
+		Build()
+	w := &Workload{Client: cl}
+
+	objects, err := ParseManifests(map[string][]byte{
+		"addons.yaml": []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: addons\n" +
+			"---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: first\n" +
+			"---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: second\n  namespace: addons\n"),
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	applied, err := w.SyncManifests(ctx, objects, nil)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(applied).To(Equal([]controlplanev1.ManifestReference{
+		{APIVersion: "v1", Kind: "Namespace", Name: "addons"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "first"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "addons", Name: "second"},
+	}))
+
+	// The namespaced objects without namespace are applied in the default namespace.
+	g.Expect(cl.Get(ctx, client.ObjectKey{Namespace: "default", Name: "first"}, &corev1.ConfigMap{})).To(Succeed())
+
+	// The resources removed from the manifests are pruned.
+	applied, err = w.SyncManifests(ctx, objects[:2], applied)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(applied).To(HaveLen(2))
+
+	err = cl.Get(ctx, client.ObjectKey{Namespace: "addons", Name: "second"}, &corev1.ConfigMap{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	// The resources are not pruned when the manifests can't all be applied.
+	unknown := &unstructured.Unstructured{}
+	unknown.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Unknown"})
+	unknown.SetName("unknown")
+
+	applied, err = w.SyncManifests(ctx, []*unstructured.Unstructured{unknown}, applied)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(applied).To(HaveLen(2))
+	g.Expect(cl.Get(ctx, client.ObjectKey{Namespace: "default", Name: "first"}, &corev1.ConfigMap{})).To(Succeed())
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+const (
+	// DefaultEtcdSnapshotDir is the directory where RKE2 stores local etcd snapshots.
+	DefaultEtcdSnapshotDir = "/var/lib/rancher/rke2/server/db/snapshots"
+
+	// etcdRestoreScript stops the rke2-server service, resets the etcd cluster membership restoring the snapshot,
+	// and starts the rke2-server service again, even if the restore failed.
+	etcdRestoreScript = `rc=0; systemctl stop rke2-server && rke2 server --cluster-reset "$@" || rc=$?; systemctl start rke2-server; exit $rc`
+)
+
+// NewEtcdRestoreCommand returns the HostCommand restoring the RKE2EtcdRestore snapshot on the given node.
+// The restore script runs as a transient systemd unit, so that it is not interrupted when the kubelet
+// is restarted together with the rke2-server service.
+// NOTE: the restored etcd data does not contain the pod running the command, so a missing pod after
+// the restore was started means the snapshot has been restored.
+func NewEtcdRestoreCommand(restore *controlplanev1.RKE2EtcdRestore, rcp *controlplanev1.RKE2ControlPlane, nodeName string) *HostCommand {
+	snapshotPath := restore.Spec.Snapshot.Name
+	s3 := restore.Spec.Snapshot.Source == controlplanev1.EtcdSnapshotSourceS3
+
+	if !s3 {
+		snapshotPath = path.Join(cmp.Or(rcp.Spec.ServerConfig.Etcd.BackupConfig.Directory, DefaultEtcdSnapshotDir), snapshotPath)
+	}
+
+	return &HostCommand{
+		Name:     "rke2-etcd-restore-" + restore.Name,
+		NodeName: nodeName,
+		Image:    cmp.Or(restore.Spec.Image, DefaultHostCommandImage),
+		Command: []string{
+			"systemd-run", "--unit=rke2-etcd-restore", "--wait", "--collect", "--service-type=oneshot",
+			"--setenv=" + hostPath,
+			"/bin/sh", "-c", etcdRestoreScript, "sh",
+			"--cluster-reset-restore-path=" + snapshotPath,
+			fmt.Sprintf("--etcd-s3=%t", s3),
+		},
+	}
+}
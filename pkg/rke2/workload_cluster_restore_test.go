@@ -0,0 +1,53 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+func TestNewEtcdRestoreCommand(t *testing.T) {
+	g := NewWithT(t)
+
+	restore := &controlplanev1.RKE2EtcdRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore"},
+		Spec: controlplanev1.RKE2EtcdRestoreSpec{
+			Snapshot: controlplanev1.EtcdRestoreSnapshot{Name: "etcd-snapshot-1"},
+		},
+	}
+	rcp := &controlplanev1.RKE2ControlPlane{}
+
+	command := NewEtcdRestoreCommand(restore, rcp, "node-1")
+	g.Expect(command.Name).To(Equal("rke2-etcd-restore-restore"))
+	g.Expect(command.NodeName).To(Equal("node-1"))
+	g.Expect(command.Image).To(Equal(DefaultHostCommandImage))
+	g.Expect(command.Command).To(ContainElements(
+		"--cluster-reset-restore-path="+DefaultEtcdSnapshotDir+"/etcd-snapshot-1", "--etcd-s3=false"))
+
+	rcp.Spec.ServerConfig.Etcd.BackupConfig.Directory = "/data/snapshots"
+	g.Expect(NewEtcdRestoreCommand(restore, rcp, "node-1").Command).
+		To(ContainElement("--cluster-reset-restore-path=/data/snapshots/etcd-snapshot-1"))
+
+	restore.Spec.Snapshot.Source = controlplanev1.EtcdSnapshotSourceS3
+	g.Expect(NewEtcdRestoreCommand(restore, rcp, "node-1").Command).
+		To(ContainElements("--cluster-reset-restore-path=etcd-snapshot-1", "--etcd-s3=true"))
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+// etcdSnapshotFileGVK is the GroupVersionKind of the RKE2 ETCDSnapshotFile, describing an etcd snapshot.
+var etcdSnapshotFileGVK = schema.GroupVersionKind{Group: "k3s.cattle.io", Version: "v1", Kind: "ETCDSnapshotFileList"}
+
+// NewEtcdSnapshotCommand returns the HostCommand taking the RKE2EtcdSnapshot on the given node.
+// The snapshot is uploaded to S3 when it is configured in the etcd backup configuration of the node.
+func NewEtcdSnapshotCommand(snapshot *controlplanev1.RKE2EtcdSnapshot, nodeName string) *HostCommand {
+	return &HostCommand{
+		Name:     "rke2-etcd-snapshot-" + snapshot.Name,
+		NodeName: nodeName,
+		Image:    cmp.Or(snapshot.Spec.Image, DefaultHostCommandImage),
+		Command: []string{
+			"rke2", "etcd-snapshot", "save", "--name", cmp.Or(snapshot.Spec.SnapshotName, snapshot.Name),
+		},
+	}
+}
+
+// EtcdSnapshots returns the etcd snapshots reported by the ETCDSnapshotFile objects, newest first.
+// Snapshots which are not ready to use are not returned.
+func (w *Workload) EtcdSnapshots(ctx context.Context) ([]controlplanev1.EtcdSnapshotInfo, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(etcdSnapshotFileGVK)
+
+	if err := w.Client.List(ctx, list); err != nil {
+		// ETCDSnapshotFile is not available on older RKE2 versions.
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "failed to list etcd snapshot files")
+	}
+
+	snapshots := []controlplanev1.EtcdSnapshotInfo{}
+
+	for _, item := range list.Items {
+		if ready, found, _ := unstructured.NestedBool(item.Object, "status", "readyToUse"); found && !ready {
+			continue
+		}
+
+		snapshot := controlplanev1.EtcdSnapshotInfo{Source: controlplanev1.EtcdSnapshotSourceLocal}
+		snapshot.Name, _, _ = unstructured.NestedString(item.Object, "spec", "snapshotName")
+		snapshot.NodeName, _, _ = unstructured.NestedString(item.Object, "spec", "nodeName")
+		snapshot.Location, _, _ = unstructured.NestedString(item.Object, "spec", "location")
+
+		if strings.HasPrefix(snapshot.Location, "s3://") {
+			snapshot.Source = controlplanev1.EtcdSnapshotSourceS3
+		}
+
+		if size, found, _ := unstructured.NestedString(item.Object, "status", "size"); found {
+			if quantity, err := resource.ParseQuantity(size); err == nil {
+				snapshot.Size = &quantity
+			}
+		}
+
+		if creationTime, found, _ := unstructured.NestedString(item.Object, "status", "creationTime"); found {
+			timestamp := &metav1.Time{}
+			if err := timestamp.UnmarshalQueryParameter(creationTime); err == nil {
+				snapshot.CreationTime = timestamp
+			}
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	slices.SortStableFunc(snapshots, func(a, b controlplanev1.EtcdSnapshotInfo) int {
+		switch {
+		case a.CreationTime == nil && b.CreationTime == nil:
+			return cmp.Compare(a.Name, b.Name)
+		case a.CreationTime == nil:
+			return 1
+		case b.CreationTime == nil:
+			return -1
+		}
+
+		return b.CreationTime.Compare(a.CreationTime.Time)
+	})
+
+	return snapshots, nil
+}
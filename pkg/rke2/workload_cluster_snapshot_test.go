@@ -0,0 +1,87 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+func TestNewEtcdSnapshotCommand(t *testing.T) {
+	g := NewWithT(t)
+
+	snapshot := &controlplanev1.RKE2EtcdSnapshot{ObjectMeta: metav1.ObjectMeta{Name: "before-upgrade"}}
+	command := NewEtcdSnapshotCommand(snapshot, "node-1")
+	g.Expect(command.Name).To(Equal("rke2-etcd-snapshot-before-upgrade"))
+	g.Expect(command.Command).To(HaveExactElements("rke2", "etcd-snapshot", "save", "--name", "before-upgrade"))
+
+	snapshot.Spec.SnapshotName = "manual"
+	g.Expect(NewEtcdSnapshotCommand(snapshot, "node-1").Command).To(HaveExactElements("rke2", "etcd-snapshot", "save", "--name", "manual"))
+}
+
+func TestEtcdSnapshots(t *testing.T) {
+	g := NewWithT(t)
+
+	snapshotFile := func(name, location, creationTime string, ready bool) client.Object {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"snapshotName": name,
+				"nodeName":     "node-1",
+				"location":     location,
+			},
+			"status": map[string]interface{}{
+				"size":         "10Mi",
+				"creationTime": creationTime,
+				"readyToUse":   ready,
+			},
+		}}
+		obj.SetGroupVersionKind(schema.GroupVersionKind{Group: "k3s.cattle.io", Version: "v1", Kind: "ETCDSnapshotFile"})
+		obj.SetName(name)
+
+		return obj
+	}
+
+	scheme := runtime.NewScheme()
+	w := &Workload{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		snapshotFile("local", "file:///var/lib/rancher/rke2/server/db/snapshots/local", "2024-06-01T10:00:00Z", true),
+		snapshotFile("remote", "s3://bucket/remote", "2024-06-02T10:00:00Z", true),
+		snapshotFile("broken", "file:///var/lib/rancher/rke2/server/db/snapshots/broken", "2024-06-03T10:00:00Z", false),
+	).Build()}
+
+	snapshots, err := w.EtcdSnapshots(context.Background())
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(snapshots).To(HaveLen(2))
+
+	g.Expect(snapshots[0].Name).To(Equal("remote"))
+	g.Expect(snapshots[0].Source).To(Equal(controlplanev1.EtcdSnapshotSourceS3))
+	g.Expect(snapshots[1].Name).To(Equal("local"))
+	g.Expect(snapshots[1].Source).To(Equal(controlplanev1.EtcdSnapshotSourceLocal))
+	g.Expect(snapshots[1].NodeName).To(Equal("node-1"))
+	g.Expect(snapshots[1].Size.Equal(resource.MustParse("10Mi"))).To(BeTrue())
+	g.Expect(snapshots[1].CreationTime.UTC().Format("2006-01-02")).To(Equal("2024-06-01"))
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+const (
+	// serverTokenRotationDir is the directory the tokens are written to on the node.
+	serverTokenRotationDir = "/var/lib/rancher/rke2/token-rotation"
+
+	// serverTokenRotationScript encrypts the bootstrap data stored in the datastore with the new token, and removes the
+	// tokens from the node.
+	serverTokenRotationScript = `dir=$1; rc=0
+rke2 token rotate --token="$(cat "$dir/token")" --new-token="$(cat "$dir/new-token")" || rc=$?; rm -rf "$dir"; exit $rc`
+
+	// nodeTokenUpdateScript replaces the token in the RKE2 configuration of the node, removes the token from the node,
+	// then restarts the given service, if any.
+	nodeTokenUpdateScript = `dir=$1; config=$2; service=$3; rc=0
+sed -i "s|^token:.*|token: $(cat "$dir/token")|" "$config" || rc=$?; rm -rf "$dir"
+[ $rc -ne 0 ] || [ -z "$service" ] || systemctl restart "$service" || rc=$?; exit $rc`
+
+	// RKE2ServerService is the systemd service running RKE2 on the control plane nodes.
+	RKE2ServerService = "rke2-server"
+)
+
+// NewServerTokenRotationCommand returns the HostCommand encrypting the bootstrap data stored in the datastore of the
+// control plane with the new token, instead of the current one.
+// NOTE: the servers keep accepting the current token until they are restarted with the new one.
+func NewServerTokenRotationCommand(rcp *controlplanev1.RKE2ControlPlane, token, newToken, nodeName string) *HostCommand {
+	return &HostCommand{
+		Name:     "rke2-token-rotation-" + rcp.Name,
+		NodeName: nodeName,
+		Image:    hostCommandImage(rcp),
+		Command:  []string{"/bin/sh", "-c", serverTokenRotationScript, "sh", serverTokenRotationDir},
+		Files: map[string]string{
+			"token":     token,
+			"new-token": newToken,
+		},
+		FilesDir: serverTokenRotationDir,
+	}
+}
+
+// NewNodeTokenUpdateCommand returns the HostCommand updating the token in the RKE2 configuration of the node of the
+// given machine, then restarting the given service, if any, to use it.
+func NewNodeTokenUpdateCommand(rcp *controlplanev1.RKE2ControlPlane, machineName, nodeName, token, service string) *HostCommand {
+	return &HostCommand{
+		Name:     "rke2-token-update-" + machineName,
+		NodeName: nodeName,
+		Image:    hostCommandImage(rcp),
+		Command:  []string{"/bin/sh", "-c", nodeTokenUpdateScript, "sh", serverTokenRotationDir, DefaultRKE2ConfigLocation, service},
+		Files:    map[string]string{"token": token},
+		FilesDir: serverTokenRotationDir,
+	}
+}
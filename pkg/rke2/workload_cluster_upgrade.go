@@ -0,0 +1,163 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"cmp"
+	"context"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+const (
+	// DefaultUpgradeImage is the image used by the system-upgrade-controller to upgrade RKE2 on the nodes.
+	DefaultUpgradeImage = "rancher/rke2-upgrade"
+
+	// DefaultUpgradeNamespace is the namespace where the system-upgrade-controller is running.
+	DefaultUpgradeNamespace = "system-upgrade"
+
+	// DefaultUpgradeServiceAccountName is the service account used by the system-upgrade-controller upgrade jobs.
+	DefaultUpgradeServiceAccountName = "system-upgrade"
+
+	// upgradePlanName is the name of the Plan upgrading the control plane nodes.
+	upgradePlanName = "rke2-control-plane"
+
+	// etcdUpgradePlanName is the name of the Plan upgrading the nodes of the etcd server pool.
+	etcdUpgradePlanName = "rke2-etcd"
+)
+
+// upgradePlanGVK is the GroupVersionKind of the system-upgrade-controller Plan.
+var upgradePlanGVK = schema.GroupVersionKind{Group: "upgrade.cattle.io", Version: "v1", Kind: "Plan"}
+
+// UpgradePlan describes a system-upgrade-controller Plan upgrading RKE2 on the control plane nodes in place.
+type UpgradePlan struct {
+	Name               string
+	Namespace          string
+	ServiceAccountName string
+	Image              string
+	Version            string
+	NodeSelector       metav1.LabelSelector
+}
+
+// NewUpgradePlan returns the UpgradePlan for the RKE2ControlPlane desired version, upgrading the nodes of the etcd
+// server pool for the etcd role, or the nodes running the kube-apiserver otherwise, as the etcd nodes don't have the
+// control plane node role.
+func NewUpgradePlan(rcp *controlplanev1.RKE2ControlPlane, role controlplanev1.ServerRole) *UpgradePlan {
+	inPlaceUpgrade := &controlplanev1.InPlaceUpgrade{}
+	if rcp.Spec.RolloutStrategy != nil && rcp.Spec.RolloutStrategy.InPlaceUpgrade != nil {
+		inPlaceUpgrade = rcp.Spec.RolloutStrategy.InPlaceUpgrade
+	}
+
+	plan := &UpgradePlan{
+		Name:               upgradePlanName,
+		Namespace:          cmp.Or(inPlaceUpgrade.Namespace, DefaultUpgradeNamespace),
+		ServiceAccountName: cmp.Or(inPlaceUpgrade.ServiceAccountName, DefaultUpgradeServiceAccountName),
+		Image:              cmp.Or(inPlaceUpgrade.Image, DefaultUpgradeImage),
+		Version:            rcp.GetDesiredVersion(),
+		NodeSelector: metav1.LabelSelector{
+			MatchLabels: map[string]string{labelNodeRoleControlPlane: "true"},
+		},
+	}
+
+	if role == controlplanev1.ServerRoleEtcd {
+		plan.Name = etcdUpgradePlanName
+		plan.NodeSelector = metav1.LabelSelector{
+			MatchLabels: map[string]string{labelNodeRoleEtcd: "true"},
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: labelNodeRoleControlPlane, Operator: metav1.LabelSelectorOpDoesNotExist},
+			},
+		}
+	}
+
+	return plan
+}
+
+func (p *UpgradePlan) object() *unstructured.Unstructured {
+	plan := &unstructured.Unstructured{}
+	plan.SetGroupVersionKind(upgradePlanGVK)
+	plan.SetName(p.Name)
+	plan.SetNamespace(p.Namespace)
+
+	return plan
+}
+
+// ApplyUpgradePlan creates or updates the system-upgrade-controller Plan upgrading the selected nodes
+// one at a time to the plan version.
+func (w *Workload) ApplyUpgradePlan(ctx context.Context, plan *UpgradePlan) error {
+	obj := plan.object()
+
+	nodeSelector, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&plan.NodeSelector)
+	if err != nil {
+		return errors.Wrapf(err, "failed to convert node selector of upgrade plan %s/%s", plan.Namespace, plan.Name)
+	}
+
+	result, err := controllerutil.CreateOrUpdate(ctx, w.Client, obj, func() error {
+		spec := map[string]interface{}{
+			"concurrency":        int64(1),
+			"cordon":             true,
+			"serviceAccountName": plan.ServiceAccountName,
+			"version":            plan.Version,
+			"nodeSelector":       nodeSelector,
+			"tolerations": []interface{}{
+				map[string]interface{}{"operator": "Exists"},
+			},
+			"upgrade": map[string]interface{}{
+				"image": plan.Image,
+			},
+		}
+
+		return unstructured.SetNestedField(obj.Object, spec, "spec")
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to apply upgrade plan %s/%s", plan.Namespace, plan.Name)
+	}
+
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("Applied upgrade plan", "plan", plan.Name, "version", plan.Version, "operation", result)
+	}
+
+	return nil
+}
+
+// DeleteUpgradePlan deletes the system-upgrade-controller Plan upgrading the selected nodes.
+func (w *Workload) DeleteUpgradePlan(ctx context.Context, plan *UpgradePlan) error {
+	if err := w.Client.Delete(ctx, plan.object()); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete upgrade plan %s/%s", plan.Namespace, plan.Name)
+	}
+
+	return nil
+}
+
+// NodeVersion returns the RKE2 version reported by the kubelet of a control plane node.
+// NOTE: this func uses nodes fetched by InitWorkload, it is required to call it before this.
+func (w *Workload) NodeVersion(nodeName string) (string, bool) {
+	node, ok := w.Nodes[nodeName]
+	if !ok {
+		return "", false
+	}
+
+	return node.Status.NodeInfo.KubeletVersion, true
+}
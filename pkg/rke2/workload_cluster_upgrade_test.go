@@ -0,0 +1,109 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+)
+
+func TestNewUpgradePlan(t *testing.T) {
+	g := NewWithT(t)
+
+	rcp := &controlplanev1.RKE2ControlPlane{Spec: controlplanev1.RKE2ControlPlaneSpec{Version: "v1.30.2+rke2r1"}}
+	plan := NewUpgradePlan(rcp, "")
+	g.Expect(plan.Name).To(Equal(upgradePlanName))
+	g.Expect(plan.NodeSelector.MatchLabels).To(Equal(map[string]string{labelNodeRoleControlPlane: "true"}))
+	g.Expect(plan.NodeSelector.MatchExpressions).To(BeEmpty())
+	g.Expect(plan.Namespace).To(Equal(DefaultUpgradeNamespace))
+	g.Expect(plan.ServiceAccountName).To(Equal(DefaultUpgradeServiceAccountName))
+	g.Expect(plan.Image).To(Equal(DefaultUpgradeImage))
+	g.Expect(plan.Version).To(Equal("v1.30.2+rke2r1"))
+
+	rcp.Spec.RolloutStrategy = &controlplanev1.RolloutStrategy{
+		Type: controlplanev1.InPlaceUpgradeStrategyType,
+		InPlaceUpgrade: &controlplanev1.InPlaceUpgrade{
+			Image:     "registry.example.com/rancher/rke2-upgrade",
+			Namespace: "cattle-system",
+		},
+	}
+	plan = NewUpgradePlan(rcp, "")
+	g.Expect(plan.Namespace).To(Equal("cattle-system"))
+	g.Expect(plan.ServiceAccountName).To(Equal(DefaultUpgradeServiceAccountName))
+	g.Expect(plan.Image).To(Equal("registry.example.com/rancher/rke2-upgrade"))
+
+	// The nodes of the etcd server pool, which don't have the control plane node role, have their own plan.
+	plan = NewUpgradePlan(rcp, controlplanev1.ServerRoleEtcd)
+	g.Expect(plan.Name).To(Equal(etcdUpgradePlanName))
+	g.Expect(plan.Namespace).To(Equal("cattle-system"))
+	g.Expect(plan.NodeSelector.MatchLabels).To(Equal(map[string]string{labelNodeRoleEtcd: "true"}))
+	g.Expect(plan.NodeSelector.MatchExpressions).To(ConsistOf(metav1.LabelSelectorRequirement{
+		Key:      labelNodeRoleControlPlane,
+		Operator: metav1.LabelSelectorOpDoesNotExist,
+	}))
+}
+
+func TestApplyAndDeleteUpgradePlan(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	w := &Workload{Client: fake.NewClientBuilder().Build()}
+	plan := NewUpgradePlan(&controlplanev1.RKE2ControlPlane{
+		Spec: controlplanev1.RKE2ControlPlaneSpec{Version: "v1.30.2+rke2r1"},
+	}, controlplanev1.ServerRoleEtcd)
+
+	g.Expect(w.ApplyUpgradePlan(ctx, plan)).To(Succeed())
+
+	plan.Version = "v1.30.3+rke2r1"
+	g.Expect(w.ApplyUpgradePlan(ctx, plan)).To(Succeed())
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(upgradePlanGVK)
+	g.Expect(w.Client.Get(ctx, client.ObjectKey{Namespace: DefaultUpgradeNamespace, Name: etcdUpgradePlanName}, obj)).To(Succeed())
+
+	version, _, err := unstructured.NestedString(obj.Object, "spec", "version")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(version).To(Equal("v1.30.3+rke2r1"))
+
+	image, _, err := unstructured.NestedString(obj.Object, "spec", "upgrade", "image")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(image).To(Equal(DefaultUpgradeImage))
+
+	nodeSelector, _, err := unstructured.NestedMap(obj.Object, "spec", "nodeSelector")
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(nodeSelector).To(Equal(map[string]interface{}{
+		"matchLabels": map[string]interface{}{labelNodeRoleEtcd: "true"},
+		"matchExpressions": []interface{}{
+			map[string]interface{}{"key": labelNodeRoleControlPlane, "operator": "DoesNotExist"},
+		},
+	}))
+
+	g.Expect(w.DeleteUpgradePlan(ctx, plan)).To(Succeed())
+	g.Expect(apierrors.IsNotFound(w.Client.Get(ctx, client.ObjectKeyFromObject(obj), obj))).To(BeTrue())
+
+	// Deleting a missing plan is not an error.
+	g.Expect(w.DeleteUpgradePlan(ctx, plan)).To(Succeed())
+}
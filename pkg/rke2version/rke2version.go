@@ -0,0 +1,75 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rke2version parses and compares RKE2 versions.
+package rke2version
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/blang/semver/v4"
+	"github.com/pkg/errors"
+)
+
+// rke2VersionRegex matches RKE2 versions, made of a Kubernetes version and an RKE2 release number, e.g. v1.30.2+rke2r1.
+var rke2VersionRegex = regexp.MustCompile(`^v(\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?)\+rke2r(\d+)$`)
+
+// RKE2Version is a parsed RKE2 version.
+type RKE2Version struct {
+	// Kubernetes is the Kubernetes version shipped by the RKE2 release.
+	Kubernetes semver.Version
+
+	// Release is the RKE2 release number for the Kubernetes version.
+	Release uint64
+}
+
+// ParseRKE2Version parses an RKE2 version, e.g. v1.30.2+rke2r1.
+func ParseRKE2Version(rke2Version string) (RKE2Version, error) {
+	matches := rke2VersionRegex.FindStringSubmatch(rke2Version)
+	if matches == nil {
+		return RKE2Version{}, errors.Errorf("%q is not a valid RKE2 version, expected format is vX.Y.Z+rke2rN", rke2Version)
+	}
+
+	kubernetesVersion, err := semver.Parse(matches[1])
+	if err != nil {
+		return RKE2Version{}, errors.Wrapf(err, "failed to parse Kubernetes version of %q", rke2Version)
+	}
+
+	release, err := strconv.ParseUint(matches[2], 10, 64)
+	if err != nil {
+		return RKE2Version{}, errors.Wrapf(err, "failed to parse RKE2 release of %q", rke2Version)
+	}
+
+	return RKE2Version{Kubernetes: kubernetesVersion, Release: release}, nil
+}
+
+// Compare returns -1, 0 or 1 if the version is lower, equal or greater than the other one,
+// comparing the Kubernetes versions first, then the RKE2 releases.
+func (v RKE2Version) Compare(other RKE2Version) int {
+	if c := v.Kubernetes.Compare(other.Kubernetes); c != 0 {
+		return c
+	}
+
+	switch {
+	case v.Release < other.Release:
+		return -1
+	case v.Release > other.Release:
+		return 1
+	default:
+		return 0
+	}
+}
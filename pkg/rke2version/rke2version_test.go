@@ -0,0 +1,114 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rke2version
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	. "github.com/onsi/gomega"
+)
+
+func TestParseRKE2Version(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    RKE2Version
+		wantErr bool
+	}{
+		{
+			name:    "RKE2 version",
+			version: "v1.30.2+rke2r1",
+			want:    RKE2Version{Kubernetes: semver.MustParse("1.30.2"), Release: 1},
+		},
+		{
+			name:    "multiple digits",
+			version: "v1.30.12+rke2r10",
+			want:    RKE2Version{Kubernetes: semver.MustParse("1.30.12"), Release: 10},
+		},
+		{
+			name:    "pre-release",
+			version: "v1.31.0-rc1+rke2r1",
+			want:    RKE2Version{Kubernetes: semver.MustParse("1.31.0-rc1"), Release: 1},
+		},
+		{
+			name:    "Kubernetes version",
+			version: "v1.30.2",
+			wantErr: true,
+		},
+		{
+			name:    "missing v prefix",
+			version: "1.30.2+rke2r1",
+			wantErr: true,
+		},
+		{
+			name:    "K3s version",
+			version: "v1.30.2+k3s1",
+			wantErr: true,
+		},
+		{
+			name:    "missing release",
+			version: "v1.30.2+rke2r",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			got, err := ParseRKE2Version(tt.version)
+			if tt.wantErr {
+				g.Expect(err).To(MatchError(ContainSubstring("is not a valid RKE2 version")))
+
+				return
+			}
+
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(got).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestRKE2VersionCompare(t *testing.T) {
+	tests := []struct {
+		name  string
+		v     string
+		other string
+		want  int
+	}{
+		{name: "equal", v: "v1.30.2+rke2r1", other: "v1.30.2+rke2r1", want: 0},
+		{name: "lower Kubernetes version", v: "v1.30.2+rke2r2", other: "v1.30.10+rke2r1", want: -1},
+		{name: "greater Kubernetes version", v: "v1.31.0+rke2r1", other: "v1.30.2+rke2r3", want: 1},
+		{name: "lower release", v: "v1.30.2+rke2r1", other: "v1.30.2+rke2r2", want: -1},
+		{name: "greater release", v: "v1.30.2+rke2r10", other: "v1.30.2+rke2r9", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			v, err := ParseRKE2Version(tt.v)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			other, err := ParseRKE2Version(tt.other)
+			g.Expect(err).ToNot(HaveOccurred())
+
+			g.Expect(v.Compare(other)).To(Equal(tt.want))
+		})
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2024 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secret
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/cluster-api/util/certs"
+)
+
+// Previous returns the purpose of the secret keeping the certificate authority replaced by a rotation.
+func Previous(purpose Purpose) Purpose {
+	return purpose + "-previous"
+}
+
+// Next returns the purpose of the secret storing the certificate authority introduced by a rotation.
+func Next(purpose Purpose) Purpose {
+	return purpose + "-next"
+}
+
+// Rotation holds the certificate authorities involved in the rotation of a set of certificates:
+// the previous certificate authorities being replaced, and the next ones replacing them.
+type Rotation struct {
+	certificates Certificates
+	previous     Certificates
+	next         Certificates
+}
+
+// LookupOrGenerateRotation returns the rotation of the certificates. The first time it is called,
+// the current certificate authorities are saved as the previous ones, and the next ones are generated.
+// Later calls return the same rotation until it is deleted, even if the certificates were updated.
+func (c Certificates) LookupOrGenerateRotation(
+	ctx context.Context,
+	ctrlclient client.Client,
+	clusterName client.ObjectKey,
+	owner metav1.OwnerReference,
+) (*Rotation, error) {
+	if err := c.Lookup(ctx, ctrlclient, clusterName); err != nil {
+		return nil, err
+	}
+
+	rotation := &Rotation{certificates: c}
+
+	for _, certificate := range c {
+		if certificate.GetKeyPair() == nil || certificate.IsExternal() {
+			return nil, errors.Errorf("certificate %s can't be rotated", certificate.GetPurpose())
+		}
+
+		managed, ok := certificate.(*ManagedCertificate)
+		if !ok {
+			return nil, errors.Errorf("certificate %s can't be rotated", certificate.GetPurpose())
+		}
+
+		previous := &ManagedCertificate{Purpose: Previous(managed.Purpose), CertFile: managed.CertFile, KeyFile: managed.KeyFile}
+
+		s, err := previous.Lookup(ctx, ctrlclient, clusterName)
+		if err != nil {
+			return nil, err
+		}
+
+		if s != nil {
+			if previous.KeyPair, err = secretToKeyPair(s); err != nil {
+				return nil, err
+			}
+		} else {
+			// Only the first certificate is kept, in case the certificate is already a bundle.
+			cert, err := certs.DecodeCertPEM(managed.KeyPair.Cert)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decode certificate %s", managed.Purpose)
+			}
+
+			previous.KeyPair = &certs.KeyPair{Cert: certs.EncodeCertPEM(cert), Key: managed.KeyPair.Key}
+			previous.Generated = true
+		}
+
+		rotation.previous = append(rotation.previous, previous)
+		rotation.next = append(rotation.next, &ManagedCertificate{
+			Purpose:  Next(managed.Purpose),
+			CertFile: managed.CertFile,
+			KeyFile:  managed.KeyFile,
+		})
+	}
+
+	if err := rotation.previous.SaveGenerated(ctx, ctrlclient, clusterName, owner); err != nil {
+		return nil, err
+	}
+
+	if err := rotation.next.LookupOrGenerate(ctx, ctrlclient, clusterName, owner); err != nil {
+		return nil, err
+	}
+
+	return rotation, nil
+}
+
+// TrustingNext returns the certificates signed by the previous certificate authorities, trusting both
+// the previous and next ones.
+func (r *Rotation) TrustingNext() Certificates {
+	return r.bundle(r.previous, r.next)
+}
+
+// SigningWithNext returns the certificates signed by the next certificate authorities, trusting both
+// the next and previous ones.
+func (r *Rotation) SigningWithNext() Certificates {
+	return r.bundle(r.next, r.previous)
+}
+
+// Completed returns the certificates signed and trusted by the next certificate authorities only.
+func (r *Rotation) Completed() Certificates {
+	return r.bundle(r.next, nil)
+}
+
+// bundle returns the certificates with the purposes of the rotated ones, made of the signing certificate
+// authorities followed by the trusted ones, as expected by RKE2 and by clients trusting the whole bundle.
+func (r *Rotation) bundle(signing, trusted Certificates) Certificates {
+	bundle := Certificates{}
+
+	for i, certificate := range r.certificates {
+		managed, _ := certificate.(*ManagedCertificate)
+		keyPair := signing[i].GetKeyPair()
+		cert := keyPair.Cert
+
+		if trusted != nil {
+			cert = bytes.Join([][]byte{cert, trusted[i].GetKeyPair().Cert}, nil)
+		}
+
+		bundle = append(bundle, &ManagedCertificate{
+			Purpose:  managed.Purpose,
+			CertFile: managed.CertFile,
+			KeyFile:  managed.KeyFile,
+			KeyPair:  &certs.KeyPair{Cert: cert, Key: keyPair.Key},
+		})
+	}
+
+	return bundle
+}
+
+// Delete deletes the secrets storing the previous and next certificate authorities, once the rotation is completed.
+func (r *Rotation) Delete(ctx context.Context, ctrlclient client.Client, clusterName client.ObjectKey) error {
+	for _, certificate := range append(r.previous, r.next...) {
+		s := &corev1.Secret{}
+		s.Namespace = clusterName.Namespace
+		s.Name = Name(clusterName.Name, certificate.GetPurpose())
+
+		if err := ctrlclient.Delete(ctx, s); client.IgnoreNotFound(err) != nil {
+			return errors.Wrapf(err, "failed to delete secret %s", s.Name)
+		}
+	}
+
+	return nil
+}
+
+// Update updates the existing secrets of the certificates with their key pairs.
+func (c Certificates) Update(ctx context.Context, ctrlclient client.Client, clusterName client.ObjectKey) error {
+	for _, certificate := range c {
+		s, err := certificate.Lookup(ctx, ctrlclient, clusterName)
+		if err != nil {
+			return err
+		}
+
+		if s == nil {
+			return errors.Errorf("secret for certificate %s not found", certificate.GetPurpose())
+		}
+
+		keyPair := certificate.GetKeyPair()
+		if bytes.Equal(s.Data[TLSCrtDataName], keyPair.Cert) && bytes.Equal(s.Data[TLSKeyDataName], keyPair.Key) {
+			continue
+		}
+
+		s.Data[TLSCrtDataName] = keyPair.Cert
+		s.Data[TLSKeyDataName] = keyPair.Key
+
+		if err := ctrlclient.Update(ctx, s); err != nil {
+			return errors.Wrapf(err, "failed to update secret %s", s.Name)
+		}
+	}
+
+	return nil
+}
@@ -21,8 +21,9 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
-	"regexp"
+	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/version"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -32,6 +33,7 @@ import (
 
 	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
 	controlplanev1 "github.com/rancher/cluster-api-provider-rke2/controlplane/api/v1beta1"
+	"github.com/rancher/cluster-api-provider-rke2/pkg/rke2version"
 )
 
 const (
@@ -112,29 +114,50 @@ func TokenName(clusterName string) string {
 	return fmt.Sprintf("%s-token", clusterName)
 }
 
-// Rke2ToKubeVersion converts an RKE2 version to a Kubernetes version.
-func Rke2ToKubeVersion(rk2Version string) (kubeVersion string, err error) {
-	regexStr := "v(\\d\\.\\d{2}\\.\\d)\\+rke2r\\d"
+// TokenSecretKey returns the key of the secret holding the server token of the cluster of the RKE2ControlPlane: the
+// one referenced by its TokenSecretRef, or the one generated by the bootstrap provider otherwise.
+func TokenSecretKey(rcp *controlplanev1.RKE2ControlPlane, clusterName string) client.ObjectKey {
+	if rcp.Spec.TokenSecretRef != nil {
+		return client.ObjectKey{Namespace: rcp.Namespace, Name: rcp.Spec.TokenSecretRef.Name}
+	}
 
-	var regex *regexp.Regexp
+	return client.ObjectKey{Namespace: rcp.Namespace, Name: TokenName(clusterName)}
+}
 
-	regex, err = regexp.Compile(regexStr)
+// TokenFromSecret returns the server token stored in the value key of the given secret, or an error if the secret
+// doesn't hold a valid token.
+func TokenFromSecret(secret *corev1.Secret) (string, error) {
+	token := string(secret.Data["value"])
+
+	switch {
+	case token == "":
+		return "", fmt.Errorf("token secret %s/%s has no value", secret.Namespace, secret.Name)
+	case strings.ContainsAny(token, " \t\r\n"):
+		return "", fmt.Errorf("token of secret %s/%s must not contain whitespaces", secret.Namespace, secret.Name)
+	}
+
+	return token, nil
+}
+
+// Rke2ToKubeVersion converts an RKE2 version to a Kubernetes version. Other versions are returned unchanged.
+func Rke2ToKubeVersion(rk2Version string) (kubeVersion string, err error) {
+	if !IsRKE2Version(rk2Version) {
+		return rk2Version, nil
+	}
+
+	parsed, err := rke2version.ParseRKE2Version(rk2Version)
 	if err != nil {
 		return "", err
 	}
 
-	kubeVersion = string(regex.ReplaceAll([]byte(rk2Version), []byte("$1")))
-
-	return kubeVersion, nil
+	return parsed.Kubernetes.String(), nil
 }
 
 // IsRKE2Version checks if a string is an RKE2 version.
 func IsRKE2Version(rke2Version string) bool {
-	regexStr := "v(\\d\\.\\d{2}\\.\\d)\\+rke2r\\d"
-
-	regex, _ := regexp.Compile(regexStr)
+	_, err := rke2version.ParseRKE2Version(rke2Version)
 
-	return regex.MatchString(rke2Version)
+	return err == nil
 }
 
 // AppendIfNotPresent appends a string to a slice only if the value does not already exist.
@@ -179,13 +202,17 @@ func GetMapKeysAsString(m map[string][]byte) (keys string) {
 }
 
 // AtLeastv125 returns true if the RKE2 version is at least v1.25.0.
-func AtLeastv125(rke2version string) (bool, error) {
-	kubeVersion, err := Rke2ToKubeVersion(rke2version)
+func AtLeastv125(rke2Version string) (bool, error) {
+	kubeVersion, err := Rke2ToKubeVersion(rke2Version)
+	if err != nil {
+		return false, err
+	}
+
+	parsedVersion, err := version.ParseGeneric(kubeVersion)
 	if err != nil {
 		return false, err
 	}
 
-	parsedVersion := version.MustParseGeneric(kubeVersion)
 	if parsedVersion.AtLeast(version.MustParseGeneric(RKE2_CIS_VERSION_CHANGE)) {
 		return true, nil
 	}
@@ -3,6 +3,9 @@ package util
 import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	bootstrapv1 "github.com/rancher/cluster-api-provider-rke2/bootstrap/api/v1beta1"
 )
 
 var _ = Describe("Testing RKE2 to Kubernetes Version conversion", func() {
@@ -13,6 +16,30 @@ var _ = Describe("Testing RKE2 to Kubernetes Version conversion", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(cpKubeVersion).To(Equal(machineVersion))
 	})
+
+	It("Should return versions which are not RKE2 versions unchanged", func() {
+		for _, version := range []string{"v1.24.6", "1.24.6", "not-a-version"} {
+			kubeVersion, err := Rke2ToKubeVersion(version)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kubeVersion).To(Equal(version))
+		}
+	})
+})
+
+var _ = Describe("Testing AtLeastv125", func() {
+	It("Should compare RKE2 and Kubernetes versions to v1.25.0", func() {
+		Expect(AtLeastv125("v1.25.0+rke2r1")).To(BeTrue())
+		Expect(AtLeastv125("v1.24.6+rke2r1")).To(BeFalse())
+		Expect(AtLeastv125("v1.26.1")).To(BeTrue())
+	})
+
+	It("Should return an error for invalid versions", func() {
+		_, err := AtLeastv125("not-a-version")
+		Expect(err).To(HaveOccurred())
+
+		Expect(ProfileCompliant(bootstrapv1.CIS, "not-a-version")).To(BeFalse())
+		Expect(ProfileCompliant(bootstrapv1.CIS1_6, "not-a-version")).To(BeFalse())
+	})
 })
 
 var _ = Describe(("Testing GetMapKeysAsString"), func() {
@@ -27,6 +54,21 @@ var _ = Describe(("Testing GetMapKeysAsString"), func() {
 	})
 })
 
+var _ = Describe("Testing TokenFromSecret", func() {
+	It("Should return the token of the value key", func() {
+		secret := &corev1.Secret{Data: map[string][]byte{"value": []byte("K10abc::server:secret")}}
+		Expect(TokenFromSecret(secret)).To(Equal("K10abc::server:secret"))
+	})
+
+	It("Should reject secrets without a valid token", func() {
+		_, err := TokenFromSecret(&corev1.Secret{Data: map[string][]byte{"token": []byte("secret")}})
+		Expect(err).To(HaveOccurred())
+
+		_, err = TokenFromSecret(&corev1.Secret{Data: map[string][]byte{"value": []byte("secret\n")}})
+		Expect(err).To(HaveOccurred())
+	})
+})
+
 var _ = Describe("Testing IsRKE2Version", func() {
 	k8sVersion := "1.24.6"
 	rke2Version := "v1.24.6+rke2r1"
@@ -37,5 +79,11 @@ var _ = Describe("Testing IsRKE2Version", func() {
 
 	It("Should return false if string is not RKE2 version", func() {
 		Expect(IsRKE2Version(k8sVersion)).To(BeFalse())
+		Expect(IsRKE2Version("v1.24.6")).To(BeFalse())
+		Expect(IsRKE2Version("v1.24.6+k3s1")).To(BeFalse())
+	})
+
+	It("Should return true for RKE2 versions with multiple digits", func() {
+		Expect(IsRKE2Version("v1.30.10+rke2r12")).To(BeTrue())
 	})
 })